@@ -1,24 +1,40 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
 
+	"github.com/tflexsoom/duffle/internal/backend"
 	"github.com/tflexsoom/duffle/internal/command"
+	"github.com/tflexsoom/duffle/internal/discovery"
+	"github.com/tflexsoom/duffle/internal/optimize"
+	"github.com/tflexsoom/duffle/internal/scaffold"
+	"github.com/tflexsoom/duffle/internal/testrun"
+	"github.com/tflexsoom/duffle/internal/watch"
 	"github.com/urfave/cli/v2"
 )
 
 func main() {
+	runEmbeddedModule()
+
 	app := &cli.App{
 		Name:  "duffle",
 		Usage: "duffle tool suite for duffle projects and modules.",
 		Commands: []*cli.Command{
 			{
-				Name:   "compile",
-				Usage:  "compile a local duffle project",
+				Name:      "compile",
+				Usage:     "compile a local duffle project",
+				ArgsUsage: "[projects...], or the project of the duffle.ddat above the working directory",
+				Description: backend.EXECUTABLE_BACKEND + " output is not native code: it is the duffle interpreter binary " +
+					"with the encoded IR of the module appended, which it runs in place of its command line.",
 				Flags:  compileFlags,
-				Action: multiProjectCmd("compile", compileSubCmd),
+				Action: compileSubCmd,
 			},
 			{
 				Name:   "parse",
@@ -33,10 +49,58 @@ func main() {
 			// 	Action: multiProjectCmd("ir", irSubCmd),
 			// },
 			{
-				Name:   "typecheck",
-				Usage:  "typecheck a duffle project",
-				Flags:  baseFlags,
-				Action: multiProjectCmd("typecheck", typecheckSubCmd),
+				Name:      "run",
+				Usage:     "typecheck and interpret a duffle project",
+				ArgsUsage: "[project [program arguments...]], or the project of the duffle.ddat above the working directory",
+				Flags:     runFlags,
+				Action:    runSubCmd,
+			},
+			{
+				Name:      "typecheck",
+				Usage:     "typecheck a duffle project",
+				ArgsUsage: "[projects...], or the project of the duffle.ddat above the working directory",
+				Flags:     baseFlags,
+				Action:    typecheckSubCmd,
+			},
+			{
+				Name:      "watch",
+				Usage:     "rerun a pipeline whenever the files of a duffle project change",
+				ArgsUsage: "<project> -- <parse|typecheck|compile|run> [options...] [-- program arguments...]",
+				Flags:     watchFlags,
+				Action:    multiProjectCmd("watch", watchSubCmd),
+			},
+			{
+				Name:      "init",
+				Usage:     "create a new duffle project from a template",
+				ArgsUsage: "<name>",
+				Flags:     initFlags,
+				Action:    multiProjectCmd("init", initSubCmd),
+			},
+			{
+				Name:      "test",
+				Usage:     "run the @test functions and golden files of duffle projects",
+				ArgsUsage: "<projects...>",
+				Flags:     testFlags,
+				Action:    multiProjectCmd("test", testSubCmd),
+			},
+			{
+				Name:      "repl",
+				Usage:     "evaluate duffle definitions and expressions interactively",
+				ArgsUsage: "[projects to load...]",
+				Flags:     replFlags,
+				Action:    replSubCmd,
+			},
+			{
+				Name:   "get",
+				Usage:  "fetch the dependencies of a duffle project and lock them",
+				Flags:  packageFlags,
+				Action: getSubCmd,
+			},
+			{
+				Name:   "vendor",
+				Usage:  "copy the locked dependencies of a duffle project into its vendor directory",
+				Flags:  packageFlags,
+				Action: vendorSubCmd,
 			},
 		},
 	}
@@ -46,11 +110,41 @@ func main() {
 	}
 }
 
+var noCacheFlag = &cli.BoolFlag{
+	Name:  "no-cache",
+	Usage: "Rebuild every file without reading or writing the results in .duffle/cache",
+	Value: false,
+}
+
+var jobsFlag = &cli.IntFlag{
+	Name:    "jobs",
+	Aliases: []string{"j"},
+	Usage:   "Number of files to process at once, every CPU when 0",
+	Value:   0,
+}
+
+var includeFlag = &cli.StringSliceFlag{
+	Name:  "include",
+	Usage: "Only build the files matching a glob, relative to the project root",
+}
+
+var excludeFlag = &cli.StringSliceFlag{
+	Name:  "exclude",
+	Usage: "Leave out the files and directories matching a glob, like a .duffleignore line",
+}
+
+func discoveryFilter(cCtx *cli.Context) discovery.Filter {
+	return discovery.Filter{
+		Include: cCtx.StringSlice("include"),
+		Exclude: cCtx.StringSlice("exclude"),
+	}
+}
+
 var baseFlags = []cli.Flag{
 	&cli.PathFlag{
 		Name:    "output",
 		Aliases: []string{"o"},
-		Usage:   "Output file pathname for the backend output",
+		Usage:   "Output file pathname for the backend output, or - for standard output",
 		Value:   "./a.out",
 	},
 	&cli.BoolFlag{
@@ -59,6 +153,10 @@ var baseFlags = []cli.Flag{
 		Usage:   "Print out debug information while performing work",
 		Value:   false,
 	},
+	noCacheFlag,
+	jobsFlag,
+	includeFlag,
+	excludeFlag,
 }
 
 func multiProjectCmd(
@@ -93,6 +191,9 @@ func parseSubCmd(cCtx *cli.Context) error {
 		OutputLocation:   cCtx.Path("output"),
 		FunctionOnly:     cCtx.Bool("function"),
 		DataOnly:         cCtx.Bool("data"),
+		Filter:           discoveryFilter(cCtx),
+		NoCache:          cCtx.Bool("no-cache"),
+		Jobs:             cCtx.Int("jobs"),
 		Verbose:          cCtx.Bool("verbose"),
 	})
 }
@@ -111,26 +212,386 @@ func typecheckSubCmd(cCtx *cli.Context) error {
 	return command.TypeCheckOnly(command.TypeCheckOptions{
 		ProjectLocations: cCtx.Args().Slice(),
 		OutputLocation:   cCtx.Path("output"),
+		Filter:           discoveryFilter(cCtx),
+		NoCache:          cCtx.Bool("no-cache"),
+		Jobs:             cCtx.Int("jobs"),
 		Verbose:          cCtx.Bool("verbose"),
 	})
 }
 
-var compileFlags = append(parseFlags,
-	&cli.StringFlag{
+var optimizeFlags = []cli.Flag{
+	&cli.BoolFlag{
+		Name:  "O0",
+		Usage: "Run the module as emitted, without optimizing it",
+	},
+	&cli.BoolFlag{
+		Name:  "O1",
+		Usage: "Propagate constants, share common subexpressions and drop dead code (default)",
+	},
+	&cli.BoolFlag{
+		Name:  "O2",
+		Usage: "Also inline small pure functions before the -O1 passes",
+	},
+	&cli.StringSliceFlag{
+		Name:  "print-after",
+		Usage: "Print the module to standard error after the named pass, one of " + strings.Join(optimize.PassNames(), ", "),
+	},
+}
+
+func levelsGiven(cCtx *cli.Context) []optimize.Level {
+	given := []optimize.Level{}
+	for _, level := range []optimize.Level{optimize.LEVEL_O0, optimize.LEVEL_O1, optimize.LEVEL_O2} {
+		if cCtx.Bool(fmt.Sprintf("O%d", level)) {
+			given = append(given, level)
+		}
+	}
+
+	return given
+}
+
+// optimizeOptions reads the optimization level flags, of which at most one
+// may be given
+func optimizeOptions(cCtx *cli.Context) (optimize.Options, error) {
+	options := optimize.Options{
+		Level:      optimize.DEFAULT_LEVEL,
+		PrintAfter: cCtx.StringSlice("print-after"),
+		Output:     os.Stderr,
+	}
+
+	given := levelsGiven(cCtx)
+	for _, level := range given {
+		options.Level = level
+	}
+
+	if len(given) > 1 {
+		return options, errors.New("only one of -O0, -O1 and -O2 may be given")
+	}
+
+	return options, nil
+}
+
+var compileFlags = append(append(parseFlags,
+	&cli.StringSliceFlag{
 		Name:        "backend",
 		Aliases:     []string{"B"},
-		Usage:       "Backend tool for the output format",
-		DefaultText: "binary_x86_64_exe",
+		Usage:       "Backend tools for the output formats, one of " + strings.Join(backend.Names(), ", ") + ", given several times to build several",
+		DefaultText: backend.DEFAULT_BACKEND,
 	},
-)
+	&cli.PathFlag{
+		Name:  "out-dir",
+		Usage: "Directory each backend writes into a directory of its own, with a build.json summary",
+	},
+), optimizeFlags...)
 
 func compileSubCmd(cCtx *cli.Context) error {
+	optimization, err := optimizeOptions(cCtx)
+	if err != nil {
+		return err
+	}
+
+	// A manifest sets the output and level of its backends, so only the
+	// ones given on the command line are passed on
+	outputLocation := ""
+	if cCtx.IsSet("output") {
+		outputLocation = cCtx.Path("output")
+	}
+
 	return command.Compile(command.CompilerOptions{
+		ProjectLocations:    cCtx.Args().Slice(),
+		OutputLocation:      outputLocation,
+		FunctionOnly:        cCtx.Bool("function"),
+		DataOnly:            cCtx.Bool("data"),
+		Backends:            cCtx.StringSlice("backend"),
+		OutputDirectory:     cCtx.Path("out-dir"),
+		Optimization:        optimization,
+		DefaultOptimization: len(levelsGiven(cCtx)) == 0,
+		Filter:              discoveryFilter(cCtx),
+		NoCache:             cCtx.Bool("no-cache"),
+		Jobs:                cCtx.Int("jobs"),
+		Verbose:             cCtx.Bool("verbose"),
+	})
+}
+
+var runFlags = append([]cli.Flag{
+	&cli.BoolFlag{
+		Name:  "gc-trace",
+		Usage: "Print garbage collector statistics to standard error",
+		Value: false,
+	},
+	&cli.BoolFlag{
+		Name:  "gc-stress",
+		Usage: "Collect garbage on every allocation to find missing roots",
+		Value: false,
+	},
+	&cli.BoolFlag{
+		Name:    "verbose",
+		Aliases: []string{"v"},
+		Usage:   "Print out debug information while performing work",
+		Value:   false,
+	},
+	noCacheFlag,
+	jobsFlag,
+	includeFlag,
+	excludeFlag,
+}, optimizeFlags...)
+
+func runSubCmd(cCtx *cli.Context) error {
+	optimization, err := optimizeOptions(cCtx)
+	if err != nil {
+		return err
+	}
+
+	projectLocations := []string{}
+	if cCtx.Args().Present() {
+		projectLocations = append(projectLocations, cCtx.Args().First())
+	}
+
+	options := command.RunOptions{
+		ProjectLocations: projectLocations,
+		Args:             cCtx.Args().Tail(),
+		GCStress:         cCtx.Bool("gc-stress"),
+		Optimization:     optimization,
+		Filter:           discoveryFilter(cCtx),
+		NoCache:          cCtx.Bool("no-cache"),
+		Jobs:             cCtx.Int("jobs"),
+		Verbose:          cCtx.Bool("verbose"),
+	}
+	if cCtx.Bool("gc-trace") {
+		options.GCTrace = os.Stderr
+	}
+
+	code, err := command.Run(options)
+	if err != nil {
+		return err
+	}
+
+	exitRun(code)
+	return nil
+}
+
+// exitRun ends duffle with the exit code of the program it ran. Watching
+// keeps duffle alive between runs, so it only reports the code instead.
+var exitRun = os.Exit
+
+var watchFlags = []cli.Flag{
+	&cli.DurationFlag{
+		Name:  "interval",
+		Usage: "How often the project files are polled for changes",
+		Value: watch.DEFAULT_INTERVAL,
+	},
+	&cli.DurationFlag{
+		Name:  "debounce",
+		Usage: "How long the files must stay unchanged before rerunning",
+		Value: watch.DEFAULT_DEBOUNCE,
+	},
+}
+
+var watchablePipelines = map[string]bool{
+	"parse":     true,
+	"typecheck": true,
+	"compile":   true,
+	"run":       true,
+}
+
+// watchSubCmd reruns another subcommand of duffle on the project. Options
+// of the pipeline come after its name, and for run, the arguments of the
+// program come after a second --.
+func watchSubCmd(cCtx *cli.Context) error {
+	project, pipeline := cCtx.Args().First(), cCtx.Args().Tail()
+	if len(pipeline) > 0 && pipeline[0] == "--" {
+		pipeline = pipeline[1:]
+	}
+
+	if len(pipeline) == 0 {
+		pipeline = []string{"typecheck"}
+	} else if !watchablePipelines[pipeline[0]] {
+		return fmt.Errorf("cannot watch %v, expected one of parse, typecheck, compile or run", pipeline[0])
+	}
+
+	args := []string{cCtx.App.Name, pipeline[0]}
+	programArgs := []string{}
+	for index, arg := range pipeline[1:] {
+		if arg == "--" {
+			programArgs = pipeline[index+2:]
+			break
+		}
+
+		args = append(args, arg)
+	}
+
+	args = append(append(args, project), programArgs...)
+	exitRun = func(code int) {
+		log.Printf("program exited with code %d", code)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return command.Watch(ctx, command.WatchOptions{
+		ProjectLocations: []string{project},
+		Interval:         cCtx.Duration("interval"),
+		Debounce:         cCtx.Duration("debounce"),
+		Build: func() error {
+			return cCtx.App.Run(args)
+		},
+	})
+}
+
+// GC_ENVIRONMENT holds a comma separated list of trace and stress for
+// compiled programs, which have no flags of their own
+const GC_ENVIRONMENT = "DUFFLE_GC"
+
+// runEmbeddedModule runs the program of an executable built by the
+// binary_x86_64_exe backend, which is the duffle interpreter with the encoded
+// IR of a module appended
+func runEmbeddedModule() {
+	module, isEmbedded, err := backend.Embedded()
+	if err != nil {
+		log.Fatal(err)
+	} else if !isEmbedded {
+		return
+	}
+
+	options := command.RunOptions{Args: os.Args[1:]}
+	for _, setting := range strings.Split(os.Getenv(GC_ENVIRONMENT), ",") {
+		switch strings.TrimSpace(setting) {
+		case "trace":
+			options.GCTrace = os.Stderr
+		case "stress":
+			options.GCStress = true
+		}
+	}
+
+	code, err := command.RunModule(module, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(code)
+}
+
+var initFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "template",
+		Usage: "Kind of project to create, one of " + strings.Join(scaffold.Templates(), ", "),
+		Value: scaffold.DEFAULT_TEMPLATE,
+	},
+	&cli.BoolFlag{
+		Name:  "force",
+		Usage: "Overwrite files that already exist",
+	},
+}
+
+func initSubCmd(cCtx *cli.Context) error {
+	return command.Init(command.InitOptions{
+		ProjectLocation: cCtx.Args().First(),
+		Template:        cCtx.String("template"),
+		Force:           cCtx.Bool("force"),
+	})
+}
+
+var testFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "run",
+		Usage: "Only run the tests whose names match the regular expression",
+	},
+	&cli.StringFlag{
+		Name:  "format",
+		Usage: "Report format, one of " + strings.Join(testrun.Formats(), ", "),
+		Value: testrun.FORMAT_TEXT,
+	},
+	&cli.PathFlag{
+		Name:  "report",
+		Usage: "File the report is written to instead of standard output",
+	},
+	&cli.BoolFlag{
+		Name:  "update",
+		Usage: "Rewrite golden files with the output of the entry point",
+	},
+	&cli.BoolFlag{
+		Name:    "verbose",
+		Aliases: []string{"v"},
+		Usage:   "Print out debug information while performing work",
+		Value:   false,
+	},
+	noCacheFlag,
+	jobsFlag,
+	includeFlag,
+	excludeFlag,
+}
+
+func testSubCmd(cCtx *cli.Context) error {
+	return command.Test(command.TestOptions{
 		ProjectLocations: cCtx.Args().Slice(),
-		OutputLocation:   cCtx.Path("output"),
-		FunctionOnly:     cCtx.Bool("function"),
-		DataOnly:         cCtx.Bool("data"),
-		Backend:          cCtx.String("backend"),
+		Run:              cCtx.String("run"),
+		Format:           cCtx.String("format"),
+		ReportLocation:   cCtx.Path("report"),
+		Update:           cCtx.Bool("update"),
+		Filter:           discoveryFilter(cCtx),
+		NoCache:          cCtx.Bool("no-cache"),
+		Jobs:             cCtx.Int("jobs"),
 		Verbose:          cCtx.Bool("verbose"),
 	})
 }
+
+var replFlags = []cli.Flag{
+	&cli.PathFlag{
+		Name:  "history",
+		Usage: "File every entry is appended to, ~/.duffle_history by default",
+	},
+	&cli.BoolFlag{
+		Name:    "verbose",
+		Aliases: []string{"v"},
+		Usage:   "Print out debug information while performing work",
+		Value:   false,
+	},
+}
+
+func replSubCmd(cCtx *cli.Context) error {
+	historyFile := cCtx.Path("history")
+	if home, err := os.UserHomeDir(); historyFile == "" && err == nil {
+		historyFile = filepath.Join(home, ".duffle_history")
+	}
+
+	return command.Repl(command.ReplOptions{
+		ProjectLocations: cCtx.Args().Slice(),
+		Input:            os.Stdin,
+		Output:           os.Stdout,
+		HistoryFile:      historyFile,
+		Verbose:          cCtx.Bool("verbose"),
+	})
+}
+
+var packageFlags = []cli.Flag{
+	&cli.PathFlag{
+		Name:  "cache",
+		Usage: "Directory fetched dependencies are cached in",
+	},
+	&cli.BoolFlag{
+		Name:    "verbose",
+		Aliases: []string{"v"},
+		Usage:   "Print out debug information while performing work",
+		Value:   false,
+	},
+}
+
+func packageOptions(cCtx *cli.Context) command.PackageOptions {
+	projectLocation := "."
+	if cCtx.Args().Len() > 0 {
+		projectLocation = cCtx.Args().First()
+	}
+
+	return command.PackageOptions{
+		ProjectLocation: projectLocation,
+		CacheLocation:   cCtx.Path("cache"),
+		Verbose:         cCtx.Bool("verbose"),
+	}
+}
+
+func getSubCmd(cCtx *cli.Context) error {
+	return command.Get(packageOptions(cCtx))
+}
+
+func vendorSubCmd(cCtx *cli.Context) error {
+	return command.Vendor(packageOptions(cCtx))
+}
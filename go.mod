@@ -2,10 +2,15 @@ module github.com/tflexsoom/duffle
 
 go 1.21
 
+require (
+	github.com/alecthomas/participle/v2 v2.1.4
+	github.com/alecthomas/repr v0.5.4
+	github.com/urfave/cli/v2 v2.27.0
+	golang.org/x/exp v0.0.0-20231226003508-02704c960a9b
+)
+
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
-	github.com/urfave/cli/v2 v2.27.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
-	golang.org/x/exp v0.0.0-20231226003508-02704c960a9b
 )
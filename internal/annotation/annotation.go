@@ -0,0 +1,126 @@
+package annotation
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+type Validator func(intermediate.Sentiment, *diagnostic.Diagnostics)
+
+// ProgramValidator runs once over every annotated sentiment of a program,
+// along with the entry point its manifest names, which may be empty
+type ProgramValidator func([]intermediate.Sentiment, string, *diagnostic.Diagnostics)
+
+type Annotation struct {
+	Name        string
+	Description string
+	Validate    Validator
+	Program     ProgramValidator
+}
+
+type Registry struct {
+	annotations map[string]Annotation
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		annotations: make(map[string]Annotation),
+	}
+}
+
+func (registry *Registry) Register(annotation Annotation) error {
+	if _, exists := registry.annotations[annotation.Name]; exists {
+		return fmt.Errorf("annotation @%v is already registered", annotation.Name)
+	}
+
+	registry.annotations[annotation.Name] = annotation
+	return nil
+}
+
+func (registry *Registry) Lookup(name string) (Annotation, bool) {
+	annotation, isOk := registry.annotations[name]
+	return annotation, isOk
+}
+
+func (registry *Registry) Names() []string {
+	names := make([]string, 0, len(registry.annotations))
+	for name := range registry.annotations {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (registry *Registry) Validate(sentiments []intermediate.Sentiment) diagnostic.Diagnostics {
+	diagnostics := diagnostic.Diagnostics{}
+	for _, sentiment := range sentiments {
+		for _, name := range sentiment.Annotations {
+			annotation, isOk := registry.annotations[name]
+			if !isOk {
+				diagnostics.Errorf(sentiment.Position, "unknown annotation @%v on %v", name, sentiment.Name)
+			} else if annotation.Validate != nil {
+				annotation.Validate(sentiment, &diagnostics)
+			}
+		}
+	}
+
+	return diagnostics
+}
+
+func (registry *Registry) ValidateProgram(sentiments []intermediate.Sentiment, entry string) diagnostic.Diagnostics {
+	diagnostics := diagnostic.Diagnostics{}
+	byAnnotation := make(map[string][]intermediate.Sentiment)
+	for _, sentiment := range sentiments {
+		for _, name := range sentiment.Annotations {
+			byAnnotation[name] = append(byAnnotation[name], sentiment)
+		}
+	}
+
+	for _, name := range registry.Names() {
+		annotation := registry.annotations[name]
+		if annotation.Program != nil {
+			annotation.Program(byAnnotation[name], entry, &diagnostics)
+		}
+	}
+
+	return diagnostics
+}
+
+var defaultRegistry = NewRegistry()
+
+func Register(annotation Annotation) error {
+	return defaultRegistry.Register(annotation)
+}
+
+func Lookup(name string) (Annotation, bool) {
+	return defaultRegistry.Lookup(name)
+}
+
+func Validate(sentiments []intermediate.Sentiment) diagnostic.Diagnostics {
+	return defaultRegistry.Validate(sentiments)
+}
+
+func ValidateProgram(sentiments []intermediate.Sentiment, entry string) diagnostic.Diagnostics {
+	return defaultRegistry.ValidateProgram(sentiments, entry)
+}
+
+func SentimentsOf(goals ...intermediate.Goal) []intermediate.Sentiment {
+	sentiments := make([]intermediate.Sentiment, 0, 16)
+	for _, goal := range goals {
+		names := make([]string, 0, len(goal.Sentments))
+		for name := range goal.Sentments {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			sentiments = append(sentiments, goal.Sentments[name])
+		}
+	}
+
+	return sentiments
+}
@@ -0,0 +1,187 @@
+package annotation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func sentimentOf(annotation string, name string, ops ...intermediate.OpCode) intermediate.Sentiment {
+	sentiment := intermediate.Sentiment{
+		Position:    lexer.Position{Filename: "test.dfl", Line: 1},
+		Annotations: []string{annotation},
+		Name:        name,
+	}
+
+	if len(ops) == 0 {
+		return sentiment
+	}
+
+	sentiment.Definition = intermediate.NewExpressionTree(intermediate.SenimentExpression{Op: ops[0]})
+	for _, op := range ops[1:] {
+		container.AddChildren(sentiment.Definition, intermediate.NewExpressionTree(intermediate.SenimentExpression{Op: op}))
+	}
+
+	return sentiment
+}
+
+func expectMessages(t *testing.T, diagnostics diagnostic.Diagnostics, fragments ...string) {
+	t.Helper()
+	if len(diagnostics) != len(fragments) {
+		t.Fatalf("expected %d diagnostics got:\n%v", len(fragments), diagnostics)
+	}
+
+	for i, fragment := range fragments {
+		if !strings.Contains(diagnostics[i].Message, fragment) {
+			t.Errorf("expected %q in %q", fragment, diagnostics[i].Message)
+		}
+	}
+}
+
+func TestBuiltinAnnotations(t *testing.T) {
+	diagnostics := Validate([]intermediate.Sentiment{
+		sentimentOf(IMPORT_ANNOTATION, "sysout", intermediate.OPCODE_CONSTEXPR, intermediate.OPCODE_USE),
+		sentimentOf(FACT_ANNOTATION, "ONE", intermediate.OPCODE_CONSTEXPR, intermediate.OPCODE_CONST),
+		sentimentOf(EXEC_ANNOTATION, "main", intermediate.OPCODE_BLOCK),
+		sentimentOf(FUNCTION_ANNOTATION, "helper", intermediate.OPCODE_BLOCK),
+	})
+
+	expectMessages(t, diagnostics)
+}
+
+func TestInvalidAnnotations(t *testing.T) {
+	sentiments := []intermediate.Sentiment{
+		sentimentOf("route", "home", intermediate.OPCODE_BLOCK),
+		sentimentOf(FACT_ANNOTATION, "LOOP", intermediate.OPCODE_BLOCK),
+		sentimentOf(FACT_ANNOTATION, "OUT", intermediate.OPCODE_CONSTEXPR, intermediate.OPCODE_USE),
+		sentimentOf(IMPORT_ANNOTATION, "loop", intermediate.OPCODE_CONSTEXPR, intermediate.OPCODE_CONST),
+		sentimentOf(EXEC_ANNOTATION, "main", intermediate.OPCODE_BLOCK),
+		sentimentOf(EXEC_ANNOTATION, "other", intermediate.OPCODE_BLOCK),
+	}
+
+	diagnostics := Validate(sentiments)
+	diagnostics.Extend(ValidateProgram(sentiments, ""))
+
+	expectMessages(t, diagnostics,
+		"unknown annotation @route",
+		"must be defined with :=",
+		"cannot bind a use expression",
+		"must bind a use expression",
+		"second entry point",
+	)
+
+	if len(diagnostics[4].Related) != 1 {
+		t.Errorf("expected the first entry point as related information")
+	}
+}
+
+func TestManifestEntryChoosesAnExec(t *testing.T) {
+	sentiments := []intermediate.Sentiment{
+		sentimentOf(EXEC_ANNOTATION, "main", intermediate.OPCODE_BLOCK),
+		sentimentOf(FUNCTION_ANNOTATION, "helper", intermediate.OPCODE_BLOCK),
+		sentimentOf(EXEC_ANNOTATION, "other", intermediate.OPCODE_BLOCK),
+	}
+
+	expectMessages(t, ValidateProgram(sentiments, "other"))
+	expectMessages(t, ValidateProgram(sentiments, "helper"), "entry point helper is not an @exec function")
+}
+
+func TestEntryPoint(t *testing.T) {
+	sentiments := []intermediate.Sentiment{
+		sentimentOf(EXEC_ANNOTATION, "main", intermediate.OPCODE_BLOCK),
+		sentimentOf(FUNCTION_ANNOTATION, "helper", intermediate.OPCODE_BLOCK),
+		sentimentOf(EXEC_ANNOTATION, "other", intermediate.OPCODE_BLOCK),
+	}
+
+	diagnostics := diagnostic.Diagnostics{}
+	if entry := EntryPoint(sentiments, "other", &diagnostics); entry != "other" {
+		t.Errorf("expected the named entry point other, got %q", entry)
+	}
+	expectMessages(t, diagnostics)
+
+	EntryPoint(sentiments, "helper", &diagnostics)
+	expectMessages(t, diagnostics, "entry point helper is not an @exec function")
+
+	diagnostics = diagnostic.Diagnostics{}
+	if entry := EntryPoint(sentiments, "", &diagnostics); entry != "main" {
+		t.Errorf("expected the first entry point main, got %q", entry)
+	}
+	expectMessages(t, diagnostics)
+
+	diagnostics = diagnostic.Diagnostics{}
+	EntryPoint(sentiments[1:2], "", &diagnostics)
+	expectMessages(t, diagnostics, "no @exec entry point was found")
+}
+
+func TestRegisterBackendAnnotation(t *testing.T) {
+	registry := NewRegistry()
+	err := registry.Register(Annotation{
+		Name: "route",
+		Validate: func(sentiment intermediate.Sentiment, diagnostics *diagnostic.Diagnostics) {
+			if len(sentiment.Inputs) == 0 {
+				diagnostics.Errorf(sentiment.Position, "@route %v needs a request input", sentiment.Name)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Register(Annotation{Name: "route"}); err == nil {
+		t.Errorf("expected duplicate registration to fail")
+	}
+
+	diagnostics := registry.Validate([]intermediate.Sentiment{
+		sentimentOf("route", "home", intermediate.OPCODE_BLOCK),
+	})
+
+	expectMessages(t, diagnostics, "needs a request input")
+}
+
+func node(op intermediate.OpCode, value []string, children ...expressionTree) expressionTree {
+	tree := intermediate.NewExpressionTree(intermediate.SenimentExpression{Op: op, Value: value})
+	for _, child := range children {
+		container.AddChildren(tree, child)
+	}
+
+	return tree
+}
+
+func selfCall(name string) expressionTree {
+	return node(intermediate.OPCODE_CALL, nil, node(intermediate.OPCODE_REF, []string{name}), node(intermediate.OPCODE_REF, []string{"n"}))
+}
+
+func TestTailrec(t *testing.T) {
+	returned := sentimentOf(TAILREC_ANNOTATION, "loop")
+	returned.Definition = node(intermediate.OPCODE_BLOCK, nil,
+		node(intermediate.OPCODE_IF, nil, selfCall("done"), node(intermediate.OPCODE_RETURN, nil, selfCall("loop"))),
+		node(intermediate.OPCODE_RETURN, nil, selfCall("loop")),
+	)
+
+	nested := sentimentOf(TAILREC_ANNOTATION, "grow")
+	nested.Definition = node(intermediate.OPCODE_CONSTEXPR, nil,
+		node(intermediate.OPCODE_CHAIN, nil, selfCall("grow"), node(intermediate.OPCODE_OPERATOR, []string{"+"}), selfCall("grow")),
+	)
+
+	captured := sentimentOf(TAILREC_ANNOTATION, "later")
+	captured.Definition = node(intermediate.OPCODE_BLOCK, nil,
+		node(intermediate.OPCODE_RETURN, nil, node(intermediate.OPCODE_CAPTURE, nil,
+			node(intermediate.OPCODE_BLOCK, nil, node(intermediate.OPCODE_RETURN, nil, selfCall("later"))),
+		)),
+	)
+
+	straight := sentimentOf(TAILREC_ANNOTATION, "once")
+	straight.Definition = node(intermediate.OPCODE_CONSTEXPR, nil, selfCall("other"))
+
+	diagnostics := Validate([]intermediate.Sentiment{returned, nested, captured, straight})
+	expectMessages(t, diagnostics,
+		"call to grow is not in tail position",
+		"call to grow is not in tail position",
+		"call to later is not in tail position",
+		"@tailrec once never calls itself",
+	)
+}
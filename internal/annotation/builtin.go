@@ -0,0 +1,178 @@
+package annotation
+
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+const (
+	FUNCTION_ANNOTATION = "@"
+	FACT_ANNOTATION     = "fact"
+	THEORY_ANNOTATION   = "theory"
+	EXEC_ANNOTATION     = "exec"
+	IMPORT_ANNOTATION   = "import"
+)
+
+func init() {
+	for _, annotation := range []Annotation{
+		{
+			Name:        FUNCTION_ANNOTATION,
+			Description: "a plain function",
+		},
+		{
+			Name:        FACT_ANNOTATION,
+			Description: "a compile-time constant",
+			Validate:    validateConstant,
+		},
+		{
+			Name:        THEORY_ANNOTATION,
+			Description: "a compile-time constant that data files may override",
+			Validate:    validateConstant,
+		},
+		{
+			Name:        EXEC_ANNOTATION,
+			Description: "the entry point of an executable",
+			Validate:    validateExec,
+			Program:     validateSingleExec,
+		},
+		{
+			Name:        TAILREC_ANNOTATION,
+			Description: "a function whose calls to itself are all tail calls",
+			Validate:    validateTailrec,
+		},
+		{
+			Name:        TEST_ANNOTATION,
+			Description: "a function duffle test runs, which passes when it returns true",
+			Validate:    validateTest,
+		},
+		{
+			Name:        IMPORT_ANNOTATION,
+			Description: "binds a name to a module imported with use",
+			Validate:    validateImport,
+		},
+	} {
+		if err := Register(annotation); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func definitionOp(sentiment intermediate.Sentiment) intermediate.OpCode {
+	if sentiment.Definition == nil {
+		return intermediate.OPCODE_NOOP
+	}
+
+	return sentiment.Definition.GetValue().Op
+}
+
+// IsUse reports sentiments that only bind a use expression
+func IsUse(sentiment intermediate.Sentiment) bool {
+	if definitionOp(sentiment) != intermediate.OPCODE_CONSTEXPR {
+		return false
+	}
+
+	children := sentiment.Definition.GetChildren()
+	return len(children) == 1 && children[0].GetValue().Op == intermediate.OPCODE_USE
+}
+
+func validateConstant(sentiment intermediate.Sentiment, diagnostics *diagnostic.Diagnostics) {
+	if definitionOp(sentiment) != intermediate.OPCODE_CONSTEXPR {
+		diagnostics.Errorf(
+			sentiment.Position,
+			"@%v %v must be defined with := as a compile-time constant",
+			sentiment.Annotations[0],
+			sentiment.Name,
+		)
+	} else if IsUse(sentiment) {
+		diagnostics.Errorf(
+			sentiment.Position,
+			"@%v %v cannot bind a use expression, use @%v instead",
+			sentiment.Annotations[0],
+			sentiment.Name,
+			IMPORT_ANNOTATION,
+		)
+	}
+
+	if len(sentiment.Inputs) > 0 {
+		diagnostics.Errorf(sentiment.Position, "@%v %v cannot take inputs", sentiment.Annotations[0], sentiment.Name)
+	}
+}
+
+func validateExec(sentiment intermediate.Sentiment, diagnostics *diagnostic.Diagnostics) {
+	op := definitionOp(sentiment)
+	if op == intermediate.OPCODE_NOOP || IsUse(sentiment) {
+		diagnostics.Errorf(sentiment.Position, "@%v %v must define a body to execute", EXEC_ANNOTATION, sentiment.Name)
+	}
+}
+
+// validateSingleExec makes sure an executable knows which @exec function it
+// runs from: the one its manifest names as the entry, or else the only one
+func validateSingleExec(sentiments []intermediate.Sentiment, entry string, diagnostics *diagnostic.Diagnostics) {
+	if entry != "" {
+		for _, sentiment := range sentiments {
+			if sentiment.Name == entry {
+				return
+			}
+		}
+
+		diagnostics.Errorf(lexer.Position{}, "entry point %v is not an @%v function", entry, EXEC_ANNOTATION)
+		return
+	}
+
+	if len(sentiments) <= 1 {
+		return
+	}
+
+	for _, duplicate := range sentiments[1:] {
+		diagnostics.Errorf(
+			duplicate.Position,
+			"@%v %v is a second entry point, name the one the executable runs from as its entry",
+			EXEC_ANNOTATION,
+			duplicate.Name,
+		).WithRelated(sentiments[0].Position, "first entry point %v is defined here", sentiments[0].Name)
+	}
+}
+
+func validateImport(sentiment intermediate.Sentiment, diagnostics *diagnostic.Diagnostics) {
+	if !IsUse(sentiment) {
+		diagnostics.Errorf(
+			sentiment.Position,
+			"@%v %v must bind a use expression such as use (dfl.sysout)",
+			IMPORT_ANNOTATION,
+			sentiment.Name,
+		)
+	}
+
+	if len(sentiment.Inputs) > 0 {
+		diagnostics.Errorf(sentiment.Position, "@%v %v cannot take inputs", IMPORT_ANNOTATION, sentiment.Name)
+	}
+}
+
+func HasAnnotation(sentiment intermediate.Sentiment, name string) bool {
+	for _, annotation := range sentiment.Annotations {
+		if annotation == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EntryPoint picks the @exec function a checked executable runs from, the
+// one named or else the first one, reporting when there is no such function
+func EntryPoint(sentiments []intermediate.Sentiment, name string, diagnostics *diagnostic.Diagnostics) string {
+	for _, sentiment := range sentiments {
+		if HasAnnotation(sentiment, EXEC_ANNOTATION) && (name == "" || sentiment.Name == name) {
+			return sentiment.Name
+		}
+	}
+
+	if name != "" {
+		diagnostics.Errorf(lexer.Position{}, "entry point %v is not an @%v function", name, EXEC_ANNOTATION)
+	} else {
+		diagnostics.Errorf(lexer.Position{}, "no @%v entry point was found for the executable", EXEC_ANNOTATION)
+	}
+
+	return ""
+}
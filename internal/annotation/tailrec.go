@@ -0,0 +1,108 @@
+package annotation
+
+import (
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+const TAILREC_ANNOTATION = "tailrec"
+
+type expressionTree = container.Tree[intermediate.SenimentExpression]
+
+// validateTailrec requires every call a function makes to itself to be in
+// tail position, where the emitter turns it into a tail call reusing the
+// caller's frame
+func validateTailrec(sentiment intermediate.Sentiment, diagnostics *diagnostic.Diagnostics) {
+	definition := sentiment.Definition
+	if definition == nil || IsUse(sentiment) {
+		diagnostics.Errorf(sentiment.Position, "@%v %v must define a body", TAILREC_ANNOTATION, sentiment.Name)
+		return
+	}
+
+	checker := tailCalls{name: sentiment.Name, diagnostics: diagnostics}
+	switch definition.GetValue().Op {
+	case intermediate.OPCODE_CONSTEXPR:
+		for _, child := range definition.GetChildren() {
+			checker.expression(child, true)
+		}
+	case intermediate.OPCODE_EVALS:
+		for _, clause := range definition.GetChildren() {
+			children := clause.GetChildren()
+			for index, child := range children {
+				checker.expression(child, index == len(children)-1)
+			}
+		}
+	default:
+		checker.statement(definition)
+	}
+
+	if checker.calls == 0 {
+		diagnostics.Warnf(sentiment.Position, "@%v %v never calls itself", TAILREC_ANNOTATION, sentiment.Name)
+	}
+}
+
+type tailCalls struct {
+	name        string
+	calls       int
+	captures    int
+	diagnostics *diagnostic.Diagnostics
+}
+
+// statement walks the statements of a block, where only returned values
+// are in tail position
+func (checker *tailCalls) statement(tree expressionTree) {
+	children := tree.GetChildren()
+	switch tree.GetValue().Op {
+	case intermediate.OPCODE_BLOCK:
+		for _, child := range children {
+			checker.statement(child)
+		}
+	case intermediate.OPCODE_IF:
+		checker.expression(children[0], false)
+		for _, branch := range children[1:] {
+			checker.statement(branch)
+		}
+	case intermediate.OPCODE_RETURN:
+		for _, child := range children {
+			checker.expression(child, true)
+		}
+	default:
+		checker.expression(tree, false)
+	}
+}
+
+func (checker *tailCalls) expression(tree expressionTree, isTail bool) {
+	expression := tree.GetValue()
+	children := tree.GetChildren()
+	if expression.Op == intermediate.OPCODE_CALL && checker.isSelf(children[0]) {
+		checker.calls++
+		if !isTail || checker.captures > 0 {
+			checker.diagnostics.Errorf(
+				expression.Position,
+				"call to %v is not in tail position, @%v needs every call to itself to be returned directly",
+				checker.name, TAILREC_ANNOTATION,
+			)
+		}
+
+		children = children[1:]
+	} else if expression.Op == intermediate.OPCODE_CAPTURE {
+		// Captures return from their own frame, never from the function's
+		checker.captures++
+		for _, child := range children {
+			checker.statement(child)
+		}
+
+		checker.captures--
+		return
+	}
+
+	for _, child := range children {
+		checker.expression(child, false)
+	}
+}
+
+func (checker *tailCalls) isSelf(tree expressionTree) bool {
+	expression := tree.GetValue()
+	return expression.Op == intermediate.OPCODE_REF && len(expression.Value) > 0 && expression.Value[0] == checker.name
+}
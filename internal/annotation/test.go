@@ -0,0 +1,24 @@
+package annotation
+
+import (
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+// TEST_ANNOTATION marks functions duffle test runs. A test takes no inputs
+// and passes when it returns true.
+const TEST_ANNOTATION = "test"
+
+func validateTest(sentiment intermediate.Sentiment, diagnostics *diagnostic.Diagnostics) {
+	if definitionOp(sentiment) == intermediate.OPCODE_NOOP || IsUse(sentiment) {
+		diagnostics.Errorf(sentiment.Position, "@%v %v must define a body", TEST_ANNOTATION, sentiment.Name)
+	}
+
+	if len(sentiment.Inputs) > 0 {
+		diagnostics.Errorf(sentiment.Position, "@%v %v cannot take inputs", TEST_ANNOTATION, sentiment.Name)
+	}
+
+	if sentiment.Result != intermediate.TYPEID_NO_TYPE && sentiment.Result != intermediate.TYPEID_BOOLEAN {
+		diagnostics.Errorf(sentiment.Position, "@%v %v must return boolean", TEST_ANNOTATION, sentiment.Name)
+	}
+}
@@ -0,0 +1,142 @@
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/tflexsoom/duffle/internal/packaging"
+)
+
+// A build writes every target into a directory of its own under the output
+// directory, and lists what it wrote in SUMMARY_FILE_NAME next to them.
+const SUMMARY_FILE_NAME = "build.json"
+
+// Target is one output of a build, written by a backend
+type Target struct {
+	Backend string
+	// Name is the file written in the directory of the backend
+	Name  string
+	Write func(io.Writer) error
+}
+
+type Artifact struct {
+	Backend string `json:"backend"`
+	// Path is relative to the output directory, and empty when the backend
+	// failed
+	Path  string `json:"path,omitempty"`
+	Size  int64  `json:"size,omitempty"`
+	Hash  string `json:"hash,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type Summary struct {
+	Module    string     `json:"module,omitempty"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// Failed counts the artifacts that could not be built
+func (summary Summary) Failed() int {
+	failed := 0
+	for _, artifact := range summary.Artifacts {
+		if artifact.Error != "" {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// Build writes each target into its own directory. Artifacts are only
+// replaced once their backend succeeds, so one that fails leaves the
+// artifacts of the others, and its own from an earlier build, as they were.
+// Targets are all checked before anything is written.
+func Build(directory string, moduleName string, targets []Target) (Summary, error) {
+	summary := Summary{Module: moduleName, Artifacts: make([]Artifact, 0, len(targets))}
+	if err := validateTargets(targets); err != nil {
+		return summary, err
+	}
+
+	for _, target := range targets {
+		summary.Artifacts = append(summary.Artifacts, build(directory, target))
+	}
+
+	return summary, writeSummary(directory, summary)
+}
+
+// validateTargets makes sure every target writes a file of its own, one
+// directory under the output directory
+func validateTargets(targets []Target) error {
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if seen[target.Backend] {
+			return fmt.Errorf("backend %v is given more than once", target.Backend)
+		}
+
+		seen[target.Backend] = true
+		for _, name := range []string{target.Backend, target.Name} {
+			if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+				return fmt.Errorf("backend %v cannot write to %q, which is not a plain file name", target.Backend, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// counter counts the bytes written through it
+type counter int64
+
+func (count *counter) Write(data []byte) (int, error) {
+	*count += counter(len(data))
+	return len(data), nil
+}
+
+func build(directory string, target Target) Artifact {
+	artifact := Artifact{Backend: target.Backend}
+	relative := filepath.Join(target.Backend, target.Name)
+	path := filepath.Join(directory, relative)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		artifact.Error = err.Error()
+		return artifact
+	}
+
+	hash := sha256.New()
+	size := counter(0)
+	err := WriteFile(path, func(writer io.Writer) error {
+		if err := target.Write(io.MultiWriter(writer, hash, &size)); err != nil {
+			return fmt.Errorf("backend %v failed: %w", target.Backend, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		artifact.Error = err.Error()
+		return artifact
+	}
+
+	artifact.Path = filepath.ToSlash(relative)
+	artifact.Size = int64(size)
+	artifact.Hash = packaging.HASH_PREFIX + hex.EncodeToString(hash.Sum(nil))
+	return artifact
+}
+
+func writeSummary(directory string, summary Summary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return err
+	}
+
+	return WriteFile(filepath.Join(directory, SUMMARY_FILE_NAME), func(writer io.Writer) error {
+		_, err := writer.Write(append(data, '\n'))
+		return err
+	})
+}
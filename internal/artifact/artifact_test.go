@@ -0,0 +1,88 @@
+package artifact
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writing(contents string) func(io.Writer) error {
+	return func(writer io.Writer) error {
+		_, err := io.WriteString(writer, contents)
+		return err
+	}
+}
+
+func TestBuildIsolatesFailures(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "build")
+	previous := filepath.Join(directory, "broken", "app")
+	os.MkdirAll(filepath.Dir(previous), 0755)
+	os.WriteFile(previous, []byte("last good build"), 0644)
+
+	summary, err := Build(directory, "app", []Target{
+		{Backend: "ir", Name: "app.ir", Write: writing("module")},
+		{Backend: "broken", Name: "app", Write: func(writer io.Writer) error {
+			io.WriteString(writer, "half")
+			return errors.New("out of space")
+		}},
+		{Backend: "docs", Name: "app.txt", Write: writing("")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Failed() != 1 || !strings.Contains(summary.Artifacts[1].Error, "backend broken failed: out of space") {
+		t.Errorf("expected only the broken backend to fail, got %+v", summary)
+	}
+
+	if ir := summary.Artifacts[0]; ir.Path != "ir/app.ir" || ir.Size != 6 ||
+		ir.Hash != "sha256-120970d812836f19888625587a4606a5ad23cef31c8684e601771552548fc6b9" {
+		t.Errorf("unexpected ir artifact %+v", ir)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(directory, "ir", "app.ir")); string(data) != "module" {
+		t.Errorf("expected the ir artifact to be written, got %q", data)
+	}
+
+	if data, _ := os.ReadFile(previous); string(data) != "last good build" {
+		t.Errorf("expected the failed backend to leave its last artifact, got %q", data)
+	}
+
+	data, err := os.ReadFile(filepath.Join(directory, SUMMARY_FILE_NAME))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written := Summary{}
+	if err := json.Unmarshal(data, &written); err != nil || written.Module != "app" || len(written.Artifacts) != 3 ||
+		written.Artifacts[2].Path != "docs/app.txt" || written.Artifacts[0].Hash != summary.Artifacts[0].Hash {
+		t.Errorf("unexpected summary %+v %v", written, err)
+	}
+}
+
+func TestBuildRejectsRepeatedBackends(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "build")
+	_, err := Build(directory, "", []Target{
+		{Backend: "ir", Name: "a", Write: writing("")},
+		{Backend: "ir", Name: "b", Write: writing("")},
+	})
+	if err == nil {
+		t.Errorf("expected a repeated backend to fail")
+	}
+
+	_, err = Build(directory, "", []Target{
+		{Backend: "ir", Name: "a", Write: writing("")},
+		{Backend: "docs", Name: filepath.Join("..", "escaped"), Write: writing("")},
+	})
+	if err == nil {
+		t.Errorf("expected an artifact outside of its directory to fail")
+	}
+
+	if _, err := os.Stat(directory); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written by a build that was rejected, got %v", err)
+	}
+}
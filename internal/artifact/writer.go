@@ -0,0 +1,113 @@
+package artifact
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// STDOUT as an output path writes to standard output instead of a file
+const STDOUT = "-"
+
+// Files starting with elfMagic are executables, everything else is data
+var elfMagic = []byte("\x7fELF")
+
+const (
+	executableMode os.FileMode = 0755
+	dataMode       os.FileMode = 0644
+)
+
+var stdout io.Writer = os.Stdout
+
+// Writer writes a file next to where it belongs, then moves it there in one
+// step when committed. Readers and concurrent builds of the same path see
+// the old file or the new one, never part of either.
+type Writer struct {
+	file   *os.File
+	path   string
+	header []byte
+	done   bool
+}
+
+func Create(path string) (*Writer, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{file: file, path: path}, nil
+}
+
+func (writer *Writer) Write(data []byte) (int, error) {
+	if missing := len(elfMagic) - len(writer.header); missing > 0 {
+		writer.header = append(writer.header, data[:min(missing, len(data))]...)
+	}
+
+	return writer.file.Write(data)
+}
+
+// Commit syncs the file to disk and moves it to its path, executable when
+// it is an ELF binary
+func (writer *Writer) Commit() error {
+	if writer.done {
+		return nil
+	}
+
+	mode := dataMode
+	if bytes.Equal(writer.header, elfMagic) {
+		mode = executableMode
+	}
+
+	err := writer.file.Chmod(mode)
+	if err == nil {
+		err = writer.file.Sync()
+	}
+
+	if closeErr := writer.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(writer.file.Name(), writer.path)
+	}
+
+	writer.done = true
+	if err != nil {
+		os.Remove(writer.file.Name())
+	}
+
+	return err
+}
+
+// Abort drops what was written, leaving the path as it was. It does nothing
+// after a commit, so it can be deferred.
+func (writer *Writer) Abort() error {
+	if writer.done {
+		return nil
+	}
+
+	writer.done = true
+	writer.file.Close()
+	return os.Remove(writer.file.Name())
+}
+
+// WriteFile writes a file through a Writer, committing it only when write
+// succeeds, or to standard output for STDOUT
+func WriteFile(path string, write func(io.Writer) error) error {
+	if path == STDOUT {
+		return write(stdout)
+	}
+
+	writer, err := Create(path)
+	if err != nil {
+		return err
+	}
+	defer writer.Abort()
+
+	if err := write(writer); err != nil {
+		return err
+	}
+
+	return writer.Commit()
+}
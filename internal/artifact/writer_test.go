@@ -0,0 +1,132 @@
+package artifact
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func leftovers(t *testing.T, directory string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(directory, ".*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return matches
+}
+
+func TestWriteFileReplacesAtomically(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "a.out")
+	if err := WriteFile(path, writing("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := WriteFile(path, func(writer io.Writer) error {
+		io.WriteString(writer, "half of the second")
+		return errors.New("backend failed")
+	})
+	if err == nil || err.Error() != "backend failed" {
+		t.Errorf("expected the failure of the write, got %v", err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "first" {
+		t.Errorf("expected a failed write to keep the old file, got %q", data)
+	}
+
+	if err := WriteFile(path, writing("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "second" {
+		t.Errorf("expected the file to be replaced, got %q", data)
+	}
+
+	if files := leftovers(t, directory); len(files) != 0 {
+		t.Errorf("expected no temporary files to be left, got %v", files)
+	}
+}
+
+func TestWriteFileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows has no executable bit")
+	}
+
+	directory := t.TempDir()
+	for name, contents := range map[string]string{
+		"program":    "\x7fELF\x02\x01\x01",
+		"module.ir":  "function main",
+		"short":      "\x7fE",
+		"empty.file": "",
+	} {
+		path := filepath.Join(directory, name)
+		if err := WriteFile(path, writing(contents)); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := dataMode
+		if name == "program" {
+			expected = executableMode
+		}
+
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != expected {
+			t.Errorf("expected %v to have mode %v, got %v %v", name, expected, info.Mode().Perm(), err)
+		}
+	}
+}
+
+func TestWriteFileStdout(t *testing.T) {
+	output := bytes.Buffer{}
+	stdout = &output
+	defer func() { stdout = os.Stdout }()
+
+	if err := WriteFile(STDOUT, writing("to the terminal")); err != nil {
+		t.Fatal(err)
+	}
+
+	if output.String() != "to the terminal" {
+		t.Errorf("expected the file on standard output, got %q", output.String())
+	}
+
+	if _, err := os.Stat(STDOUT); !os.IsNotExist(err) {
+		t.Errorf("expected no file named %v to be written", STDOUT)
+	}
+}
+
+func TestWriteFileConcurrently(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "a.out")
+	group := sync.WaitGroup{}
+	for build := 0; build < 8; build++ {
+		group.Add(1)
+		go func(build int) {
+			defer group.Done()
+			contents := strings.Repeat(fmt.Sprint(build), 1<<16)
+			if err := WriteFile(path, writing(contents)); err != nil {
+				t.Error(err)
+			}
+		}(build)
+	}
+
+	group.Wait()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 1<<16 || strings.Count(string(data), string(data[:1])) != len(data) {
+		t.Errorf("expected the output of a single build, got a mix of %d bytes", len(data))
+	}
+
+	if files := leftovers(t, directory); len(files) != 0 {
+		t.Errorf("expected no temporary files to be left, got %v", files)
+	}
+}
@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+const (
+	IR_BACKEND         = "ir"
+	EXECUTABLE_BACKEND = "binary_x86_64_exe"
+	DEFAULT_BACKEND    = EXECUTABLE_BACKEND
+)
+
+// Backend writes a module in its output format
+type Backend func(module intermediate.Module, writer io.Writer) error
+
+var backends = map[string]Backend{
+	IR_BACKEND:         writeIr,
+	EXECUTABLE_BACKEND: writeExecutable,
+}
+
+func Lookup(name string) (Backend, error) {
+	if name == "" {
+		name = DEFAULT_BACKEND
+	}
+
+	backend, isOk := backends[name]
+	if !isOk {
+		return nil, fmt.Errorf("unknown backend %v, expected one of %v", name, Names())
+	}
+
+	return backend, nil
+}
+
+func Names() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func writeIr(module intermediate.Module, writer io.Writer) error {
+	_, err := io.WriteString(writer, module.String())
+	return err
+}
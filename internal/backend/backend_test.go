@@ -0,0 +1,113 @@
+package backend
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func testModule() intermediate.Module {
+	module := intermediate.NewModule()
+	value := module.ValueOf(intermediate.TextLiteral("hello"))
+	module.Functions = append(module.Functions, intermediate.Function{
+		Name:      "main",
+		Registers: 1,
+		Definition: []intermediate.Instruction{
+			{Instruction: intermediate.VALUE, Dest: 0, Operand: uint64(value)},
+			{Instruction: intermediate.RETURN, Args: []intermediate.Register{0}},
+		},
+	})
+
+	return module
+}
+
+func TestEmbeddedModule(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "program")
+	contents := bytes.NewBufferString("host binary")
+	if err := writePayload(testModule(), contents); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(file, contents.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	module, isEmbedded, err := EmbeddedModule(file)
+	if err != nil || !isEmbedded {
+		t.Fatalf("expected an embedded module, got %v %v", isEmbedded, err)
+	}
+
+	if module.String() != testModule().String() {
+		t.Errorf("module changed while embedding:\n%v", module)
+	}
+
+	reader, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if size, err := hostSize(reader); err != nil || size != int64(len("host binary")) {
+		t.Errorf("unexpected host size %d %v", size, err)
+	}
+}
+
+func TestPlainExecutable(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "program")
+	if err := os.WriteFile(file, []byte("just a binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, isEmbedded, err := EmbeddedModule(file); err != nil || isEmbedded {
+		t.Fatalf("expected no embedded module, got %v %v", isEmbedded, err)
+	}
+}
+
+func TestMarkHost(t *testing.T) {
+	if markedHostSize() != 0 {
+		t.Fatalf("expected a bare binary to record no host size, got %d", markedHostSize())
+	}
+
+	bare := []byte("head " + hostMarker + " tail")
+	if err := markHost(bare); err != nil {
+		t.Fatal(err)
+	}
+
+	marker := string(bare[len("head "):][:len(hostMarker)])
+	if size, err := strconv.ParseInt(marker[len(hostMarkerPrefix):], 16, 64); err != nil || size != int64(len(bare)) {
+		t.Errorf("expected the size of the copy in its marker, got %q", marker)
+	}
+
+	if err := markHost([]byte(hostMarker + hostMarker)); err == nil {
+		t.Errorf("expected a repeated marker to fail")
+	}
+
+	if err := markHost([]byte("no marker")); err == nil {
+		t.Errorf("expected a missing marker to fail")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	write, err := Lookup(IR_BACKEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := strings.Builder{}
+	if err := write(testModule(), &output); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output.String(), "function #0 main") {
+		t.Errorf("unexpected ir output:\n%v", output.String())
+	}
+
+	if _, err := Lookup("wasm"); err == nil || !strings.Contains(err.Error(), EXECUTABLE_BACKEND) {
+		t.Errorf("expected an unknown backend error listing the backends, got %v", err)
+	}
+}
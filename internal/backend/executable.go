@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	goruntime "runtime"
+	"strconv"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/verify"
+)
+
+// Executables are not native code: they are a copy of the duffle
+// interpreter binary followed by the encoded IR of the module, its length
+// and PAYLOAD_MAGIC. The interpreter runs the module it finds at its end
+// instead of the command line.
+const PAYLOAD_MAGIC = "DUFFLEXE"
+
+const trailerLength = 8 + len(PAYLOAD_MAGIC)
+
+// hostMarker holds the size of the interpreter ahead of the module, written
+// into the copy by writeExecutable. A bare duffle binary keeps a size of
+// zero, so it knows it has no module without reading itself.
+const hostMarkerPrefix = "DUFFLEHOST"
+
+var hostMarker = hostMarkerPrefix + "0000000000000000"
+
+func writeExecutable(module intermediate.Module, writer io.Writer) error {
+	if goruntime.GOARCH != "amd64" {
+		return fmt.Errorf("%v needs duffle to be built for amd64, not %v", EXECUTABLE_BACKEND, goruntime.GOARCH)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	host, err := os.Open(self)
+	if err != nil {
+		return err
+	}
+	defer host.Close()
+
+	// A compiled program may itself compile, so only copy the bare binary
+	size, err := hostSize(host)
+	if err != nil {
+		return err
+	}
+
+	bare := make([]byte, size)
+	if _, err := io.ReadFull(host, bare); err != nil {
+		return err
+	} else if err := markHost(bare); err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(bare); err != nil {
+		return err
+	}
+
+	return writePayload(module, writer)
+}
+
+// markHost writes the size of a copy of the interpreter into its hostMarker
+func markHost(bare []byte) error {
+	marker := []byte(hostMarker)
+	at := bytes.Index(bare, marker)
+	if at < 0 || bytes.Contains(bare[at+1:], marker) {
+		return errors.New("duffle binary has no single host marker to record its size in")
+	}
+
+	copy(bare[at+len(hostMarkerPrefix):], fmt.Sprintf("%016x", len(bare)))
+	return nil
+}
+
+// markedHostSize is the size recorded in hostMarker, zero for a bare binary
+func markedHostSize() int64 {
+	size, err := strconv.ParseInt(hostMarker[len(hostMarkerPrefix):], 16, 64)
+	if err != nil {
+		return 0
+	}
+
+	return size
+}
+
+func writePayload(module intermediate.Module, writer io.Writer) error {
+	payload, err := module.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	trailer := make([]byte, 8, trailerLength)
+	binary.LittleEndian.PutUint64(trailer, uint64(len(payload)))
+	trailer = append(trailer, PAYLOAD_MAGIC...)
+
+	if _, err := writer.Write(payload); err != nil {
+		return err
+	}
+
+	_, err = writer.Write(trailer)
+	return err
+}
+
+func readTrailer(file *os.File) (int64, int64, bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, false, err
+	} else if info.Size() < int64(trailerLength) {
+		return info.Size(), 0, false, nil
+	}
+
+	trailer := make([]byte, trailerLength)
+	if _, err := file.ReadAt(trailer, info.Size()-int64(trailerLength)); err != nil {
+		return 0, 0, false, err
+	} else if string(trailer[8:]) != PAYLOAD_MAGIC {
+		return info.Size(), 0, false, nil
+	}
+
+	length := int64(binary.LittleEndian.Uint64(trailer))
+	if length > info.Size()-int64(trailerLength) {
+		return 0, 0, false, errors.New("embedded module is larger than its executable")
+	}
+
+	return info.Size() - int64(trailerLength) - length, length, true, nil
+}
+
+func hostSize(file *os.File) (int64, error) {
+	size, _, _, err := readTrailer(file)
+	return size, err
+}
+
+// Embedded reads the module appended to the running executable, if any.
+// Only an executable larger than the interpreter it records can have one,
+// so bare duffle binaries never read themselves.
+func Embedded() (intermediate.Module, bool, error) {
+	size := markedHostSize()
+	if size == 0 {
+		return intermediate.Module{}, false, nil
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return intermediate.Module{}, false, err
+	}
+
+	if info, err := os.Stat(self); err != nil || info.Size() <= size {
+		return intermediate.Module{}, false, err
+	}
+
+	return EmbeddedModule(self)
+}
+
+// EmbeddedModule reads the module appended to an executable, if any
+func EmbeddedModule(path string) (intermediate.Module, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return intermediate.Module{}, false, err
+	}
+	defer file.Close()
+
+	offset, length, isEmbedded, err := readTrailer(file)
+	if err != nil || !isEmbedded {
+		return intermediate.Module{}, false, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := file.ReadAt(payload, offset); err != nil {
+		return intermediate.Module{}, false, err
+	}
+
+	module := intermediate.Module{}
+	if err := module.UnmarshalBinary(payload); err != nil {
+		return intermediate.Module{}, false, fmt.Errorf("embedded module is corrupt: %w", err)
+	} else if err := verify.Module(module); err != nil {
+		return intermediate.Module{}, false, fmt.Errorf("embedded module is invalid:\n%w", err)
+	}
+
+	return module, true, nil
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+// DIRECTORY holds the cache of a project, relative to its root
+const DIRECTORY = ".duffle/cache"
+
+// Entries start with ENTRY_MAGIC, the cache version and the sha256 of the
+// data after them. Bump VERSION whenever what an entry holds changes
+// meaning, so entries of older builds are ignored.
+const (
+	ENTRY_MAGIC = "DFLC"
+	VERSION     = 1
+)
+
+const headerLength = len(ENTRY_MAGIC) + 1 + sha256.Size
+
+// Cache stores build results by a key of the inputs they were built from.
+// A nil cache stores nothing, for clean builds and builds that could not
+// open one. A cache may be used by several goroutines at once.
+type Cache struct {
+	Directory string
+	Stats     Stats
+	statsLock sync.Mutex
+}
+
+type Stats struct {
+	Hits     int
+	Misses   int
+	Repaired int
+}
+
+func (stats Stats) String() string {
+	return fmt.Sprintf("%d hits, %d misses, %d repaired", stats.Hits, stats.Misses, stats.Repaired)
+}
+
+// Open uses the cache of a project, creating it when missing. Something
+// else in the place of the cache directory is left alone, and fails.
+func Open(projectLocation string) (*Cache, error) {
+	root := projectLocation
+	if info, err := os.Stat(projectLocation); err == nil && !info.IsDir() {
+		root = filepath.Dir(projectLocation)
+	}
+
+	directory := filepath.Join(root, filepath.FromSlash(DIRECTORY))
+	if info, err := os.Stat(directory); err == nil && !info.IsDir() {
+		return nil, fmt.Errorf("%v is not a directory", directory)
+	}
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Cache{Directory: directory}, nil
+}
+
+// buildId names the build of duffle using the cache, since another build
+// may lower, check or emit the same files differently. Builds of a clean
+// checkout go by their revision, and any other by the hash of its binary.
+var buildId = sync.OnceValue(func() string {
+	if info, isOk := debug.ReadBuildInfo(); isOk {
+		revision, isModified := "", false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				isModified = setting.Value == "true"
+			}
+		}
+
+		if revision != "" && !isModified {
+			return info.GoVersion + " " + revision
+		}
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+
+	hash, _ := HashFile(self)
+	return hash
+})
+
+// Key hashes the inputs of a build result, together with the build of
+// duffle and the versions of the cache and the module encoding
+func Key(parts ...string) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%v\x00%d\x00%d\x00", buildId(), VERSION, intermediate.MODULE_VERSION)
+	for _, part := range parts {
+		io.WriteString(hash, part)
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// HashFile hashes the contents of a file
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (cache *Cache) count(counter *int) {
+	cache.statsLock.Lock()
+	defer cache.statsLock.Unlock()
+	*counter++
+}
+
+func (cache *Cache) pathOf(kind string, key string) string {
+	return filepath.Join(cache.Directory, kind, key[:2], key)
+}
+
+// Get reads an entry. Entries that fail their checksum are removed and
+// reported as missing, so the result is built and stored again.
+func (cache *Cache) Get(kind string, key string) ([]byte, bool) {
+	if cache == nil {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(cache.pathOf(kind, key))
+	if err != nil {
+		cache.count(&cache.Stats.Misses)
+		return nil, false
+	}
+
+	payload, isValid := payloadOf(data)
+	if !isValid {
+		cache.Invalidate(kind, key)
+		cache.count(&cache.Stats.Misses)
+		return nil, false
+	}
+
+	cache.count(&cache.Stats.Hits)
+	return payload, true
+}
+
+func payloadOf(data []byte) ([]byte, bool) {
+	if len(data) < headerLength || !bytes.HasPrefix(data, []byte(ENTRY_MAGIC)) || data[len(ENTRY_MAGIC)] != VERSION {
+		return nil, false
+	}
+
+	payload := data[headerLength:]
+	sum := sha256.Sum256(payload)
+	return payload, bytes.Equal(sum[:], data[len(ENTRY_MAGIC)+1:headerLength])
+}
+
+// Put stores an entry. It is written next to its place and renamed into
+// it, so a build stopped halfway never leaves half an entry behind.
+func (cache *Cache) Put(kind string, key string, payload []byte) error {
+	if cache == nil {
+		return nil
+	}
+
+	path := cache.pathOf(kind, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	sum := sha256.Sum256(payload)
+	header := append([]byte(ENTRY_MAGIC), VERSION)
+	_, err = temp.Write(append(append(header, sum[:]...), payload...))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
+
+// Invalidate removes an entry that could not be used, such as one whose
+// data no longer decodes
+func (cache *Cache) Invalidate(kind string, key string) {
+	if cache == nil {
+		return
+	}
+
+	if err := os.Remove(cache.pathOf(kind, key)); err == nil || !os.IsNotExist(err) {
+		cache.count(&cache.Stats.Repaired)
+	}
+}
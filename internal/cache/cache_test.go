@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func TestCacheEntries(t *testing.T) {
+	project := t.TempDir()
+	cache, err := Open(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key("goal", "main.dfl", "hash")
+	if _, isCached := cache.Get("goal", key); isCached {
+		t.Fatal("expected an empty cache")
+	}
+
+	if err := cache.Put("goal", key, []byte("lowered")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, isCached := cache.Get("goal", key); !isCached || string(data) != "lowered" {
+		t.Errorf("expected the stored entry, got %q %v", data, isCached)
+	}
+
+	if cache.Stats != (Stats{Hits: 1, Misses: 1}) {
+		t.Errorf("unexpected stats %v", cache.Stats)
+	}
+
+	if Key("goal", "main.dfl", "hash") != key || Key("goal", "main.dfl", "other") == key {
+		t.Errorf("expected keys to follow their inputs")
+	}
+}
+
+func TestKeyFollowsBuild(t *testing.T) {
+	current := buildId
+	defer func() { buildId = current }()
+
+	if current() == "" {
+		t.Errorf("expected the test binary to have a build id")
+	}
+
+	key := Key("goal", "main.dfl", "hash")
+	buildId = func() string { return "another build" }
+	if Key("goal", "main.dfl", "hash") == key {
+		t.Errorf("expected another build of duffle to use other keys")
+	}
+}
+
+func TestCacheRepairsCorruption(t *testing.T) {
+	project := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(project, ".duffle"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file where the cache directory belongs is not the cache's to remove
+	junk := filepath.Join(project, filepath.FromSlash(DIRECTORY))
+	if err := os.WriteFile(junk, []byte("junk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(project); err == nil {
+		t.Errorf("expected a file in the place of the cache to fail")
+	} else if data, err := os.ReadFile(junk); err != nil || string(data) != "junk" {
+		t.Errorf("expected the file to be left in place, got %q %v", data, err)
+	}
+
+	cache, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key("module", "program")
+	if err := cache.Put("module", key, []byte("encoded module")); err != nil {
+		t.Fatal(err)
+	}
+
+	path := cache.pathOf("module", key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, isCached := cache.Get("module", key); isCached {
+		t.Errorf("expected a corrupt entry to miss")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) || cache.Stats.Repaired != 1 {
+		t.Errorf("expected the corrupt entry to be removed, got %v with %v", err, cache.Stats)
+	}
+}
+
+func TestCacheConcurrentUse(t *testing.T) {
+	cache, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group := sync.WaitGroup{}
+	for worker := 0; worker < 8; worker++ {
+		group.Add(1)
+		go func(worker int) {
+			defer group.Done()
+			key := Key("ast", fmt.Sprint(worker%4))
+			cache.Get("ast", key)
+			if err := cache.Put("ast", key, []byte(fmt.Sprint(worker%4))); err != nil {
+				t.Error(err)
+			}
+		}(worker)
+	}
+
+	group.Wait()
+	for file := 0; file < 4; file++ {
+		if data, isCached := cache.Get("ast", Key("ast", fmt.Sprint(file))); !isCached || string(data) != fmt.Sprint(file) {
+			t.Errorf("expected entry %v, got %q %v", file, data, isCached)
+		}
+	}
+
+	if cache.Stats.Hits+cache.Stats.Misses != 12 {
+		t.Errorf("expected every read to be counted, got %v", cache.Stats)
+	}
+}
+
+func format(tree container.Tree[intermediate.SenimentExpression]) string {
+	parts := []string{strings.Join(tree.GetValue().Value, ".")}
+	for _, child := range tree.GetChildren() {
+		parts = append(parts, format(child))
+	}
+
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func TestGoalEncoding(t *testing.T) {
+	position := lexer.Position{Filename: "main.dfl", Line: 4}
+	definition := intermediate.NewExpressionTree(intermediate.SenimentExpression{Op: intermediate.OPCODE_CHAIN, Value: []string{"chain"}})
+	left := intermediate.NewExpressionTree(intermediate.SenimentExpression{Position: position, Op: intermediate.OPCODE_REF, Value: []string{"a"}})
+	container.AddChildren(left, intermediate.NewExpressionTree(intermediate.SenimentExpression{Op: intermediate.OPCODE_CONST, Value: []string{"1"}}))
+	container.AddChildren(definition, left)
+	container.AddChildren(definition, intermediate.NewExpressionTree(intermediate.SenimentExpression{Op: intermediate.OPCODE_OPERATOR, Value: []string{"+"}}))
+
+	goal := intermediate.NewGoal()
+	goal.Sentments["main"] = intermediate.Sentiment{Name: "main", Position: position, Definition: definition}
+	goal.Sentments["declared"] = intermediate.Sentiment{Name: "declared"}
+	goal.Imports = append(goal.Imports, intermediate.Import{Alias: "sysout", Path: []string{"dfl", "sysout"}})
+
+	data, err := EncodeGoal(goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeGoal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	main := decoded.Sentments["main"]
+	if main.Position != position || format(main.Definition) != format(definition) {
+		t.Errorf("definition changed while encoding: %v", format(main.Definition))
+	}
+
+	if main.Definition.GetChild(0).GetValue().Position != position {
+		t.Errorf("expression position changed while encoding")
+	}
+
+	if decoded.Sentments["declared"].Definition != nil || len(decoded.Imports) != 1 || decoded.Constants == nil {
+		t.Errorf("goal changed while encoding: %+v", decoded)
+	}
+
+}
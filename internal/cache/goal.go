@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+type expressionTree = container.Tree[intermediate.SenimentExpression]
+
+// storedNode is an expression of a definition in preorder, followed by its
+// children
+type storedNode struct {
+	Expression intermediate.SenimentExpression
+	Children   int
+}
+
+// storedGoal is a goal gob can encode. Trees are interfaces, so definitions
+// are flattened apart from the sentiments holding them.
+type storedGoal struct {
+	Goal        intermediate.Goal
+	Definitions map[string][]storedNode
+}
+
+// EncodeGoal encodes a lowered goal for the cache
+func EncodeGoal(goal intermediate.Goal) ([]byte, error) {
+	stored := storedGoal{Goal: goal, Definitions: make(map[string][]storedNode, len(goal.Sentments))}
+	stored.Goal.Sentments = make(map[string]intermediate.Sentiment, len(goal.Sentments))
+	for name, sentiment := range goal.Sentments {
+		if sentiment.Definition != nil {
+			stored.Definitions[name] = flatten(sentiment.Definition, nil)
+			sentiment.Definition = nil
+		}
+
+		stored.Goal.Sentments[name] = sentiment
+	}
+
+	data := bytes.Buffer{}
+	if err := gob.NewEncoder(&data).Encode(stored); err != nil {
+		return nil, err
+	}
+
+	return data.Bytes(), nil
+}
+
+// DecodeGoal decodes a goal encoded by EncodeGoal. Gob leaves empty maps
+// out, so every map of the goal is made again.
+func DecodeGoal(data []byte) (intermediate.Goal, error) {
+	stored := storedGoal{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored); err != nil {
+		return intermediate.Goal{}, err
+	}
+
+	goal := intermediate.NewGoal()
+	goal.Imports = append(goal.Imports, stored.Goal.Imports...)
+	for id, name := range stored.Goal.Types {
+		goal.Types[id] = name
+	}
+
+	for name, literal := range stored.Goal.Constants {
+		goal.Constants[name] = literal
+	}
+
+	for name, structure := range stored.Goal.Structs {
+		goal.Structs[name] = structure
+	}
+
+	for name, operator := range stored.Goal.Operators {
+		goal.Operators[name] = operator
+	}
+
+	for name, sentiment := range stored.Goal.Sentments {
+		if nodes, isOk := stored.Definitions[name]; isOk && len(nodes) > 0 {
+			sentiment.Definition, _ = unflatten(nodes)
+		}
+
+		goal.Sentments[name] = sentiment
+	}
+
+	return goal, nil
+}
+
+func flatten(tree expressionTree, nodes []storedNode) []storedNode {
+	children := tree.GetChildren()
+	nodes = append(nodes, storedNode{Expression: tree.GetValue(), Children: len(children)})
+	for _, child := range children {
+		nodes = flatten(child, nodes)
+	}
+
+	return nodes
+}
+
+// unflatten rebuilds the tree at the start of nodes, giving the nodes
+// after it
+func unflatten(nodes []storedNode) (expressionTree, []storedNode) {
+	tree := intermediate.NewExpressionTree(nodes[0].Expression)
+	rest := nodes[1:]
+	for count := nodes[0].Children; count > 0 && len(rest) > 0; count-- {
+		var child expressionTree
+		child, rest = unflatten(rest)
+		container.AddChildren(tree, child)
+	}
+
+	return tree, rest
+}
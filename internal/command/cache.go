@@ -0,0 +1,201 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/tflexsoom/duffle/internal/cache"
+	"github.com/tflexsoom/duffle/internal/files"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/optimize"
+	"github.com/tflexsoom/duffle/internal/resolve"
+	"github.com/tflexsoom/duffle/internal/verify"
+)
+
+// Kinds of cache entries. Parse results and lowered goals depend on a
+// single file, and checks on a file and the files it imports. Modules and
+// checks of a whole program depend on every file of the program, since
+// inference checks the program as a whole.
+const (
+	AST_CACHE    = "ast"
+	GOAL_CACHE   = "goal"
+	CHECK_CACHE  = "check"
+	MODULE_CACHE = "module"
+)
+
+// openCache opens the build cache of the first project, or gives nil for a
+// clean build, which neither reads nor writes a cache. A cache that cannot
+// be opened only makes the build slower, so it is reported and the build
+// goes on without one.
+func openCache(projectLocations []string, noCache bool) *cache.Cache {
+	if len(projectLocations) == 0 || noCache {
+		return nil
+	}
+
+	buildCache, err := cache.Open(projectLocations[0])
+	if err != nil {
+		log.Printf("build cache disabled: %v", err)
+		return nil
+	}
+
+	return buildCache
+}
+
+func logCache(buildCache *cache.Cache, isVerbose bool) {
+	if buildCache != nil && isVerbose {
+		log.Printf("build cache %v: %v", buildCache.Directory, buildCache.Stats)
+	}
+}
+
+// fileKey identifies a file by its path, which positions name, and contents
+func fileKey(kind string, sourceFileType files.SourceFileType, file string) (string, error) {
+	hash, err := cache.HashFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return cache.Key(kind, fmt.Sprint(sourceFileType), file, hash), nil
+}
+
+func cachedProcessor(
+	buildCache *cache.Cache,
+	kind string,
+	processor func(files.SourceFileType, string, *os.File) (string, error),
+) func(files.SourceFileType, string, *os.File) (string, error) {
+	return func(sourceFileType files.SourceFileType, file string, reader *os.File) (string, error) {
+		key, err := fileKey(kind, sourceFileType, file)
+		if err != nil {
+			return "", err
+		}
+
+		if data, isCached := buildCache.Get(kind, key); isCached {
+			return string(data), nil
+		}
+
+		data, err := processor(sourceFileType, file, reader)
+		if err != nil {
+			return "", err
+		}
+
+		return data, buildCache.Put(kind, key, []byte(data))
+	}
+}
+
+// lowerCached lowers a function file, reusing the goal lowered from the
+// same contents before
+func lowerCached(buildCache *cache.Cache, file string) (intermediate.Goal, error) {
+	key, err := fileKey(GOAL_CACHE, files.FunctionFile, file)
+	if err != nil {
+		return intermediate.Goal{}, err
+	}
+
+	if data, isCached := buildCache.Get(GOAL_CACHE, key); isCached {
+		goal, err := cache.DecodeGoal(data)
+		if err == nil {
+			return goal, nil
+		}
+
+		buildCache.Invalidate(GOAL_CACHE, key)
+	}
+
+	reader, err := os.Open(file)
+	if err != nil {
+		return intermediate.Goal{}, err
+	}
+	defer reader.Close()
+
+	goal, err := lowerProcessor(files.FunctionFile, file, reader)
+	if err != nil {
+		return intermediate.Goal{}, err
+	}
+
+	data, err := cache.EncodeGoal(goal)
+	if err != nil {
+		return intermediate.Goal{}, err
+	}
+
+	return goal, buildCache.Put(GOAL_CACHE, key, data)
+}
+
+// checkKey identifies the check of a function file by its path and contents
+// and the contents of every file its imports resolve to, so that a file is
+// checked again whenever one of its imports changes
+func checkKey(resolver resolve.Resolver, file string, goal intermediate.Goal) (string, error) {
+	hash, err := cache.HashFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	parts := []string{CHECK_CACHE, file, hash}
+	for _, imported := range resolver.ImportedFiles(goal.Imports) {
+		// A file that cannot be read is still part of the key, so the check
+		// runs again once it can
+		importedHash, _ := cache.HashFile(imported)
+		parts = append(parts, imported, importedHash)
+	}
+
+	return cache.Key(parts...), nil
+}
+
+// programCheckKey identifies the check of a whole program by its entry, the
+// check key of each function file and the contents of the data file next to
+// it, whose values are folded into the check
+func programCheckKey(
+	fileMap map[files.SourceFileType][]string,
+	projectLocations []string,
+	entry string,
+	goals []intermediate.Goal,
+) (string, error) {
+	parts := []string{CHECK_CACHE, entry}
+	for index, file := range fileMap[files.FunctionFile] {
+		key, err := checkKey(resolve.NewResolver(projectLocationOf(projectLocations, file)), file, goals[index])
+		if err != nil {
+			return "", err
+		}
+
+		dataHash, _ := cache.HashFile(dataFileOf(file))
+		parts = append(parts, key, dataHash)
+	}
+
+	return cache.Key(parts...), nil
+}
+
+// programKey identifies a whole program by the paths and contents of all
+// its files, the entry it runs from and the passes its module is optimized
+// with
+func programKey(fileMap map[files.SourceFileType][]string, entry string, optimization optimize.Options) (string, error) {
+	parts := []string{MODULE_CACHE, entry, fmt.Sprint(optimization.Level)}
+	for sourceFileType := files.FunctionFile; sourceFileType < files.SOURCE_FILE_TYPE_LENGTH; sourceFileType++ {
+		sorted := append([]string{}, fileMap[sourceFileType]...)
+		sort.Strings(sorted)
+		for _, file := range sorted {
+			hash, err := cache.HashFile(file)
+			if err != nil {
+				return "", err
+			}
+
+			parts = append(parts, file, hash)
+		}
+	}
+
+	return cache.Key(parts...), nil
+}
+
+// cachedModule gives the module built before from the same program, which
+// is checked again since it is about to run
+func cachedModule(buildCache *cache.Cache, key string) (intermediate.Module, bool) {
+	data, isCached := buildCache.Get(MODULE_CACHE, key)
+	if !isCached {
+		return intermediate.Module{}, false
+	}
+
+	module := intermediate.Module{}
+	if err := module.UnmarshalBinary(data); err != nil || verify.Module(module) != nil {
+		buildCache.Invalidate(MODULE_CACHE, key)
+		return intermediate.Module{}, false
+	}
+
+	return module, true
+}
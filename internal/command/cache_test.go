@@ -0,0 +1,85 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/cache"
+	"github.com/tflexsoom/duffle/internal/resolve"
+)
+
+const (
+	wordsSource = "@fact GREETING := \"hello\"\n"
+	mainSource  = "@import sysout := use (dfl.sysout)\n@import GREETING := use (words.GREETING)\n\n@exec main := sysout GREETING\n"
+)
+
+func TestCheckKeyFollowsImports(t *testing.T) {
+	project := t.TempDir()
+	main, words := filepath.Join(project, "main.dfl"), filepath.Join(project, "words.dfl")
+	writeProjectFile(t, words, wordsSource)
+	writeProjectFile(t, main, mainSource)
+
+	resolver := resolve.NewResolver(project)
+	keyOf := func(file string) string {
+		t.Helper()
+		goal, err := lowerCached(nil, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := checkKey(resolver, file, goal)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return key
+	}
+
+	mainKey, wordsKey := keyOf(main), keyOf(words)
+	writeProjectFile(t, main, mainSource+"\n")
+	if keyOf(main) == mainKey || keyOf(words) != wordsKey {
+		t.Errorf("expected only the changed file to be checked again")
+	}
+
+	mainKey = keyOf(main)
+	writeProjectFile(t, words, "@fact GREETING := \"hi\"\n")
+	if keyOf(main) == mainKey {
+		t.Errorf("expected a file to be checked again once its import changed")
+	}
+}
+
+func TestTypeCheckReusesChecks(t *testing.T) {
+	project := t.TempDir()
+	writeProjectFile(t, filepath.Join(project, "words.dfl"), wordsSource)
+	writeProjectFile(t, filepath.Join(project, "main.dfl"), mainSource)
+
+	options := TypeCheckOptions{ProjectLocations: []string{project}, OutputLocation: filepath.Join(project, "checked.txt")}
+	for run := 0; run < 2; run++ {
+		if err := TypeCheckOnly(options); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+	}
+
+	// A cached pass of the program must not hide a change to an import
+	writeProjectFile(t, filepath.Join(project, "words.dfl"), "@fact OTHER := \"hello\"\n")
+	if err := TypeCheckOnly(options); err == nil || !strings.Contains(err.Error(), "unknown name GREETING") {
+		t.Errorf("expected the removed import to fail the check, got %v", err)
+	}
+}
+
+func TestNoCacheLeavesNoCache(t *testing.T) {
+	project := t.TempDir()
+	writeProjectFile(t, filepath.Join(project, "words.dfl"), wordsSource)
+	writeProjectFile(t, filepath.Join(project, "main.dfl"), mainSource)
+
+	options := TypeCheckOptions{ProjectLocations: []string{project}, OutputLocation: filepath.Join(t.TempDir(), "checked.txt"), NoCache: true}
+	if err := TypeCheckOnly(options); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(project, filepath.FromSlash(cache.DIRECTORY))); !os.IsNotExist(err) {
+		t.Errorf("expected a clean build to leave no cache behind, got %v", err)
+	}
+}
@@ -2,21 +2,30 @@ package command
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/alecthomas/repr"
+	"github.com/tflexsoom/duffle/internal/artifact"
+	"github.com/tflexsoom/duffle/internal/backend"
+	"github.com/tflexsoom/duffle/internal/compile"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
 	"github.com/tflexsoom/duffle/internal/discovery"
 	"github.com/tflexsoom/duffle/internal/files"
-	"github.com/tflexsoom/duffle/internal/parsing/ddatgrammar"
-	"github.com/tflexsoom/duffle/internal/parsing/dflgrammar"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/language/function"
+	"github.com/tflexsoom/duffle/internal/optimize"
 	"github.com/tflexsoom/duffle/internal/typing"
 )
 
-func getFileMap(projectLocations []string, isVerbose bool) (map[files.SourceFileType][]string, error) {
+func getFileMap(projectLocations []string, filter discovery.Filter, isVerbose bool) (map[files.SourceFileType][]string, error) {
 	fileMap := make(map[files.SourceFileType][]string)
 	for _, location := range projectLocations {
-		discoveredFileMap, err := discovery.DiscoverFiles(location, isVerbose)
+		discoveredFileMap, err := discovery.Discover(location, filter, isVerbose)
 		if err != nil {
 			return nil, err
 		}
@@ -29,42 +38,25 @@ func getFileMap(projectLocations []string, isVerbose bool) (map[files.SourceFile
 	return fileMap, nil
 }
 
-func writeOutput(fileName string, data string, isVerbose bool) error {
-	outFile, err := os.OpenFile(
-		fileName,
-		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
-		0644,
-	)
-	if err != nil {
-		return err
-	}
-	defer outFile.Close()
-
-	num, err := outFile.WriteString(data)
-	if err != nil {
-		return err
-	}
-
-	if isVerbose {
-		log.Printf("%d bytes written!", num)
-	}
-
-	return nil
-}
-
 type FileLogicOptions interface {
 	GetProjectLocations() []string
 	GetFunctionFilesOnly() bool
 	GetDataFilesOnly() bool
 	GetOutputLocation() string
+	GetFilter() discovery.Filter
+	GetJobs() int
 	IsVerbose() bool
 }
 
+// withFileLogicAndOutput runs a processor on every discovered file on a
+// pool of workers. The output holds the results of function files and then
+// of data files, each in the order they were discovered, and is only
+// replaced once every file was processed.
 func withFileLogicAndOutput(
 	fileLogicOptions FileLogicOptions,
 	processor func(files.SourceFileType, string, *os.File) (string, error),
 ) error {
-	fileMap, err := getFileMap(fileLogicOptions.GetProjectLocations(), fileLogicOptions.IsVerbose())
+	fileMap, err := getFileMap(fileLogicOptions.GetProjectLocations(), fileLogicOptions.GetFilter(), fileLogicOptions.IsVerbose())
 	if err != nil {
 		return err
 	}
@@ -75,52 +67,52 @@ func withFileLogicAndOutput(
 		files.DataFile:     defaultTrue || fileLogicOptions.GetDataFilesOnly(),
 	}
 
-	tempFileName := fileLogicOptions.GetOutputLocation() + "_temp"
-	os.Remove(tempFileName)
-
-	for sourceFileType, files := range fileMap {
+	fileList := make([]string, 0, len(fileMap[files.FunctionFile])+len(fileMap[files.DataFile]))
+	fileTypes := make(map[string]files.SourceFileType, cap(fileList))
+	for sourceFileType := files.FunctionFile; sourceFileType < files.SOURCE_FILE_TYPE_LENGTH; sourceFileType++ {
 		if !fileFilter[sourceFileType] {
 			continue
 		}
 
-		for _, file := range files {
-			reader, err := os.Open(file)
-			if err != nil {
-				return err
-			}
-
-			data, err := processor(sourceFileType, file, reader)
-			if err != nil {
-				return err
-			}
-
-			err = writeOutput(tempFileName, data, fileLogicOptions.IsVerbose())
-			if err != nil {
-				return err
-			}
+		for _, file := range fileMap[sourceFileType] {
+			fileList = append(fileList, file)
+			fileTypes[file] = sourceFileType
 		}
 	}
 
-	err = os.Remove(fileLogicOptions.GetOutputLocation())
-	if err != nil {
-		return err
-	}
+	outputs, err := forEachFile(fileList, fileLogicOptions.GetJobs(), func(file string) (string, error) {
+		reader, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
 
-	err = os.Rename(tempFileName, fileLogicOptions.GetOutputLocation())
+		return processor(fileTypes[file], file, reader)
+	})
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	return artifact.WriteFile(fileLogicOptions.GetOutputLocation(), func(writer io.Writer) error {
+		written := 0
+		for _, data := range outputs {
+			num, err := io.WriteString(writer, data)
+			written += num
+			if err != nil {
+				return err
+			}
+		}
 
-var parsers = map[files.SourceFileType](func() (files.SourceFileParser, error)){
-	files.FunctionFile: dflgrammar.GetDflParser,
-	files.DataFile:     ddatgrammar.GetDdatParser,
+		if fileLogicOptions.IsVerbose() {
+			log.Printf("%d bytes written!", written)
+		}
+
+		return nil
+	})
 }
 
 func parseProcessor(sourceFileType files.SourceFileType, file string, reader *os.File) (interface{}, error) {
-	parser, err := parsers[sourceFileType]()
+	parser, err := parsers[sourceFileType].get()
 	if err != nil {
 		return nil, err
 	}
@@ -147,6 +139,9 @@ type ParserOptions struct {
 	OutputLocation   string
 	FunctionOnly     bool
 	DataOnly         bool
+	Filter           discovery.Filter
+	NoCache          bool
+	Jobs             int
 	Verbose          bool
 }
 
@@ -166,36 +161,53 @@ func (options ParserOptions) GetOutputLocation() string {
 	return options.OutputLocation
 }
 
+func (options ParserOptions) GetFilter() discovery.Filter {
+	return options.Filter
+}
+
+func (options ParserOptions) GetJobs() int {
+	return options.Jobs
+}
+
 func (options ParserOptions) IsVerbose() bool {
 	return options.Verbose
 }
 
 func ParseOnly(options ParserOptions) error {
-	return withFileLogicAndOutput(options, parseStringProcessor)
+	buildCache := openCache(options.ProjectLocations, options.NoCache)
+	defer logCache(buildCache, options.Verbose)
+	return withFileLogicAndOutput(options, cachedProcessor(buildCache, AST_CACHE, parseStringProcessor))
 }
 
-func typeCheckProcessor(sourceFileType files.SourceFileType, file string, reader *os.File) (string, error) {
+func lowerProcessor(sourceFileType files.SourceFileType, file string, reader *os.File) (intermediate.Goal, error) {
 	ast, err := parseProcessor(sourceFileType, file, reader)
 	if err != nil {
-		return "", err
+		return intermediate.Goal{}, err
 	}
 
-	casted, isOk := ast.(*dflgrammar.Module)
+	return lowerAST(ast)
+}
+
+func lowerAST(ast interface{}) (intermediate.Goal, error) {
+	casted, isOk := ast.(*function.Module)
 	if !isOk {
-		return "", errors.New("casting module did not work for parsing to ir")
+		return intermediate.Goal{}, errors.New("casting module did not work for parsing to ir")
 	}
 
-	data, err := typing.TypeCheck(file, *casted)
-	if err != nil {
-		return "", err
+	goal, diagnostics := compile.LowerModule(*casted)
+	if diagnostics.HasErrors() {
+		return intermediate.Goal{}, diagnostics
 	}
 
-	return data, nil
+	return goal, nil
 }
 
 type TypeCheckOptions struct {
 	ProjectLocations []string
 	OutputLocation   string
+	Filter           discovery.Filter
+	NoCache          bool
+	Jobs             int
 	Verbose          bool
 }
 
@@ -215,16 +227,107 @@ func (options TypeCheckOptions) GetOutputLocation() string {
 	return options.OutputLocation
 }
 
+func (options TypeCheckOptions) GetFilter() discovery.Filter {
+	return options.Filter
+}
+
+func (options TypeCheckOptions) GetJobs() int {
+	return options.Jobs
+}
+
 func (options TypeCheckOptions) IsVerbose() bool {
 	return options.Verbose
 }
 
-func TypeCheckOnly(options TypeCheckOptions) error {
-	return withFileLogicAndOutput(options, typeCheckProcessor)
+func projectLocationOf(projectLocations []string, file string) string {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return projectLocations[0]
+	}
+
+	for _, location := range projectLocations {
+		absLocation, err := filepath.Abs(location)
+		if err == nil && strings.HasPrefix(absFile, absLocation+string(filepath.Separator)) {
+			return location
+		}
+	}
+
+	return projectLocations[0]
 }
 
-func compileProcessor(sourceFileType files.SourceFileType, file string, reader *os.File) (string, error) {
-	return "", nil
+// checkOutput gives the output of a checked program, which is the warnings of
+// each function file, or a pass for a file without any, in the order the
+// files were discovered
+func checkOutput(functionFiles []string, diagnostics diagnostic.Diagnostics) string {
+	builder := strings.Builder{}
+	for _, file := range functionFiles {
+		var warnings diagnostic.Diagnostics
+		for _, warning := range diagnostics {
+			if warning.Position.Filename == file {
+				warnings = append(warnings, warning)
+			}
+		}
+
+		if len(warnings) > 0 {
+			builder.WriteString(warnings.String())
+		} else {
+			builder.WriteString(typing.PASS_STRING)
+		}
+	}
+
+	return builder.String()
+}
+
+// TypeCheckOnly checks a program the way it is checked before it runs, with
+// the function files lowered on a pool of workers. The output is only
+// written once the whole program passed.
+func TypeCheckOnly(options TypeCheckOptions) error {
+	current, err := findProject(options.ProjectLocations)
+	if err != nil {
+		return err
+	}
+
+	options.ProjectLocations = current.locations
+	fileMap, err := getFileMap(options.ProjectLocations, options.Filter, options.Verbose)
+	if err != nil {
+		return err
+	}
+
+	buildCache := openCache(options.ProjectLocations, options.NoCache)
+	defer logCache(buildCache, options.Verbose)
+
+	goals, configs, err := lowerProgram(fileMap, buildCache, options.Jobs)
+	if err != nil {
+		return err
+	}
+
+	// Only programs that passed are stored, so the same files pass again
+	key, err := programCheckKey(fileMap, options.ProjectLocations, current.manifest.Entry, goals)
+	if err != nil {
+		return err
+	}
+
+	output, isCached := buildCache.Get(CHECK_CACHE, key)
+	if !isCached {
+		diagnostics := checkProgram(fileMap, options.ProjectLocations, goals, configs, current.manifest.Entry)
+		if diagnostics.HasErrors() {
+			return diagnostics
+		}
+
+		output = []byte(checkOutput(fileMap[files.FunctionFile], diagnostics))
+		if err := buildCache.Put(CHECK_CACHE, key, output); err != nil {
+			return err
+		}
+	}
+
+	return artifact.WriteFile(options.OutputLocation, func(writer io.Writer) error {
+		written, err := writer.Write(output)
+		if options.Verbose {
+			log.Printf("%d bytes written!", written)
+		}
+
+		return err
+	})
 }
 
 type CompilerOptions struct {
@@ -232,8 +335,18 @@ type CompilerOptions struct {
 	OutputLocation   string
 	FunctionOnly     bool
 	DataOnly         bool
-	Backend          string
-	Verbose          bool
+	Backends         []string
+	// OutputDirectory holds a directory for each backend, instead of the
+	// single OutputLocation
+	OutputDirectory string
+	Optimization    optimize.Options
+	// DefaultOptimization is set when no level was given, so the manifest
+	// may choose one
+	DefaultOptimization bool
+	Filter              discovery.Filter
+	NoCache             bool
+	Jobs                int
+	Verbose             bool
 }
 
 func (options CompilerOptions) GetProjectLocations() []string {
@@ -252,10 +365,122 @@ func (options CompilerOptions) GetOutputLocation() string {
 	return options.OutputLocation
 }
 
+func (options CompilerOptions) GetFilter() discovery.Filter {
+	return options.Filter
+}
+
+func (options CompilerOptions) GetJobs() int {
+	return options.Jobs
+}
+
 func (options CompilerOptions) IsVerbose() bool {
 	return options.Verbose
 }
 
+// Compile builds the projects given, or the project of the manifest above
+// the working directory with the backends, outputs and entry point it sets.
+// A single backend writes to one output, while several backends, or an
+// output directory, write every artifact under that directory.
 func Compile(options CompilerOptions) error {
-	return withFileLogicAndOutput(options, compileProcessor)
+	current, err := findProject(options.ProjectLocations)
+	if err != nil {
+		return err
+	}
+
+	backendNames := current.backendsOf(options.Backends)
+	if len(backendNames) == 1 && options.OutputDirectory == "" {
+		return compileOne(current, backendNames[0], options)
+	} else if options.OutputLocation != "" {
+		return errors.New("--output names a single file, use --out-dir to build several backends")
+	}
+
+	return compileAll(current, backendNames, options)
+}
+
+// moduleOf builds the module a backend writes, at the optimization level
+// the manifest sets for it
+func moduleOf(current project, backendName string, options CompilerOptions) (intermediate.Module, error) {
+	optimization, err := current.optimizationOf(backendName, options.Optimization, options.DefaultOptimization)
+	if err != nil {
+		return intermediate.Module{}, err
+	}
+
+	return buildModule(current.locations, current.manifest.Entry, options.Filter, options.Verbose, options.NoCache, options.Jobs, optimization)
+}
+
+func compileOne(current project, backendName string, options CompilerOptions) error {
+	outputLocation := current.outputOf(backendName, options.OutputLocation)
+	module, err := moduleOf(current, backendName, options)
+	if err != nil {
+		return err
+	}
+
+	err = writeModule(module, backendName, outputLocation)
+	if err == nil && options.Verbose {
+		log.Printf("%v written with backend %v", outputLocation, backendName)
+	}
+
+	return err
+}
+
+// compileAll writes every backend into its own directory along with a
+// summary of the build. The module is built once for every optimization
+// level the backends ask for, and a backend failing does not stop the others.
+func compileAll(current project, backendNames []string, options CompilerOptions) error {
+	targets := make([]artifact.Target, 0, len(backendNames))
+	modules := make(map[optimize.Level]intermediate.Module, 1)
+	for _, backendName := range backendNames {
+		write, err := backend.Lookup(backendName)
+		if err != nil {
+			return err
+		}
+
+		optimization, err := current.optimizationOf(backendName, options.Optimization, options.DefaultOptimization)
+		if err != nil {
+			return err
+		}
+
+		module, isBuilt := modules[optimization.Level]
+		if !isBuilt {
+			module, err = buildModule(current.locations, current.manifest.Entry, options.Filter, options.Verbose, options.NoCache, options.Jobs, optimization)
+			if err != nil {
+				return err
+			}
+
+			modules[optimization.Level] = module
+		}
+
+		targets = append(targets, artifact.Target{
+			Backend: backendName,
+			Name:    current.artifactNameOf(backendName),
+			Write: func(writer io.Writer) error {
+				return write(module, writer)
+			},
+		})
+	}
+
+	outputDirectory := current.outputDirectoryOf(options.OutputDirectory)
+	summary, err := artifact.Build(outputDirectory, current.manifest.Name, targets)
+	if err != nil {
+		return err
+	}
+
+	failures := make([]error, 0, summary.Failed())
+	for _, built := range summary.Artifacts {
+		if built.Error != "" {
+			failures = append(failures, errors.New(built.Error))
+		} else if options.Verbose {
+			log.Printf("%v written with backend %v", filepath.Join(outputDirectory, built.Path), built.Backend)
+		}
+	}
+
+	if options.Verbose {
+		log.Printf("build summary written to %v", filepath.Join(outputDirectory, artifact.SUMMARY_FILE_NAME))
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d backends failed:\n%w", len(failures), len(targets), errors.Join(failures...))
+	}
+
+	return nil
 }
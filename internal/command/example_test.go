@@ -0,0 +1,150 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/testrun"
+)
+
+var examplesLocation = filepath.Join("..", "..", "example")
+
+// copyExample copies an example into a temporary directory, so the build
+// cache is not written into the source tree
+func copyExample(t *testing.T, example string) string {
+	t.Helper()
+	location := filepath.Join(t.TempDir(), filepath.Base(example))
+	if err := os.Mkdir(location, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(example)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(example, entry.Name()))
+		if err == nil {
+			err = os.WriteFile(filepath.Join(location, entry.Name()), data, 0644)
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return location
+}
+
+func TestExamplesMatchTheirGoldens(t *testing.T) {
+	goldens, err := filepath.Glob(filepath.Join(examplesLocation, "*", "*"+testrun.GOLDEN_ENDING))
+	if err != nil || len(goldens) == 0 {
+		t.Fatalf("expected golden files in the examples, got %v %v", goldens, err)
+	}
+
+	for _, golden := range goldens {
+		location := copyExample(t, filepath.Dir(golden))
+		report := filepath.Join(t.TempDir(), "report.txt")
+		err := Test(TestOptions{
+			ProjectLocations: []string{location},
+			Format:           testrun.FORMAT_TEXT,
+			ReportLocation:   report,
+			NoCache:          true,
+		})
+
+		output, _ := os.ReadFile(report)
+		if err != nil {
+			t.Errorf("expected %v to match its golden, got %v\n%s", location, err, output)
+		} else if name := strings.TrimSuffix(filepath.Base(golden), testrun.GOLDEN_ENDING); !strings.Contains(string(output), "PASS: golden/"+name) {
+			t.Errorf("expected %v to run its golden %v, got\n%s", location, name, output)
+		}
+	}
+}
+
+func TestExamplesTypeCheck(t *testing.T) {
+	examples, err := filepath.Glob(filepath.Join(examplesLocation, "*"))
+	if err != nil || len(examples) != 5 {
+		t.Fatalf("expected five examples, got %v %v", examples, err)
+	}
+
+	for _, example := range examples {
+		err := TypeCheckOnly(TypeCheckOptions{
+			ProjectLocations: []string{copyExample(t, example)},
+			OutputLocation:   filepath.Join(t.TempDir(), "checked.txt"),
+			NoCache:          true,
+		})
+		if err != nil {
+			t.Errorf("expected %v to typecheck, got %v", example, err)
+		}
+	}
+}
+
+func TestExampleWithArguments(t *testing.T) {
+	// example2 reads the number of levels from its command line
+	stdout := &bytes.Buffer{}
+	_, err := Run(RunOptions{
+		ProjectLocations: []string{copyExample(t, filepath.Join(examplesLocation, "example2"))},
+		Args:             []string{"3"},
+		Stdout:           stdout,
+		NoCache:          true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if stdout.String() != "*\n*\n" {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+}
+
+func TestRebindingAnImport(t *testing.T) {
+	// The fixture imports sysout three times, the last two from other members
+	err := TypeCheckOnly(TypeCheckOptions{
+		ProjectLocations: []string{copyExample(t, filepath.Join("testdata", "rebound"))},
+		NoCache:          true,
+	})
+	if err == nil {
+		t.Fatalf("expected rebinding an import to fail to typecheck")
+	}
+
+	for _, expected := range []string{
+		"rebound.dfl:2:1: error: import alias sysout is rebound to dfl.loop",
+		"rebound.dfl:3:1: error: import alias sysout is rebound to dfl.text2Number",
+		"rebound.dfl:1:1: note: sysout was first bound to dfl.sysout here",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in the diagnostics, got\n%v", expected, err)
+		}
+	}
+}
+
+func TestRedefiningAFunction(t *testing.T) {
+	project := t.TempDir()
+	writeProjectFile(t, filepath.Join(project, "main.dfl"), strings.Join([]string{
+		`@import sysout := use (dfl.sysout)`,
+		`@fact ONE := 1`,
+		``,
+		`@exec main := sysout ONE`,
+		`@fact ONE := 2`,
+	}, "\n")+"\n")
+
+	err := TypeCheckOnly(TypeCheckOptions{ProjectLocations: []string{project}, OutputLocation: filepath.Join(project, "checked.txt"), NoCache: true})
+	if err == nil {
+		t.Fatalf("expected a second definition of ONE to fail to typecheck")
+	}
+
+	for _, expected := range []string{
+		"main.dfl:5:1: error: ONE is already defined",
+		"main.dfl:2:1: note: ONE was first defined here",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in the diagnostics, got\n%v", expected, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tflexsoom/duffle/internal/scaffold"
+)
+
+type InitOptions struct {
+	ProjectLocation string
+	Template        string
+	Force           bool
+	Output          io.Writer
+}
+
+// Init creates a new project from one of the templates built into duffle
+func Init(options InitOptions) error {
+	output := options.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	written, err := scaffold.Create(scaffold.Options{
+		Directory: options.ProjectLocation,
+		Template:  options.Template,
+		Force:     options.Force,
+	})
+	for _, path := range written {
+		fmt.Fprintf(output, "created %v\n", path)
+	}
+
+	return err
+}
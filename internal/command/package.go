@@ -0,0 +1,147 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/tflexsoom/duffle/internal/artifact"
+	"github.com/tflexsoom/duffle/internal/files"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/language/config"
+	"github.com/tflexsoom/duffle/internal/packaging"
+)
+
+func readDataConfigs(file string) ([]intermediate.DataConfig, error) {
+	reader, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	ast, err := parseProcessor(files.DataFile, file, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	casted, isOk := ast.(*config.Configuration)
+	if !isOk {
+		return nil, errors.New("casting configuration did not work for reading data")
+	}
+
+	configs := make([]intermediate.DataConfig, 0, len(casted.Assignments))
+	for _, assignment := range casted.Assignments {
+		configs = append(configs, assignment.GetDataConfig())
+	}
+
+	return configs, nil
+}
+
+func readManifest(directory string) (packaging.Manifest, error) {
+	file := filepath.Join(directory, packaging.MANIFEST_FILE_NAME)
+	configs, err := readDataConfigs(file)
+	if err != nil {
+		return packaging.Manifest{}, err
+	}
+
+	manifest, err := packaging.ManifestOf(configs)
+	if err != nil {
+		return packaging.Manifest{}, fmt.Errorf("%v: %v", file, err)
+	}
+
+	return manifest, nil
+}
+
+func readLockfile(directory string) (packaging.Lockfile, error) {
+	file := filepath.Join(directory, packaging.LOCK_FILE_NAME)
+	configs, err := readDataConfigs(file)
+	if err != nil {
+		return packaging.Lockfile{}, err
+	}
+
+	lock, err := packaging.LockfileOf(configs)
+	if err != nil {
+		return packaging.Lockfile{}, fmt.Errorf("%v: %v", file, err)
+	}
+
+	return lock, nil
+}
+
+type PackageOptions struct {
+	ProjectLocation string
+	CacheLocation   string
+	Verbose         bool
+}
+
+func newManager(options PackageOptions) (packaging.Manager, error) {
+	cacheLocation := options.CacheLocation
+	if cacheLocation == "" {
+		defaultLocation, err := packaging.DefaultCacheDir()
+		if err != nil {
+			return packaging.Manager{}, err
+		}
+
+		cacheLocation = defaultLocation
+	}
+
+	if options.Verbose {
+		log.Printf("using the dependency cache in %v", cacheLocation)
+	}
+
+	return packaging.NewManager(options.ProjectLocation, cacheLocation, readManifest), nil
+}
+
+// Get fetches the dependencies of a project's manifest and writes its lockfile
+func Get(options PackageOptions) error {
+	manifest, err := readManifest(options.ProjectLocation)
+	if err != nil {
+		return err
+	}
+
+	previous, err := readLockfile(options.ProjectLocation)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	manager, err := newManager(options)
+	if err != nil {
+		return err
+	}
+
+	lock, err := manager.Get(manifest, previous)
+	if err != nil {
+		return err
+	}
+
+	if options.Verbose {
+		for _, locked := range lock.Dependencies {
+			log.Printf("locked %v at %v, hashed %v", locked.Name, locked.Revision, locked.Hash)
+		}
+	}
+
+	return artifact.WriteFile(filepath.Join(options.ProjectLocation, packaging.LOCK_FILE_NAME), func(writer io.Writer) error {
+		_, err := io.WriteString(writer, lock.String())
+		return err
+	})
+}
+
+// Vendor copies the locked dependencies of a project into its vendor directory
+func Vendor(options PackageOptions) error {
+	lock, err := readLockfile(options.ProjectLocation)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%v has no %v, run \"duffle get\" first", options.ProjectLocation, packaging.LOCK_FILE_NAME)
+	} else if err != nil {
+		return err
+	}
+
+	manager, err := newManager(options)
+	if err != nil {
+		return err
+	}
+
+	return manager.Vendor(lock)
+}
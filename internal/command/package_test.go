@@ -0,0 +1,78 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/packaging"
+)
+
+func writeProjectFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetAndVendorFromManifest(t *testing.T) {
+	sources := t.TempDir()
+	shared := filepath.Join(sources, "shared")
+	writeProjectFile(t, filepath.Join(shared, "shared.dfl"), "@@ two = 2\n")
+	writeProjectFile(t, filepath.Join(shared, packaging.MANIFEST_FILE_NAME), "module.name = \"shared\"\n")
+
+	project := filepath.Join(sources, "app")
+	writeProjectFile(t, filepath.Join(project, packaging.MANIFEST_FILE_NAME), strings.Join([]string{
+		`module.name = "app"`,
+		`module.roots = ["src"]`,
+		`module.entry = "main"`,
+		`build.backends = ["ir"]`,
+		`ir.output = "build/app.ir"`,
+		`ir.optimize = 0`,
+		`dependencies = [("shared", "../shared")]`,
+	}, "\n")+"\n")
+
+	manifest, err := readManifest(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Name != "app" || manifest.Entry != "main" || len(manifest.Roots) != 1 || manifest.Roots[0] != "src" ||
+		manifest.Targets["ir"].Output != "build/app.ir" || *manifest.Targets["ir"].Optimize != 0 ||
+		len(manifest.Dependencies) != 1 || manifest.Dependencies[0].Source != "../shared" {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+
+	options := PackageOptions{ProjectLocation: project, CacheLocation: t.TempDir()}
+	if err := Get(options); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := readLockfile(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked, isOk := lock.Lookup("shared")
+	if !isOk || locked.Source != "../shared" || !strings.HasPrefix(locked.Hash, packaging.HASH_PREFIX) {
+		t.Fatalf("unexpected lockfile\n%v", lock)
+	}
+
+	if err := Vendor(options); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := os.ReadFile(filepath.Join(project, "vendor", "shared", "shared.dfl")); err != nil || string(content) != "@@ two = 2\n" {
+		t.Errorf("unexpected vendored content %q: %v", content, err)
+	}
+
+	writeProjectFile(t, filepath.Join(shared, "shared.dfl"), "@@ two = 3\n")
+	if err := Get(options); err == nil || !strings.Contains(err.Error(), "shared hashes to") {
+		t.Errorf("expected the changed dependency to mismatch its lock, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package command
+
+import (
+	"errors"
+	goruntime "runtime"
+	"sync"
+
+	"github.com/tflexsoom/duffle/internal/files"
+	"github.com/tflexsoom/duffle/internal/language/generator"
+)
+
+// jobsOf gives how many files may be processed at once, every CPU when
+// the count is not set
+func jobsOf(jobs int) int {
+	if jobs <= 0 {
+		return goruntime.NumCPU()
+	}
+
+	return jobs
+}
+
+// forEachFile runs work on every file with at most jobs of them running at
+// once. Results keep the order of the files however the work is scheduled,
+// and the failures of all files are joined in that order too.
+func forEachFile[T any](fileList []string, jobs int, work func(file string) (T, error)) ([]T, error) {
+	results := make([]T, len(fileList))
+	errs := make([]error, len(fileList))
+	indexes := make(chan int)
+	group := sync.WaitGroup{}
+	for worker := 0; worker < jobsOf(jobs) && worker < len(fileList); worker++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for index := range indexes {
+				results[index], errs[index] = work(fileList[index])
+			}
+		}()
+	}
+
+	for index := range fileList {
+		indexes <- index
+	}
+
+	close(indexes)
+	group.Wait()
+	return results, errors.Join(errs...)
+}
+
+// sharedParser builds a parser the first time it is needed. Participle
+// parsers keep no state between parses, so one is shared by every worker.
+type sharedParser struct {
+	once   sync.Once
+	build  func() (files.SourceFileParser, error)
+	parser files.SourceFileParser
+	err    error
+}
+
+func (shared *sharedParser) get() (files.SourceFileParser, error) {
+	shared.once.Do(func() {
+		shared.parser, shared.err = shared.build()
+	})
+
+	return shared.parser, shared.err
+}
+
+var parsers = map[files.SourceFileType]*sharedParser{
+	files.FunctionFile: {build: generator.GetDflParser},
+	files.DataFile:     {build: generator.GetDdatParser},
+}
@@ -0,0 +1,241 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/artifact"
+	"github.com/tflexsoom/duffle/internal/backend"
+	"github.com/tflexsoom/duffle/internal/cache"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/discovery"
+	"github.com/tflexsoom/duffle/internal/emit"
+	"github.com/tflexsoom/duffle/internal/files"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/interpreter"
+	"github.com/tflexsoom/duffle/internal/optimize"
+	"github.com/tflexsoom/duffle/internal/resolve"
+	"github.com/tflexsoom/duffle/internal/runtime"
+	"github.com/tflexsoom/duffle/internal/typing"
+	"github.com/tflexsoom/duffle/internal/verify"
+)
+
+// dataFileOf gives the data file next to a function file, which holds the
+// values of its theories
+func dataFileOf(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file)) + "." + files.DATA_FILE_ENDING
+}
+
+// dataConfigsOf reads the data file of a function file, if it has one
+func dataConfigsOf(file string) ([]intermediate.DataConfig, error) {
+	dataFile := dataFileOf(file)
+	if _, err := os.Stat(dataFile); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return readDataConfigs(dataFile)
+}
+
+// lowerProgram lowers the function files of a program on a pool of workers,
+// reading the data configs next to each of them
+func lowerProgram(
+	fileMap map[files.SourceFileType][]string,
+	buildCache *cache.Cache,
+	jobs int,
+) ([]intermediate.Goal, [][]intermediate.DataConfig, error) {
+	type loadedFile struct {
+		goal    intermediate.Goal
+		configs []intermediate.DataConfig
+	}
+
+	loaded, err := forEachFile(fileMap[files.FunctionFile], jobs, func(file string) (loadedFile, error) {
+		goal, err := lowerCached(buildCache, file)
+		if err != nil {
+			return loadedFile{}, err
+		}
+
+		configs, err := dataConfigsOf(file)
+		return loadedFile{goal, configs}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	goals := make([]intermediate.Goal, 0, len(loaded))
+	configs := make([][]intermediate.DataConfig, 0, len(loaded))
+	for _, file := range loaded {
+		goals = append(goals, file.goal)
+		configs = append(configs, file.configs)
+	}
+
+	return goals, configs, nil
+}
+
+// checkProgram resolves and checks the lowered goals of a program running
+// from its entry together, in the order their files were discovered
+func checkProgram(
+	fileMap map[files.SourceFileType][]string,
+	projectLocations []string,
+	goals []intermediate.Goal,
+	configs [][]intermediate.DataConfig,
+	entry string,
+) diagnostic.Diagnostics {
+	graph := resolve.NewGraph()
+	diagnostics := diagnostic.Diagnostics{}
+	for index, file := range fileMap[files.FunctionFile] {
+		resolver := resolve.NewResolver(projectLocationOf(projectLocations, file))
+		diagnostics.Extend(graph.Resolve(resolver, file, goals[index].Imports))
+	}
+
+	diagnostics.Extend(graph.Cycles())
+	diagnostics.Extend(typing.CheckProgram(goals, configs, entry))
+	return diagnostics
+}
+
+// loadProgram lowers and checks the function files of a program running
+// from its entry
+func loadProgram(
+	fileMap map[files.SourceFileType][]string,
+	projectLocations []string,
+	entry string,
+	buildCache *cache.Cache,
+	jobs int,
+) ([]intermediate.Goal, error) {
+	goals, configs, err := lowerProgram(fileMap, buildCache, jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	if diagnostics := checkProgram(fileMap, projectLocations, goals, configs, entry); diagnostics.HasErrors() {
+		return nil, diagnostics
+	}
+
+	return goals, nil
+}
+
+// buildModule builds the optimized module of a program running from its
+// entry, or reuses the one built from the same files before. Printing passes
+// needs them to run, so it skips the cached module.
+func buildModule(projectLocations []string, entry string, filter discovery.Filter, isVerbose bool, noCache bool, jobs int, optimization optimize.Options) (intermediate.Module, error) {
+	fileMap, err := getFileMap(projectLocations, filter, isVerbose)
+	if err != nil {
+		return intermediate.Module{}, err
+	}
+
+	buildCache := openCache(projectLocations, noCache)
+	defer logCache(buildCache, isVerbose)
+
+	key, err := programKey(fileMap, entry, optimization)
+	if err != nil {
+		return intermediate.Module{}, err
+	} else if module, isCached := cachedModule(buildCache, key); isCached && len(optimization.PrintAfter) == 0 {
+		return module, nil
+	}
+
+	goals, err := loadProgram(fileMap, projectLocations, entry, buildCache, jobs)
+	if err != nil {
+		return intermediate.Module{}, err
+	}
+
+	module, diagnostics := emit.Emit(goals, entry)
+	if diagnostics.HasErrors() {
+		return intermediate.Module{}, diagnostics
+	}
+
+	if err := verify.Module(module); err != nil {
+		return intermediate.Module{}, fmt.Errorf("emitted module is invalid:\n%w", err)
+	}
+
+	if optimization.Output == nil {
+		optimization.Output = os.Stderr
+	}
+
+	optimization.Verify = true
+	if err := optimize.Optimize(&module, optimization); err != nil {
+		return intermediate.Module{}, err
+	}
+
+	data, err := module.MarshalBinary()
+	if err != nil {
+		return intermediate.Module{}, err
+	}
+
+	return module, buildCache.Put(MODULE_CACHE, key, data)
+}
+
+type RunOptions struct {
+	ProjectLocations []string
+	Args             []string
+	Stdout           io.Writer
+	GCTrace          io.Writer
+	GCStress         bool
+	Optimization     optimize.Options
+	Filter           discovery.Filter
+	NoCache          bool
+	Jobs             int
+	Verbose          bool
+}
+
+// RunModule interprets a module, giving the exit code of its entry
+func RunModule(module intermediate.Module, options RunOptions) (int, error) {
+	stdout := options.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	program, err := interpreter.New(module, stdout)
+	if err != nil {
+		return 1, err
+	}
+
+	program.Heap().Stress = options.GCStress
+	program.Heap().Trace = options.GCTrace
+	result, err := program.Run(options.Args)
+	if options.GCTrace != nil {
+		fmt.Fprintf(options.GCTrace, "gc: %v\n", program.Heap().Statistics())
+	}
+
+	if err != nil {
+		return 1, err
+	} else if result.Kind == runtime.KIND_INTEGER {
+		return int(result.Integer), nil
+	}
+
+	return 0, nil
+}
+
+// Run interprets the project given, or the project of the manifest above
+// the working directory
+func Run(options RunOptions) (int, error) {
+	current, err := findProject(options.ProjectLocations)
+	if err != nil {
+		return 1, err
+	}
+
+	module, err := buildModule(current.locations, current.manifest.Entry, options.Filter, options.Verbose, options.NoCache, options.Jobs, options.Optimization)
+	if err != nil {
+		return 1, err
+	}
+
+	return RunModule(module, options)
+}
+
+// writeModule writes a module with a backend, replacing the output only
+// once the backend succeeds
+func writeModule(module intermediate.Module, backendName string, outputLocation string) error {
+	write, err := backend.Lookup(backendName)
+	if err != nil {
+		return err
+	}
+
+	return artifact.WriteFile(outputLocation, func(writer io.Writer) error {
+		if err := write(module, writer); err != nil {
+			return fmt.Errorf("backend %v failed: %w", backendName, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,162 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/backend"
+	"github.com/tflexsoom/duffle/internal/optimize"
+	"github.com/tflexsoom/duffle/internal/packaging"
+)
+
+func TestRunFromManifestEntry(t *testing.T) {
+	project := t.TempDir()
+	writeProjectFile(t, filepath.Join(project, "main.dfl"), strings.Join([]string{
+		`@import sysout := use (dfl.sysout)`,
+		``,
+		`@exec main := sysout "main"`,
+		`@exec other := sysout "other"`,
+	}, "\n")+"\n")
+
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without paths, the project is the manifest above the working directory
+	if err := os.Chdir(project); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(workingDirectory) })
+
+	manifest := filepath.Join(project, packaging.MANIFEST_FILE_NAME)
+	writeProjectFile(t, manifest, "module.name = \"app\"\nmodule.roots = [\".\"]\n")
+	if _, err := Run(RunOptions{NoCache: true}); err == nil ||
+		!strings.Contains(err.Error(), "@exec other is a second entry point") {
+		t.Errorf("expected two unnamed entry points to fail, got %v", err)
+	}
+
+	writeProjectFile(t, manifest, "module.name = \"app\"\nmodule.roots = [\".\"]\nmodule.entry = \"other\"\n")
+	stdout := &bytes.Buffer{}
+	if _, err := Run(RunOptions{Stdout: stdout, NoCache: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(stdout.String(), "other") || strings.Contains(stdout.String(), "main") {
+		t.Errorf("expected the manifest entry other to run, got %q", stdout.String())
+	}
+
+	// typecheck reports the same entry points as run, before anything is built
+	checked := filepath.Join(t.TempDir(), "checked.txt")
+	if err := TypeCheckOnly(TypeCheckOptions{OutputLocation: checked, NoCache: true}); err != nil {
+		t.Errorf("expected the manifest entry other to typecheck, got %v", err)
+	}
+
+	writeProjectFile(t, manifest, "module.name = \"app\"\nmodule.roots = [\".\"]\nmodule.entry = \"missing\"\n")
+	if err := TypeCheckOnly(TypeCheckOptions{OutputLocation: checked, NoCache: true}); err == nil ||
+		!strings.Contains(err.Error(), "entry point missing is not an @exec function") {
+		t.Errorf("expected an entry without an @exec function to fail to typecheck, got %v", err)
+	}
+}
+
+func TestManifestAboveAGivenProject(t *testing.T) {
+	project := t.TempDir()
+	source := filepath.Join(project, "src")
+	writeProjectFile(t, filepath.Join(source, "main.dfl"), strings.Join([]string{
+		`@import sysout := use (dfl.sysout)`,
+		``,
+		`@exec main := sysout "main"`,
+		`@exec other := sysout "other"`,
+	}, "\n")+"\n")
+	writeProjectFile(t, filepath.Join(project, packaging.MANIFEST_FILE_NAME), "module.name = \"app\"\nmodule.roots = [\"src\"]\nmodule.entry = \"other\"\n")
+
+	stdout := &bytes.Buffer{}
+	if _, err := Run(RunOptions{ProjectLocations: []string{source}, Stdout: stdout, NoCache: true}); err != nil {
+		t.Fatal(err)
+	} else if stdout.String() != "other" {
+		t.Errorf("expected the entry of the manifest above the project to run, got %q", stdout.String())
+	}
+}
+
+func TestCompileBuildsTheModuleOnce(t *testing.T) {
+	project := t.TempDir()
+	writeProjectFile(t, filepath.Join(project, "main.dfl"), "@import sysout := use (dfl.sysout)\n\n@exec main := sysout \"hello\"\n")
+
+	passes := &bytes.Buffer{}
+	outputDirectory := filepath.Join(project, "build")
+	err := Compile(CompilerOptions{
+		ProjectLocations: []string{project},
+		Backends:         []string{backend.IR_BACKEND, backend.EXECUTABLE_BACKEND},
+		OutputDirectory:  outputDirectory,
+		Optimization:     optimize.Options{Level: optimize.LEVEL_O2, PrintAfter: optimize.PassNames()[:1], Output: passes},
+		NoCache:          true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if printed := strings.Count(passes.String(), "; after "); printed != 1 {
+		t.Errorf("expected the module to be built once for both backends, got %d builds", printed)
+	}
+}
+
+func TestRunFixityDeclarations(t *testing.T) {
+	project := t.TempDir()
+	writeProjectFile(t, filepath.Join(project, "main.dfl"), strings.Join([]string{
+		`@import sysout := use (dfl.sysout)`,
+		``,
+		`infixl 6 +++`,
+		`infixr 5 ^^`,
+		`infix 4 ===`,
+		``,
+		`@fact TEN := 10`,
+		`@fact THREE := 3`,
+		`@fact TWO := 2`,
+		``,
+		`@@ number +++ <number a> <number b> := a - b`,
+		`@@ number ^^ <number a> <number b> := a - b`,
+		`@@ boolean === <number a> <number b> := a = b`,
+		``,
+		`@exec main begin`,
+		`  sysout (TEN +++ THREE +++ TWO)`,
+		`  sysout (TEN ^^ THREE ^^ TWO)`,
+		`  sysout (TWO ^^ TWO === TEN)`,
+		`end`,
+	}, "\n")+"\n")
+
+	stdout := &bytes.Buffer{}
+	if _, err := Run(RunOptions{ProjectLocations: []string{project}, Stdout: stdout, NoCache: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// (10 - 3) - 2, then 10 - (3 - 2), then (2 - 2) = 10
+	if stdout.String() != "59false" {
+		t.Errorf("expected the operators to group by their fixity, got %q", stdout.String())
+	}
+}
+
+func TestTypeCheckWritesNothingUntilTheProgramPasses(t *testing.T) {
+	project := t.TempDir()
+	output := filepath.Join(project, "checked.txt")
+	writeProjectFile(t, filepath.Join(project, "words.dfl"), wordsSource)
+	writeProjectFile(t, filepath.Join(project, "main.dfl"), strings.Join([]string{
+		`@import sysout := use (dfl.sysout)`,
+		`@import GREETING := use (words.GREETING)`,
+		`@fact ONE := 1`,
+		``,
+		`@exec main := sysout (GREETING + ONE)`,
+	}, "\n")+"\n")
+
+	// Each file passes on its own, and only inference across both fails
+	err := TypeCheckOnly(TypeCheckOptions{ProjectLocations: []string{project}, OutputLocation: output, NoCache: true})
+	if err == nil || !strings.Contains(err.Error(), "operator + cannot be applied to text and integer") {
+		t.Errorf("expected the program to fail to typecheck, got %v", err)
+	}
+
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("expected no output for a program that failed, got %v", err)
+	}
+}
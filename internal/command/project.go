@@ -0,0 +1,157 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tflexsoom/duffle/internal/backend"
+	"github.com/tflexsoom/duffle/internal/optimize"
+	"github.com/tflexsoom/duffle/internal/packaging"
+)
+
+// DEFAULT_OUTPUT_LOCATION is written when neither the command line nor a
+// manifest name an output. Builds of several backends write into
+// DEFAULT_OUTPUT_DIRECTORY instead, next to the manifest if there is one.
+const (
+	DEFAULT_OUTPUT_LOCATION  = "./a.out"
+	DEFAULT_OUTPUT_DIRECTORY = "build"
+	DEFAULT_ARTIFACT_NAME    = "a.out"
+)
+
+// project is what a command builds: the paths it was given, or the roots
+// of the manifest found above the working directory when it was given none
+type project struct {
+	locations []string
+	// directory holds the manifest, and is empty when there is none
+	directory string
+	manifest  packaging.Manifest
+}
+
+// findProject reads the manifest in or above the first path given, or else
+// above the working directory. Paths that were given are built instead of
+// the roots of their manifest, and need not have one.
+func findProject(projectLocations []string) (project, error) {
+	if len(projectLocations) > 0 {
+		file, err := packaging.FindManifest(projectLocations[0])
+		if errors.Is(err, packaging.ErrNoManifest) {
+			return project{locations: projectLocations}, nil
+		} else if err != nil {
+			return project{}, err
+		}
+
+		current, err := readProject(file)
+		if err != nil {
+			return project{}, err
+		}
+
+		current.locations = projectLocations
+		return current, nil
+	}
+
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		return project{}, err
+	}
+
+	file, err := packaging.FindManifest(workingDirectory)
+	if err != nil {
+		return project{}, fmt.Errorf("no project was given and %w", err)
+	}
+
+	return readProject(file)
+}
+
+// readProject reads a manifest along with the roots it names
+func readProject(file string) (project, error) {
+	directory := filepath.Dir(file)
+	manifest, err := readManifest(directory)
+	if err != nil {
+		return project{}, err
+	}
+
+	for backendName := range manifest.Targets {
+		if _, err := backend.Lookup(backendName); err != nil {
+			return project{}, fmt.Errorf("%v: %w", file, err)
+		}
+	}
+
+	locations := make([]string, 0, len(manifest.Roots))
+	for _, root := range manifest.Roots {
+		locations = append(locations, filepath.Join(directory, filepath.FromSlash(root)))
+	}
+
+	return project{locations: locations, directory: directory, manifest: manifest}, nil
+}
+
+// backendsOf gives the backends to build with, the ones given on the
+// command line or else the defaults of the manifest
+func (current project) backendsOf(backendNames []string) []string {
+	if len(backendNames) > 0 {
+		return backendNames
+	} else if len(current.manifest.Backends) > 0 {
+		return current.manifest.Backends
+	}
+
+	return []string{backend.DEFAULT_BACKEND}
+}
+
+// outputOf gives where a backend writes, the path given on the command
+// line or else the output the manifest sets for the backend
+func (current project) outputOf(backendName string, outputLocation string) string {
+	if outputLocation != "" {
+		return outputLocation
+	}
+
+	if backendName == "" {
+		backendName = backend.DEFAULT_BACKEND
+	}
+
+	if target, isOk := current.manifest.Targets[backendName]; isOk && target.Output != "" {
+		return filepath.Join(current.directory, filepath.FromSlash(target.Output))
+	}
+
+	return DEFAULT_OUTPUT_LOCATION
+}
+
+// outputDirectoryOf gives where builds of several backends write
+func (current project) outputDirectoryOf(outputDirectory string) string {
+	if outputDirectory != "" {
+		return outputDirectory
+	}
+
+	return filepath.Join(current.directory, DEFAULT_OUTPUT_DIRECTORY)
+}
+
+// artifactNameOf names the file a backend writes in its directory, after
+// the output the manifest sets for it or else the module
+func (current project) artifactNameOf(backendName string) string {
+	if target, isOk := current.manifest.Targets[backendName]; isOk && target.Output != "" {
+		return filepath.Base(filepath.FromSlash(target.Output))
+	} else if current.manifest.Name != "" {
+		return current.manifest.Name
+	}
+
+	return DEFAULT_ARTIFACT_NAME
+}
+
+// optimizationOf applies the level the manifest sets for a backend, unless
+// one was given on the command line
+func (current project) optimizationOf(backendName string, optimization optimize.Options, isDefault bool) (optimize.Options, error) {
+	if backendName == "" {
+		backendName = backend.DEFAULT_BACKEND
+	}
+
+	target, isOk := current.manifest.Targets[backendName]
+	if !isDefault || !isOk || target.Optimize == nil {
+		return optimization, nil
+	}
+
+	optimization.Level = optimize.Level(*target.Optimize)
+	if _, err := optimize.PassesOf(optimization.Level); err != nil {
+		return optimization, fmt.Errorf("%v.%v: %w", backendName, packaging.OPTIMIZE_CONFIG, err)
+	}
+
+	return optimization, nil
+}
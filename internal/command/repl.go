@@ -0,0 +1,87 @@
+package command
+
+import (
+	"io"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/discovery"
+	"github.com/tflexsoom/duffle/internal/files"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/repl"
+)
+
+type ReplOptions struct {
+	ProjectLocations []string
+	Input            io.Reader
+	Output           io.Writer
+	HistoryFile      string
+	Verbose          bool
+}
+
+// lowerSource lowers function source typed into the repl
+func lowerSource(file string, source string) (intermediate.Goal, error) {
+	parser, err := parsers[files.FunctionFile].get()
+	if err != nil {
+		return intermediate.Goal{}, err
+	}
+
+	ast, err := parser.ParseSourceFile(file, strings.NewReader(source))
+	if err != nil {
+		return intermediate.Goal{}, err
+	}
+
+	return lowerAST(ast)
+}
+
+// loadProject lowers the function files of a project for the repl, through
+// the build cache of the project
+func loadProject(location string, isVerbose bool) ([]intermediate.Goal, [][]intermediate.DataConfig, error) {
+	projectLocations := []string{location}
+	fileMap, err := getFileMap(projectLocations, discovery.Filter{}, isVerbose)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buildCache := openCache(projectLocations, false)
+	defer logCache(buildCache, isVerbose)
+
+	goals := make([]intermediate.Goal, 0, len(fileMap[files.FunctionFile]))
+	configs := make([][]intermediate.DataConfig, 0, len(fileMap[files.FunctionFile]))
+	for _, file := range fileMap[files.FunctionFile] {
+		goal, err := lowerCached(buildCache, file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		goalConfigs, err := dataConfigsOf(file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		goals = append(goals, goal)
+		configs = append(configs, goalConfigs)
+	}
+
+	return goals, configs, nil
+}
+
+// Repl runs an interactive session with the given projects loaded
+func Repl(options ReplOptions) error {
+	session := repl.New(repl.Options{
+		Lower: lowerSource,
+		Load: func(location string) ([]intermediate.Goal, [][]intermediate.DataConfig, error) {
+			return loadProject(location, options.Verbose)
+		},
+		Input:       options.Input,
+		Output:      options.Output,
+		HistoryFile: options.HistoryFile,
+	})
+
+	for _, location := range options.ProjectLocations {
+		if err := session.Load(location); err != nil {
+			return err
+		}
+	}
+
+	return session.Run()
+}
@@ -0,0 +1,118 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/tflexsoom/duffle/internal/discovery"
+	"github.com/tflexsoom/duffle/internal/emit"
+	"github.com/tflexsoom/duffle/internal/testrun"
+	"github.com/tflexsoom/duffle/internal/verify"
+)
+
+type TestOptions struct {
+	ProjectLocations []string
+	Run              string
+	Format           string
+	ReportLocation   string
+	Update           bool
+	Filter           discovery.Filter
+	NoCache          bool
+	Jobs             int
+	Verbose          bool
+}
+
+// Test runs the @test functions and golden files of every project, each
+// project as a suite of its own
+func Test(options TestOptions) error {
+	var filter *regexp.Regexp
+	if options.Run != "" {
+		var err error
+		if filter, err = regexp.Compile(options.Run); err != nil {
+			return fmt.Errorf("invalid --run pattern: %w", err)
+		}
+	}
+
+	var report io.Writer = os.Stdout
+	if options.ReportLocation != "" {
+		reportFile, err := os.Create(options.ReportLocation)
+		if err != nil {
+			return err
+		}
+		defer reportFile.Close()
+
+		report = reportFile
+	}
+
+	failed, total := 0, 0
+	for _, location := range options.ProjectLocations {
+		suite, err := testSuite(location, options)
+		if err != nil {
+			return err
+		}
+
+		results := testrun.Run(suite, testrun.Options{Filter: filter, Jobs: options.Jobs, Update: options.Update})
+		if err := testrun.Report(report, options.Format, filepath.Base(filepath.Clean(location)), results); err != nil {
+			return err
+		}
+
+		failed += testrun.Failed(results)
+		total += len(results)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tests failed", failed, total)
+	}
+
+	return nil
+}
+
+// testSuite builds a project without requiring an entry point, and
+// without optimizing it, since that would drop the tests nothing calls.
+// Golden files run from the entry its manifest names, if it has one.
+func testSuite(location string, options TestOptions) (testrun.Suite, error) {
+	current, err := findProject([]string{location})
+	if err != nil {
+		return testrun.Suite{}, err
+	}
+
+	projectLocations := current.locations
+	fileMap, err := getFileMap(projectLocations, options.Filter, options.Verbose)
+	if err != nil {
+		return testrun.Suite{}, err
+	}
+
+	buildCache := openCache(projectLocations, options.NoCache)
+	defer logCache(buildCache, options.Verbose)
+
+	goals, err := loadProgram(fileMap, projectLocations, current.manifest.Entry, buildCache, options.Jobs)
+	if err != nil {
+		return testrun.Suite{}, err
+	}
+
+	tests, entry := testrun.Discover(goals)
+	if current.manifest.Entry != "" {
+		entry = current.manifest.Entry
+	}
+
+	goldens, err := testrun.FindGoldens(location)
+	if err != nil {
+		return testrun.Suite{}, err
+	} else if len(tests) == 0 && len(goldens) == 0 {
+		return testrun.Suite{}, fmt.Errorf("no tests or golden files were found in %v", location)
+	}
+
+	module, diagnostics := emit.EmitLibrary(goals)
+	if diagnostics.HasErrors() {
+		return testrun.Suite{}, diagnostics
+	}
+
+	if err := verify.Module(module); err != nil {
+		return testrun.Suite{}, fmt.Errorf("emitted module is invalid:\n%w", err)
+	}
+
+	return testrun.Suite{Module: module, Entry: entry, Tests: tests, Goldens: goldens}, nil
+}
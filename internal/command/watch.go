@@ -0,0 +1,60 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tflexsoom/duffle/internal/watch"
+)
+
+type WatchOptions struct {
+	ProjectLocations []string
+	Interval         time.Duration
+	Debounce         time.Duration
+	Output           io.Writer
+	// Build runs the chosen pipeline. Its results stay in the build cache,
+	// so a rebuild only redoes the files that changed.
+	Build func() error
+}
+
+// Watch rebuilds the projects whenever their files change until the context
+// is done, printing only the diagnostics each rebuild adds or resolves
+func Watch(ctx context.Context, options WatchOptions) error {
+	output := options.Output
+	if output == nil {
+		output = os.Stderr
+	}
+
+	report := watch.Report{}
+	watcher := watch.Watcher{
+		Locations: options.ProjectLocations,
+		Interval:  options.Interval,
+		Debounce:  options.Debounce,
+	}
+
+	return watcher.Watch(ctx, func(changed []string) {
+		if len(changed) > 0 {
+			fmt.Fprintf(output, "changed: %v\n", strings.Join(changed, ", "))
+		}
+
+		err := options.Build()
+		added, resolved := report.Update(err)
+		for _, message := range added {
+			fmt.Fprintln(output, message)
+		}
+
+		for _, message := range resolved {
+			fmt.Fprintf(output, "resolved: %v\n", message)
+		}
+
+		if err == nil {
+			fmt.Fprintln(output, "build succeeded")
+		} else {
+			fmt.Fprintf(output, "build failed: %d new, %d resolved\n", len(added), len(resolved))
+		}
+	})
+}
@@ -1 +1,227 @@
 package compile
+
+import (
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/fixity"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/language/function"
+)
+
+type lowering struct {
+	goal        intermediate.Goal
+	diagnostics diagnostic.Diagnostics
+}
+
+func LowerModule(module function.Module) (intermediate.Goal, diagnostic.Diagnostics) {
+	state := lowering{
+		goal:        intermediate.NewGoal(),
+		diagnostics: diagnostic.Diagnostics{},
+	}
+
+	for _, modulePart := range module.ModuleParts {
+		switch part := modulePart.(type) {
+		case function.ImportModulePart:
+			state.lowerImports(part)
+		case function.FunctionModulePart:
+			for _, fn := range part.Functions {
+				state.defineSentiment(state.lowerFunction(fn))
+			}
+		case function.StructModulePart:
+			for _, structure := range part.Structs {
+				state.lowerStruct(structure)
+			}
+		case function.OperatorModulePart:
+			for _, declaration := range part.Declarations {
+				state.lowerFixity(declaration)
+			}
+		}
+	}
+
+	return state.goal, state.diagnostics
+}
+
+func TypeName(fnType function.Type) string {
+	if len(fnType.Generics) == 0 {
+		return fnType.Name
+	}
+
+	generics := make([]string, 0, len(fnType.Generics))
+	for _, generic := range fnType.Generics {
+		generics = append(generics, TypeName(generic))
+	}
+
+	return fnType.Name + "[" + strings.Join(generics, ", ") + "]"
+}
+
+func (state *lowering) lowerFunction(fn function.Function) intermediate.Sentiment {
+	sentiment := intermediate.Sentiment{
+		Position:    fn.Pos,
+		Annotations: []string{},
+		Name:        fn.Name.Name,
+		Inputs:      make([]intermediate.SentimentInput, 0, len(fn.Inputs)),
+	}
+
+	if fn.Name.IsOperator {
+		state.checkOperatorFunction(fn)
+	}
+
+	if fn.Annotation != nil {
+		sentiment.Annotations = append(sentiment.Annotations, *fn.Annotation)
+	}
+
+	if fn.Type.Name != "" {
+		sentiment.Result = state.goal.TypeIdOf(TypeName(fn.Type))
+	}
+
+	for _, param := range fn.Generics {
+		sentiment.TypeParams = append(sentiment.TypeParams, intermediate.TypeParam{
+			Position: param.Pos,
+			Name:     param.Name,
+			Bounds:   param.Bounds,
+		})
+	}
+
+	for _, input := range fn.Inputs {
+		sentiment.Inputs = append(sentiment.Inputs, intermediate.SentimentInput{
+			Name:   input.Name,
+			TypeId: state.goal.TypeIdOf(TypeName(input.Type)),
+		})
+	}
+
+	switch definition := fn.Definition.(type) {
+	case function.ConstexprDefinition:
+		sentiment.Definition = state.lowerConstexprDefinition(definition)
+	case function.BlockDefinition:
+		sentiment.Definition = state.lowerBlock(definition.Pos, definition.Instructions)
+	case function.PatternDefinition:
+		sentiment.Definition = state.lowerPatternDefinition(fn, definition)
+	}
+
+	return sentiment
+}
+
+func (state *lowering) lowerStruct(structure function.Struct) {
+	if defined, exists := state.goal.Structs[structure.Name]; exists {
+		state.diagnostics.Errorf(structure.Pos, "struct %v is already defined", structure.Name).
+			WithRelated(defined.Position, "%v is first defined here", structure.Name)
+		return
+	}
+
+	lowered := intermediate.Struct{
+		Position: structure.Pos,
+		Name:     structure.Name,
+		Fields:   make([]intermediate.StructField, 0, len(structure.Fields)),
+	}
+
+	for _, field := range structure.Fields {
+		if lowered.FieldIndex(field.Name) >= 0 {
+			state.diagnostics.Errorf(field.Pos, "struct %v has more than one field named %v", structure.Name, field.Name)
+			continue
+		}
+
+		lowered.Fields = append(lowered.Fields, intermediate.StructField{
+			Name:   field.Name,
+			TypeId: state.goal.TypeIdOf(TypeName(field.Type)),
+		})
+	}
+
+	state.goal.TypeIdOf(structure.Name)
+	state.goal.Structs[structure.Name] = lowered
+}
+
+func (state *lowering) lowerFixity(declaration function.FixityDeclaration) {
+	if declaration.Precedence < fixity.MIN_PRECEDENCE || declaration.Precedence > fixity.MAX_PRECEDENCE {
+		state.diagnostics.Errorf(
+			declaration.Pos,
+			"precedence %d is out of range, operators are declared from %d to %d",
+			declaration.Precedence, fixity.MIN_PRECEDENCE, fixity.MAX_PRECEDENCE,
+		)
+		return
+	}
+
+	for _, operator := range declaration.Operators {
+		if fixity.IsBuiltin(operator) {
+			state.diagnostics.Errorf(declaration.Pos, "operator %v is built in and its fixity cannot be declared", operator)
+			continue
+		} else if declared, exists := state.goal.Operators[operator]; exists {
+			state.diagnostics.Errorf(declaration.Pos, "fixity of operator %v is already declared", operator).
+				WithRelated(declared.Position, "%v is first declared here", operator)
+			continue
+		}
+
+		state.goal.Operators[operator] = intermediate.Fixity{
+			Position:      declaration.Pos,
+			Operator:      operator,
+			Associativity: fixity.AssociativityOf(declaration.Fixity),
+			Precedence:    declaration.Precedence,
+		}
+	}
+}
+
+// Operators are functions of their two operands
+func (state *lowering) checkOperatorFunction(fn function.Function) {
+	inputs := len(fn.Inputs)
+	if patterns, isPattern := fn.Definition.(function.PatternDefinition); isPattern && inputs == 0 && len(patterns.Patterns) > 0 {
+		inputs = len(patterns.Patterns[0].Params)
+	}
+
+	if fixity.IsBuiltin(fn.Name.Name) {
+		state.diagnostics.Errorf(fn.Pos, "operator %v is built in and cannot be redefined", fn.Name.Name)
+	} else if inputs != 2 {
+		state.diagnostics.Errorf(fn.Pos, "operator %v takes %d inputs but operators take exactly 2", fn.Name.Name, inputs)
+	}
+}
+
+func (state *lowering) lowerImports(part function.ImportModulePart) {
+	for _, imported := range part.Imports {
+		for _, path := range imported.ImportVal() {
+			parts := strings.Split(path, ".")
+			state.goal.Imports = append(state.goal.Imports, intermediate.Import{
+				Position: imported.Position(),
+				Alias:    parts[len(parts)-1],
+				Path:     parts,
+			})
+		}
+	}
+}
+
+// defineSentiment adds a lowered function to the goal, keeping the first of
+// two definitions of a name. Names an import binds are not checked here, as
+// imports resolve apart from the functions of the goal, and checking the
+// bindings reports an alias that is rebound.
+func (state *lowering) defineSentiment(sentiment intermediate.Sentiment) {
+	isImport := isImportBinding(sentiment)
+	if isImport {
+		state.recordImportBinding(sentiment)
+	}
+
+	previous, isDefined := state.goal.Sentments[sentiment.Name]
+	if isDefined && !isImport && !isImportBinding(previous) {
+		state.diagnostics.Errorf(sentiment.Position, "%v is already defined", sentiment.Name).
+			WithRelated(previous.Position, "%v was first defined here", sentiment.Name)
+		return
+	}
+
+	state.goal.Sentments[sentiment.Name] = sentiment
+}
+
+// isImportBinding tells a function defined as name := use (path)
+func isImportBinding(sentiment intermediate.Sentiment) bool {
+	if sentiment.Definition == nil || sentiment.Definition.GetValue().Op != intermediate.OPCODE_CONSTEXPR {
+		return false
+	}
+
+	children := sentiment.Definition.GetChildren()
+	return len(children) == 1 && children[0].GetValue().Op == intermediate.OPCODE_USE
+}
+
+// @import name := use (path) binds the used module to an explicit alias
+func (state *lowering) recordImportBinding(sentiment intermediate.Sentiment) {
+	state.goal.Imports = append(state.goal.Imports, intermediate.Import{
+		Position: sentiment.Position,
+		Alias:    sentiment.Name,
+		Path:     sentiment.Definition.GetChildren()[0].GetValue().Value,
+	})
+}
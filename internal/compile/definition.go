@@ -0,0 +1,147 @@
+package compile
+
+import (
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/language/function"
+)
+
+func (state *lowering) lowerConstexprDefinition(definition function.ConstexprDefinition) expressionTree {
+	root := newNode(definition.Pos, intermediate.OPCODE_CONSTEXPR)
+	for _, expression := range definition.Constexpr {
+		container.AddChildren(root, state.lowerConstexpr(expression))
+	}
+
+	return root
+}
+
+func (state *lowering) lowerConstexpr(expression function.ConstexprExpression) expressionTree {
+	atoms := make([]expressionTree, 0, 4)
+	for expression != nil {
+		switch current := expression.(type) {
+		case function.ConstexprReferenceExpression:
+			for _, reference := range current.ReferenceGroup {
+				atoms = append(atoms, newNode(current.Pos, intermediate.OPCODE_REF, reference))
+			}
+			expression = current.NextExecution
+		case function.ConstexprOperatorExpression:
+			atoms = append(atoms, newNode(current.Pos, intermediate.OPCODE_OPERATOR, current.ReferenceGroup...))
+			expression = current.NextExecution
+		case function.ConstexprParentheticalExpression:
+			atoms = append(atoms, state.lowerConstexpr(current.Execution))
+			expression = current.NextExecution
+		case function.ConstexprCaptureExpression:
+			capture := newNode(current.Pos, intermediate.OPCODE_CAPTURE)
+			container.AddChildren(capture, state.lowerConstexpr(current.Execution))
+			atoms = append(atoms, capture)
+			expression = current.NextExecution
+		case function.UseExpression:
+			atoms = append(atoms, newNode(current.Pos, intermediate.OPCODE_USE, current.Path...))
+			expression = current.NextExecution
+		case function.LiteralExpression:
+			typeId, text, isOk := literalOf(current.Value)
+			if !isOk {
+				state.diagnostics.Errorf(current.Pos, "unsupported literal %T", current.Value)
+			}
+
+			atoms = append(atoms, newTypedNode(current.Pos, typeId, intermediate.OPCODE_CONST, text))
+			expression = nil
+		default:
+			state.diagnostics.Errorf(expression.Position(), "unsupported constant expression %T", expression)
+			expression = nil
+		}
+	}
+
+	return state.lowerChain(atoms)
+}
+
+func (state *lowering) lowerBlock(position lexer.Position, instructions []function.BlockExpression) expressionTree {
+	block := newNode(position, intermediate.OPCODE_BLOCK)
+	for _, instruction := range instructions {
+		container.AddChildren(block, state.lowerBlockExpression(instruction))
+	}
+
+	return block
+}
+
+func (state *lowering) lowerInlineBlock(position lexer.Position, instructions []function.InlineExpression) expressionTree {
+	block := newNode(position, intermediate.OPCODE_BLOCK)
+	for _, instruction := range instructions {
+		container.AddChildren(block, state.lowerInline(instruction))
+	}
+
+	return block
+}
+
+func (state *lowering) lowerBlockExpression(expression function.BlockExpression) expressionTree {
+	switch current := expression.(type) {
+	case function.LabelExpression:
+		label := newNode(current.Pos, intermediate.OPCODE_LABEL, current.Label)
+		container.AddChildren(label, state.lowerInline(current.Resolution))
+		return label
+	case function.InlineConditionalExpression:
+		conditional := newNode(current.Pos, intermediate.OPCODE_IF)
+		container.AddChildren(conditional, state.lowerInline(current.Condition))
+		container.AddChildren(conditional, state.lowerInlineBlock(
+			current.ConditionExecution.Position(),
+			[]function.InlineExpression{current.ConditionExecution},
+		))
+		return conditional
+	case function.BlockConditionalExpression:
+		return state.lowerBlockConditional(current)
+	case function.BlockCaptureExpression:
+		inputs := make([]string, 0, len(current.Inputs))
+		inputTypes := make([]string, 0, len(current.Inputs)+1)
+		for _, input := range current.Inputs {
+			inputs = append(inputs, input.Name)
+			inputTypes = append(inputTypes, TypeName(input.Type))
+		}
+
+		// Captures declaring their result are typed as a whole, otherwise
+		// the result is left to inference
+		typeId := intermediate.TYPEID_NO_TYPE
+		if current.Type.Name != "" {
+			inputTypes = append(inputTypes, TypeName(current.Type))
+			typeId = state.goal.TypeIdOf("Function[" + strings.Join(inputTypes, ", ") + "]")
+		}
+
+		capture := newTypedNode(current.Pos, typeId, intermediate.OPCODE_CAPTURE, inputs...)
+		container.AddChildren(capture, state.lowerBlock(current.Pos, current.Instructions))
+		return capture
+	case function.InlineExpression:
+		return state.lowerInline(current)
+	}
+
+	state.diagnostics.Errorf(expression.Position(), "unsupported block expression %T", expression)
+	return newNode(expression.Position(), intermediate.OPCODE_NOOP)
+}
+
+func (state *lowering) lowerBlockConditional(expression function.BlockConditionalExpression) expressionTree {
+	conditional := newNode(expression.Pos, intermediate.OPCODE_IF)
+	container.AddChildren(conditional, state.lowerBlockExpression(expression.Condition))
+	container.AddChildren(conditional, state.lowerInlineBlock(expression.Pos, expression.Execution))
+
+	// Else-if branches nest as the alternative block of the previous branch
+	alternative := state.lowerInlineBlock(expression.Pos, expression.Alternative)
+	for i := len(expression.SubConditional) - 1; i >= 0; i-- {
+		sub := expression.SubConditional[i]
+		nested := newNode(sub.Pos, intermediate.OPCODE_IF)
+		container.AddChildren(nested, state.lowerInline(sub.Condition))
+		container.AddChildren(nested, state.lowerInlineBlock(sub.Pos, sub.Execution))
+		if len(alternative.GetChildren()) > 0 {
+			container.AddChildren(nested, alternative)
+		}
+
+		alternative = newNode(sub.Pos, intermediate.OPCODE_BLOCK)
+		container.AddChildren(alternative, nested)
+	}
+
+	if len(alternative.GetChildren()) > 0 {
+		container.AddChildren(conditional, alternative)
+	}
+
+	return conditional
+}
@@ -0,0 +1,155 @@
+package compile
+
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/language/function"
+	"github.com/tflexsoom/duffle/internal/parsing/util"
+)
+
+type expressionTree = container.Tree[intermediate.SenimentExpression]
+
+func newNode(position lexer.Position, op intermediate.OpCode, value ...string) expressionTree {
+	return newTypedNode(position, intermediate.TYPEID_NO_TYPE, op, value...)
+}
+
+func newTypedNode(
+	position lexer.Position,
+	typeId intermediate.TypeId,
+	op intermediate.OpCode,
+	value ...string,
+) expressionTree {
+	return intermediate.NewExpressionTree(intermediate.SenimentExpression{
+		Position: position,
+		TypeId:   typeId,
+		Op:       op,
+		Value:    value,
+	})
+}
+
+func literalOf(value util.Value) (intermediate.TypeId, string, bool) {
+	switch literal := value.(type) {
+	case util.BoolGrammar:
+		return intermediate.TYPEID_BOOLEAN, literal.Val, true
+	case util.FloatGrammar:
+		return intermediate.TYPEID_DECIMAL, literal.Val, true
+	case util.IntGrammar:
+		return intermediate.TYPEID_INTEGER, literal.Val, true
+	case util.StringGrammar:
+		return intermediate.TYPEID_TEXT, literal.Val, true
+	case function.Char:
+		return intermediate.TYPEID_CHAR, literal.Val, true
+	}
+
+	return intermediate.TYPEID_NO_TYPE, "", false
+}
+
+// Inline expressions are parsed as a flat chain of atoms. Juxtaposed atoms are
+// applications while operators split the chain into operands.
+func (state *lowering) lowerInline(expression function.InlineExpression) expressionTree {
+	atoms := make([]expressionTree, 0, 4)
+	for expression != nil {
+		switch current := expression.(type) {
+		case function.ReferenceExpression:
+			for _, reference := range current.ReferenceGroup {
+				atoms = append(atoms, newNode(current.Pos, intermediate.OPCODE_REF, reference))
+			}
+			expression = current.NextExecution
+		case function.OperatorExpression:
+			atoms = append(atoms, newNode(current.Pos, intermediate.OPCODE_OPERATOR, current.ReferenceGroup...))
+			expression = current.NextExecution
+		case function.ParentheticalExpression:
+			atoms = append(atoms, state.lowerInline(current.Execution))
+			expression = current.NextExecution
+		case function.InlineCaptureExpression:
+			capture := newNode(current.Pos, intermediate.OPCODE_CAPTURE)
+			container.AddChildren(capture, state.lowerInline(current.Execution))
+			atoms = append(atoms, capture)
+			expression = current.NextExecution
+		case function.BlockCaptureExpression:
+			// Block captures end the chain since the block owns everything up to end
+			atoms = append(atoms, state.lowerBlockExpression(current))
+			expression = nil
+		default:
+			state.diagnostics.Errorf(expression.Position(), "unsupported expression %T", expression)
+			expression = nil
+		}
+	}
+
+	if len(atoms) > 0 && isReturn(atoms[0]) {
+		result := newNode(atoms[0].GetValue().Position, intermediate.OPCODE_RETURN)
+		if len(atoms) > 1 {
+			container.AddChildren(result, state.lowerChain(atoms[1:]))
+		}
+
+		return result
+	}
+
+	return state.lowerChain(atoms)
+}
+
+func isReturn(atom expressionTree) bool {
+	value := atom.GetValue()
+	return value.Op == intermediate.OPCODE_REF && value.Value[0] == intermediate.RETURN_REFERENCE
+}
+
+func (state *lowering) lowerChain(atoms []expressionTree) expressionTree {
+	if len(atoms) == 0 {
+		return newNode(lexer.Position{}, intermediate.OPCODE_NOOP)
+	}
+
+	operands := make([]expressionTree, 0, len(atoms))
+	operators := make([]expressionTree, 0, len(atoms)/2)
+	group := make([]expressionTree, 0, len(atoms))
+	for _, atom := range atoms {
+		if atom.GetValue().Op != intermediate.OPCODE_OPERATOR {
+			group = append(group, atom)
+			continue
+		}
+
+		if len(group) == 0 {
+			state.diagnostics.Errorf(atom.GetValue().Position, "operator %v is missing its left operand", atom.GetValue().Value[0])
+		}
+
+		operands = append(operands, applicationOf(group))
+		operators = append(operators, atom)
+		group = make([]expressionTree, 0, len(atoms))
+	}
+
+	if len(group) == 0 {
+		last := atoms[len(atoms)-1].GetValue()
+		state.diagnostics.Errorf(last.Position, "operator %v is missing its right operand", last.Value[0])
+	}
+
+	operands = append(operands, applicationOf(group))
+	if len(operators) == 0 {
+		return operands[0]
+	}
+
+	chain := newNode(atoms[0].GetValue().Position, intermediate.OPCODE_CHAIN)
+	for index, operand := range operands {
+		if index > 0 {
+			container.AddChildren(chain, operators[index-1])
+		}
+
+		container.AddChildren(chain, operand)
+	}
+
+	return chain
+}
+
+func applicationOf(group []expressionTree) expressionTree {
+	if len(group) == 0 {
+		return newNode(lexer.Position{}, intermediate.OPCODE_NOOP)
+	} else if len(group) == 1 {
+		return group[0]
+	}
+
+	call := newNode(group[0].GetValue().Position, intermediate.OPCODE_CALL)
+	for _, atom := range group {
+		container.AddChildren(call, atom)
+	}
+
+	return call
+}
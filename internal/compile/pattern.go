@@ -0,0 +1,89 @@
+package compile
+
+import (
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/language/function"
+)
+
+func (state *lowering) lowerPatternDefinition(
+	fn function.Function,
+	definition function.PatternDefinition,
+) expressionTree {
+	evals := newNode(definition.Pos, intermediate.OPCODE_EVALS)
+	arity := len(fn.Inputs)
+	if arity == 0 && len(definition.Patterns) > 0 {
+		arity = len(definition.Patterns[0].Params)
+	}
+
+	for _, clause := range definition.Patterns {
+		if clause.Name != fn.Name.Name {
+			state.diagnostics.Errorf(
+				clause.Pos,
+				"clause for %v found in the definition of %v",
+				clause.Name,
+				fn.Name.Name,
+			).WithRelated(fn.Pos, "%v is defined here", fn.Name.Name)
+		}
+
+		if len(clause.Params) != arity {
+			state.diagnostics.Errorf(
+				clause.Pos,
+				"clause of %v takes %d patterns but %d are expected",
+				fn.Name.Name,
+				len(clause.Params),
+				arity,
+			)
+		}
+
+		clauseTree := newNode(clause.Pos, intermediate.OPCODE_CLAUSE, clause.Name)
+		for _, param := range clause.Params {
+			container.AddChildren(clauseTree, state.lowerPattern(param))
+		}
+
+		container.AddChildren(clauseTree, state.lowerInline(clause.Definition))
+		container.AddChildren(evals, clauseTree)
+	}
+
+	return evals
+}
+
+func (state *lowering) lowerPattern(param function.PatternParam) expressionTree {
+	switch current := param.(type) {
+	case function.WildcardPattern:
+		return newNode(current.Pos, intermediate.OPCODE_PATTERN_WILDCARD)
+	case function.BindingPattern:
+		return newNode(current.Pos, intermediate.OPCODE_PATTERN_BIND, current.Name)
+	case function.LiteralPattern:
+		typeId, text, isOk := literalOf(current.Value)
+		if !isOk {
+			state.diagnostics.Errorf(current.Pos, "unsupported literal pattern %T", current.Value)
+		}
+
+		return newTypedNode(current.Pos, typeId, intermediate.OPCODE_PATTERN_LITERAL, text)
+	case function.StructPattern:
+		node := newNode(current.Pos, intermediate.OPCODE_PATTERN_CONSTRUCTOR, current.Name)
+		for _, field := range current.Fields {
+			container.AddChildren(node, state.lowerPattern(field))
+		}
+
+		return node
+	case function.ListPattern:
+		tail := newNode(current.Pos, intermediate.OPCODE_PATTERN_CONSTRUCTOR, intermediate.LIST_EMPTY_CONSTRUCTOR)
+		if current.Tail != nil {
+			tail = state.lowerPattern(current.Tail)
+		}
+
+		for i := len(current.Elements) - 1; i >= 0; i-- {
+			cons := newNode(current.Elements[i].Position(), intermediate.OPCODE_PATTERN_CONSTRUCTOR, intermediate.LIST_CONS_CONSTRUCTOR)
+			container.AddChildren(cons, state.lowerPattern(current.Elements[i]))
+			container.AddChildren(cons, tail)
+			tail = cons
+		}
+
+		return tail
+	}
+
+	state.diagnostics.Errorf(param.Position(), "unsupported pattern %T", param)
+	return newNode(param.Position(), intermediate.OPCODE_PATTERN_WILDCARD)
+}
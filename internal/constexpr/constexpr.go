@@ -0,0 +1,178 @@
+package constexpr
+
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+const LIST_OF_REFERENCE = "listOf"
+
+type visitState int
+
+const (
+	STATE_UNVISITED visitState = iota
+	STATE_VISITING
+	STATE_VISITED
+)
+
+type evaluator struct {
+	goal        intermediate.Goal
+	overrides   map[string]intermediate.DataConfig
+	states      map[string]visitState
+	stack       []intermediate.Sentiment
+	diagnostics diagnostic.Diagnostics
+}
+
+func IsConstant(sentiment intermediate.Sentiment) bool {
+	for _, name := range sentiment.Annotations {
+		if name == annotation.FACT_ANNOTATION || name == annotation.THEORY_ANNOTATION {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isTheory(sentiment intermediate.Sentiment) bool {
+	for _, name := range sentiment.Annotations {
+		if name == annotation.THEORY_ANNOTATION {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Fold evaluates every @fact and @theory of the goal and publishes the
+// results in goal.Constants. Data file assignments override theories and
+// provide the contents of listOf facts.
+func Fold(goal intermediate.Goal, configs []intermediate.DataConfig) diagnostic.Diagnostics {
+	state := evaluator{
+		goal:        goal,
+		overrides:   make(map[string]intermediate.DataConfig, len(configs)),
+		states:      make(map[string]visitState),
+		stack:       make([]intermediate.Sentiment, 0, 8),
+		diagnostics: diagnostic.Diagnostics{},
+	}
+
+	for _, config := range configs {
+		state.overrides[config.SecondName] = config
+	}
+
+	for _, sentiment := range annotation.SentimentsOf(goal) {
+		if IsConstant(sentiment) {
+			state.evaluateName(sentiment.Name, sentiment.Position)
+		}
+	}
+
+	return state.diagnostics
+}
+
+func (state *evaluator) evaluateName(name string, position lexer.Position) (intermediate.Literal, bool) {
+	switch state.states[name] {
+	case STATE_VISITED:
+		value, isOk := state.goal.Constants[name]
+		return value, isOk
+	case STATE_VISITING:
+		state.reportCycle(name, position)
+		return intermediate.Literal{}, false
+	}
+
+	sentiment, isOk := state.goal.Sentments[name]
+	if !isOk || !IsConstant(sentiment) || sentiment.Definition == nil ||
+		sentiment.Definition.GetValue().Op != intermediate.OPCODE_CONSTEXPR {
+		state.diagnostics.Errorf(position, "%v is not a compile-time constant", name)
+		return intermediate.Literal{}, false
+	}
+
+	state.states[name] = STATE_VISITING
+	state.stack = append(state.stack, sentiment)
+
+	value, isOk := state.evaluateDefinition(sentiment)
+
+	state.stack = state.stack[:len(state.stack)-1]
+	state.states[name] = STATE_VISITED
+	if isOk {
+		state.goal.Constants[name] = value
+	}
+
+	return value, isOk
+}
+
+func (state *evaluator) evaluateDefinition(sentiment intermediate.Sentiment) (intermediate.Literal, bool) {
+	children := sentiment.Definition.GetChildren()
+	if len(children) != 1 {
+		state.diagnostics.Errorf(sentiment.Position, "%v must be defined by a single expression", sentiment.Name)
+		return intermediate.Literal{}, false
+	}
+
+	value, isOk := state.evaluate(children[0])
+	config, isOverridden := state.overrides[sentiment.Name]
+	if !isOverridden || !isOk || isListOf(children[0]) {
+		return value, isOk
+	}
+
+	if !isTheory(sentiment) {
+		state.diagnostics.Warnf(
+			sentiment.Position,
+			"data value for @%v %v is ignored, declare it as a @%v to override it",
+			annotation.FACT_ANNOTATION,
+			sentiment.Name,
+			annotation.THEORY_ANNOTATION,
+		)
+		return value, isOk
+	}
+
+	override, err := intermediate.LiteralOfData(config.Values)
+	if err != nil {
+		state.diagnostics.Errorf(sentiment.Position, "data value for %v is invalid: %v", sentiment.Name, err)
+		return value, false
+	}
+
+	if override.Type != value.Type {
+		state.diagnostics.Errorf(
+			sentiment.Position,
+			"data value for %v is %v but the theory is %v",
+			sentiment.Name,
+			override.TypeName,
+			value.TypeName,
+		)
+		return value, false
+	}
+
+	return override, true
+}
+
+func (state *evaluator) reportCycle(name string, position lexer.Position) {
+	start := 0
+	for index, sentiment := range state.stack {
+		if sentiment.Name == name {
+			start = index
+			break
+		}
+	}
+
+	cycle := state.stack[start:]
+	names := name
+	for _, sentiment := range cycle[1:] {
+		names += " -> " + sentiment.Name
+	}
+
+	diagnostic := state.diagnostics.Errorf(position, "constant %v depends on itself: %v -> %v", name, names, name)
+	for _, sentiment := range cycle {
+		diagnostic.WithRelated(sentiment.Position, "%v is defined here", sentiment.Name)
+	}
+}
+
+func isListOf(tree container.Tree[intermediate.SenimentExpression]) bool {
+	value := tree.GetValue()
+	if value.Op != intermediate.OPCODE_CALL {
+		return false
+	}
+
+	callee := tree.GetChild(0).GetValue()
+	return callee.Op == intermediate.OPCODE_REF && callee.Value[0] == LIST_OF_REFERENCE
+}
@@ -0,0 +1,226 @@
+package constexpr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+type node = container.Tree[intermediate.SenimentExpression]
+
+func leaf(op intermediate.OpCode, typeId intermediate.TypeId, value ...string) node {
+	return intermediate.NewExpressionTree(intermediate.SenimentExpression{Op: op, TypeId: typeId, Value: value})
+}
+
+func integer(text string) node {
+	return leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_INTEGER, text)
+}
+
+func ref(name string) node {
+	return leaf(intermediate.OPCODE_REF, intermediate.TYPEID_NO_TYPE, name)
+}
+
+func parent(op intermediate.OpCode, children ...node) node {
+	tree := leaf(op, intermediate.TYPEID_NO_TYPE)
+	for _, child := range children {
+		container.AddChildren(tree, child)
+	}
+
+	return tree
+}
+
+func chain(operands ...interface{}) node {
+	children := make([]node, 0, len(operands))
+	for _, operand := range operands {
+		if operator, isOk := operand.(string); isOk {
+			children = append(children, leaf(intermediate.OPCODE_OPERATOR, intermediate.TYPEID_NO_TYPE, operator))
+		} else {
+			children = append(children, operand.(node))
+		}
+	}
+
+	return parent(intermediate.OPCODE_CHAIN, children...)
+}
+
+func goalOf(facts map[string]node) intermediate.Goal {
+	goal := intermediate.NewGoal()
+	for name, expression := range facts {
+		goal.Sentments[name] = intermediate.Sentiment{
+			Annotations: []string{annotation.FACT_ANNOTATION},
+			Name:        name,
+			Definition:  parent(intermediate.OPCODE_CONSTEXPR, expression),
+		}
+	}
+
+	return goal
+}
+
+func TestFoldReferences(t *testing.T) {
+	goal := goalOf(map[string]node{
+		"ONE":    integer("1"),
+		"LEVELS": integer("5"),
+		"MAX":    chain(ref("LEVELS"), "-", ref("ONE"), "*", integer("2")),
+		"HALF":   chain(ref("MAX"), "/", leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_DECIMAL, "2.0")),
+		"BIG":    chain(ref("MAX"), ">", ref("ONE")),
+	})
+
+	diagnostics := Fold(goal, nil)
+	if len(diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics:\n%v", diagnostics)
+	}
+
+	if goal.Constants["MAX"].Integer != 8 {
+		t.Errorf("unexpected MAX: %v", goal.Constants["MAX"])
+	}
+
+	if goal.Constants["HALF"].Decimal != 4.0 {
+		t.Errorf("unexpected HALF: %v", goal.Constants["HALF"])
+	}
+
+	if !goal.Constants["BIG"].Boolean {
+		t.Errorf("unexpected BIG: %v", goal.Constants["BIG"])
+	}
+}
+
+func TestFoldErrors(t *testing.T) {
+	cases := map[string]map[string]node{
+		"depends on itself": {
+			"A": chain(ref("B"), "+", integer("1")),
+			"B": ref("A"),
+		},
+		"division by zero": {
+			"ZERO": integer("0"),
+			"A":    chain(integer("1"), "/", ref("ZERO")),
+		},
+		"overflows a number": {
+			"A": chain(integer("9223372036854775807"), "+", integer("1")),
+		},
+		"does not fit in 64 bits": {
+			"A": integer("92233720368547758070"),
+		},
+		"not a compile-time constant": {
+			"A": ref("sysout"),
+		},
+	}
+
+	for expected, facts := range cases {
+		diagnostics := Fold(goalOf(facts), nil)
+		if !diagnostics.HasErrors() || !strings.Contains(diagnostics.String(), expected) {
+			t.Errorf("expected %q got:\n%v", expected, diagnostics)
+		}
+	}
+}
+
+func TestFoldDataOverrides(t *testing.T) {
+	goal := goalOf(map[string]node{
+		"STUDENTS": parent(intermediate.OPCODE_CALL, ref(LIST_OF_REFERENCE), ref("Student")),
+	})
+	goal.Sentments["LEVELS"] = intermediate.Sentiment{
+		Annotations: []string{annotation.THEORY_ANNOTATION},
+		Name:        "LEVELS",
+		Definition:  parent(intermediate.OPCODE_CONSTEXPR, integer("5")),
+	}
+
+	levels := container.NewGraphTreeCap[intermediate.DataValue](1, 1).AddChild(
+		intermediate.DataValue{Type: intermediate.TYPEID_INTEGER, TextValue: "10"},
+	)
+
+	students := container.NewGraphTreeCap[intermediate.DataValue](2, 2).SetValue(
+		intermediate.DataValue{Type: intermediate.TYPEID_LIST},
+	)
+	student := container.NewGraphTreeCap[intermediate.DataValue](1, 2).SetValue(
+		intermediate.DataValue{Type: intermediate.TYPEID_STRUCT},
+	)
+	student.AddChild(intermediate.DataValue{Type: intermediate.TYPEID_TEXT, TextValue: `"Abby"`})
+	container.AddChildren(students, student)
+
+	diagnostics := Fold(goal, []intermediate.DataConfig{
+		{FirstName: "pyramid", SecondName: "LEVELS", Values: levels},
+		{SecondName: "STUDENTS", Values: students},
+	})
+	if len(diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics:\n%v", diagnostics)
+	}
+
+	if goal.Constants["LEVELS"].Integer != 10 {
+		t.Errorf("unexpected LEVELS: %v", goal.Constants["LEVELS"])
+	}
+
+	if goal.Constants["STUDENTS"].String() != `[("Abby")]` || goal.Constants["STUDENTS"].TypeName != "List[Student]" {
+		t.Errorf("unexpected STUDENTS: %v %v", goal.Constants["STUDENTS"], goal.Constants["STUDENTS"].TypeName)
+	}
+}
+
+type dataField struct {
+	name   string
+	typeId intermediate.TypeId
+	text   string
+}
+
+func studentData(students ...[]dataField) container.Tree[intermediate.DataValue] {
+	list := container.NewGraphTreeCap[intermediate.DataValue](2, 2).SetValue(
+		intermediate.DataValue{Type: intermediate.TYPEID_LIST},
+	)
+	for _, fields := range students {
+		student := container.NewGraphTreeCap[intermediate.DataValue](1, 2).SetValue(
+			intermediate.DataValue{Type: intermediate.TYPEID_STRUCT},
+		)
+		for _, field := range fields {
+			student.AddChild(intermediate.DataValue{Type: field.typeId, TextValue: field.text, Name: field.name})
+		}
+
+		container.AddChildren(list, student)
+	}
+
+	return list
+}
+
+func TestFoldDataStructs(t *testing.T) {
+	foldStudents := func(students ...[]dataField) (intermediate.Goal, string) {
+		goal := goalOf(map[string]node{
+			"STUDENTS": parent(intermediate.OPCODE_CALL, ref(LIST_OF_REFERENCE), ref("Student")),
+		})
+		goal.Structs["Student"] = intermediate.Struct{
+			Name: "Student",
+			Fields: []intermediate.StructField{
+				{Name: "Name", TypeId: intermediate.TYPEID_TEXT},
+				{Name: "Gpa", TypeId: intermediate.TYPEID_DECIMAL},
+			},
+		}
+
+		diagnostics := Fold(goal, []intermediate.DataConfig{{SecondName: "STUDENTS", Values: studentData(students...)}})
+		return goal, diagnostics.String()
+	}
+
+	goal, diagnostics := foldStudents(
+		[]dataField{{"", intermediate.TYPEID_TEXT, `"Abby"`}, {"", intermediate.TYPEID_INTEGER, "3"}},
+		[]dataField{{"Gpa", intermediate.TYPEID_DECIMAL, "2.5"}, {"Name", intermediate.TYPEID_TEXT, `"Benny"`}},
+	)
+	if diagnostics != "" {
+		t.Fatalf("unexpected diagnostics:\n%v", diagnostics)
+	}
+
+	students := goal.Constants["STUDENTS"]
+	if students.String() != `[("Abby", 3), ("Benny", 2.5)]` || students.Items[1].TypeName != "Student" {
+		t.Errorf("unexpected STUDENTS: %v", students)
+	}
+
+	failures := map[string][]dataField{
+		"Student has 2 fields but data value 0 for STUDENTS gives 1": {{"", intermediate.TYPEID_TEXT, `"Abby"`}},
+		"field Gpa of Student is decimal but data value 0 for STUDENTS gives text": {
+			{"", intermediate.TYPEID_TEXT, `"Abby"`}, {"", intermediate.TYPEID_TEXT, `"high"`},
+		},
+		"Student has no field Age":                       {{"Name", intermediate.TYPEID_TEXT, `"Abby"`}, {"Age", intermediate.TYPEID_INTEGER, "9"}},
+		"data value 0 for STUDENTS is missing field Gpa": {{"Name", intermediate.TYPEID_TEXT, `"Abby"`}},
+		"mixes named and positional fields":              {{"Name", intermediate.TYPEID_TEXT, `"Abby"`}, {"", intermediate.TYPEID_DECIMAL, "1.0"}},
+	}
+
+	for expected, student := range failures {
+		if _, diagnostics := foldStudents(student); !strings.Contains(diagnostics, expected) {
+			t.Errorf("expected %q got:\n%v", expected, diagnostics)
+		}
+	}
+}
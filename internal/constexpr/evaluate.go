@@ -0,0 +1,277 @@
+package constexpr
+
+import (
+	"math"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func (state *evaluator) evaluate(tree container.Tree[intermediate.SenimentExpression]) (intermediate.Literal, bool) {
+	expression := tree.GetValue()
+	switch expression.Op {
+	case intermediate.OPCODE_CONST:
+		value, err := intermediate.ParseLiteral(expression.TypeId, expression.Value[0])
+		if err != nil {
+			state.diagnostics.Errorf(expression.Position, "%v", err)
+			return intermediate.Literal{}, false
+		}
+
+		return value, true
+	case intermediate.OPCODE_REF:
+		return state.evaluateName(expression.Value[0], expression.Position)
+	case intermediate.OPCODE_CAPTURE:
+		return state.evaluate(tree.GetChild(0))
+	case intermediate.OPCODE_CALL:
+		return state.evaluateCall(tree)
+	case intermediate.OPCODE_CHAIN:
+		return state.evaluateChain(tree)
+	}
+
+	state.diagnostics.Errorf(expression.Position, "expression cannot be evaluated at compile time")
+	return intermediate.Literal{}, false
+}
+
+func (state *evaluator) evaluateCall(tree container.Tree[intermediate.SenimentExpression]) (intermediate.Literal, bool) {
+	children := tree.GetChildren()
+	callee := children[0].GetValue()
+	if !isListOf(tree) {
+		name := "expression"
+		if callee.Op == intermediate.OPCODE_REF {
+			name = callee.Value[0]
+		}
+
+		state.diagnostics.Errorf(callee.Position, "%v cannot be called at compile time", name)
+		return intermediate.Literal{}, false
+	}
+
+	if len(children) != 2 || children[1].GetValue().Op != intermediate.OPCODE_REF {
+		state.diagnostics.Errorf(callee.Position, "%v expects exactly one element type", LIST_OF_REFERENCE)
+		return intermediate.Literal{}, false
+	}
+
+	elementType := children[1].GetValue().Value[0]
+	current := state.stack[len(state.stack)-1]
+	config, isOk := state.overrides[current.Name]
+	if !isOk {
+		return intermediate.ListLiteral(elementType, []intermediate.Literal{}), true
+	}
+
+	value, err := intermediate.LiteralOfData(config.Values)
+	if err != nil {
+		state.diagnostics.Errorf(current.Position, "data value for %v is invalid: %v", current.Name, err)
+		return intermediate.Literal{}, false
+	} else if value.Type != intermediate.TYPEID_LIST {
+		state.diagnostics.Errorf(current.Position, "data value for %v must be a list of %v", current.Name, elementType)
+		return intermediate.Literal{}, false
+	}
+
+	structure, isDeclared := state.goal.Structs[elementType]
+	items := unwrap(config.Values).GetChildren()
+	for index := range value.Items {
+		if isDeclared && index < len(items) {
+			value.Items[index], isOk = state.bindStruct(current, structure, items[index], index)
+			if !isOk {
+				return intermediate.Literal{}, false
+			}
+		} else if value.Items[index].Type == intermediate.TYPEID_STRUCT {
+			value.Items[index].TypeName = elementType
+		}
+	}
+
+	return intermediate.ListLiteral(elementType, value.Items), true
+}
+
+// Chains fold from left to right, every operator binds equally
+func (state *evaluator) evaluateChain(tree container.Tree[intermediate.SenimentExpression]) (intermediate.Literal, bool) {
+	children := tree.GetChildren()
+	result, isOk := state.evaluate(children[0])
+	for index := 1; isOk && index+1 < len(children); index += 2 {
+		operator := children[index].GetValue()
+		right, isRightOk := state.evaluate(children[index+1])
+		if !isRightOk {
+			return intermediate.Literal{}, false
+		}
+
+		result, isOk = state.apply(operator.Value[0], operator.Position, result, right)
+	}
+
+	return result, isOk
+}
+
+func isNumber(literal intermediate.Literal) bool {
+	return literal.Type == intermediate.TYPEID_INTEGER || literal.Type == intermediate.TYPEID_DECIMAL
+}
+
+func asDecimal(literal intermediate.Literal) float64 {
+	if literal.Type == intermediate.TYPEID_INTEGER {
+		return float64(literal.Integer)
+	}
+
+	return literal.Decimal
+}
+
+func (state *evaluator) apply(
+	operator string,
+	position lexer.Position,
+	left intermediate.Literal,
+	right intermediate.Literal,
+) (intermediate.Literal, bool) {
+	switch operator {
+	case "+", "-", "*", "/", "%":
+		if left.Type == intermediate.TYPEID_TEXT && right.Type == intermediate.TYPEID_TEXT && operator == "+" {
+			return intermediate.TextLiteral(left.Text + right.Text), true
+		} else if !isNumber(left) || !isNumber(right) {
+			break
+		} else if left.Type == intermediate.TYPEID_INTEGER && right.Type == intermediate.TYPEID_INTEGER {
+			return state.applyInteger(operator, position, left.Integer, right.Integer)
+		}
+
+		return state.applyDecimal(operator, position, asDecimal(left), asDecimal(right))
+	case "=", "!=", "<", ">", "<=", ">=":
+		return state.compare(operator, position, left, right)
+	case "&", "|":
+		if left.Type != intermediate.TYPEID_BOOLEAN || right.Type != intermediate.TYPEID_BOOLEAN {
+			break
+		} else if operator == "&" {
+			return intermediate.BooleanLiteral(left.Boolean && right.Boolean), true
+		}
+
+		return intermediate.BooleanLiteral(left.Boolean || right.Boolean), true
+	default:
+		state.diagnostics.Errorf(position, "operator %v cannot be evaluated at compile time", operator)
+		return intermediate.Literal{}, false
+	}
+
+	state.diagnostics.Errorf(position, "operator %v cannot be applied to %v and %v", operator, left.TypeName, right.TypeName)
+	return intermediate.Literal{}, false
+}
+
+func (state *evaluator) applyInteger(operator string, position lexer.Position, left int64, right int64) (intermediate.Literal, bool) {
+	var result int64
+	overflow := false
+	switch operator {
+	case "+":
+		result = left + right
+		overflow = (right > 0 && left > math.MaxInt64-right) || (right < 0 && left < math.MinInt64-right)
+	case "-":
+		result = left - right
+		overflow = (right < 0 && left > math.MaxInt64+right) || (right > 0 && left < math.MinInt64+right)
+	case "*":
+		result = left * right
+		overflow = left != 0 && (result/left != right || (left == -1 && right == math.MinInt64))
+	case "/", "%":
+		if right == 0 {
+			state.diagnostics.Errorf(position, "division by zero in constant expression")
+			return intermediate.Literal{}, false
+		}
+
+		overflow = left == math.MinInt64 && right == -1
+		if operator == "/" {
+			result = left / right
+		} else if !overflow {
+			result = left % right
+		}
+	}
+
+	if overflow {
+		state.diagnostics.Errorf(position, "constant expression %d %v %d overflows a number", left, operator, right)
+		return intermediate.Literal{}, false
+	}
+
+	return intermediate.IntegerLiteral(result), true
+}
+
+func (state *evaluator) applyDecimal(operator string, position lexer.Position, left float64, right float64) (intermediate.Literal, bool) {
+	var result float64
+	switch operator {
+	case "+":
+		result = left + right
+	case "-":
+		result = left - right
+	case "*":
+		result = left * right
+	case "/", "%":
+		if right == 0 {
+			state.diagnostics.Errorf(position, "division by zero in constant expression")
+			return intermediate.Literal{}, false
+		}
+
+		if operator == "/" {
+			result = left / right
+		} else {
+			result = math.Mod(left, right)
+		}
+	}
+
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		state.diagnostics.Errorf(position, "constant expression %v %v %v overflows a decimal", left, operator, right)
+		return intermediate.Literal{}, false
+	}
+
+	return intermediate.DecimalLiteral(result), true
+}
+
+func (state *evaluator) compare(
+	operator string,
+	position lexer.Position,
+	left intermediate.Literal,
+	right intermediate.Literal,
+) (intermediate.Literal, bool) {
+	var ordering int
+	switch {
+	case isNumber(left) && isNumber(right):
+		ordering = compareNumbers(left, right)
+	case left.Type == right.Type && (left.Type == intermediate.TYPEID_TEXT || left.Type == intermediate.TYPEID_CHAR):
+		if left.Text < right.Text {
+			ordering = -1
+		} else if left.Text > right.Text {
+			ordering = 1
+		}
+	case left.Type == intermediate.TYPEID_BOOLEAN && right.Type == intermediate.TYPEID_BOOLEAN &&
+		(operator == "=" || operator == "!="):
+		if left.Boolean != right.Boolean {
+			ordering = 1
+		}
+	default:
+		state.diagnostics.Errorf(position, "operator %v cannot compare %v and %v", operator, left.TypeName, right.TypeName)
+		return intermediate.Literal{}, false
+	}
+
+	switch operator {
+	case "=":
+		return intermediate.BooleanLiteral(ordering == 0), true
+	case "!=":
+		return intermediate.BooleanLiteral(ordering != 0), true
+	case "<":
+		return intermediate.BooleanLiteral(ordering < 0), true
+	case ">":
+		return intermediate.BooleanLiteral(ordering > 0), true
+	case "<=":
+		return intermediate.BooleanLiteral(ordering <= 0), true
+	}
+
+	return intermediate.BooleanLiteral(ordering >= 0), true
+}
+
+func compareNumbers(left intermediate.Literal, right intermediate.Literal) int {
+	if left.Type == intermediate.TYPEID_INTEGER && right.Type == intermediate.TYPEID_INTEGER {
+		if left.Integer < right.Integer {
+			return -1
+		} else if left.Integer > right.Integer {
+			return 1
+		}
+
+		return 0
+	}
+
+	leftValue, rightValue := asDecimal(left), asDecimal(right)
+	if leftValue < rightValue {
+		return -1
+	} else if leftValue > rightValue {
+		return 1
+	}
+
+	return 0
+}
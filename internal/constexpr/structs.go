@@ -0,0 +1,129 @@
+package constexpr
+
+import (
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/types"
+)
+
+type dataTree = container.Tree[intermediate.DataValue]
+
+// unwrap skips the untyped nodes data files wrap single values in
+func unwrap(tree dataTree) dataTree {
+	for tree.GetValue().Type == intermediate.TYPEID_NO_TYPE && len(tree.GetChildren()) == 1 {
+		tree = tree.GetChildren()[0]
+	}
+
+	return tree
+}
+
+// bindStruct matches a data file struct to a declared struct. Fields are
+// given either all in declaration order or all by name.
+func (state *evaluator) bindStruct(
+	current intermediate.Sentiment,
+	structure intermediate.Struct,
+	tree dataTree,
+	item int,
+) (intermediate.Literal, bool) {
+	tree = unwrap(tree)
+	if tree.GetValue().Type != intermediate.TYPEID_STRUCT {
+		state.diagnostics.Errorf(current.Position, "data value %d for %v must be a %v", item, current.Name, structure.Name)
+		return intermediate.Literal{}, false
+	}
+
+	children := tree.GetChildren()
+	named := len(children) > 0 && unwrap(children[0]).GetValue().Name != ""
+	if !named && len(children) != len(structure.Fields) {
+		state.diagnostics.Errorf(
+			current.Position,
+			"%v has %d fields but data value %d for %v gives %d",
+			structure.Name,
+			len(structure.Fields),
+			item,
+			current.Name,
+			len(children),
+		).WithRelated(structure.Position, "%v is defined here", structure.Name)
+		return intermediate.Literal{}, false
+	}
+
+	fields := make([]intermediate.Literal, len(structure.Fields))
+	given := make([]bool, len(structure.Fields))
+	isOk := true
+	for position, child := range children {
+		index := position
+		name := unwrap(child).GetValue().Name
+		switch {
+		case (name != "") != named:
+			state.diagnostics.Errorf(current.Position, "data value %d for %v mixes named and positional fields", item, current.Name)
+			return intermediate.Literal{}, false
+		case named:
+			index = structure.FieldIndex(name)
+			if index < 0 {
+				state.diagnostics.Errorf(current.Position, "%v has no field %v", structure.Name, name).
+					WithRelated(structure.Position, "%v is defined here", structure.Name)
+				isOk = false
+				continue
+			} else if given[index] {
+				state.diagnostics.Errorf(current.Position, "field %v is given more than once in data value %d for %v", name, item, current.Name)
+				isOk = false
+				continue
+			}
+		}
+
+		field, fieldOk := state.bindField(current, structure, index, child, item)
+		fields[index], given[index] = field, true
+		isOk = isOk && fieldOk
+	}
+
+	for index, field := range structure.Fields {
+		if !given[index] && isOk {
+			state.diagnostics.Errorf(current.Position, "data value %d for %v is missing field %v", item, current.Name, field.Name).
+				WithRelated(structure.Position, "%v is defined here", structure.Name)
+			isOk = false
+		}
+	}
+
+	return intermediate.Literal{Type: intermediate.TYPEID_STRUCT, TypeName: structure.Name, Items: fields}, isOk
+}
+
+func (state *evaluator) bindField(
+	current intermediate.Sentiment,
+	structure intermediate.Struct,
+	index int,
+	tree dataTree,
+	item int,
+) (intermediate.Literal, bool) {
+	field := structure.Fields[index]
+	typeName := state.goal.TypeNameOf(field.TypeId)
+	if nested, isStruct := state.goal.Structs[typeName]; isStruct {
+		return state.bindStruct(current, nested, tree, item)
+	}
+
+	value, err := intermediate.LiteralOfData(tree)
+	if err != nil {
+		state.diagnostics.Errorf(current.Position, "data value for %v is invalid: %v", current.Name, err)
+		return intermediate.Literal{}, false
+	}
+
+	// Whole numbers are accepted wherever decimals are expected
+	if value.Type == intermediate.TYPEID_INTEGER && field.TypeId == intermediate.TYPEID_DECIMAL {
+		return intermediate.DecimalLiteral(float64(value.Integer)), true
+	}
+
+	expected, err := types.Parse(typeName)
+	if err == nil && (types.Substitution{}).Unify(expected, types.Of(value)) != nil {
+		state.diagnostics.Errorf(
+			current.Position,
+			"field %v of %v is %v but data value %d for %v gives %v",
+			field.Name,
+			structure.Name,
+			typeName,
+			item,
+			current.Name,
+			types.Of(value),
+		)
+		return value, false
+	}
+
+	return value, true
+}
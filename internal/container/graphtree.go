@@ -82,8 +82,8 @@ func (st *GraphTree[V]) GetChildren() []Tree[V] {
 
 	result := make([]Tree[V], 0, st.BreadthAlloc)
 
-	for _, childIndex := range relation {
-		result = append(result, st.GetChild(int(childIndex)))
+	for index := range relation {
+		result = append(result, st.GetChild(index))
 	}
 
 	return result
@@ -84,3 +84,28 @@ func TestGraphTreeMaxDepthSpeed(t *testing.T) {
 		t.Errorf("unexpected left depth first got : %v", treeResult)
 	}
 }
+
+func TestGraphTreeAddChildren(t *testing.T) {
+	subTree := NewGraphTree[int]()
+	subTree.SetValue(2)
+	subTree.AddChild(3).AddChild(4)
+
+	tree := NewGraphTree[int]()
+	tree.SetValue(1)
+	AddChildren(tree, subTree)
+	tree.AddChild(5)
+
+	children := tree.GetChildren()
+	if len(children) != 2 {
+		t.Fatalf("unexpected children count: %d", len(children))
+	}
+
+	if children[0].GetValue() != 2 || children[1].GetValue() != 5 {
+		t.Errorf("unexpected children got : %v", tree.GetChildrenData())
+	}
+
+	depthResult := LeftDepthFirst(tree)
+	if !equals(depthResult, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("unexpected left depth first got : %v", depthResult)
+	}
+}
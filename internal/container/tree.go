@@ -13,7 +13,8 @@ type Tree[V any] interface {
 }
 
 func AddChildren[V any](self Tree[V], other Tree[V]) Tree[V] {
-	subTree := self.AddChild(other.GetValue())
+	self.AddChild(other.GetValue())
+	subTree := self.GetChild(len(self.GetChildren()) - 1)
 	for _, child := range other.GetChildren() {
 		AddChildren[V](subTree, child)
 	}
@@ -0,0 +1,103 @@
+package diagnostic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+type Severity int
+
+const (
+	SEVERITY_ERROR Severity = iota
+	SEVERITY_WARNING
+)
+
+var severityNames = map[Severity]string{
+	SEVERITY_ERROR:   "error",
+	SEVERITY_WARNING: "warning",
+}
+
+func (severity Severity) String() string {
+	return severityNames[severity]
+}
+
+type Related struct {
+	Position lexer.Position
+	Message  string
+}
+
+type Diagnostic struct {
+	Severity Severity
+	Position lexer.Position
+	Message  string
+	Related  []Related
+}
+
+func (diagnostic *Diagnostic) WithRelated(position lexer.Position, format string, args ...interface{}) *Diagnostic {
+	diagnostic.Related = append(diagnostic.Related, Related{
+		Position: position,
+		Message:  fmt.Sprintf(format, args...),
+	})
+
+	return diagnostic
+}
+
+func (diagnostic Diagnostic) String() string {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("%v: %v: %v", diagnostic.Position, diagnostic.Severity, diagnostic.Message))
+	for _, related := range diagnostic.Related {
+		builder.WriteString(fmt.Sprintf("\n\t%v: note: %v", related.Position, related.Message))
+	}
+
+	return builder.String()
+}
+
+type Diagnostics []*Diagnostic
+
+func (diagnostics *Diagnostics) add(severity Severity, position lexer.Position, format string, args ...interface{}) *Diagnostic {
+	diagnostic := &Diagnostic{
+		Severity: severity,
+		Position: position,
+		Message:  fmt.Sprintf(format, args...),
+	}
+
+	*diagnostics = append(*diagnostics, diagnostic)
+	return diagnostic
+}
+
+func (diagnostics *Diagnostics) Errorf(position lexer.Position, format string, args ...interface{}) *Diagnostic {
+	return diagnostics.add(SEVERITY_ERROR, position, format, args...)
+}
+
+func (diagnostics *Diagnostics) Warnf(position lexer.Position, format string, args ...interface{}) *Diagnostic {
+	return diagnostics.add(SEVERITY_WARNING, position, format, args...)
+}
+
+func (diagnostics *Diagnostics) Extend(others Diagnostics) {
+	*diagnostics = append(*diagnostics, others...)
+}
+
+func (diagnostics Diagnostics) HasErrors() bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == SEVERITY_ERROR {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (diagnostics Diagnostics) String() string {
+	lines := make([]string, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		lines = append(lines, diagnostic.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (diagnostics Diagnostics) Error() string {
+	return diagnostics.String()
+}
@@ -7,44 +7,184 @@ import (
 	"strings"
 
 	"github.com/tflexsoom/duffle/internal/files"
+	"github.com/tflexsoom/duffle/internal/packaging"
 )
 
+// Filter narrows the files of a project with globs written relative to its
+// root, in the syntax of ignore files. Exclude wins over Include, and an
+// empty Include keeps every file.
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+func patternsOf(globs []string) ([]pattern, error) {
+	patterns := make([]pattern, 0, len(globs))
+	for _, glob := range globs {
+		current, isOk, err := parsePattern(glob, "")
+		if err != nil {
+			return nil, err
+		} else if isOk {
+			patterns = append(patterns, current)
+		}
+	}
+
+	return patterns, nil
+}
+
+type walker struct {
+	fileMap map[files.SourceFileType][]string
+	include []pattern
+	exclude []pattern
+	// visited holds the real paths of walked directories, so symlinks
+	// leading back into the project are walked once and loops end
+	visited map[string]bool
+	verbose bool
+}
+
 func DiscoverFiles(path string, verbose bool) (map[files.SourceFileType][]string, error) {
+	return Discover(path, Filter{}, verbose)
+}
+
+// Discover finds the source files of a project. Directories are read in
+// name order and symlinks are followed, skipping hidden directories and
+// whatever .duffleignore files or the filter leave out.
+func Discover(path string, filter Filter, verbose bool) (map[files.SourceFileType][]string, error) {
 	if verbose {
-		log.Default().Printf("READING DIR %v", path)
+		log.Printf("reading directory %v", path)
 	}
 
-	fileMap := make(map[files.SourceFileType][]string)
+	include, err := patternsOf(filter.Include)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := patternsOf(filter.Exclude)
+	if err != nil {
+		return nil, err
+	}
 
-	filepath.WalkDir(path, func(path string, d os.DirEntry, err error) error {
-		if verbose {
-			log.Default().Printf("SCANNING %v WITH ERR %v", path, err)
+	current := walker{
+		fileMap: make(map[files.SourceFileType][]string),
+		include: include,
+		exclude: exclude,
+		visited: make(map[string]bool),
+		verbose: verbose,
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		err = current.walkDir(path, "", nil)
+	} else {
+		current.addFile(path, filepath.Base(path))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if verbose {
+		log.Printf("discovered %v", current.fileMap)
+	}
+
+	return current.fileMap, nil
+}
+
+// walkDir reads a directory found at a slash separated path relative to
+// the root, under the ignore patterns of its parents
+func (current *walker) walkDir(directory string, relative string, ignored []pattern) error {
+	realPath, err := filepath.EvalSymlinks(directory)
+	if err != nil {
+		return err
+	}
+
+	if current.visited[realPath] {
+		if current.verbose {
+			log.Printf("skipping %v, already walked as %v", directory, realPath)
 		}
 
-		if err != nil {
-			return err
+		return nil
+	}
+	current.visited[realPath] = true
+
+	ownIgnored, err := readIgnoreFile(directory, relative)
+	if err != nil {
+		return err
+	}
+
+	if len(ownIgnored) > 0 {
+		ignored = append(ignored[:len(ignored):len(ignored)], ownIgnored...)
+	}
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(directory, entry.Name())
+		entryRelative := entry.Name()
+		if relative != "" {
+			entryRelative = relative + "/" + entry.Name()
 		}
 
-		sourceFileType := files.SourceFileEnding[GetFileEnding(path)]
-		if sourceFileType != files.UNKNOWN_SOURCE_FILE {
-			fileMap[sourceFileType] = append(fileMap[sourceFileType], path)
+		if current.verbose {
+			log.Printf("scanning %v", path)
 		}
 
-		return nil
-	})
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
 
-	if verbose {
-		log.Default().Printf("Result: %v", fileMap)
+			isDir = info.IsDir()
+		}
+
+		if isIgnored(ignored, entryRelative, isDir) || matchesAny(current.exclude, entryRelative, isDir) {
+			continue
+		}
+
+		// Hidden directories hold version control and tool state, while
+		// vendored modules are walked like the rest of the project
+		if isDir {
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+
+			if err := current.walkDir(path, entryRelative, ignored); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if len(current.include) > 0 && !matchesAny(current.include, entryRelative, false) {
+			continue
+		}
+
+		current.addFile(path, entry.Name())
 	}
 
-	return fileMap, nil
+	return nil
 }
 
-func GetFileEnding(filename string) string {
-	if filename[0:2] == "./" {
-		filename = filename[2:]
+func (current *walker) addFile(path string, name string) {
+	if name == packaging.MANIFEST_FILE_NAME {
+		return
 	}
 
-	fileParts := strings.Split(filename, ".")
-	return fileParts[len(fileParts)-1]
+	sourceFileType := files.SourceFileEnding[GetFileEnding(name)]
+	if sourceFileType != files.UNKNOWN_SOURCE_FILE {
+		current.fileMap[sourceFileType] = append(current.fileMap[sourceFileType], path)
+	}
+}
+
+func GetFileEnding(filename string) string {
+	return strings.TrimPrefix(filepath.Ext(filename), ".")
 }
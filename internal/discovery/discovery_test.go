@@ -0,0 +1,164 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/files"
+)
+
+func TestDiscoverVendoredModules(t *testing.T) {
+	project := t.TempDir()
+	for _, name := range []string{
+		"main.dfl",
+		"duffle.ddat",
+		"settings.ddat",
+		"vendor/mathlib/math.dfl",
+		"vendor/mathlib/duffle.ddat",
+		".git/objects/stray.dfl",
+	} {
+		path := filepath.Join(project, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileMap, err := DiscoverFiles(project, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functionFiles := fileMap[files.FunctionFile]
+	if len(functionFiles) != 2 || functionFiles[0] != filepath.Join(project, "main.dfl") ||
+		functionFiles[1] != filepath.Join(project, "vendor", "mathlib", "math.dfl") {
+		t.Errorf("unexpected function files %v", functionFiles)
+	}
+
+	if dataFiles := fileMap[files.DataFile]; len(dataFiles) != 1 {
+		t.Errorf("unexpected data files %v", dataFiles)
+	}
+}
+
+func writeProject(t *testing.T, project string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(project, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func relativeFiles(t *testing.T, project string, paths []string) []string {
+	t.Helper()
+	relative := make([]string, 0, len(paths))
+	for _, path := range paths {
+		name, err := filepath.Rel(project, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		relative = append(relative, filepath.ToSlash(name))
+	}
+
+	return relative
+}
+
+func TestDiscoverIgnoreFiles(t *testing.T) {
+	project := t.TempDir()
+	writeProject(t, project,
+		"main.dfl",
+		"build/main.dfl",
+		"lib/keep.dfl",
+		"lib/generated.dfl",
+		"lib/vendor/nested.dfl",
+		"lib/deep/old.dfl",
+		"scratch.dfl",
+	)
+
+	os.WriteFile(filepath.Join(project, IGNORE_FILE_NAME), []byte("# outputs\nbuild/\n*.dfl\n!lib/**\n!/main.dfl\n"), 0644)
+	os.WriteFile(filepath.Join(project, "lib", IGNORE_FILE_NAME), []byte("generated.dfl\n/vendor\ndeep/*.dfl\n"), 0644)
+
+	fileMap, err := DiscoverFiles(project, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functionFiles := relativeFiles(t, project, fileMap[files.FunctionFile])
+	if strings.Join(functionFiles, " ") != "lib/keep.dfl main.dfl" {
+		t.Errorf("unexpected function files %v", functionFiles)
+	}
+}
+
+func TestDiscoverFilter(t *testing.T) {
+	project := t.TempDir()
+	writeProject(t, project, "main.dfl", "main.ddat", "lib/util.dfl", "lib/util_test.dfl", "tools/gen.dfl")
+
+	fileMap, err := Discover(project, Filter{Include: []string{"*.dfl"}, Exclude: []string{"*_test.dfl", "/tools"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functionFiles := relativeFiles(t, project, fileMap[files.FunctionFile])
+	if strings.Join(functionFiles, " ") != "lib/util.dfl main.dfl" || len(fileMap[files.DataFile]) != 0 {
+		t.Errorf("unexpected files %v", fileMap)
+	}
+
+	if _, err := Discover(project, Filter{Exclude: []string{"[z-a]"}}, false); err == nil {
+		t.Errorf("expected an invalid pattern to fail")
+	}
+}
+
+func TestDiscoverSymlinks(t *testing.T) {
+	project := t.TempDir()
+	shared := t.TempDir()
+	writeProject(t, project, "main.dfl")
+	writeProject(t, shared, "shared.dfl")
+
+	if err := os.Symlink(shared, filepath.Join(project, "shared")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+
+	os.Symlink(project, filepath.Join(project, "loop"))
+	os.Symlink(filepath.Join(shared, "shared.dfl"), filepath.Join(project, "linked.dfl"))
+
+	fileMap, err := DiscoverFiles(project, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functionFiles := relativeFiles(t, project, fileMap[files.FunctionFile])
+	if strings.Join(functionFiles, " ") != "linked.dfl main.dfl shared/shared.dfl" {
+		t.Errorf("unexpected function files %v", functionFiles)
+	}
+
+	os.Symlink(filepath.Join(project, "missing"), filepath.Join(project, "broken.dfl"))
+	if _, err := DiscoverFiles(project, false); err == nil || !strings.Contains(err.Error(), "broken.dfl") {
+		t.Errorf("expected the broken symlink to be reported, got %v", err)
+	}
+}
+
+func TestDiscoverErrors(t *testing.T) {
+	if _, err := DiscoverFiles(filepath.Join(t.TempDir(), "missing"), false); err == nil {
+		t.Errorf("expected a missing project to fail")
+	}
+}
+
+func TestGetFileEnding(t *testing.T) {
+	for name, expected := range map[string]string{
+		"main.dfl":        "dfl",
+		"./main.dfl":      "dfl",
+		"a":               "",
+		"":                "",
+		"dir.d/main.ddat": "ddat",
+		"archive.tar.gz":  "gz",
+	} {
+		if ending := GetFileEnding(name); ending != expected {
+			t.Errorf("expected %q to end with %q, got %q", name, expected, ending)
+		}
+	}
+}
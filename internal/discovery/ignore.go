@@ -0,0 +1,165 @@
+package discovery
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IGNORE_FILE_NAME lists paths to leave out of discovery, with the syntax
+// of .gitignore. Patterns apply below the directory of the file holding
+// them, and the last pattern matching a path decides.
+const IGNORE_FILE_NAME = ".duffleignore"
+
+type pattern struct {
+	expression *regexp.Regexp
+	// base is the directory the pattern was written in, relative to the
+	// root of the walk
+	base    string
+	negate  bool
+	dirOnly bool
+}
+
+// parsePattern reads a line of an ignore file or a glob given on the
+// command line. Blank lines and comments give no pattern.
+func parsePattern(line string, base string) (pattern, bool, error) {
+	text := line
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false, nil
+	}
+
+	current := pattern{base: base}
+	if strings.HasPrefix(line, "!") {
+		current.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		current.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// Patterns holding a slash are anchored to their base, while the rest
+	// match a name at any depth
+	prefix := "^(?:.*/)?"
+	if strings.Contains(line, "/") {
+		prefix = "^"
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return pattern{}, false, nil
+	}
+
+	expression, err := regexp.Compile(prefix + globExpression(line) + "$")
+	if err != nil {
+		return pattern{}, false, fmt.Errorf("invalid pattern %q: %w", text, err)
+	}
+
+	current.expression = expression
+	return current, true, nil
+}
+
+// globExpression translates a glob where * and ? stay within a directory
+// and ** crosses any number of them
+func globExpression(glob string) string {
+	builder := strings.Builder{}
+	for index := 0; index < len(glob); index++ {
+		switch character := glob[index]; {
+		case strings.HasPrefix(glob[index:], "**/"):
+			builder.WriteString("(?:.*/)?")
+			index += 2
+		case strings.HasPrefix(glob[index:], "**"):
+			builder.WriteString(".*")
+			index++
+		case character == '*':
+			builder.WriteString("[^/]*")
+		case character == '?':
+			builder.WriteString("[^/]")
+		case character == '[' && strings.Contains(glob[index:], "]"):
+			end := index + strings.Index(glob[index:], "]")
+			class := glob[index+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			builder.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			index = end
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(character)))
+		}
+	}
+
+	return builder.String()
+}
+
+// matches reports whether a pattern applies to a path relative to the
+// root of the walk
+func (current pattern) matches(relative string, isDir bool) bool {
+	if current.dirOnly && !isDir {
+		return false
+	}
+
+	if current.base != "" {
+		if !strings.HasPrefix(relative, current.base+"/") {
+			return false
+		}
+
+		relative = strings.TrimPrefix(relative, current.base+"/")
+	}
+
+	return current.expression.MatchString(relative)
+}
+
+// isIgnored applies patterns in order, so later ones override earlier ones
+func isIgnored(patterns []pattern, relative string, isDir bool) bool {
+	ignored := false
+	for _, current := range patterns {
+		if current.matches(relative, isDir) {
+			ignored = !current.negate
+		}
+	}
+
+	return ignored
+}
+
+func matchesAny(patterns []pattern, relative string, isDir bool) bool {
+	for _, current := range patterns {
+		if current.matches(relative, isDir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readIgnoreFile reads the patterns of the ignore file in a directory, if
+// it has one
+func readIgnoreFile(directory string, base string) ([]pattern, error) {
+	file, err := os.Open(filepath.Join(directory, IGNORE_FILE_NAME))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns := []pattern{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		current, isOk, err := parsePattern(scanner.Text(), base)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%d: %w", file.Name(), line, err)
+		} else if isOk {
+			patterns = append(patterns, current)
+		}
+	}
+
+	return patterns, scanner.Err()
+}
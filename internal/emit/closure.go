@@ -0,0 +1,53 @@
+package emit
+
+import (
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+// freeVariables lists the locals a capture uses without binding them itself,
+// in the order they are first used. These are copied into the closure when
+// it is created and arrive ahead of the inputs of the capture.
+func freeVariables(capture expressionTree) []string {
+	bound := make(map[string]bool)
+	boundNames(capture, bound)
+
+	free := make([]string, 0, 4)
+	seen := make(map[string]bool)
+	var visit func(tree expressionTree)
+	visit = func(tree expressionTree) {
+		expression := tree.GetValue()
+		if expression.Op == intermediate.OPCODE_REF && len(expression.Value) == 3 &&
+			(expression.Value[1] == intermediate.REFERENCE_LOCAL || expression.Value[1] == intermediate.REFERENCE_THUNK) {
+			name := expression.Value[2]
+			if !bound[name] && !seen[name] {
+				seen[name] = true
+				free = append(free, name)
+			}
+		}
+
+		for _, child := range tree.GetChildren() {
+			visit(child)
+		}
+	}
+
+	visit(capture)
+	return free
+}
+
+// boundNames collects the inputs and labels of a capture, including those of
+// the captures nested inside it
+func boundNames(tree expressionTree, bound map[string]bool) {
+	expression := tree.GetValue()
+	switch expression.Op {
+	case intermediate.OPCODE_CAPTURE:
+		for _, name := range expression.Value {
+			bound[name] = true
+		}
+	case intermediate.OPCODE_LABEL:
+		bound[expression.Value[0]] = true
+	}
+
+	for _, child := range tree.GetChildren() {
+		boundNames(child, bound)
+	}
+}
@@ -0,0 +1,186 @@
+package emit
+
+import (
+	"sort"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/stdlib"
+	"github.com/tflexsoom/duffle/internal/typing"
+)
+
+type expressionTree = container.Tree[intermediate.SenimentExpression]
+
+type emitter struct {
+	goals       []intermediate.Goal
+	module      *intermediate.Module
+	functions   map[string]intermediate.FunctionId
+	arities     map[string]int
+	wrappers    map[string]intermediate.FunctionId
+	generics    map[string]generic
+	instances   map[string]intermediate.FunctionId
+	diagnostics *diagnostic.Diagnostics
+}
+
+// Emit turns the functions of a type checked program into a module the
+// interpreter and the backends can run. Every reference must already be
+// resolved by typing.Infer. The module runs from the @exec function named
+// entry, which may be left empty for programs with a single one.
+func Emit(goals []intermediate.Goal, entry string) (intermediate.Module, diagnostic.Diagnostics) {
+	diagnostics := diagnostic.Diagnostics{}
+	entry = annotation.EntryPoint(annotation.SentimentsOf(goals...), entry, &diagnostics)
+	module, emitted := EmitLibrary(goals)
+	diagnostics.Extend(emitted)
+	for id, function := range module.Functions {
+		if function.Name == entry {
+			module.Entry = intermediate.FunctionId(id)
+		}
+	}
+
+	return module, diagnostics
+}
+
+// EmitLibrary emits a program that need not have an entry point, such as
+// one only holding tests. Its entry is an @exec function when there is one.
+func EmitLibrary(goals []intermediate.Goal) (intermediate.Module, diagnostic.Diagnostics) {
+	diagnostics := diagnostic.Diagnostics{}
+	module := intermediate.NewModule()
+	state := &emitter{
+		goals:       goals,
+		module:      &module,
+		functions:   make(map[string]intermediate.FunctionId),
+		arities:     make(map[string]int),
+		wrappers:    make(map[string]intermediate.FunctionId),
+		generics:    make(map[string]generic),
+		instances:   make(map[string]intermediate.FunctionId),
+		diagnostics: &diagnostics,
+	}
+
+	type entry struct {
+		goal      intermediate.Goal
+		sentiment intermediate.Sentiment
+	}
+
+	entries := make([]entry, 0, 16)
+	for _, goal := range goals {
+		for _, sentiment := range goal.Sentments {
+			if typing.IsFunction(sentiment) {
+				entries = append(entries, entry{goal: goal, sentiment: sentiment})
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].sentiment.Name < entries[j].sentiment.Name
+	})
+
+	// Generic sentiments are emitted once for every type they are used at
+	for _, current := range entries {
+		state.arities[current.sentiment.Name] = arityOf(current.sentiment)
+		if len(typing.TypeParamsOf(current.goal, current.sentiment)) > 0 {
+			state.generics[current.sentiment.Name] = generic{current.goal, current.sentiment}
+			if annotation.HasAnnotation(current.sentiment, annotation.EXEC_ANNOTATION) {
+				diagnostics.Errorf(current.sentiment.Position, "entry point %v cannot be generic", current.sentiment.Name)
+			}
+
+			continue
+		}
+
+		id := state.reserve(current.sentiment.Name)
+		state.functions[current.sentiment.Name] = id
+		if annotation.HasAnnotation(current.sentiment, annotation.EXEC_ANNOTATION) {
+			module.Entry = id
+		}
+	}
+
+	for _, current := range entries {
+		if _, isGeneric := state.generics[current.sentiment.Name]; isGeneric {
+			continue
+		}
+
+		function := state.newFunction(current.goal, nil)
+		function.emitSentiment(current.sentiment)
+		state.define(state.functions[current.sentiment.Name], function)
+	}
+
+	return module, diagnostics
+}
+
+func arityOf(sentiment intermediate.Sentiment) int {
+	if len(sentiment.Inputs) > 0 || sentiment.Definition.GetValue().Op != intermediate.OPCODE_EVALS {
+		return len(sentiment.Inputs)
+	}
+
+	for _, clause := range sentiment.Definition.GetChildren() {
+		return len(clause.GetChildren()) - 1
+	}
+
+	return 0
+}
+
+func (state *emitter) reserve(name string) intermediate.FunctionId {
+	state.module.Functions = append(state.module.Functions, intermediate.Function{Name: name})
+	return intermediate.FunctionId(len(state.module.Functions) - 1)
+}
+
+func (state *emitter) define(id intermediate.FunctionId, function *functionEmitter) {
+	markTailCalls(function.instructions)
+	defined := intermediate.Function{
+		Name:       state.module.Functions[id].Name,
+		Type:       function.typeName,
+		Params:     function.params,
+		Captures:   function.captured,
+		Registers:  function.registers,
+		Definition: function.instructions,
+	}
+
+	defined.StackMaps = intermediate.StackMapsOf(defined)
+	state.module.Functions[id] = defined
+}
+
+// wrapperOf gives a function calling a standard member, for members passed
+// around as values
+func (state *emitter) wrapperOf(position lexer.Position, path string, arity int) intermediate.FunctionId {
+	if id, isOk := state.wrappers[path]; isOk {
+		return id
+	}
+
+	id := state.reserve(path)
+	state.wrappers[path] = id
+
+	function := state.newFunction(intermediate.Goal{}, nil)
+	function.typeName = standardType(path)
+	function.params = uint32(arity)
+	args := make([]intermediate.Register, 0, arity)
+	for index := 0; index < arity; index++ {
+		args = append(args, function.newRegister())
+	}
+
+	result := function.emit(position, intermediate.INTRINSIC, state.module.IntrinsicOf(path), args...)
+	function.emitReturn(position, result)
+	state.define(id, function)
+	return id
+}
+
+func standardType(path string) string {
+	for _, member := range stdlib.Members() {
+		if member.Path() == path && len(member.Signatures) > 0 {
+			return member.Signatures[0].Type().String()
+		}
+	}
+
+	return ""
+}
+
+func standardArity(path string) int {
+	for _, member := range stdlib.Members() {
+		if member.Path() == path && len(member.Signatures) > 0 {
+			return len(member.Signatures[0].Params)
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,162 @@
+package emit
+
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/pattern"
+)
+
+const (
+	HEAD_INTRINSIC   = "dfl.head"
+	TAIL_INTRINSIC   = "dfl.tail"
+	LENGTH_INTRINSIC = "dfl.length"
+)
+
+// scrutinees remembers the register of every path already loaded on the
+// current branch of a decision tree along with the constructor it matched
+type scrutinees struct {
+	registers    map[string]intermediate.Register
+	constructors map[string]pattern.Constructor
+}
+
+func (current scrutinees) copy() scrutinees {
+	result := scrutinees{
+		registers:    make(map[string]intermediate.Register, len(current.registers)),
+		constructors: make(map[string]pattern.Constructor, len(current.constructors)),
+	}
+
+	for path, register := range current.registers {
+		result.registers[path] = register
+	}
+
+	for path, constructor := range current.constructors {
+		result.constructors[path] = constructor
+	}
+
+	return result
+}
+
+func (function *functionEmitter) emitEvals(sentiment intermediate.Sentiment, params []intermediate.Register) {
+	clauses := sentiment.Definition.GetChildren()
+	decision := pattern.DefaultSignatures().Compile(pattern.ClausesOf(sentiment.Definition))
+
+	current := scrutinees{
+		registers:    make(map[string]intermediate.Register, len(params)),
+		constructors: make(map[string]pattern.Constructor),
+	}
+
+	for index, param := range params {
+		current.registers[pattern.Path{index}.String()] = param
+	}
+
+	function.emitDecision(sentiment.Position, decision, clauses, current)
+}
+
+func (function *functionEmitter) emitDecision(
+	position lexer.Position,
+	decision *pattern.Decision,
+	clauses []expressionTree,
+	current scrutinees,
+) {
+	switch decision.Kind {
+	case pattern.DECISION_FAIL:
+		matchFailure(function, position)
+	case pattern.DECISION_LEAF:
+		clause := clauses[decision.Clause]
+		children := clause.GetChildren()
+		function.pushScope()
+		for name, path := range decision.Bindings {
+			function.bind(name, function.scrutinee(clause.GetValue().Position, path, current))
+		}
+
+		body := children[len(children)-1]
+		function.emitReturn(body.GetValue().Position, function.emitExpression(body))
+		function.popScope()
+	case pattern.DECISION_SWITCH:
+		value := function.scrutinee(position, decision.Path, current)
+		for _, option := range decision.Cases {
+			matched := current.copy()
+			matched.constructors[decision.Path.String()] = option.Constructor
+
+			condition, isConditional := function.emitTest(position, value, option.Constructor)
+			if !isConditional {
+				function.emitDecision(position, option.Decision, clauses, matched)
+				return
+			}
+
+			branch := function.emitJump(position, intermediate.BRANCH, condition)
+			function.emitDecision(position, option.Decision, clauses, matched)
+			function.patch(branch)
+		}
+
+		if decision.Default != nil {
+			function.emitDecision(position, decision.Default, clauses, current.copy())
+		} else {
+			matchFailure(function, position)
+		}
+	}
+}
+
+// scrutinee loads the value at a path, deconstructing the values above it
+// with the constructors they matched
+func (function *functionEmitter) scrutinee(
+	position lexer.Position,
+	path pattern.Path,
+	current scrutinees,
+) intermediate.Register {
+	if register, isOk := current.registers[path.String()]; isOk {
+		return register
+	}
+
+	parentPath := path[:len(path)-1]
+	parent := function.scrutinee(position, parentPath, current)
+	index := path[len(path)-1]
+
+	var register intermediate.Register
+	constructor := current.constructors[parentPath.String()]
+	switch {
+	case constructor.Name == intermediate.LIST_CONS_CONSTRUCTOR && index == 0:
+		register = function.emitIntrinsic(position, HEAD_INTRINSIC, parent)
+	case constructor.Name == intermediate.LIST_CONS_CONSTRUCTOR:
+		register = function.emitIntrinsic(position, TAIL_INTRINSIC, parent)
+	default:
+		register = function.emit(position, intermediate.FIELD, uint64(index), parent)
+	}
+
+	current.registers[path.String()] = register
+	return register
+}
+
+// emitTest gives a boolean register telling whether the value was built by
+// the constructor. Constructors that are the only one of their type need no
+// test at all.
+func (function *functionEmitter) emitTest(
+	position lexer.Position,
+	value intermediate.Register,
+	constructor pattern.Constructor,
+) (intermediate.Register, bool) {
+	switch {
+	case constructor.Name == intermediate.LIST_EMPTY_CONSTRUCTOR, constructor.Name == intermediate.LIST_CONS_CONSTRUCTOR:
+		operator := "="
+		if constructor.Name == intermediate.LIST_CONS_CONSTRUCTOR {
+			operator = "!="
+		}
+
+		length := function.emitIntrinsic(position, LENGTH_INTRINSIC, value)
+		zero := function.emitValue(position, intermediate.IntegerLiteral(0))
+		return function.emitIntrinsic(position, operator, length, zero), true
+	case constructor.TypeId == intermediate.TYPEID_BOOLEAN:
+		expected := function.emitValue(position, intermediate.BooleanLiteral(constructor.Name == "true"))
+		return function.emitIntrinsic(position, "=", value, expected), true
+	case constructor.IsLiteral:
+		literal, err := intermediate.ParseLiteral(constructor.TypeId, constructor.Name)
+		if err != nil {
+			function.emitter.diagnostics.Errorf(position, "%v", err)
+		}
+
+		expected := function.emitValue(position, literal)
+		return function.emitIntrinsic(position, "=", value, expected), true
+	}
+
+	return 0, false
+}
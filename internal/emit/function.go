@@ -0,0 +1,358 @@
+package emit
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+	"github.com/tflexsoom/duffle/internal/types"
+)
+
+type functionEmitter struct {
+	emitter      *emitter
+	goal         intermediate.Goal
+	parent       *functionEmitter
+	name         string
+	instance     string
+	typeName     string
+	types        types.Substitution
+	params       uint32
+	captured     uint32
+	registers    uint32
+	instructions []intermediate.Instruction
+	scopes       []map[string]intermediate.Register
+	captures     int
+}
+
+func (state *emitter) newFunction(goal intermediate.Goal, parent *functionEmitter) *functionEmitter {
+	return &functionEmitter{
+		emitter:      state,
+		goal:         goal,
+		parent:       parent,
+		instructions: make([]intermediate.Instruction, 0, 16),
+		scopes:       []map[string]intermediate.Register{make(map[string]intermediate.Register)},
+	}
+}
+
+func (function *functionEmitter) newRegister() intermediate.Register {
+	function.registers++
+	return intermediate.Register(function.registers - 1)
+}
+
+func (function *functionEmitter) emit(
+	position lexer.Position,
+	code intermediate.InstructionCode,
+	operand uint64,
+	args ...intermediate.Register,
+) intermediate.Register {
+	dest := function.newRegister()
+	function.instructions = append(function.instructions, intermediate.Instruction{
+		Instruction: code,
+		Dest:        dest,
+		Operand:     operand,
+		Args:        args,
+		Position:    position,
+	})
+
+	return dest
+}
+
+// emitJump adds a jump or branch whose target is patched once it is known
+func (function *functionEmitter) emitJump(position lexer.Position, code intermediate.InstructionCode, args ...intermediate.Register) int {
+	function.instructions = append(function.instructions, intermediate.Instruction{
+		Instruction: code,
+		Args:        args,
+		Position:    position,
+	})
+
+	return len(function.instructions) - 1
+}
+
+func (function *functionEmitter) patch(jump int) {
+	function.instructions[jump].Operand = uint64(len(function.instructions))
+}
+
+func (function *functionEmitter) emitReturn(position lexer.Position, value intermediate.Register) {
+	function.instructions = append(function.instructions, intermediate.Instruction{
+		Instruction: intermediate.RETURN,
+		Args:        []intermediate.Register{value},
+		Position:    position,
+	})
+}
+
+func (function *functionEmitter) emitValue(position lexer.Position, literal intermediate.Literal) intermediate.Register {
+	return function.emit(position, intermediate.VALUE, uint64(function.emitter.module.ValueOf(literal)))
+}
+
+func (function *functionEmitter) emitIntrinsic(position lexer.Position, name string, args ...intermediate.Register) intermediate.Register {
+	return function.emit(position, intermediate.INTRINSIC, function.emitter.module.IntrinsicOf(name), args...)
+}
+
+func (function *functionEmitter) pushScope() {
+	function.scopes = append(function.scopes, make(map[string]intermediate.Register))
+}
+
+func (function *functionEmitter) popScope() {
+	function.scopes = function.scopes[:len(function.scopes)-1]
+}
+
+func (function *functionEmitter) bind(name string, register intermediate.Register) {
+	function.scopes[len(function.scopes)-1][name] = register
+}
+
+func (function *functionEmitter) local(name string) (intermediate.Register, bool) {
+	for i := len(function.scopes) - 1; i >= 0; i-- {
+		if register, isOk := function.scopes[i][name]; isOk {
+			return register, true
+		}
+	}
+
+	return 0, false
+}
+
+func (function *functionEmitter) emitSentiment(sentiment intermediate.Sentiment) {
+	function.name = sentiment.Name
+	if function.instance != "" {
+		function.name = function.instance
+	}
+
+	function.typeName = function.concreteName(sentiment.Definition.GetValue().TypeId)
+	function.params = uint32(arityOf(sentiment))
+	params := make([]intermediate.Register, 0, function.params)
+	for index := uint32(0); index < function.params; index++ {
+		params = append(params, function.newRegister())
+	}
+
+	for index, input := range sentiment.Inputs {
+		function.bind(input.Name, params[index])
+	}
+
+	definition := sentiment.Definition
+	switch definition.GetValue().Op {
+	case intermediate.OPCODE_CONSTEXPR:
+		function.emitReturn(sentiment.Position, function.emitExpression(definition.GetChild(0)))
+	case intermediate.OPCODE_EVALS:
+		function.emitEvals(sentiment, params)
+	default:
+		function.emitExpression(definition)
+		function.emitReturn(sentiment.Position, function.emitValue(sentiment.Position, intermediate.NothingLiteral()))
+	}
+}
+
+func (function *functionEmitter) emitExpression(tree expressionTree) intermediate.Register {
+	expression := tree.GetValue()
+	children := tree.GetChildren()
+	switch expression.Op {
+	case intermediate.OPCODE_CONST:
+		literal, err := intermediate.ParseLiteral(expression.TypeId, expression.Value[0])
+		if err != nil {
+			function.emitter.diagnostics.Errorf(expression.Position, "%v", err)
+		}
+
+		return function.emitValue(expression.Position, literal)
+	case intermediate.OPCODE_REF:
+		return function.emitReference(expression)
+	case intermediate.OPCODE_CALL:
+		return function.emitCall(tree)
+	case intermediate.OPCODE_CHAIN:
+		start := 1
+		var result intermediate.Register
+		if len(children) > 2 && isFieldOperator(children[1]) && isProjection(children[0]) {
+			id := function.emitter.projectionOf(expression.Position, children[0].GetValue().Value[2], children[2])
+			result = function.emit(expression.Position, intermediate.CLOSURE, uint64(id))
+			start = 3
+		} else {
+			result = function.emitExpression(children[0])
+		}
+
+		for index := start; index+1 < len(children); index += 2 {
+			if isFieldOperator(children[index]) {
+				result = function.emitField(children[index+1], result)
+				continue
+			}
+
+			operator := children[index].GetValue()
+			right := function.emitExpression(children[index+1])
+			result = function.emitIntrinsic(operator.Position, operator.Value[0], result, right)
+		}
+
+		return result
+	case intermediate.OPCODE_CAPTURE:
+		return function.emitCapture(tree)
+	case intermediate.OPCODE_LABEL:
+		value := function.emitExpression(children[0])
+		function.bind(expression.Value[0], value)
+		return value
+	case intermediate.OPCODE_IF:
+		function.emitConditional(tree)
+	case intermediate.OPCODE_BLOCK:
+		function.pushScope()
+		for _, child := range children {
+			function.emitExpression(child)
+		}
+		function.popScope()
+	case intermediate.OPCODE_RETURN:
+		var value intermediate.Register
+		if len(children) > 0 {
+			value = function.emitExpression(children[0])
+		} else {
+			value = function.emitValue(expression.Position, intermediate.NothingLiteral())
+		}
+
+		function.emitReturn(expression.Position, value)
+	case intermediate.OPCODE_NOOP:
+	default:
+		function.emitter.diagnostics.Errorf(expression.Position, "expression cannot be compiled")
+	}
+
+	return function.emitValue(expression.Position, intermediate.NothingLiteral())
+}
+
+func (function *functionEmitter) emitConditional(tree expressionTree) {
+	children := tree.GetChildren()
+	position := tree.GetValue().Position
+	condition := function.emitExpression(children[0])
+	branch := function.emitJump(position, intermediate.BRANCH, condition)
+	function.emitExpression(children[1])
+	if len(children) < 3 {
+		function.patch(branch)
+		return
+	}
+
+	jump := function.emitJump(position, intermediate.JUMP)
+	function.patch(branch)
+	function.emitExpression(children[2])
+	function.patch(jump)
+}
+
+func (function *functionEmitter) constant(name string) (intermediate.Literal, bool) {
+	if literal, isOk := function.goal.Constants[name]; isOk {
+		return literal, true
+	}
+
+	for _, goal := range function.emitter.goals {
+		if literal, isOk := goal.Constants[name]; isOk {
+			return literal, true
+		}
+	}
+
+	return intermediate.Literal{}, false
+}
+
+func (function *functionEmitter) emitReference(expression intermediate.SenimentExpression) intermediate.Register {
+	if len(expression.Value) < 3 {
+		function.emitter.diagnostics.Errorf(expression.Position, "%v was not resolved by the type checker", expression.Value[0])
+		return function.emitValue(expression.Position, intermediate.NothingLiteral())
+	}
+
+	kind, target := expression.Value[1], expression.Value[2]
+	switch kind {
+	case intermediate.REFERENCE_LOCAL:
+		if register, isOk := function.local(target); isOk {
+			return register
+		}
+	case intermediate.REFERENCE_THUNK:
+		if register, isOk := function.local(target); isOk {
+			return function.emit(expression.Position, intermediate.APPLY, 0, register)
+		}
+	case intermediate.REFERENCE_CONSTANT:
+		if literal, isOk := function.constant(target); isOk {
+			return function.emitValue(expression.Position, literal)
+		}
+	case intermediate.REFERENCE_SENTIMENT:
+		id := function.functionOf(expression)
+		if function.emitter.arities[target] == 0 {
+			return function.emit(expression.Position, intermediate.CALL, uint64(id))
+		}
+
+		return function.emit(expression.Position, intermediate.CLOSURE, uint64(id))
+	case intermediate.REFERENCE_STRUCT:
+		if _, structure, isOk := function.emitter.structOf(target); isOk && len(structure.Fields) == 0 {
+			return function.emitConstruct(expression.Position, target)
+		} else if isOk {
+			return function.emit(expression.Position, intermediate.CLOSURE, uint64(function.emitter.constructorOf(expression.Position, target)))
+		}
+	case intermediate.REFERENCE_STANDARD:
+		arity := standardArity(target)
+		if arity == 0 {
+			return function.emitIntrinsic(expression.Position, target)
+		}
+
+		return function.emit(expression.Position, intermediate.CLOSURE, uint64(function.emitter.wrapperOf(expression.Position, target, arity)))
+	}
+
+	function.emitter.diagnostics.Errorf(expression.Position, "%v cannot be compiled as a %v reference", target, kind)
+	return function.emitValue(expression.Position, intermediate.NothingLiteral())
+}
+
+func (function *functionEmitter) emitCall(tree expressionTree) intermediate.Register {
+	children := tree.GetChildren()
+	head := children[0].GetValue()
+	args := make([]intermediate.Register, 0, len(children))
+	for _, child := range children[1:] {
+		args = append(args, function.emitExpression(child))
+	}
+
+	if head.Op == intermediate.OPCODE_REF && len(head.Value) == 3 {
+		switch head.Value[1] {
+		case intermediate.REFERENCE_SENTIMENT:
+			return function.emit(head.Position, intermediate.CALL, uint64(function.functionOf(head)), args...)
+		case intermediate.REFERENCE_STANDARD:
+			return function.emitIntrinsic(head.Position, head.Value[2], args...)
+		case intermediate.REFERENCE_STRUCT:
+			return function.emitConstruct(head.Position, head.Value[2], args...)
+		}
+	}
+
+	callee := function.emitExpression(children[0])
+	return function.emit(head.Position, intermediate.APPLY, 0, append([]intermediate.Register{callee}, args...)...)
+}
+
+// Captures become functions of their own. Inline captures return their
+// expression while block captures take inputs and return like a function.
+// The locals a capture uses from the functions around it are closed over.
+func (function *functionEmitter) emitCapture(tree expressionTree) intermediate.Register {
+	expression := tree.GetValue()
+	function.captures++
+	capture := function.emitter.newFunction(function.goal, function)
+	capture.name = fmt.Sprintf("%v$capture%d", function.name, function.captures)
+	capture.types = function.types
+	capture.typeName = function.concreteName(expression.TypeId)
+
+	free := freeVariables(tree)
+	closed := make([]intermediate.Register, 0, len(free))
+	for _, name := range free {
+		register, isOk := function.local(name)
+		if !isOk {
+			function.emitter.diagnostics.Errorf(expression.Position, "%v is not visible here", name)
+			register = function.emitValue(expression.Position, intermediate.NothingLiteral())
+		}
+
+		closed = append(closed, register)
+		capture.bind(name, capture.newRegister())
+	}
+
+	capture.captured = uint32(len(free))
+	body := tree.GetChild(0)
+	if body.GetValue().Op != intermediate.OPCODE_BLOCK {
+		capture.emitReturn(expression.Position, capture.emitExpression(body))
+	} else {
+		capture.params = uint32(len(expression.Value))
+		for _, name := range expression.Value {
+			capture.bind(name, capture.newRegister())
+		}
+
+		capture.emitExpression(body)
+		capture.emitReturn(expression.Position, capture.emitValue(expression.Position, intermediate.NothingLiteral()))
+	}
+
+	id := function.emitter.reserve(capture.name)
+	function.emitter.define(id, capture)
+	return function.emit(expression.Position, intermediate.CLOSURE, uint64(id), closed...)
+}
+
+func matchFailure(function *functionEmitter, position lexer.Position) {
+	name := function.emitValue(position, intermediate.TextLiteral(function.name))
+	function.emitReturn(position, function.emitIntrinsic(position, runtime.MATCH_FAILURE, name))
+}
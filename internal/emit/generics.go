@@ -0,0 +1,105 @@
+package emit
+
+import (
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/types"
+	"github.com/tflexsoom/duffle/internal/typing"
+)
+
+type generic struct {
+	goal      intermediate.Goal
+	sentiment intermediate.Sentiment
+}
+
+// concrete replaces the type parameters of the function being emitted by
+// the types it is specialized at. Variables nothing depends on are left as
+// nothing, so backends never see a type variable.
+func (function *functionEmitter) concrete(t types.Type) types.Type {
+	return concreteOf(function.types.Apply(t))
+}
+
+func concreteOf(t types.Type) types.Type {
+	if t.Variable {
+		return types.Nothing
+	} else if len(t.Args) == 0 {
+		return t
+	}
+
+	args := make([]types.Type, 0, len(t.Args))
+	for _, arg := range t.Args {
+		args = append(args, concreteOf(arg))
+	}
+
+	return types.Named(t.Name, args...)
+}
+
+func (function *functionEmitter) concreteName(typeId intermediate.TypeId) string {
+	name := function.goal.TypeNameOf(typeId)
+	parsed, err := types.Parse(name)
+	if typeId == intermediate.TYPEID_NO_TYPE || err != nil {
+		return name
+	}
+
+	return function.concrete(parsed).String()
+}
+
+// functionOf gives the function a reference to a sentiment stands for.
+// Generic sentiments are specialized at the type the reference was checked
+// with.
+func (function *functionEmitter) functionOf(expression intermediate.SenimentExpression) intermediate.FunctionId {
+	target := expression.Value[2]
+	definition, isGeneric := function.emitter.generics[target]
+	if !isGeneric {
+		return function.emitter.functions[target]
+	}
+
+	parsed, err := types.Parse(function.goal.TypeNameOf(expression.TypeId))
+	if expression.TypeId == intermediate.TYPEID_NO_TYPE || err != nil {
+		function.emitter.diagnostics.Errorf(expression.Position, "the type %v is used at was not inferred", target)
+		parsed = types.Variable("?")
+	}
+
+	return function.emitter.instanceOf(expression.Position, definition, function.concrete(parsed))
+}
+
+// instanceOf gives the copy of a generic sentiment specialized at a type,
+// emitting it the first time the type is used. Copies are named after the
+// types their parameters stand for, e.g. sort[integer].
+func (state *emitter) instanceOf(position lexer.Position, definition generic, t types.Type) intermediate.FunctionId {
+	sentiment := definition.sentiment
+	bound := types.Substitution{}
+	if err := bound.Unify(typing.DeclaredType(definition.goal, sentiment), t); err != nil {
+		state.diagnostics.Errorf(position, "%v cannot be specialized at %v: %v", sentiment.Name, t, err)
+	}
+
+	params := typing.TypeParamsOf(definition.goal, sentiment)
+	specialized := make(types.Substitution, len(params))
+	args := make([]string, 0, len(params))
+	for _, param := range params {
+		arg := concreteOf(bound.Apply(types.Variable(param.Name)))
+		specialized[param.Name] = arg
+		args = append(args, arg.String())
+	}
+
+	name := sentiment.Name + "[" + strings.Join(args, ", ") + "]"
+	if id, isOk := state.instances[name]; isOk {
+		return id
+	}
+
+	id := state.reserve(name)
+	state.instances[name] = id
+	function := state.newFunction(definition.goal, nil)
+	function.types = specialized
+	function.instance = name
+	function.emitSentiment(sentiment)
+	if arityOf(sentiment) == 0 {
+		t = types.Function(nil, t)
+	}
+
+	function.typeName = t.String()
+	state.define(id, function)
+	return id
+}
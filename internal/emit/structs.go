@@ -0,0 +1,109 @@
+package emit
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+func (state *emitter) structOf(name string) (intermediate.Goal, intermediate.Struct, bool) {
+	for _, goal := range state.goals {
+		if structure, isOk := goal.Structs[name]; isOk {
+			return goal, structure, true
+		}
+	}
+
+	return intermediate.Goal{}, intermediate.Struct{}, false
+}
+
+func isFieldOperator(tree expressionTree) bool {
+	expression := tree.GetValue()
+	return expression.Op == intermediate.OPCODE_OPERATOR && expression.Value[0] == intermediate.FIELD_OPERATOR
+}
+
+func isProjection(tree expressionTree) bool {
+	expression := tree.GetValue()
+	return expression.Op == intermediate.OPCODE_REF && len(expression.Value) == 3 &&
+		expression.Value[1] == intermediate.REFERENCE_STRUCT
+}
+
+func (function *functionEmitter) emitConstruct(position lexer.Position, name string, fields ...intermediate.Register) intermediate.Register {
+	args := append([]intermediate.Register{function.emitValue(position, intermediate.TextLiteral(name))}, fields...)
+	return function.emitIntrinsic(position, runtime.STRUCT_CONSTRUCTOR, args...)
+}
+
+func (function *functionEmitter) emitField(tree expressionTree, value intermediate.Register) intermediate.Register {
+	expression := tree.GetValue()
+	if len(expression.Value) < 3 || expression.Value[1] != intermediate.REFERENCE_FIELD {
+		function.emitter.diagnostics.Errorf(expression.Position, "field %v was not resolved by the type checker", expression.Value[0])
+		return function.emitValue(expression.Position, intermediate.NothingLiteral())
+	}
+
+	index, err := strconv.Atoi(expression.Value[2])
+	if err != nil {
+		function.emitter.diagnostics.Errorf(expression.Position, "field %v has no index", expression.Value[0])
+	}
+
+	return function.emit(expression.Position, intermediate.FIELD, uint64(index), value)
+}
+
+func fieldTypes(goal intermediate.Goal, structure intermediate.Struct) []string {
+	names := make([]string, 0, len(structure.Fields)+1)
+	for _, field := range structure.Fields {
+		names = append(names, goal.TypeNameOf(field.TypeId))
+	}
+
+	return names
+}
+
+// constructorOf gives a function building a struct, for structs passed
+// around as values
+func (state *emitter) constructorOf(position lexer.Position, name string) intermediate.FunctionId {
+	if id, isOk := state.wrappers[name]; isOk {
+		return id
+	}
+
+	goal, structure, _ := state.structOf(name)
+	id := state.reserve(name)
+	state.wrappers[name] = id
+
+	function := state.newFunction(goal, nil)
+	function.typeName = "Function[" + strings.Join(append(fieldTypes(goal, structure), name), ", ") + "]"
+	function.params = uint32(len(structure.Fields))
+	fields := make([]intermediate.Register, 0, len(structure.Fields))
+	for range structure.Fields {
+		fields = append(fields, function.newRegister())
+	}
+
+	function.emitReturn(position, function.emitConstruct(position, name, fields...))
+	state.define(id, function)
+	return id
+}
+
+// projectionOf gives the function (Student . Name) stands for, reading one
+// field out of a struct
+func (state *emitter) projectionOf(position lexer.Position, name string, field expressionTree) intermediate.FunctionId {
+	path := name + intermediate.FIELD_OPERATOR + field.GetValue().Value[0]
+	if id, isOk := state.wrappers[path]; isOk {
+		return id
+	}
+
+	goal, structure, _ := state.structOf(name)
+	id := state.reserve(path)
+	state.wrappers[path] = id
+
+	function := state.newFunction(goal, nil)
+	function.params = 1
+	value := function.newRegister()
+	result := function.emitField(field, value)
+	if index := structure.FieldIndex(field.GetValue().Value[0]); index >= 0 {
+		function.typeName = "Function[" + name + ", " + fieldTypes(goal, structure)[index] + "]"
+	}
+
+	function.emitReturn(position, result)
+	state.define(id, function)
+	return id
+}
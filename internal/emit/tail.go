@@ -0,0 +1,52 @@
+package emit
+
+import "github.com/tflexsoom/duffle/internal/intermediate"
+
+// markTailCalls turns every call whose result is returned unchanged into a
+// tail call, so recursion in tail position runs in constant stack space.
+// The result may reach its return through moves and jumps.
+func markTailCalls(definition []intermediate.Instruction) {
+	for index, instruction := range definition {
+		var tail intermediate.InstructionCode
+		switch instruction.Instruction {
+		case intermediate.CALL:
+			tail = intermediate.TAILCALL
+		case intermediate.APPLY:
+			tail = intermediate.TAILAPPLY
+		default:
+			continue
+		}
+
+		if returns(definition, index+1, instruction.Dest) {
+			definition[index].Instruction = tail
+			definition[index].Dest = 0
+		}
+	}
+}
+
+// returns tells whether the instructions from start on do nothing but
+// return the value of a register
+func returns(definition []intermediate.Instruction, start int, value intermediate.Register) bool {
+	for steps, index := 0, start; steps < len(definition) && index < len(definition); steps++ {
+		instruction := definition[index]
+		switch instruction.Instruction {
+		case intermediate.NOOP:
+			index++
+		case intermediate.MOVE:
+			if instruction.Args[0] != value {
+				return false
+			}
+
+			value = instruction.Dest
+			index++
+		case intermediate.JUMP:
+			index = int(instruction.Operand)
+		case intermediate.RETURN:
+			return instruction.Args[0] == value
+		default:
+			return false
+		}
+	}
+
+	return false
+}
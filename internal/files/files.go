@@ -13,9 +13,14 @@ const (
 	SOURCE_FILE_TYPE_LENGTH
 )
 
+const (
+	FUNCTION_FILE_ENDING = "dfl"
+	DATA_FILE_ENDING     = "ddat"
+)
+
 var SourceFileEnding = map[string]SourceFileType{
-	"dfl":  FunctionFile,
-	"ddat": DataFile,
+	FUNCTION_FILE_ENDING: FunctionFile,
+	DATA_FILE_ENDING:     DataFile,
 }
 
 type SourceFileParser interface {
@@ -0,0 +1,252 @@
+package fixity
+
+import (
+	"sort"
+
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+type expressionTree = container.Tree[intermediate.SenimentExpression]
+
+// Operators without a declaration group like Haskell's, to the left and
+// tighter than every built in operator but the field operator
+const (
+	DEFAULT_PRECEDENCE = 9
+	MIN_PRECEDENCE     = 0
+	MAX_PRECEDENCE     = 9
+)
+
+var builtin = map[string]intermediate.Fixity{
+	intermediate.FIELD_OPERATOR: {Operator: intermediate.FIELD_OPERATOR, Associativity: intermediate.ASSOCIATIVE_LEFT, Precedence: 10},
+	"*":                         {Operator: "*", Associativity: intermediate.ASSOCIATIVE_LEFT, Precedence: 7},
+	"/":                         {Operator: "/", Associativity: intermediate.ASSOCIATIVE_LEFT, Precedence: 7},
+	"%":                         {Operator: "%", Associativity: intermediate.ASSOCIATIVE_LEFT, Precedence: 7},
+	"+":                         {Operator: "+", Associativity: intermediate.ASSOCIATIVE_LEFT, Precedence: 6},
+	"-":                         {Operator: "-", Associativity: intermediate.ASSOCIATIVE_LEFT, Precedence: 6},
+	"=":                         {Operator: "=", Associativity: intermediate.ASSOCIATIVE_NONE, Precedence: 4},
+	"!=":                        {Operator: "!=", Associativity: intermediate.ASSOCIATIVE_NONE, Precedence: 4},
+	"<":                         {Operator: "<", Associativity: intermediate.ASSOCIATIVE_NONE, Precedence: 4},
+	">":                         {Operator: ">", Associativity: intermediate.ASSOCIATIVE_NONE, Precedence: 4},
+	"<=":                        {Operator: "<=", Associativity: intermediate.ASSOCIATIVE_NONE, Precedence: 4},
+	">=":                        {Operator: ">=", Associativity: intermediate.ASSOCIATIVE_NONE, Precedence: 4},
+	"&":                         {Operator: "&", Associativity: intermediate.ASSOCIATIVE_RIGHT, Precedence: 3},
+	"|":                         {Operator: "|", Associativity: intermediate.ASSOCIATIVE_RIGHT, Precedence: 2},
+}
+
+// IsBuiltin reports the operators the language defines itself. Their
+// fixity is fixed and they cannot be redefined.
+func IsBuiltin(operator string) bool {
+	_, isOk := builtin[operator]
+	return isOk
+}
+
+// AssociativityOf reads the keyword of a fixity declaration
+func AssociativityOf(keyword string) intermediate.Associativity {
+	switch keyword {
+	case "infixl":
+		return intermediate.ASSOCIATIVE_LEFT
+	case "infixr":
+		return intermediate.ASSOCIATIVE_RIGHT
+	}
+
+	return intermediate.ASSOCIATIVE_NONE
+}
+
+type table map[string]intermediate.Fixity
+
+func (operators table) of(operator string) intermediate.Fixity {
+	if fixity, isOk := builtin[operator]; isOk {
+		return fixity
+	} else if fixity, isOk := operators[operator]; isOk {
+		return fixity
+	}
+
+	return intermediate.Fixity{Operator: operator, Associativity: intermediate.ASSOCIATIVE_LEFT, Precedence: DEFAULT_PRECEDENCE}
+}
+
+// tableOf merges the operators the goals of a program declare. An operator
+// may be declared by more than one goal only with the same fixity.
+func tableOf(goals []intermediate.Goal, diagnostics *diagnostic.Diagnostics) table {
+	operators := make(table)
+	for _, goal := range goals {
+		names := make([]string, 0, len(goal.Operators))
+		for name := range goal.Operators {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			declared := goal.Operators[name]
+			previous, exists := operators[name]
+			if exists && (previous.Associativity != declared.Associativity || previous.Precedence != declared.Precedence) {
+				diagnostics.Errorf(
+					declared.Position,
+					"operator %v is declared %v at precedence %d",
+					name, declared.Associativity, declared.Precedence,
+				).WithRelated(
+					previous.Position,
+					"%v is also declared %v at precedence %d here",
+					name, previous.Associativity, previous.Precedence,
+				)
+				continue
+			}
+
+			operators[name] = declared
+		}
+	}
+
+	return operators
+}
+
+// Reassociate nests the flat operator chains of every sentiment by the
+// fixity of their operators. Built in operators stay chains of one operator
+// while other operators become calls of the function they name. Lowering
+// leaves parenthesized chains nested already, so they are kept as written.
+func Reassociate(goals ...intermediate.Goal) diagnostic.Diagnostics {
+	diagnostics := diagnostic.Diagnostics{}
+	state := reassociation{operators: tableOf(goals, &diagnostics), diagnostics: &diagnostics}
+	for _, goal := range goals {
+		names := make([]string, 0, len(goal.Sentments))
+		for name := range goal.Sentments {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			sentiment := goal.Sentments[name]
+			if sentiment.Definition == nil {
+				continue
+			}
+
+			sentiment.Definition = state.rewrite(sentiment.Definition)
+			goal.Sentments[name] = sentiment
+		}
+	}
+
+	return diagnostics
+}
+
+type reassociation struct {
+	operators   table
+	diagnostics *diagnostic.Diagnostics
+}
+
+type pending struct {
+	tree   expressionTree
+	fixity intermediate.Fixity
+}
+
+// rewrite copies a tree with its chains nested, since trees cannot drop
+// children in place
+func (state *reassociation) rewrite(tree expressionTree) expressionTree {
+	expression := tree.GetValue()
+	children := tree.GetChildren()
+	if expression.Op == intermediate.OPCODE_CHAIN && len(children) >= 3 {
+		return state.nest(children)
+	}
+
+	rewritten := intermediate.NewExpressionTree(expression)
+	for _, child := range children {
+		container.AddChildren(rewritten, state.rewrite(child))
+	}
+
+	return rewritten
+}
+
+// nest groups the operands of a chain by precedence climbing. Operators of
+// the same precedence group by their shared associativity. Mixing
+// associativities at one precedence, or chaining non-associative
+// operators, needs parentheses.
+func (state *reassociation) nest(children []expressionTree) expressionTree {
+	operands := []expressionTree{state.rewrite(children[0])}
+	operators := make([]pending, 0, len(children)/2)
+	for index := 1; index+1 < len(children); index += 2 {
+		operator := children[index]
+		current := state.operators.of(operator.GetValue().Value[0])
+		for len(operators) > 0 {
+			top := operators[len(operators)-1]
+			if top.fixity.Precedence < current.Precedence {
+				break
+			} else if top.fixity.Precedence == current.Precedence {
+				if top.fixity.Associativity != current.Associativity || current.Associativity == intermediate.ASSOCIATIVE_NONE {
+					state.ambiguous(operator, current, top)
+				} else if current.Associativity == intermediate.ASSOCIATIVE_RIGHT {
+					break
+				}
+			}
+
+			operands = state.reduce(operands, top)
+			operators = operators[:len(operators)-1]
+		}
+
+		operators = append(operators, pending{operator, current})
+		operands = append(operands, state.rewrite(children[index+1]))
+	}
+
+	for index := len(operators) - 1; index >= 0; index-- {
+		operands = state.reduce(operands, operators[index])
+	}
+
+	return operands[0]
+}
+
+func (state *reassociation) reduce(operands []expressionTree, operator pending) []expressionTree {
+	left, right := operands[len(operands)-2], operands[len(operands)-1]
+	value := operator.tree.GetValue()
+	var nested expressionTree
+	if IsBuiltin(value.Value[0]) {
+		nested = intermediate.NewExpressionTree(intermediate.SenimentExpression{
+			Position: left.GetValue().Position,
+			Op:       intermediate.OPCODE_CHAIN,
+		})
+		container.AddChildren(nested, left)
+		container.AddChildren(nested, operator.tree)
+	} else {
+		nested = intermediate.NewExpressionTree(intermediate.SenimentExpression{
+			Position: value.Position,
+			Op:       intermediate.OPCODE_CALL,
+		})
+		container.AddChildren(nested, intermediate.NewExpressionTree(intermediate.SenimentExpression{
+			Position: value.Position,
+			Op:       intermediate.OPCODE_REF,
+			Value:    []string{value.Value[0]},
+		}))
+		container.AddChildren(nested, left)
+	}
+
+	container.AddChildren(nested, right)
+	return append(operands[:len(operands)-2], nested)
+}
+
+func (state *reassociation) ambiguous(operator expressionTree, current intermediate.Fixity, previous pending) {
+	position := operator.GetValue().Position
+	if current.Operator == previous.fixity.Operator {
+		state.diagnostics.Errorf(position, "operator %v is non-associative and cannot be chained without parentheses", current.Operator)
+		return
+	}
+
+	var reported *diagnostic.Diagnostic
+	if current.Associativity == intermediate.ASSOCIATIVE_NONE && previous.fixity.Associativity == intermediate.ASSOCIATIVE_NONE {
+		reported = state.diagnostics.Errorf(
+			position,
+			"operators %v and %v are non-associative at precedence %d and cannot be mixed without parentheses",
+			previous.fixity.Operator, current.Operator, current.Precedence,
+		)
+	} else {
+		reported = state.diagnostics.Errorf(
+			position,
+			"operators %v and %v share precedence %d but are %v and %v, add parentheses to group them",
+			previous.fixity.Operator, current.Operator, current.Precedence, previous.fixity.Associativity, current.Associativity,
+		)
+	}
+
+	if previous.fixity.Position.Line > 0 {
+		reported.WithRelated(previous.fixity.Position, "%v is declared here", previous.fixity.Operator)
+	}
+
+	if current.Position.Line > 0 {
+		reported.WithRelated(current.Position, "%v is declared here", current.Operator)
+	}
+}
@@ -0,0 +1,120 @@
+package fixity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func leaf(op intermediate.OpCode, value ...string) expressionTree {
+	return intermediate.NewExpressionTree(intermediate.SenimentExpression{Op: op, Value: value})
+}
+
+// chain builds the flat chain lowering gives, alternating operands and
+// operators
+func chain(atoms ...string) expressionTree {
+	tree := leaf(intermediate.OPCODE_CHAIN)
+	for index, atom := range atoms {
+		if index%2 == 1 {
+			container.AddChildren(tree, leaf(intermediate.OPCODE_OPERATOR, atom))
+		} else {
+			container.AddChildren(tree, leaf(intermediate.OPCODE_REF, atom))
+		}
+	}
+
+	return tree
+}
+
+// format writes nested chains and calls with explicit parentheses
+func format(tree expressionTree) string {
+	expression := tree.GetValue()
+	parts := make([]string, 0, 3)
+	for _, child := range tree.GetChildren() {
+		parts = append(parts, format(child))
+	}
+
+	switch expression.Op {
+	case intermediate.OPCODE_CHAIN, intermediate.OPCODE_CALL:
+		return "(" + strings.Join(parts, " ") + ")"
+	}
+
+	return expression.Value[0]
+}
+
+func goalOf(body expressionTree, operators ...intermediate.Fixity) intermediate.Goal {
+	goal := intermediate.NewGoal()
+	goal.Sentments["main"] = intermediate.Sentiment{Name: "main", Definition: body}
+	for _, operator := range operators {
+		goal.Operators[operator.Operator] = operator
+	}
+
+	return goal
+}
+
+func TestReassociate(t *testing.T) {
+	concat := intermediate.Fixity{Operator: "++", Associativity: intermediate.ASSOCIATIVE_RIGHT, Precedence: 5}
+	for _, test := range []struct {
+		atoms    []string
+		expected string
+	}{
+		{[]string{"a", "+", "b", "*", "c"}, "(a + (b * c))"},
+		{[]string{"a", "-", "b", "-", "c"}, "((a - b) - c)"},
+		{[]string{"a", "+", "b", "<=", "c", "&", "d"}, "(((a + b) <= c) & d)"},
+		{[]string{"x", ".", "y", "+", "z"}, "((x . y) + z)"},
+		{[]string{"a", "++", "b", "++", "c"}, "(++ a (++ b c))"},
+		{[]string{"a", "++", "b", "+", "c"}, "(++ a (b + c))"},
+		{[]string{"a", "<>", "b", "*", "c"}, "((<> a b) * c)"},
+	} {
+		goal := goalOf(chain(test.atoms...), concat)
+		if diagnostics := Reassociate(goal); len(diagnostics) > 0 {
+			t.Fatalf("unexpected diagnostics for %v:\n%v", test.atoms, diagnostics)
+		}
+
+		if nested := format(goal.Sentments["main"].Definition); nested != test.expected {
+			t.Errorf("%v nested as %v, expected %v", strings.Join(test.atoms, " "), nested, test.expected)
+		}
+	}
+}
+
+func TestReassociateKeepsParentheses(t *testing.T) {
+	body := leaf(intermediate.OPCODE_CHAIN)
+	container.AddChildren(body, chain("a", "+", "b"))
+	container.AddChildren(body, leaf(intermediate.OPCODE_OPERATOR, "*"))
+	container.AddChildren(body, leaf(intermediate.OPCODE_REF, "c"))
+
+	goal := goalOf(body)
+	Reassociate(goal)
+	Reassociate(goal)
+	if nested := format(goal.Sentments["main"].Definition); nested != "((a + b) * c)" {
+		t.Errorf("nested as %v", nested)
+	}
+}
+
+func TestReassociateAmbiguity(t *testing.T) {
+	declared := lexer.Position{Filename: "ops.dfl", Line: 3}
+	right := intermediate.Fixity{Position: declared, Operator: "<+", Associativity: intermediate.ASSOCIATIVE_RIGHT, Precedence: 6}
+	goal := goalOf(leaf(intermediate.OPCODE_BLOCK), right)
+	goal.Sentments["chained"] = intermediate.Sentiment{Name: "chained", Definition: chain("a", "<", "b", "<", "c")}
+	goal.Sentments["mixed"] = intermediate.Sentiment{Name: "mixed", Definition: chain("a", "<", "b", "=", "c")}
+	goal.Sentments["sided"] = intermediate.Sentiment{Name: "sided", Definition: chain("a", "+", "b", "<+", "c")}
+
+	other := goalOf(leaf(intermediate.OPCODE_BLOCK), intermediate.Fixity{Operator: "<+", Associativity: intermediate.ASSOCIATIVE_LEFT, Precedence: 6})
+	diagnostics := Reassociate(goal, other)
+	if len(diagnostics) != 4 {
+		t.Fatalf("expected 4 diagnostics got:\n%v", diagnostics)
+	}
+
+	for index, fragment := range []string{
+		"operator <+ is declared left associative at precedence 6",
+		"operator < is non-associative and cannot be chained without parentheses",
+		"operators < and = are non-associative at precedence 4 and cannot be mixed without parentheses",
+		"operators + and <+ share precedence 6 but are left associative and right associative",
+	} {
+		if !strings.Contains(diagnostics[index].Message, fragment) {
+			t.Errorf("expected %q in %q", fragment, diagnostics[index].Message)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package intermediate
 
 import "github.com/tflexsoom/duffle/internal/container"
 
+// DataValue is a value of a data file. Struct fields given by name carry
+// their field name.
 type DataValue struct {
 	Type      TypeId
 	TextValue string
+	Name      string
 }
 
 type DataConfig struct {
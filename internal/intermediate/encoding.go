@@ -0,0 +1,291 @@
+package intermediate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Encoded modules start with MODULE_MAGIC and the version of the format
+// that follows. Bump MODULE_VERSION whenever the layout of a module, a
+// literal or an instruction changes, so stale caches are read as such.
+const (
+	MODULE_MAGIC   = "DFLM"
+	MODULE_VERSION = 1
+)
+
+// ErrModuleVersion is wrapped by the error of decoding a module written by
+// another version of the format
+var ErrModuleVersion = errors.New("unsupported module version")
+
+// MarshalBinary encodes a module in the versioned binary format. Numbers
+// are varints and texts are prefixed with their length.
+func (module Module) MarshalBinary() ([]byte, error) {
+	encoder := moduleEncoder{}
+	encoder.buffer.WriteString(MODULE_MAGIC)
+	encoder.unsigned(MODULE_VERSION)
+	encoder.unsigned(uint64(module.Entry))
+
+	encoder.unsigned(uint64(len(module.Values)))
+	for _, value := range module.Values {
+		encoder.literal(value)
+	}
+
+	encoder.unsigned(uint64(len(module.Intrinsics)))
+	for _, intrinsic := range module.Intrinsics {
+		encoder.text(intrinsic)
+	}
+
+	encoder.unsigned(uint64(len(module.Functions)))
+	for _, function := range module.Functions {
+		encoder.function(function)
+	}
+
+	return encoder.buffer.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a module written by MarshalBinary of the same
+// format version
+func (module *Module) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(MODULE_MAGIC)) {
+		return errors.New("data is not an encoded duffle module")
+	}
+
+	decoder := moduleDecoder{data: data[len(MODULE_MAGIC):]}
+	if version := decoder.unsigned(); decoder.err == nil && version != MODULE_VERSION {
+		return fmt.Errorf("%w %d, expected %d", ErrModuleVersion, version, MODULE_VERSION)
+	}
+
+	decoded := NewModule()
+	decoded.Entry = FunctionId(decoder.unsigned())
+	for count := decoder.count(); count > 0; count-- {
+		decoded.Values = append(decoded.Values, decoder.literal())
+	}
+
+	for count := decoder.count(); count > 0; count-- {
+		decoded.Intrinsics = append(decoded.Intrinsics, decoder.text())
+	}
+
+	for count := decoder.count(); count > 0; count-- {
+		decoded.Functions = append(decoded.Functions, decoder.function())
+	}
+
+	if decoder.err != nil {
+		return decoder.err
+	} else if len(decoder.data) > 0 {
+		return fmt.Errorf("encoded module has %d unexpected trailing bytes", len(decoder.data))
+	}
+
+	*module = decoded
+	return nil
+}
+
+type moduleEncoder struct {
+	buffer bytes.Buffer
+}
+
+func (encoder *moduleEncoder) unsigned(value uint64) {
+	encoder.buffer.Write(binary.AppendUvarint(nil, value))
+}
+
+func (encoder *moduleEncoder) signed(value int64) {
+	encoder.buffer.Write(binary.AppendVarint(nil, value))
+}
+
+func (encoder *moduleEncoder) text(value string) {
+	encoder.unsigned(uint64(len(value)))
+	encoder.buffer.WriteString(value)
+}
+
+func (encoder *moduleEncoder) boolean(value bool) {
+	if value {
+		encoder.unsigned(1)
+	} else {
+		encoder.unsigned(0)
+	}
+}
+
+func (encoder *moduleEncoder) literal(literal Literal) {
+	encoder.unsigned(uint64(literal.Type))
+	encoder.text(literal.TypeName)
+	encoder.boolean(literal.Boolean)
+	encoder.signed(literal.Integer)
+	encoder.unsigned(math.Float64bits(literal.Decimal))
+	encoder.text(literal.Text)
+	encoder.unsigned(uint64(len(literal.Items)))
+	for _, item := range literal.Items {
+		encoder.literal(item)
+	}
+}
+
+func (encoder *moduleEncoder) position(position lexer.Position) {
+	encoder.text(position.Filename)
+	encoder.signed(int64(position.Offset))
+	encoder.signed(int64(position.Line))
+	encoder.signed(int64(position.Column))
+}
+
+func (encoder *moduleEncoder) function(function Function) {
+	encoder.text(function.Name)
+	encoder.text(function.Type)
+	encoder.unsigned(uint64(function.Params))
+	encoder.unsigned(uint64(function.Captures))
+	encoder.unsigned(uint64(function.Registers))
+	encoder.unsigned(uint64(len(function.Definition)))
+	for _, instruction := range function.Definition {
+		encoder.unsigned(uint64(instruction.Instruction))
+		encoder.unsigned(uint64(instruction.Dest))
+		encoder.unsigned(instruction.Operand)
+		encoder.registers(instruction.Args)
+		encoder.position(instruction.Position)
+	}
+
+	encoder.unsigned(uint64(len(function.StackMaps)))
+	for _, stackMap := range function.StackMaps {
+		encoder.unsigned(uint64(stackMap.Instruction))
+		encoder.registers(stackMap.Live)
+	}
+}
+
+func (encoder *moduleEncoder) registers(registers []Register) {
+	encoder.unsigned(uint64(len(registers)))
+	for _, register := range registers {
+		encoder.unsigned(uint64(register))
+	}
+}
+
+// moduleDecoder keeps the first error it meets and reads zeroes after it,
+// so a truncated module is reported once at the end
+type moduleDecoder struct {
+	data []byte
+	err  error
+}
+
+func (decoder *moduleDecoder) fail(err error) {
+	if decoder.err == nil {
+		decoder.err = err
+	}
+
+	decoder.data = nil
+}
+
+func (decoder *moduleDecoder) unsigned() uint64 {
+	value, read := binary.Uvarint(decoder.data)
+	if read <= 0 {
+		decoder.fail(errors.New("encoded module is truncated"))
+		return 0
+	}
+
+	decoder.data = decoder.data[read:]
+	return value
+}
+
+func (decoder *moduleDecoder) signed() int64 {
+	value, read := binary.Varint(decoder.data)
+	if read <= 0 {
+		decoder.fail(errors.New("encoded module is truncated"))
+		return 0
+	}
+
+	decoder.data = decoder.data[read:]
+	return value
+}
+
+// count reads the length of a list, which cannot be more than the bytes
+// left since every entry takes at least one
+func (decoder *moduleDecoder) count() int {
+	count := decoder.unsigned()
+	if count > uint64(len(decoder.data)) {
+		decoder.fail(fmt.Errorf("encoded module lists %d entries in %d bytes", count, len(decoder.data)))
+		return 0
+	}
+
+	return int(count)
+}
+
+func (decoder *moduleDecoder) text() string {
+	length := decoder.count()
+	value := string(decoder.data[:length])
+	decoder.data = decoder.data[length:]
+	return value
+}
+
+func (decoder *moduleDecoder) literal() Literal {
+	literal := Literal{
+		Type:     TypeId(decoder.unsigned()),
+		TypeName: decoder.text(),
+		Boolean:  decoder.unsigned() != 0,
+		Integer:  decoder.signed(),
+		Decimal:  math.Float64frombits(decoder.unsigned()),
+		Text:     decoder.text(),
+	}
+
+	if count := decoder.count(); count > 0 {
+		literal.Items = make([]Literal, 0, count)
+		for ; count > 0; count-- {
+			literal.Items = append(literal.Items, decoder.literal())
+		}
+	}
+
+	return literal
+}
+
+func (decoder *moduleDecoder) position() lexer.Position {
+	return lexer.Position{
+		Filename: decoder.text(),
+		Offset:   int(decoder.signed()),
+		Line:     int(decoder.signed()),
+		Column:   int(decoder.signed()),
+	}
+}
+
+func (decoder *moduleDecoder) function() Function {
+	function := Function{
+		Name:      decoder.text(),
+		Type:      decoder.text(),
+		Params:    uint32(decoder.unsigned()),
+		Captures:  uint32(decoder.unsigned()),
+		Registers: uint32(decoder.unsigned()),
+	}
+
+	count := decoder.count()
+	function.Definition = make([]Instruction, 0, count)
+	for ; count > 0; count-- {
+		function.Definition = append(function.Definition, Instruction{
+			Instruction: InstructionCode(decoder.unsigned()),
+			Dest:        Register(decoder.unsigned()),
+			Operand:     decoder.unsigned(),
+			Args:        decoder.registers(),
+			Position:    decoder.position(),
+		})
+	}
+
+	count = decoder.count()
+	function.StackMaps = make([]StackMap, 0, count)
+	for ; count > 0; count-- {
+		function.StackMaps = append(function.StackMaps, StackMap{
+			Instruction: uint32(decoder.unsigned()),
+			Live:        decoder.registers(),
+		})
+	}
+
+	return function
+}
+
+func (decoder *moduleDecoder) registers() []Register {
+	count := decoder.count()
+	if count == 0 {
+		return nil
+	}
+
+	registers := make([]Register, 0, count)
+	for ; count > 0; count-- {
+		registers = append(registers, Register(decoder.unsigned()))
+	}
+
+	return registers
+}
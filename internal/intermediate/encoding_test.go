@@ -0,0 +1,82 @@
+package intermediate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+func encodedModule() Module {
+	module := NewModule()
+	greeting := module.ValueOf(TextLiteral("hello\n"))
+	module.ValueOf(ListLiteral("decimal", []Literal{DecimalLiteral(-1.5), DecimalLiteral(2)}))
+	module.ValueOf(IntegerLiteral(-42))
+	module.ValueOf(CharLiteral("é"))
+	module.ValueOf(BooleanLiteral(true))
+	module.Functions = append(module.Functions, Function{
+		Name:      "main",
+		Type:      "Function[List[text], integer]",
+		Params:    1,
+		Registers: 3,
+		Definition: []Instruction{
+			{Instruction: VALUE, Dest: 1, Operand: uint64(greeting), Position: lexer.Position{Filename: "main.dfl", Line: 3, Column: 5}},
+			{Instruction: INTRINSIC, Dest: 2, Operand: module.IntrinsicOf("dfl.sysout"), Args: []Register{1}},
+			{Instruction: RETURN, Args: []Register{2}},
+		},
+	})
+	module.Functions[0].StackMaps = StackMapsOf(module.Functions[0])
+	return module
+}
+
+func TestModuleEncoding(t *testing.T) {
+	module := encodedModule()
+	data, err := module.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := Module{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.String() != module.String() {
+		t.Errorf("module changed while encoding:\n%v\nexpected\n%v", decoded, module)
+	}
+
+	if position := decoded.Functions[0].Definition[0].Position; position.Filename != "main.dfl" || position.Line != 3 || position.Column != 5 {
+		t.Errorf("position changed while encoding: %v", position)
+	}
+}
+
+func TestModuleEncodingErrors(t *testing.T) {
+	data, err := encodedModule().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newer := append([]byte(MODULE_MAGIC), MODULE_VERSION+1)
+	newer = append(newer, data[len(MODULE_MAGIC)+1:]...)
+	for _, test := range []struct {
+		data     []byte
+		expected string
+	}{
+		{[]byte("not a module"), "not an encoded duffle module"},
+		{newer, "unsupported module version 2, expected 1"},
+		{data[:len(data)-3], "truncated"},
+		{append(append([]byte{}, data...), 0), "1 unexpected trailing bytes"},
+	} {
+		module := Module{}
+		err := module.UnmarshalBinary(test.data)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected %q, got %v", test.expected, err)
+		}
+	}
+
+	module := Module{}
+	if err := module.UnmarshalBinary(newer); !errors.Is(err, ErrModuleVersion) {
+		t.Errorf("expected a version error, got %v", err)
+	}
+}
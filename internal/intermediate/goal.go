@@ -1,13 +1,17 @@
 package intermediate
 
-import "github.com/tflexsoom/duffle/internal/container"
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/container"
+)
 
 type OpCode uint32
 
 type SenimentExpression struct {
-	TypeId TypeId
-	Op     OpCode
-	Value  []string
+	Position lexer.Position
+	TypeId   TypeId
+	Op       OpCode
+	Value    []string
 }
 
 type SentimentInput struct {
@@ -15,20 +19,149 @@ type SentimentInput struct {
 	TypeId TypeId
 }
 
+// TypeParam is a type variable a generic sentiment declares, with the
+// operators every type it stands for must support
+type TypeParam struct {
+	Position lexer.Position
+	Name     string
+	Bounds   []string
+}
+
 type Sentiment struct {
+	Position    lexer.Position
 	Annotations []string
 	Name        string
+	TypeParams  []TypeParam
 	Inputs      []SentimentInput
+	Result      TypeId
 	Definition  container.Tree[SenimentExpression]
 }
 
+type StructField struct {
+	Name   string
+	TypeId TypeId
+}
+
+type Struct struct {
+	Position lexer.Position
+	Name     string
+	Fields   []StructField
+}
+
+// FieldIndex gives the position of a field, or -1 when the struct has none
+// by that name
+func (structure Struct) FieldIndex(name string) int {
+	for index, field := range structure.Fields {
+		if field.Name == name {
+			return index
+		}
+	}
+
+	return -1
+}
+
+type Associativity uint32
+
+const (
+	ASSOCIATIVE_LEFT Associativity = iota
+	ASSOCIATIVE_RIGHT
+	ASSOCIATIVE_NONE
+)
+
+// Fixity is how an operator groups with the operators next to it in a
+// chain. Higher precedences group tighter.
+type Fixity struct {
+	Position      lexer.Position
+	Operator      string
+	Associativity Associativity
+	Precedence    int
+}
+
+func (associativity Associativity) String() string {
+	switch associativity {
+	case ASSOCIATIVE_LEFT:
+		return "left associative"
+	case ASSOCIATIVE_RIGHT:
+		return "right associative"
+	}
+
+	return "non-associative"
+}
+
+type Import struct {
+	Position lexer.Position
+	Alias    string
+	Path     []string
+}
+
 type Goal struct {
 	Sentments map[string]Sentiment
 	Types     map[TypeId]string
+	Constants map[string]Literal
+	Structs   map[string]Struct
+	Operators map[string]Fixity
+	Imports   []Import
 }
 
 const (
 	OPCODE_NOOP OpCode = iota
 	OPCODE_CONST
 	OPCODE_CALL
+	OPCODE_REF
+	OPCODE_OPERATOR
+	OPCODE_CHAIN
+	OPCODE_CAPTURE
+	OPCODE_EVALS
+	OPCODE_CLAUSE
+	OPCODE_PATTERN_WILDCARD
+	OPCODE_PATTERN_BIND
+	OPCODE_PATTERN_LITERAL
+	OPCODE_PATTERN_CONSTRUCTOR
+	OPCODE_CONSTEXPR
+	OPCODE_USE
+	OPCODE_BLOCK
+	OPCODE_LABEL
+	OPCODE_IF
+	OPCODE_RETURN
+)
+
+const RETURN_REFERENCE = "return"
+
+// Type checking resolves every reference by extending its value to
+// [name, kind, target], where target names the constant, sentiment,
+// standard member or struct the reference was resolved to. Fields read with
+// the field operator hold their index as target. Thunks are locals holding a
+// block capture of no inputs, which is called where it is referenced.
+const (
+	REFERENCE_LOCAL     = "local"
+	REFERENCE_THUNK     = "thunk"
+	REFERENCE_CONSTANT  = "constant"
+	REFERENCE_SENTIMENT = "sentiment"
+	REFERENCE_STANDARD  = "standard"
+	REFERENCE_STRUCT    = "struct"
+	REFERENCE_FIELD     = "field"
+)
+
+// FIELD_OPERATOR reads a field of a struct value, or projects the field out
+// of a struct type when its left operand names the struct
+const FIELD_OPERATOR = "."
+
+const (
+	LIST_EMPTY_CONSTRUCTOR = "[]"
+	LIST_CONS_CONSTRUCTOR  = "::"
 )
+
+func NewGoal() Goal {
+	return Goal{
+		Sentments: make(map[string]Sentiment),
+		Types:     make(map[TypeId]string),
+		Constants: make(map[string]Literal),
+		Structs:   make(map[string]Struct),
+		Operators: make(map[string]Fixity),
+		Imports:   make([]Import, 0),
+	}
+}
+
+func NewExpressionTree(expression SenimentExpression) container.Tree[SenimentExpression] {
+	return container.NewGraphTreeCap[SenimentExpression](2, 2).SetValue(expression)
+}
@@ -0,0 +1,145 @@
+package intermediate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/container"
+)
+
+// Literal is a fully evaluated compile-time value
+type Literal struct {
+	Type     TypeId
+	TypeName string
+	Boolean  bool
+	Integer  int64
+	Decimal  float64
+	Text     string
+	Items    []Literal
+}
+
+func BooleanLiteral(value bool) Literal {
+	return Literal{Type: TYPEID_BOOLEAN, TypeName: "boolean", Boolean: value}
+}
+
+func IntegerLiteral(value int64) Literal {
+	return Literal{Type: TYPEID_INTEGER, TypeName: "number", Integer: value}
+}
+
+func DecimalLiteral(value float64) Literal {
+	return Literal{Type: TYPEID_DECIMAL, TypeName: "decimal", Decimal: value}
+}
+
+// NothingLiteral is the value of expressions that produce no value
+func NothingLiteral() Literal {
+	return Literal{Type: TYPEID_NO_TYPE, TypeName: "nothing"}
+}
+
+func TextLiteral(value string) Literal {
+	return Literal{Type: TYPEID_TEXT, TypeName: "text", Text: value}
+}
+
+func CharLiteral(value string) Literal {
+	return Literal{Type: TYPEID_CHAR, TypeName: "char", Text: value}
+}
+
+func ListLiteral(elementType string, items []Literal) Literal {
+	return Literal{Type: TYPEID_LIST, TypeName: "List[" + elementType + "]", Items: items}
+}
+
+func (literal Literal) String() string {
+	switch literal.Type {
+	case TYPEID_BOOLEAN:
+		return strconv.FormatBool(literal.Boolean)
+	case TYPEID_INTEGER, TYPEID_BYTE:
+		return strconv.FormatInt(literal.Integer, 10)
+	case TYPEID_DECIMAL:
+		return strconv.FormatFloat(literal.Decimal, 'f', -1, 64)
+	case TYPEID_TEXT:
+		return strconv.Quote(literal.Text)
+	case TYPEID_CHAR:
+		return "'" + strings.Trim(strconv.Quote(literal.Text), "\"") + "'"
+	case TYPEID_LIST, TYPEID_STRUCT:
+		items := make([]string, 0, len(literal.Items))
+		for _, item := range literal.Items {
+			items = append(items, item.String())
+		}
+
+		if literal.Type == TYPEID_LIST {
+			return "[" + strings.Join(items, ", ") + "]"
+		}
+
+		return "(" + strings.Join(items, ", ") + ")"
+	}
+
+	return fmt.Sprintf("<%v>", literal.TypeName)
+}
+
+var literalEscapes = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\r`, "\r", `\\`, "\\", `\'`, "'", `\"`, "\"")
+
+// ParseLiteral reads the source text of a literal token of the given type
+func ParseLiteral(typeId TypeId, text string) (Literal, error) {
+	switch typeId {
+	case TYPEID_BOOLEAN:
+		value, err := strconv.ParseBool(text)
+		return BooleanLiteral(value), err
+	case TYPEID_INTEGER:
+		value, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return Literal{}, fmt.Errorf("integer literal %v does not fit in 64 bits", text)
+		}
+
+		return IntegerLiteral(value), nil
+	case TYPEID_DECIMAL:
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return Literal{}, fmt.Errorf("decimal literal %v is out of range", text)
+		}
+
+		return DecimalLiteral(value), nil
+	case TYPEID_TEXT:
+		return TextLiteral(literalEscapes.Replace(strings.Trim(text, `"`))), nil
+	case TYPEID_CHAR:
+		value := literalEscapes.Replace(strings.Trim(text, `'`))
+		if len([]rune(value)) != 1 {
+			return Literal{}, fmt.Errorf("char literal %v must hold exactly one character", text)
+		}
+
+		return CharLiteral(value), nil
+	}
+
+	return Literal{}, fmt.Errorf("%v is not a literal", text)
+}
+
+// LiteralOfData converts parsed data file values into literals
+func LiteralOfData(tree container.Tree[DataValue]) (Literal, error) {
+	value := tree.GetValue()
+	switch value.Type {
+	case TYPEID_NO_TYPE:
+		children := tree.GetChildren()
+		if len(children) != 1 {
+			return Literal{}, fmt.Errorf("data value is empty")
+		}
+
+		return LiteralOfData(children[0])
+	case TYPEID_LIST, TYPEID_STRUCT:
+		items := make([]Literal, 0, len(tree.GetChildren()))
+		for _, child := range tree.GetChildren() {
+			item, err := LiteralOfData(child)
+			if err != nil {
+				return Literal{}, err
+			}
+
+			items = append(items, item)
+		}
+
+		if value.Type == TYPEID_STRUCT {
+			return Literal{Type: TYPEID_STRUCT, TypeName: "struct", Items: items}, nil
+		}
+
+		return ListLiteral("a", items), nil
+	}
+
+	return ParseLiteral(value.Type, value.TextValue)
+}
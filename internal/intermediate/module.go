@@ -1,38 +1,185 @@
 package intermediate
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
 type InstructionCode uint64
 
 const (
-	NOOP InstructionCode = iota
-	PARAM
-	VALUE
-	//...
+	NOOP      InstructionCode = iota
+	VALUE                     // Dest = module value Operand
+	MOVE                      // Dest = Args[0]
+	CALL                      // Dest = function Operand called with Args
+	INTRINSIC                 // Dest = intrinsic Operand called with Args
+	APPLY                     // Dest = function value Args[0] called with Args[1:]
+	CLOSURE                   // Dest = function value of function Operand capturing Args
+	JUMP                      // continue at instruction Operand
+	BRANCH                    // continue at instruction Operand when Args[0] is false
+	RETURN                    // return Args[0]
+	FIELD                     // Dest = field Operand of Args[0]
+	TAILCALL                  // return function Operand called with Args in place of this frame
+	TAILAPPLY                 // return function value Args[0] called with Args[1:] in place of this frame
 )
 
+var InstructionNames = map[InstructionCode]string{
+	NOOP:      "noop",
+	VALUE:     "value",
+	MOVE:      "move",
+	CALL:      "call",
+	INTRINSIC: "intrinsic",
+	APPLY:     "apply",
+	CLOSURE:   "closure",
+	JUMP:      "jump",
+	BRANCH:    "branch",
+	RETURN:    "return",
+	FIELD:     "field",
+	TAILCALL:  "tailcall",
+	TAILAPPLY: "tailapply",
+}
+
 type FunctionId uint64
 type ValueId uint64
 
-const NOT_A_VALUE_ID int64 = int64(-1)
+// Register numbers the local slots of a function frame
+type Register uint32
 
-type Value struct {
-	LiteralValue []byte
-	MemoryValue  ValueId
-	CacheValue   uint8
-}
+const NOT_A_VALUE_ID int64 = int64(-1)
 
 type Instruction struct {
 	Instruction InstructionCode
-	Args        []Value
+	Dest        Register
+	Operand     uint64
+	Args        []Register
+	Position    lexer.Position
 }
 
+// Function inputs arrive in the first registers. Closures receive their
+// captured values ahead of their own inputs. Type is the Function[...] type
+// the function was checked with, without the captured values.
 type Function struct {
-	Name            string
-	Definition      []Instruction
-	RequiredSymbols string
-	RequiredValues  ValueId
+	Name       string
+	Type       string
+	Params     uint32
+	Captures   uint32
+	Registers  uint32
+	Definition []Instruction
+	StackMaps  []StackMap
 }
 
 type Module struct {
-	Functions map[FunctionId]Function
-	Values    map[ValueId][]byte
+	Functions  []Function
+	Values     []Literal
+	Intrinsics []string
+	Entry      FunctionId
+}
+
+func NewModule() Module {
+	return Module{
+		Functions:  make([]Function, 0, 16),
+		Values:     make([]Literal, 0, 16),
+		Intrinsics: make([]string, 0, 8),
+	}
+}
+
+// ValueOf interns a literal into the module's value table
+func (module *Module) ValueOf(literal Literal) ValueId {
+	for id, value := range module.Values {
+		if value.Type == literal.Type && value.String() == literal.String() {
+			return ValueId(id)
+		}
+	}
+
+	module.Values = append(module.Values, literal)
+	return ValueId(len(module.Values) - 1)
+}
+
+// IntrinsicOf interns the name of an intrinsic into the module's intrinsic table
+func (module *Module) IntrinsicOf(name string) uint64 {
+	for id, intrinsic := range module.Intrinsics {
+		if intrinsic == name {
+			return uint64(id)
+		}
+	}
+
+	module.Intrinsics = append(module.Intrinsics, name)
+	return uint64(len(module.Intrinsics) - 1)
+}
+
+func (instruction Instruction) String() string {
+	builder := strings.Builder{}
+	if code := instruction.Instruction; code != JUMP && code != BRANCH && code != RETURN && !IsTailCall(code) {
+		builder.WriteString(fmt.Sprintf("r%d = ", instruction.Dest))
+	}
+
+	builder.WriteString(InstructionNames[instruction.Instruction])
+	switch instruction.Instruction {
+	case VALUE, CALL, INTRINSIC, CLOSURE, JUMP, BRANCH, FIELD, TAILCALL:
+		builder.WriteString(fmt.Sprintf(" #%d", instruction.Operand))
+	}
+
+	for _, arg := range instruction.Args {
+		builder.WriteString(fmt.Sprintf(" r%d", arg))
+	}
+
+	return builder.String()
+}
+
+// String lists the module in a readable assembly-like form
+func (module Module) String() string {
+	builder := strings.Builder{}
+	for id, value := range module.Values {
+		builder.WriteString(fmt.Sprintf("value #%d = %v\n", id, value))
+	}
+
+	for id, intrinsic := range module.Intrinsics {
+		builder.WriteString(fmt.Sprintf("intrinsic #%d = %v\n", id, intrinsic))
+	}
+
+	for id := range module.Functions {
+		builder.WriteString("\n")
+		builder.WriteString(module.FormatFunction(FunctionId(id)))
+	}
+
+	return builder.String()
+}
+
+// FormatFunction lists a single function of the module the way String does
+func (module Module) FormatFunction(id FunctionId) string {
+	builder := strings.Builder{}
+	function := module.Functions[id]
+	entry := ""
+	if id == module.Entry {
+		entry = " entry"
+	}
+
+	if function.Type != "" {
+		entry = " type=" + function.Type + entry
+	}
+
+	builder.WriteString(fmt.Sprintf(
+		"function #%d %v params=%d captures=%d registers=%d%v\n",
+		id, function.Name, function.Params, function.Captures, function.Registers, entry,
+	))
+	stackMaps := make(map[uint32][]Register, len(function.StackMaps))
+	for _, stackMap := range function.StackMaps {
+		stackMaps[stackMap.Instruction] = stackMap.Live
+	}
+
+	for index, instruction := range function.Definition {
+		builder.WriteString(fmt.Sprintf("  %3d: %v", index, instruction))
+		if live, isSafepoint := stackMaps[uint32(index)]; isSafepoint {
+			builder.WriteString(" ; live")
+			for _, register := range live {
+				builder.WriteString(fmt.Sprintf(" r%d", register))
+			}
+		}
+
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
 }
@@ -0,0 +1,108 @@
+package intermediate
+
+import "sort"
+
+// StackMap lists the registers still in use while a safepoint runs. The
+// collector treats exactly those registers of a frame as roots.
+type StackMap struct {
+	Instruction uint32
+	Live        []Register
+}
+
+// IsSafepoint reports instructions that may allocate or call, which are the
+// only places a collection can happen
+func IsSafepoint(code InstructionCode) bool {
+	return code == CALL || code == INTRINSIC || code == APPLY || code == CLOSURE
+}
+
+// HasDest reports instructions that write a register
+func HasDest(code InstructionCode) bool {
+	return code != NOOP && code != JUMP && code != BRANCH && code != RETURN && !IsTailCall(code)
+}
+
+// IsTailCall reports calls that end their function, replacing its frame
+// with the callee's
+func IsTailCall(code InstructionCode) bool {
+	return code == TAILCALL || code == TAILAPPLY
+}
+
+// SuccessorsOf gives the instructions that may run after the one at index.
+// The end of the definition is a successor of its last instruction.
+func SuccessorsOf(definition []Instruction, index int) []int {
+	instruction := definition[index]
+	switch instruction.Instruction {
+	case JUMP:
+		return []int{int(instruction.Operand)}
+	case BRANCH:
+		return []int{index + 1, int(instruction.Operand)}
+	case RETURN, TAILCALL, TAILAPPLY:
+		return nil
+	}
+
+	return []int{index + 1}
+}
+
+// StackMapsOf finds the live registers at every safepoint of a function by
+// solving liveness backwards over its instructions
+func StackMapsOf(function Function) []StackMap {
+	definition := function.Definition
+	liveIn := make([]map[Register]bool, len(definition)+1)
+	liveOut := make([]map[Register]bool, len(definition))
+	for index := range liveIn {
+		liveIn[index] = make(map[Register]bool)
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for index := len(definition) - 1; index >= 0; index-- {
+			out := make(map[Register]bool)
+			for _, successor := range SuccessorsOf(definition, index) {
+				if successor <= len(definition) {
+					for register := range liveIn[successor] {
+						out[register] = true
+					}
+				}
+			}
+
+			in := make(map[Register]bool, len(out))
+			for register := range out {
+				in[register] = true
+			}
+
+			instruction := definition[index]
+			if HasDest(instruction.Instruction) {
+				delete(in, instruction.Dest)
+			}
+
+			for _, arg := range instruction.Args {
+				in[arg] = true
+			}
+
+			liveOut[index] = out
+			if len(in) != len(liveIn[index]) {
+				changed = true
+			}
+
+			liveIn[index] = in
+		}
+	}
+
+	stackMaps := make([]StackMap, 0, len(definition)/2)
+	for index, instruction := range definition {
+		if !IsSafepoint(instruction.Instruction) {
+			continue
+		}
+
+		live := make([]Register, 0, len(liveOut[index]))
+		for register := range liveOut[index] {
+			if register != instruction.Dest {
+				live = append(live, register)
+			}
+		}
+
+		sort.Slice(live, func(i, j int) bool { return live[i] < live[j] })
+		stackMaps = append(stackMaps, StackMap{Instruction: uint32(index), Live: live})
+	}
+
+	return stackMaps
+}
@@ -13,3 +13,48 @@ const (
 	TYPEID_LIST           // 0+ bytes For Configuration
 	TYPEID_STRUCT         // 0+ bytes For Configuration
 )
+
+// First Id handed out to named types
+const TYPEID_USER_DEFINED = TYPEID_STRUCT + 1
+
+var PrimitiveTypeNames = map[string]TypeId{
+	"boolean": TYPEID_BOOLEAN,
+	"byte":    TYPEID_BYTE,
+	"char":    TYPEID_CHAR,
+	"integer": TYPEID_INTEGER,
+	"number":  TYPEID_INTEGER,
+	"decimal": TYPEID_DECIMAL,
+	"text":    TYPEID_TEXT,
+	"List":    TYPEID_LIST,
+}
+
+func (goal Goal) TypeIdOf(typeName string) TypeId {
+	if typeId, isOk := PrimitiveTypeNames[typeName]; isOk {
+		return typeId
+	}
+
+	for typeId, name := range goal.Types {
+		if name == typeName {
+			return typeId
+		}
+	}
+
+	typeId := TYPEID_USER_DEFINED + TypeId(len(goal.Types))
+	goal.Types[typeId] = typeName
+	return typeId
+}
+
+// TypeNameOf gives the name a type id was allocated for
+func (goal Goal) TypeNameOf(typeId TypeId) string {
+	if name, isOk := goal.Types[typeId]; isOk {
+		return name
+	}
+
+	for name, primitive := range PrimitiveTypeNames {
+		if primitive == typeId && name != "number" {
+			return name
+		}
+	}
+
+	return ""
+}
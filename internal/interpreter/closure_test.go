@@ -0,0 +1,95 @@
+package interpreter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+func capture(inputs []string, children ...node) node {
+	return parent(intermediate.OPCODE_CAPTURE, inputs, parent(intermediate.OPCODE_BLOCK, nil, children...))
+}
+
+func label(name string, value node) node {
+	return parent(intermediate.OPCODE_LABEL, []string{name}, value)
+}
+
+func returns(value node) node {
+	return parent(intermediate.OPCODE_RETURN, nil, value)
+}
+
+// closingGoal holds captures using locals of main, one of them through a
+// capture nested in another
+func closingGoal() intermediate.Goal {
+	goal := countingGoal()
+	goal.Sentments["main"] = intermediate.Sentiment{
+		Annotations: []string{annotation.EXEC_ANNOTATION},
+		Name:        "main",
+		Inputs:      []intermediate.SentimentInput{{Name: "args", TypeId: goal.TypeIdOf("List[text]")}},
+		Result:      intermediate.TYPEID_INTEGER,
+		Definition: parent(intermediate.OPCODE_BLOCK, nil,
+			label("offset", call(ref("count"), ref("args"))),
+			label("adder", capture([]string{"x"},
+				returns(parent(intermediate.OPCODE_CHAIN, nil, ref("x"), operator("+"), ref("offset"))),
+			)),
+			label("twice", capture([]string{"y"},
+				label("again", capture([]string{"z"},
+					returns(parent(intermediate.OPCODE_CHAIN, nil, call(ref("adder"), ref("z")), operator("+"), ref("offset"))),
+				)),
+				returns(call(ref("again"), ref("y"))),
+			)),
+			returns(call(ref("twice"), integer("10"))),
+		),
+	}
+
+	return goal
+}
+
+func TestClosures(t *testing.T) {
+	module := moduleOf(t, closingGoal())
+	program, err := New(module, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := program.Run([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%v", err, module)
+	}
+
+	if !result.Equal(runtime.Integer(16)) {
+		t.Errorf("unexpected result %v\n%v", result.Format(), module)
+	}
+
+	expected := map[string]struct {
+		captures uint32
+		typeName string
+	}{
+		"main":                   {0, "Function[List[text], integer]"},
+		"main$capture1":          {1, "Function[integer, integer]"},
+		"main$capture2":          {2, "Function[integer, integer]"},
+		"main$capture2$capture1": {2, "Function[integer, integer]"},
+	}
+
+	for _, function := range module.Functions {
+		if want, isOk := expected[function.Name]; isOk && (function.Captures != want.captures || function.Type != want.typeName) {
+			t.Errorf("%v captures %d with type %v, expected %d with type %v", function.Name, function.Captures, function.Type, want.captures, want.typeName)
+		}
+	}
+}
+
+func TestClosuresUnderStress(t *testing.T) {
+	program, err := New(moduleOf(t, closingGoal()), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program.Heap().Stress = true
+	result, err := program.Run([]string{"a", "b"})
+	if err != nil || !result.Equal(runtime.Integer(14)) {
+		t.Errorf("expected 14 but found %v (%v)", result.Format(), err)
+	}
+}
@@ -0,0 +1,81 @@
+package interpreter
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+// joiningGoal keeps a joined list alive across a call that allocates
+func joiningGoal() intermediate.Goal {
+	goal := countingGoal()
+	goal.Imports = append(goal.Imports, intermediate.Import{Alias: "concat", Path: []string{"dfl", "concat"}})
+
+	main := goal.Sentments["main"]
+	main.Definition = parent(intermediate.OPCODE_BLOCK, nil,
+		parent(intermediate.OPCODE_LABEL, []string{"joined"}, call(ref("concat"), ref("args"), ref("args"))),
+		parent(intermediate.OPCODE_LABEL, []string{"total"}, call(ref("count"), ref("joined"))),
+		call(ref("sysout"), ref("joined")),
+		parent(intermediate.OPCODE_RETURN, nil, ref("total")),
+	)
+	goal.Sentments["main"] = main
+	return goal
+}
+
+func TestStressCollection(t *testing.T) {
+	stdout, trace := bytes.Buffer{}, bytes.Buffer{}
+	program, err := New(moduleOf(t, joiningGoal()), &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program.Heap().Stress = true
+	program.Heap().Trace = &trace
+	result, err := program.Run([]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !result.Equal(runtime.Integer(4)) || stdout.String() != `["a", "b", "a", "b"]` {
+		t.Errorf("unexpected result %v and output %q", result.Format(), stdout.String())
+	}
+
+	stats := program.Heap().Statistics()
+	if stats.Collections != stats.Allocations || stats.Freed == 0 {
+		t.Errorf("expected a collection per allocation that frees objects, got %v", stats)
+	}
+
+	if !strings.HasPrefix(trace.String(), "gc 1: freed 0 objects") {
+		t.Errorf("unexpected trace %q", trace.String())
+	}
+}
+
+func TestStressFindsMissingRoots(t *testing.T) {
+	module := moduleOf(t, joiningGoal())
+	for index, function := range module.Functions {
+		if function.Name != "main" {
+			continue
+		}
+
+		// Forget that the joined list is still needed while count runs
+		for mapIndex, stackMap := range function.StackMaps {
+			if function.Definition[stackMap.Instruction].Instruction == intermediate.CALL {
+				module.Functions[index].StackMaps[mapIndex].Live = nil
+			}
+		}
+	}
+
+	program, err := New(module, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program.Heap().Stress = true
+	if _, err := program.Run([]string{"a", "b"}); !errors.Is(err, runtime.ErrCollected) {
+		t.Fatalf("expected a use of a collected value, got %v", err)
+	}
+}
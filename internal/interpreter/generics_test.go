@@ -0,0 +1,102 @@
+package interpreter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+// genericGoal uses a bounded generic at two types and a recursive generic
+// whose recursion must reuse its own specialization
+func genericGoal() intermediate.Goal {
+	goal := countingGoal()
+	typeA := goal.TypeIdOf("a")
+	goal.Sentments["pick"] = intermediate.Sentiment{
+		Name:       "pick",
+		TypeParams: []intermediate.TypeParam{{Name: "a", Bounds: []string{"<="}}},
+		Inputs:     []intermediate.SentimentInput{{Name: "x", TypeId: typeA}, {Name: "y", TypeId: typeA}},
+		Result:     typeA,
+		Definition: parent(intermediate.OPCODE_BLOCK, nil,
+			parent(intermediate.OPCODE_IF, nil,
+				parent(intermediate.OPCODE_CHAIN, nil, ref("x"), operator("<="), ref("y")),
+				returns(ref("x")),
+			),
+			returns(ref("y")),
+		),
+	}
+
+	goal.Sentments["repeat"] = intermediate.Sentiment{
+		Name:       "repeat",
+		TypeParams: []intermediate.TypeParam{{Name: "a"}},
+		Inputs:     []intermediate.SentimentInput{{Name: "x", TypeId: typeA}, {Name: "n", TypeId: intermediate.TYPEID_INTEGER}},
+		Result:     typeA,
+		Definition: parent(intermediate.OPCODE_BLOCK, nil,
+			parent(intermediate.OPCODE_IF, nil,
+				parent(intermediate.OPCODE_CHAIN, nil, ref("n"), operator("<="), integer("0")),
+				returns(ref("x")),
+			),
+			returns(call(ref("repeat"), ref("x"), parent(intermediate.OPCODE_CHAIN, nil, ref("n"), operator("-"), integer("1")))),
+		),
+	}
+
+	goal.Sentments["main"] = intermediate.Sentiment{
+		Annotations: []string{annotation.EXEC_ANNOTATION},
+		Name:        "main",
+		Inputs:      []intermediate.SentimentInput{{Name: "args", TypeId: goal.TypeIdOf("List[text]")}},
+		Result:      intermediate.TYPEID_INTEGER,
+		Definition: parent(intermediate.OPCODE_BLOCK, nil,
+			label("small", call(ref("pick"), integer("3"), integer("7"))),
+			call(ref("sysout"), call(ref("pick"), text("b"), text("a"))),
+			returns(parent(intermediate.OPCODE_CHAIN, nil,
+				ref("small"),
+				operator("+"),
+				call(ref("repeat"), integer("4"), integer("5")),
+			)),
+		),
+	}
+
+	return goal
+}
+
+func TestGenerics(t *testing.T) {
+	module := moduleOf(t, genericGoal())
+	stdout := bytes.Buffer{}
+	program, err := New(module, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := program.Run(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%v", err, module)
+	}
+
+	if !result.Equal(runtime.Integer(7)) || stdout.String() != "a" {
+		t.Errorf("unexpected result %v and output %q", result.Format(), stdout.String())
+	}
+
+	expected := map[string]string{
+		"pick[integer]":   "Function[integer, integer, integer]",
+		"pick[text]":      "Function[text, text, text]",
+		"repeat[integer]": "Function[integer, integer, integer]",
+	}
+
+	for _, function := range module.Functions {
+		if function.Name == "pick" || function.Name == "repeat" {
+			t.Errorf("generic %v was emitted without being specialized\n%v", function.Name, module)
+		} else if typeName, isOk := expected[function.Name]; isOk {
+			if function.Type != typeName {
+				t.Errorf("%v has type %v, expected %v", function.Name, function.Type, typeName)
+			}
+
+			delete(expected, function.Name)
+		}
+	}
+
+	for name := range expected {
+		t.Errorf("expected %v to be specialized\n%v", name, module)
+	}
+}
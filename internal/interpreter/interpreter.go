@@ -0,0 +1,309 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+
+	// The standard library registers its intrinsics when it is loaded
+	_ "github.com/tflexsoom/duffle/internal/stdlib"
+)
+
+const DEFAULT_MAX_DEPTH = 10000
+
+type RuntimeError struct {
+	Position lexer.Position
+	Function string
+	Err      error
+}
+
+func (err RuntimeError) Error() string {
+	return fmt.Sprintf("%v: runtime error in %v: %v", err.Position, err.Function, err.Err)
+}
+
+func (err RuntimeError) Unwrap() error {
+	return err.Err
+}
+
+type Interpreter struct {
+	MaxDepth   int
+	module     intermediate.Module
+	values     []runtime.Value
+	intrinsics []runtime.Intrinsic
+	stackMaps  [][][]intermediate.Register
+	stdout     io.Writer
+	heap       *runtime.Heap
+	frames     []frame
+}
+
+type frame struct {
+	id        intermediate.FunctionId
+	function  *intermediate.Function
+	registers []runtime.Value
+	counter   int
+	dest      intermediate.Register
+}
+
+// New prepares a module for running, failing when the module needs an
+// intrinsic this build does not have
+func New(module intermediate.Module, stdout io.Writer) (*Interpreter, error) {
+	interpreter := &Interpreter{
+		MaxDepth:   DEFAULT_MAX_DEPTH,
+		module:     module,
+		values:     make([]runtime.Value, 0, len(module.Values)),
+		intrinsics: make([]runtime.Intrinsic, 0, len(module.Intrinsics)),
+		stackMaps:  make([][][]intermediate.Register, 0, len(module.Functions)),
+		stdout:     stdout,
+		heap:       runtime.NewHeap(),
+		frames:     make([]frame, 0, 64),
+	}
+
+	interpreter.heap.Roots = interpreter.roots
+	for _, function := range module.Functions {
+		interpreter.stackMaps = append(interpreter.stackMaps, stackMapsOf(function))
+	}
+
+	for _, value := range module.Values {
+		interpreter.values = append(interpreter.values, runtime.FromLiteral(value))
+	}
+
+	for _, name := range module.Intrinsics {
+		intrinsic, isOk := runtime.Lookup(name)
+		if !isOk {
+			return nil, fmt.Errorf("module needs unknown intrinsic %v", name)
+		}
+
+		interpreter.intrinsics = append(interpreter.intrinsics, intrinsic)
+	}
+
+	if len(module.Functions) <= int(module.Entry) {
+		return nil, fmt.Errorf("module has no entry function")
+	}
+
+	return interpreter, nil
+}
+
+// stackMapsOf indexes the live registers of a function by instruction. A
+// function without stack maps has every register scanned instead.
+func stackMapsOf(function intermediate.Function) [][]intermediate.Register {
+	if len(function.StackMaps) == 0 {
+		return nil
+	}
+
+	stackMaps := make([][]intermediate.Register, len(function.Definition))
+	for _, stackMap := range function.StackMaps {
+		stackMaps[stackMap.Instruction] = append(make([]intermediate.Register, 0, len(stackMap.Live)), stackMap.Live...)
+	}
+
+	return stackMaps
+}
+
+func (interpreter *Interpreter) Stdout() io.Writer {
+	return interpreter.stdout
+}
+
+func (interpreter *Interpreter) Heap() *runtime.Heap {
+	return interpreter.heap
+}
+
+// roots marks the registers every frame still uses at the safepoint it is
+// stopped at
+func (interpreter *Interpreter) roots(mark func(runtime.Value)) {
+	for _, current := range interpreter.frames {
+		stackMaps := interpreter.stackMaps[current.id]
+		if stackMaps == nil {
+			for _, value := range current.registers {
+				mark(value)
+			}
+
+			continue
+		} else if current.counter == 0 {
+			continue
+		}
+
+		for _, register := range stackMaps[current.counter-1] {
+			mark(current.registers[register])
+		}
+	}
+}
+
+// Run calls the entry function. An entry taking an input receives the
+// arguments as a list of text.
+func (interpreter *Interpreter) Run(args []string) (runtime.Value, error) {
+	entry := interpreter.module.Functions[interpreter.module.Entry]
+	inputs := make([]runtime.Value, 0, 1)
+	if entry.Params == 1 {
+		items := make([]runtime.Value, 0, len(args))
+		for _, arg := range args {
+			items = append(items, runtime.Text(arg))
+		}
+
+		inputs = append(inputs, runtime.List(items))
+	} else if entry.Params > 1 {
+		return runtime.Nothing, fmt.Errorf("entry %v takes %d inputs but at most 1 is supported", entry.Name, entry.Params)
+	}
+
+	return interpreter.call(interpreter.module.Entry, inputs)
+}
+
+func (interpreter *Interpreter) Apply(function runtime.Value, args ...runtime.Value) (runtime.Value, error) {
+	if function.Kind != runtime.KIND_FUNCTION {
+		return runtime.Nothing, fmt.Errorf("%v is not a function", function.Format())
+	}
+
+	return interpreter.call(intermediate.FunctionId(function.Integer), append(append([]runtime.Value{}, function.Items()...), args...))
+}
+
+func (interpreter *Interpreter) newFrame(id intermediate.FunctionId, args []runtime.Value) (frame, error) {
+	if len(interpreter.module.Functions) <= int(id) {
+		return frame{}, fmt.Errorf("function #%d does not exist", id)
+	}
+
+	function := &interpreter.module.Functions[id]
+	if uint32(len(args)) != function.Params+function.Captures {
+		return frame{}, fmt.Errorf("%v takes %d inputs but was given %d", function.Name, function.Params, len(args)-int(function.Captures))
+	}
+
+	registers := make([]runtime.Value, function.Registers)
+	copy(registers, args)
+	return frame{id: id, function: function, registers: registers}, nil
+}
+
+func (interpreter *Interpreter) push(next frame) error {
+	if len(interpreter.frames) >= interpreter.MaxDepth {
+		return fmt.Errorf("calls are nested deeper than %d", interpreter.MaxDepth)
+	}
+
+	interpreter.frames = append(interpreter.frames, next)
+	return nil
+}
+
+// call runs a function to completion. Calls between module functions are
+// kept on the frame stack, only intrinsics calling back into the module
+// nest on the Go stack. Tail calls replace the frame of their caller, so
+// they never count towards the maximum depth.
+func (interpreter *Interpreter) call(id intermediate.FunctionId, args []runtime.Value) (runtime.Value, error) {
+	base := len(interpreter.frames)
+	defer func() { interpreter.frames = interpreter.frames[:base] }()
+
+	first, err := interpreter.newFrame(id, args)
+	if err != nil {
+		return runtime.Nothing, err
+	} else if err := interpreter.push(first); err != nil {
+		return runtime.Nothing, err
+	}
+
+	for {
+		current := &interpreter.frames[len(interpreter.frames)-1]
+		if current.counter >= len(current.function.Definition) {
+			return runtime.Nothing, fmt.Errorf("%v ends without returning", current.function.Name)
+		}
+
+		instruction := current.function.Definition[current.counter]
+		current.counter++
+		registers := current.registers
+
+		fail := func(err error) (runtime.Value, error) {
+			if _, isRuntime := err.(RuntimeError); isRuntime {
+				return runtime.Nothing, err
+			}
+
+			return runtime.Nothing, RuntimeError{Position: instruction.Position, Function: current.function.Name, Err: err}
+		}
+
+		inputs, err := interpreter.read(registers, instruction.Args)
+		if err != nil {
+			return fail(err)
+		}
+
+		switch instruction.Instruction {
+		case intermediate.NOOP:
+		case intermediate.VALUE:
+			registers[instruction.Dest] = interpreter.values[instruction.Operand]
+		case intermediate.MOVE:
+			registers[instruction.Dest] = inputs[0]
+		case intermediate.CALL, intermediate.APPLY, intermediate.TAILCALL, intermediate.TAILAPPLY:
+			id := intermediate.FunctionId(instruction.Operand)
+			if instruction.Instruction == intermediate.APPLY || instruction.Instruction == intermediate.TAILAPPLY {
+				callee := inputs[0]
+				if callee.Kind != runtime.KIND_FUNCTION {
+					return fail(fmt.Errorf("%v is not a function", callee.Format()))
+				}
+
+				id = intermediate.FunctionId(callee.Integer)
+				inputs = append(append([]runtime.Value{}, callee.Items()...), inputs[1:]...)
+			}
+
+			next, err := interpreter.newFrame(id, inputs)
+			if err != nil {
+				return fail(err)
+			}
+
+			if intermediate.IsTailCall(instruction.Instruction) {
+				next.dest = current.dest
+				*current = next
+				continue
+			}
+
+			next.dest = instruction.Dest
+			if err := interpreter.push(next); err != nil {
+				return fail(err)
+			}
+		case intermediate.INTRINSIC:
+			// Arguments may be dead after the call but the intrinsic still uses them
+			mark := interpreter.heap.Pin(inputs...)
+			result, err := interpreter.intrinsics[instruction.Operand](interpreter, inputs)
+			interpreter.heap.Release(mark)
+			if err != nil {
+				return fail(err)
+			}
+
+			registers[instruction.Dest] = result
+		case intermediate.CLOSURE:
+			registers[instruction.Dest] = interpreter.heap.Function(intermediate.FunctionId(instruction.Operand), inputs)
+		case intermediate.JUMP:
+			current.counter = int(instruction.Operand)
+		case intermediate.BRANCH:
+			if !inputs[0].Bool() {
+				current.counter = int(instruction.Operand)
+			}
+		case intermediate.RETURN:
+			dest := current.dest
+			interpreter.frames = interpreter.frames[:len(interpreter.frames)-1]
+			if len(interpreter.frames) == base {
+				return inputs[0], nil
+			}
+
+			interpreter.frames[len(interpreter.frames)-1].registers[dest] = inputs[0]
+		case intermediate.FIELD:
+			items := inputs[0].Items()
+			if int(instruction.Operand) >= len(items) {
+				return fail(fmt.Errorf("%v has no field %d", inputs[0].Format(), instruction.Operand))
+			}
+
+			registers[instruction.Dest] = items[instruction.Operand]
+		default:
+			return fail(fmt.Errorf("unknown instruction %d", instruction.Instruction))
+		}
+	}
+}
+
+// read loads the arguments of an instruction. Under stress every value is
+// checked to still be alive, which catches registers missing from a stack map.
+func (interpreter *Interpreter) read(registers []runtime.Value, args []intermediate.Register) ([]runtime.Value, error) {
+	values := make([]runtime.Value, 0, len(args))
+	for _, arg := range args {
+		if interpreter.heap.Stress {
+			if err := interpreter.heap.Check(registers[arg]); err != nil {
+				return nil, err
+			}
+		}
+
+		values = append(values, registers[arg])
+	}
+
+	return values, nil
+}
@@ -0,0 +1,221 @@
+package interpreter
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/emit"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+	"github.com/tflexsoom/duffle/internal/typing"
+	"github.com/tflexsoom/duffle/internal/verify"
+)
+
+type node = container.Tree[intermediate.SenimentExpression]
+
+func leaf(op intermediate.OpCode, typeId intermediate.TypeId, value ...string) node {
+	return intermediate.NewExpressionTree(intermediate.SenimentExpression{
+		Position: lexer.Position{Filename: "test.dfl", Line: 1},
+		Op:       op,
+		TypeId:   typeId,
+		Value:    value,
+	})
+}
+
+func parent(op intermediate.OpCode, value []string, children ...node) node {
+	tree := leaf(op, intermediate.TYPEID_NO_TYPE, value...)
+	for _, child := range children {
+		container.AddChildren(tree, child)
+	}
+
+	return tree
+}
+
+func integer(text string) node {
+	return leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_INTEGER, text)
+}
+
+func ref(name string) node {
+	return leaf(intermediate.OPCODE_REF, intermediate.TYPEID_NO_TYPE, name)
+}
+
+func call(children ...node) node {
+	return parent(intermediate.OPCODE_CALL, nil, children...)
+}
+
+func operator(name string) node {
+	return leaf(intermediate.OPCODE_OPERATOR, intermediate.TYPEID_NO_TYPE, name)
+}
+
+// countingGoal holds a program printing a greeting and counting its
+// arguments with a pattern matching function
+func countingGoal() intermediate.Goal {
+	goal := intermediate.NewGoal()
+	goal.Imports = append(goal.Imports, intermediate.Import{Alias: "sysout", Path: []string{"dfl", "sysout"}})
+	goal.Sentments["GREETING"] = intermediate.Sentiment{
+		Annotations: []string{annotation.FACT_ANNOTATION},
+		Name:        "GREETING",
+		Definition: parent(intermediate.OPCODE_CONSTEXPR, nil,
+			leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_TEXT, `"hello "`),
+		),
+	}
+
+	goal.Sentments["count"] = intermediate.Sentiment{
+		Annotations: []string{annotation.FUNCTION_ANNOTATION},
+		Name:        "count",
+		Definition: parent(intermediate.OPCODE_EVALS, nil,
+			parent(intermediate.OPCODE_CLAUSE, []string{"count"},
+				parent(intermediate.OPCODE_PATTERN_CONSTRUCTOR, []string{intermediate.LIST_EMPTY_CONSTRUCTOR}),
+				integer("0"),
+			),
+			parent(intermediate.OPCODE_CLAUSE, []string{"count"},
+				parent(intermediate.OPCODE_PATTERN_CONSTRUCTOR, []string{intermediate.LIST_CONS_CONSTRUCTOR},
+					leaf(intermediate.OPCODE_PATTERN_WILDCARD, intermediate.TYPEID_NO_TYPE),
+					leaf(intermediate.OPCODE_PATTERN_BIND, intermediate.TYPEID_NO_TYPE, "rest"),
+				),
+				parent(intermediate.OPCODE_CHAIN, nil, integer("1"), operator("+"), call(ref("count"), ref("rest"))),
+			),
+		),
+	}
+
+	goal.Sentments["main"] = intermediate.Sentiment{
+		Annotations: []string{annotation.EXEC_ANNOTATION},
+		Name:        "main",
+		Inputs:      []intermediate.SentimentInput{{Name: "args", TypeId: goal.TypeIdOf("List[text]")}},
+		Result:      intermediate.TYPEID_INTEGER,
+		Definition: parent(intermediate.OPCODE_BLOCK, nil,
+			call(ref("sysout"), ref("GREETING")),
+			parent(intermediate.OPCODE_LABEL, []string{"total"}, call(ref("count"), ref("args"))),
+			call(ref("sysout"), ref("total")),
+			parent(intermediate.OPCODE_RETURN, nil, ref("total")),
+		),
+	}
+
+	return goal
+}
+
+func moduleOf(t *testing.T, goals ...intermediate.Goal) intermediate.Module {
+	t.Helper()
+	diagnostics := typing.CheckProgram(goals, nil, "")
+	if diagnostics.HasErrors() {
+		t.Fatalf("unexpected type errors:\n%v", diagnostics)
+	}
+
+	module, diagnostics := emit.Emit(goals, "")
+	if diagnostics.HasErrors() {
+		t.Fatalf("unexpected emit errors:\n%v", diagnostics)
+	}
+
+	if err := verify.Module(module); err != nil {
+		t.Fatalf("emitted an invalid module:\n%v\n%v", err, module)
+	}
+
+	return module
+}
+
+func TestRunProgram(t *testing.T) {
+	module := moduleOf(t, countingGoal())
+	stdout := bytes.Buffer{}
+	program, err := New(module, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := program.Run([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%v", err, module)
+	}
+
+	if !result.Equal(runtime.Integer(3)) {
+		t.Errorf("unexpected result %v", result.Format())
+	}
+
+	if stdout.String() != "hello 3" {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+}
+
+func TestRunThunk(t *testing.T) {
+	goal := countingGoal()
+	goal.Sentments["main"] = intermediate.Sentiment{
+		Annotations: []string{annotation.EXEC_ANNOTATION},
+		Name:        "main",
+		Definition: parent(intermediate.OPCODE_BLOCK, nil,
+			parent(intermediate.OPCODE_LABEL, []string{"message"},
+				parent(intermediate.OPCODE_CAPTURE, nil,
+					parent(intermediate.OPCODE_BLOCK, nil,
+						call(ref("sysout"), ref("GREETING")),
+						parent(intermediate.OPCODE_RETURN, nil, ref("GREETING")),
+					),
+				),
+			),
+			call(ref("sysout"), ref("message")),
+			call(ref("sysout"), ref("message")),
+		),
+	}
+
+	module := moduleOf(t, goal)
+	stdout := bytes.Buffer{}
+	program, err := New(module, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := program.Run(nil); err != nil {
+		t.Fatalf("unexpected error: %v\n%v", err, module)
+	}
+
+	if stdout.String() != strings.Repeat("hello ", 4) {
+		t.Errorf("expected the capture to run at each use of its name, got %q", stdout.String())
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	module := moduleOf(t, countingGoal())
+	program, err := New(module, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program.MaxDepth = 4
+	_, err = program.Run([]string{"a", "b", "c", "d", "e"})
+	runtimeError := RuntimeError{}
+	if !errors.As(err, &runtimeError) || !strings.Contains(err.Error(), "deeper than 4") {
+		t.Fatalf("expected a depth error, got %v", err)
+	}
+
+	if runtimeError.Function != "count" || runtimeError.Position.Filename != "test.dfl" {
+		t.Errorf("unexpected error location %v in %v", runtimeError.Position, runtimeError.Function)
+	}
+}
+
+func TestMatchFailure(t *testing.T) {
+	goal := countingGoal()
+	count := goal.Sentments["count"]
+	count.Definition = parent(intermediate.OPCODE_EVALS, nil, count.Definition.GetChildren()[1])
+	goal.Sentments["count"] = count
+
+	program, err := New(moduleOf(t, goal), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = program.Run(nil)
+	if err == nil || !strings.Contains(err.Error(), "no clause of count matches") {
+		t.Fatalf("expected a match failure, got %v", err)
+	}
+}
+
+func TestUnknownIntrinsic(t *testing.T) {
+	module := intermediate.NewModule()
+	module.IntrinsicOf("dfl.missing")
+	module.Functions = append(module.Functions, intermediate.Function{Name: "main"})
+	if _, err := New(module, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "dfl.missing") {
+		t.Fatalf("expected an unknown intrinsic error, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package interpreter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+// operatorGoal declares <+> to append a digit and chains it, where only
+// grouping to the right gives 33
+func operatorGoal() intermediate.Goal {
+	goal := countingGoal()
+	goal.Operators["<+>"] = intermediate.Fixity{Operator: "<+>", Associativity: intermediate.ASSOCIATIVE_RIGHT, Precedence: 5}
+	goal.Sentments["<+>"] = intermediate.Sentiment{
+		Name: "<+>",
+		Inputs: []intermediate.SentimentInput{
+			{Name: "a", TypeId: intermediate.TYPEID_INTEGER},
+			{Name: "b", TypeId: intermediate.TYPEID_INTEGER},
+		},
+		Result: intermediate.TYPEID_INTEGER,
+		Definition: parent(intermediate.OPCODE_CONSTEXPR, nil,
+			parent(intermediate.OPCODE_CHAIN, nil, ref("a"), operator("*"), integer("10"), operator("+"), ref("b")),
+		),
+	}
+
+	goal.Sentments["main"] = intermediate.Sentiment{
+		Annotations: []string{annotation.EXEC_ANNOTATION},
+		Name:        "main",
+		Inputs:      []intermediate.SentimentInput{{Name: "args", TypeId: goal.TypeIdOf("List[text]")}},
+		Result:      intermediate.TYPEID_INTEGER,
+		Definition: parent(intermediate.OPCODE_BLOCK, nil,
+			returns(parent(intermediate.OPCODE_CHAIN, nil, integer("1"), operator("<+>"), integer("2"), operator("<+>"), integer("3"))),
+		),
+	}
+
+	return goal
+}
+
+func TestUserOperators(t *testing.T) {
+	module := moduleOf(t, operatorGoal())
+	program, err := New(module, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := program.Run(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%v", err, module)
+	}
+
+	if !result.Equal(runtime.Integer(33)) {
+		t.Errorf("unexpected result %v\n%v", result.Format(), module)
+	}
+}
@@ -0,0 +1,61 @@
+package interpreter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/optimize"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+// TestOptimizedPrograms runs every test program at every level, expecting
+// the results and output of the unoptimized program
+func TestOptimizedPrograms(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		goal func() intermediate.Goal
+		args []string
+	}{
+		{"counting", countingGoal, []string{"a", "b", "c"}},
+		{"countdown", countingDownGoal, []string{"a", "b"}},
+		{"closures", closingGoal, []string{"a", "b", "c"}},
+		{"structs", studentGoal, []string{"a", "b", "c"}},
+		{"generics", genericGoal, nil},
+		{"operators", operatorGoal, nil},
+	} {
+		module := moduleOf(t, test.goal())
+		expected, expectedOutput := runUnder(t, test.name, module, test.args)
+		for _, level := range []optimize.Level{optimize.LEVEL_O0, optimize.LEVEL_O1, optimize.LEVEL_O2} {
+			optimized := module
+			if err := optimize.Optimize(&optimized, optimize.Options{Level: level, Verify: true}); err != nil {
+				t.Fatal(err)
+			}
+
+			result, output := runUnder(t, test.name, optimized, test.args)
+			if !result.Equal(expected) || output != expectedOutput {
+				t.Errorf(
+					"%v at -O%d gave %v and %q, expected %v and %q\n%v",
+					test.name, level, result.Format(), output, expected.Format(), expectedOutput, optimized,
+				)
+			}
+		}
+	}
+}
+
+func runUnder(t *testing.T, name string, module intermediate.Module, args []string) (runtime.Value, string) {
+	t.Helper()
+	stdout := bytes.Buffer{}
+	program, err := New(module, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program.Heap().Stress = true
+	result, err := program.Run(args)
+	if err != nil {
+		t.Fatalf("%v failed: %v\n%v", name, err, module)
+	}
+
+	return result, stdout.String()
+}
@@ -0,0 +1,76 @@
+package interpreter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+func text(value string) node {
+	return leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_TEXT, `"`+value+`"`)
+}
+
+// studentGoal builds a struct, reads a field of it and passes a projection
+// of another field around as a function
+func studentGoal() intermediate.Goal {
+	goal := countingGoal()
+	goal.Structs["Student"] = intermediate.Struct{
+		Name: "Student",
+		Fields: []intermediate.StructField{
+			{Name: "Name", TypeId: intermediate.TYPEID_TEXT},
+			{Name: "Grade", TypeId: intermediate.TYPEID_INTEGER},
+		},
+	}
+
+	goal.Sentments["main"] = intermediate.Sentiment{
+		Annotations: []string{annotation.EXEC_ANNOTATION},
+		Name:        "main",
+		Inputs:      []intermediate.SentimentInput{{Name: "args", TypeId: goal.TypeIdOf("List[text]")}},
+		Result:      intermediate.TYPEID_INTEGER,
+		Definition: parent(intermediate.OPCODE_BLOCK, nil,
+			label("student", call(ref("Student"), text("Abby"), call(ref("count"), ref("args")))),
+			label("grade", parent(intermediate.OPCODE_CHAIN, nil, ref("Student"), operator("."), ref("Grade"))),
+			call(ref("sysout"), parent(intermediate.OPCODE_CHAIN, nil, ref("student"), operator("."), ref("Name"))),
+			returns(parent(intermediate.OPCODE_CHAIN, nil,
+				call(ref("grade"), ref("student")),
+				operator("+"),
+				parent(intermediate.OPCODE_CHAIN, nil, ref("student"), operator("."), ref("Grade")),
+			)),
+		),
+	}
+
+	return goal
+}
+
+func TestStructs(t *testing.T) {
+	module := moduleOf(t, studentGoal())
+	stdout := bytes.Buffer{}
+	program, err := New(module, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program.Heap().Stress = true
+	result, err := program.Run([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%v", err, module)
+	}
+
+	if !result.Equal(runtime.Integer(6)) || stdout.String() != "Abby" {
+		t.Errorf("unexpected result %v and output %q", result.Format(), stdout.String())
+	}
+
+	projected := false
+	for _, function := range module.Functions {
+		if function.Name == "Student.Grade" {
+			projected = function.Type == "Function[Student, integer]"
+		}
+	}
+
+	if !projected {
+		t.Errorf("expected a Student.Grade projection\n%v", module)
+	}
+}
@@ -0,0 +1,72 @@
+package interpreter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+// countingDownGoal counts to a million with a tail recursive function,
+// far deeper than calls may nest
+func countingDownGoal() intermediate.Goal {
+	goal := countingGoal()
+	goal.Sentments["countdown"] = intermediate.Sentiment{
+		Annotations: []string{annotation.TAILREC_ANNOTATION},
+		Name:        "countdown",
+		Inputs: []intermediate.SentimentInput{
+			{Name: "n", TypeId: intermediate.TYPEID_INTEGER},
+			{Name: "total", TypeId: intermediate.TYPEID_INTEGER},
+		},
+		Result: intermediate.TYPEID_INTEGER,
+		Definition: parent(intermediate.OPCODE_BLOCK, nil,
+			parent(intermediate.OPCODE_IF, nil,
+				parent(intermediate.OPCODE_CHAIN, nil, ref("n"), operator("<="), integer("0")),
+				returns(ref("total")),
+			),
+			returns(call(ref("countdown"),
+				parent(intermediate.OPCODE_CHAIN, nil, ref("n"), operator("-"), integer("1")),
+				parent(intermediate.OPCODE_CHAIN, nil, ref("total"), operator("+"), integer("1")),
+			)),
+		),
+	}
+
+	goal.Sentments["main"] = intermediate.Sentiment{
+		Annotations: []string{annotation.EXEC_ANNOTATION},
+		Name:        "main",
+		Inputs:      []intermediate.SentimentInput{{Name: "args", TypeId: goal.TypeIdOf("List[text]")}},
+		Result:      intermediate.TYPEID_INTEGER,
+		Definition: parent(intermediate.OPCODE_BLOCK, nil,
+			returns(call(ref("countdown"), integer("1000000"), call(ref("count"), ref("args")))),
+		),
+	}
+
+	return goal
+}
+
+func TestTailCalls(t *testing.T) {
+	module := moduleOf(t, countingDownGoal())
+	for _, function := range module.Functions {
+		for _, instruction := range function.Definition {
+			if function.Name == "countdown" && instruction.Instruction == intermediate.CALL {
+				t.Errorf("countdown calls without a tail call:\n%v", module)
+			}
+		}
+	}
+
+	program, err := New(module, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := program.Run([]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !result.Equal(runtime.Integer(1000002)) {
+		t.Errorf("unexpected result %v", result.Format())
+	}
+}
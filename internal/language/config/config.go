@@ -7,6 +7,7 @@ package config
 import (
 	"github.com/alecthomas/participle/v2/lexer"
 	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/language/data"
 )
 
 type Configuration struct {
@@ -18,9 +19,9 @@ type Configuration struct {
 type Assignment struct {
 	Pos lexer.Position
 
-	FirstName  string          `@IDENTIFIER`
-	SecondName *string         `("." @IDENTIFIER )?`
-	Value      DuffleDataValue `WHITESPACE* "=" WHITESPACE* @@ WHITESPACE* EOL`
+	FirstName  string               `@IDENTIFIER`
+	SecondName *string              `("." @IDENTIFIER )?`
+	Value      data.DuffleDataValue `WHITESPACE* "=" WHITESPACE* @@ WHITESPACE* EOL`
 }
 
 func (a Assignment) GetDataConfig() intermediate.DataConfig {
@@ -34,6 +35,6 @@ func (a Assignment) GetDataConfig() intermediate.DataConfig {
 	return intermediate.DataConfig{
 		FirstName:  firstName,
 		SecondName: secondName,
-		Values:     a.Value.Value(),
+		Values:     a.Value.DuffleValue(),
 	}
 }
@@ -1,21 +1,90 @@
 package data
 
-import "github.com/alecthomas/participle/v2/lexer"
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
 
 type List struct {
-	Position lexer.Position
-	Vals     []DuffleDataValue `"[" WHITESPACE* EOL? WHITESPACE* @@? ("," EOL? WHITESPACE* @@)* WHITESPACE* EOL? WHITESPACE*"]"`
+	Pos  lexer.Position
+	Vals []DuffleDataValue `"[" WHITESPACE* EOL? WHITESPACE* @@? ("," EOL? WHITESPACE* @@)* WHITESPACE* EOL? WHITESPACE*"]"`
 }
 
-func (l List) Pos() lexer.Position {
-	return l.Position
+func (l List) DuffleValue() container.Tree[intermediate.DataValue] {
+	result := container.NewGraphTreeCap[intermediate.DataValue](2, uint(len(l.Vals)))
+	result.SetValue(
+		intermediate.DataValue{
+			Type:      intermediate.TYPEID_LIST,
+			TextValue: "",
+		},
+	)
+
+	for _, val := range l.Vals {
+		if val.IsGroup() {
+			container.AddChildren(result, val.DuffleValue())
+		} else {
+			result.AddChild(val.DuffleValue().GetValue())
+		}
+	}
+
+	return result
+}
+
+func (l List) Position() lexer.Position {
+	return l.Pos
+}
+
+func (l List) IsGroup() bool {
+	return true
 }
 
 type Struct struct {
-	Position lexer.Position
-	Vals     []DuffleDataValue `"(" WHITESPACE* EOL? WHITESPACE* @@? ("," EOL? WHITESPACE* @@)* WHITESPACE* EOL? WHITESPACE* ")"`
+	Pos  lexer.Position
+	Vals []Field `"(" WHITESPACE* EOL? WHITESPACE* @@? ("," EOL? WHITESPACE* @@)* WHITESPACE* EOL? WHITESPACE* ")"`
+}
+
+func (s Struct) DuffleValue() container.Tree[intermediate.DataValue] {
+	result := container.NewGraphTreeCap[intermediate.DataValue](2, uint(len(s.Vals)))
+	result.SetValue(
+		intermediate.DataValue{
+			Type:      intermediate.TYPEID_STRUCT,
+			TextValue: "",
+		},
+	)
+
+	for _, field := range s.Vals {
+		value := field.Value.DuffleValue()
+		named := value.GetValue()
+		named.Name = field.Name
+		if !field.Value.IsGroup() {
+			result.AddChild(named)
+			continue
+		}
+
+		value.SetValue(named)
+		container.AddChildren(result, value)
+	}
+
+	return result
+}
+
+func (s Struct) Position() lexer.Position {
+	return s.Pos
+}
+
+func (s Struct) IsGroup() bool {
+	return true
+}
+
+// Field is a value of a struct, optionally given by the name of the field
+// it is bound to
+type Field struct {
+	Pos   lexer.Position
+	Name  string          `( @IDENTIFIER WHITESPACE* "=" WHITESPACE* )?`
+	Value DuffleDataValue `@@`
 }
 
-func (s Struct) Pos() lexer.Position {
-	return s.Position
+func (f Field) Position() lexer.Position {
+	return f.Pos
 }
@@ -5,45 +5,92 @@
 // notes: Might trade this out for a TOML parser instead!
 package data
 
-import "github.com/alecthomas/participle/v2/lexer"
-
-const (
-	VAL_BOOLEAN = SingleGrammar{
-		name:    "BOOLEAN",
-		regex:   "true|false",
-		grammar: `@( "true" | "false")`,
-	}
-
-	VAL_FLOAT = SingleGrammar{
-		name:    "DECIMAL",
-		regex:   `[\d]+\.[\d]*`,
-		grammar: `@DECIMAL`,
-	}
-
-	VAL_INTEGER = SingleGrammar{
-		name:    "INTEGER",
-		regex:   `[\d]+`,
-		grammar: `@INTEGER`,
-	}
-
-	VAL_FUNC_STRING = SingleGrammar{
-		name:    "QUOTED_VAL",
-		regex:   `"[^"]*"`,
-		grammar: `@QUOTED_VAL`,
-	}
-
-	VAL_FUNC_CHAR = SingleGrammar{
-		name:    "SINGLE_QUOTED_VAL",
-		regex:   `'[^']*'`,
-		grammar: `@SIGNLE_QUOTED_VAL`,
-	}
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/parsing/util"
 )
 
+type DuffleDataValue interface {
+	DuffleValue() container.Tree[intermediate.DataValue]
+	Position() lexer.Position
+	IsGroup() bool
+}
+
+func scalarOf(typeId intermediate.TypeId, text string) container.Tree[intermediate.DataValue] {
+	return container.NewGraphTreeCap[intermediate.DataValue](1, 1).SetValue(
+		intermediate.DataValue{
+			Type:      typeId,
+			TextValue: text,
+		})
+}
+
+// -- Boolean Grammar
+type Boolean util.BoolGrammar
+
+func (b Boolean) DuffleValue() container.Tree[intermediate.DataValue] {
+	return scalarOf(intermediate.TYPEID_BOOLEAN, b.Val)
+}
+
+func (b Boolean) Position() lexer.Position {
+	return b.Pos
+}
+
+func (b Boolean) IsGroup() bool {
+	return false
+}
+
+// -- Float Grammar
+type Float util.FloatGrammar
+
+func (f Float) DuffleValue() container.Tree[intermediate.DataValue] {
+	return scalarOf(intermediate.TYPEID_DECIMAL, f.Val)
+}
+
+func (f Float) Position() lexer.Position {
+	return f.Pos
+}
+
+func (f Float) IsGroup() bool {
+	return false
+}
+
+// -- Int Grammar
+type Int util.IntGrammar
+
+func (i Int) DuffleValue() container.Tree[intermediate.DataValue] {
+	return scalarOf(intermediate.TYPEID_INTEGER, i.Val)
+}
+
+func (i Int) Position() lexer.Position {
+	return i.Pos
+}
+
+func (i Int) IsGroup() bool {
+	return false
+}
+
+// -- String Grammar
+type String util.StringGrammar
+
+func (s String) DuffleValue() container.Tree[intermediate.DataValue] {
+	return scalarOf(intermediate.TYPEID_TEXT, s.Val)
+}
+
+func (s String) Position() lexer.Position {
+	return s.Pos
+}
+
+func (s String) IsGroup() bool {
+	return false
+}
+
 type ConfigValue struct {
-	Position lexer.Position
-	Val      string `(@IDENTIFIER | @QUOTED_VAL | @TEXT) (@WHITESPACE* (@IDENTIFIER | @TEXT))*`
+	Pos lexer.Position
+	Val string `(@IDENTIFIER | @QUOTED_VAL | @TEXT) (@WHITESPACE* (@IDENTIFIER | @TEXT))*`
 }
 
-func (f ConfigValue) Pos() lexer.Position {
-	return f.Position
+func (f ConfigValue) Position() lexer.Position {
+	return f.Pos
 }
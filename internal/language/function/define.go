@@ -1,66 +1,145 @@
 package function
 
-import "github.com/alecthomas/participle/v2/lexer"
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/parsing/util"
+)
 
 type FunctionModulePart struct {
-	Position  lexer.Position
-	Functions []Function `( @@ EOL+ )+`
+	Pos       lexer.Position
+	Functions []Function `( @@ EOL* )+`
 }
 
 func (modPart FunctionModulePart) ModulePart() {}
-func (modPart FunctionModulePart) Pos() lexer.Position {
-	return modPart.Position
+func (modPart FunctionModulePart) Position() lexer.Position {
+	return modPart.Pos
 }
 
 type FunctionDefinition interface {
 	FunctionDefinition()
-	Pos() lexer.Position
+	Position() lexer.Position
 }
 
 type Function struct {
-	Position   lexer.Position
+	Pos        lexer.Position
 	Annotation *string            `"@" (@IDENTIFIER | "@") `
-	Type       Type               `( @@ (?= IDENTIFIER ( BEGIN_KEYWORD | EVALS_KEYWORD | "<" ) ) )?`
+	Type       Type               `( @@ (?= ( IDENTIFIER | OPERATOR ) ( BEGIN_KEYWORD | EVALS_KEYWORD | "<" | "[" ) ) )?`
 	Name       FunctionName       `( @IDENTIFIER | @OPERATOR )`
+	Generics   []TypeParam        `( "[" @@ ( "," @@ )* "]" )?`
 	Inputs     []Input            `( "<" @@ ">")*`
 	Definition FunctionDefinition `@@`
 }
 
+// TypeParam declares a type variable of a generic function along with the
+// operators its types must support, as in [a, k supports <=]
+type TypeParam struct {
+	Pos lexer.Position
+
+	Name   string   `@IDENTIFIER`
+	Bounds []string `( SUPPORTS_KEYWORD @BOUND_OPERATOR+ )?`
+}
+
 type ConstexprDefinition struct {
-	Position  lexer.Position
+	Pos       lexer.Position
 	Constexpr []ConstexprExpression `":=" @@`
 }
 
 func (expr ConstexprDefinition) FunctionDefinition() {}
-func (expr ConstexprDefinition) Pos() lexer.Position {
-	return expr.Position
+func (expr ConstexprDefinition) Position() lexer.Position {
+	return expr.Pos
 }
 
 type BlockDefinition struct {
-	Position     lexer.Position
+	Pos          lexer.Position
 	Instructions []BlockExpression `BEGIN_KEYWORD EOL* ( @@ (";" | EOL) EOL* )* END_KEYWORD`
 }
 
 func (expr BlockDefinition) FunctionDefinition() {}
-func (expr BlockDefinition) Pos() lexer.Position {
-	return expr.Position
+func (expr BlockDefinition) Position() lexer.Position {
+	return expr.Pos
 }
 
 type PatternDefinition struct {
-	Position lexer.Position
+	Pos lexer.Position
 
-	Patterns []Pattern `EVALS_KEYWORD EOL ( @@ EOL )* END_EVAL`
+	Patterns []Pattern `EVALS_KEYWORD EOL ( @@ EOL? )* END_EVAL`
 }
 
 func (expr PatternDefinition) FunctionDefinition() {}
-func (expr PatternDefinition) Pos() lexer.Position {
-	return expr.Position
+func (expr PatternDefinition) Position() lexer.Position {
+	return expr.Pos
 }
 
 type Pattern struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Name       string           `@IDENTIFIER`
-	Params     []string         `@IDENTIFIER* "="`
+	Params     []PatternParam   `@@* "="`
 	Definition InlineExpression `@@`
 }
+
+func (pattern Pattern) Position() lexer.Position {
+	return pattern.Pos
+}
+
+type PatternParam interface {
+	PatternParam()
+	Position() lexer.Position
+}
+
+type WildcardPattern struct {
+	Pos lexer.Position
+
+	Wildcard string `@WILDCARD`
+}
+
+func (param WildcardPattern) PatternParam() {}
+func (param WildcardPattern) Position() lexer.Position {
+	return param.Pos
+}
+
+type BindingPattern struct {
+	Pos lexer.Position
+
+	Name string `@IDENTIFIER`
+}
+
+func (param BindingPattern) PatternParam() {}
+func (param BindingPattern) Position() lexer.Position {
+	return param.Pos
+}
+
+type LiteralPattern struct {
+	Pos lexer.Position
+
+	Value util.Value `@@`
+}
+
+func (param LiteralPattern) PatternParam() {}
+func (param LiteralPattern) Position() lexer.Position {
+	return param.Pos
+}
+
+type StructPattern struct {
+	Pos lexer.Position
+
+	Name   string         `"(" @IDENTIFIER`
+	Fields []PatternParam `@@* ")"`
+}
+
+func (param StructPattern) PatternParam() {}
+func (param StructPattern) Position() lexer.Position {
+	return param.Pos
+}
+
+type ListPattern struct {
+	Pos lexer.Position
+
+	Elements []PatternParam `"[" ( @@ ( "," @@ )* )?`
+	Tail     PatternParam   `( ":" @@ )? "]"`
+}
+
+func (param ListPattern) PatternParam() {}
+func (param ListPattern) Position() lexer.Position {
+	return param.Pos
+}
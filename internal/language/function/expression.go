@@ -1,24 +1,27 @@
 package function
 
-import "github.com/alecthomas/participle/v2/lexer"
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/parsing/util"
+)
 
 type BlockExpression interface {
 	Block()
-	Pos() lexer.Position
+	Position() lexer.Position
 }
 
 type InlineExpression interface {
 	Inline()
-	Pos() lexer.Position
+	Position() lexer.Position
 }
 
 type ConstexprExpression interface {
 	Constexpr()
-	Pos() lexer.Position
+	Position() lexer.Position
 }
 
 type BlockConditionalExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Condition      BlockExpression       `IF_KEYWORD "(" @@ ")"`
 	Execution      []InlineExpression    `THEN_KEYWORD EOL+ (@@ EOL+)*`
@@ -27,43 +30,43 @@ type BlockConditionalExpression struct {
 }
 
 func (expression BlockConditionalExpression) Block() {}
-func (expression BlockConditionalExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression BlockConditionalExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type SubBlockConditional struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Condition InlineExpression   `ELSEIF_KEYWORD "(" @@ ")"`
 	Execution []InlineExpression `THEN_KEYWORD EOL+ (@@ EOL+)*`
 }
 
 type LabelExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Label      string           `@IDENTIFIER`
 	Resolution InlineExpression `":=" @@`
 }
 
 func (expression LabelExpression) Block() {}
-func (expression LabelExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression LabelExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type InlineConditionalExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Condition          InlineExpression `INLINE_IF_KEYWORD "(" @@ ")"`
 	ConditionExecution InlineExpression `@@`
 }
 
 func (expression InlineConditionalExpression) Block() {}
-func (expression InlineConditionalExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression InlineConditionalExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type ParentheticalExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Execution     InlineExpression `"(" EOL* @@ EOL* ")"`
 	NextExecution InlineExpression `@@?`
@@ -71,37 +74,38 @@ type ParentheticalExpression struct {
 
 func (expression ParentheticalExpression) Block()  {}
 func (expression ParentheticalExpression) Inline() {}
-func (expression ParentheticalExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression ParentheticalExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type ConstexprParentheticalExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Execution     ConstexprExpression `"(" @@ ")"`
 	NextExecution ConstexprExpression `@@?`
 }
 
 func (expression ConstexprParentheticalExpression) Constexpr() {}
-func (expression ConstexprParentheticalExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression ConstexprParentheticalExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type BlockCaptureExpression struct {
-	Position     lexer.Position
-	Annotation   *string           `"@" `
-	Type         Type              `( @@ (?= ( BEGIN_KEYWORD | EVALS_KEYWORD | "<" ) ) )?`
+	Pos          lexer.Position
+	Annotation   *string           `"@" @"@"`
+	Type         Type              `( @@ (?= ( BEGIN_KEYWORD | "<" ) ) )?`
 	Inputs       []Input           `( "<" @@ ">")*`
 	Instructions []BlockExpression `BEGIN_KEYWORD EOL* ( @@ (";" | EOL) EOL* )* END_KEYWORD`
 }
 
-func (expression BlockCaptureExpression) Block() {}
-func (expression BlockCaptureExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression BlockCaptureExpression) Block()  {}
+func (expression BlockCaptureExpression) Inline() {}
+func (expression BlockCaptureExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type InlineCaptureExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Execution     InlineExpression `BACKTICK @@ BACKTICK`
 	NextExecution InlineExpression `@@?`
@@ -109,24 +113,24 @@ type InlineCaptureExpression struct {
 
 func (expression InlineCaptureExpression) Block()  {}
 func (expression InlineCaptureExpression) Inline() {}
-func (expression InlineCaptureExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression InlineCaptureExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type ConstexprCaptureExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Execution     ConstexprExpression `BACKTICK @@ BACKTICK`
 	NextExecution ConstexprExpression `@@?`
 }
 
 func (expression ConstexprCaptureExpression) Constexpr() {}
-func (expression ConstexprCaptureExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression ConstexprCaptureExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type ReferenceExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	ReferenceGroup []string         `@IDENTIFIER+`
 	NextExecution  InlineExpression `@@?`
@@ -134,53 +138,75 @@ type ReferenceExpression struct {
 
 func (expression ReferenceExpression) Block()  {}
 func (expression ReferenceExpression) Inline() {}
-func (expression ReferenceExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression ReferenceExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type ConstexprReferenceExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	ReferenceGroup []string            `@IDENTIFIER+`
 	NextExecution  ConstexprExpression `@@?`
 }
 
 func (expression ConstexprReferenceExpression) Constexpr() {}
-func (expression ConstexprReferenceExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression ConstexprReferenceExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type OperatorExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	ReferenceGroup []string         `@OPERATOR`
 	NextExecution  InlineExpression `@@?`
 }
 
 func (expression OperatorExpression) Inline() {}
-func (expression OperatorExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression OperatorExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type ConstexprOperatorExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	ReferenceGroup []string            `@OPERATOR`
 	NextExecution  ConstexprExpression `@@?`
 }
 
 func (expression ConstexprOperatorExpression) Constexpr() {}
-func (expression ConstexprOperatorExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression ConstexprOperatorExpression) Position() lexer.Position {
+	return expression.Pos
+}
+
+type UseExpression struct {
+	Pos lexer.Position
+
+	Path          []string            `USE_KEYWORD "(" @IDENTIFIER ( "." @IDENTIFIER )* ")"`
+	NextExecution ConstexprExpression `@@?`
+}
+
+func (expression UseExpression) Constexpr() {}
+func (expression UseExpression) Position() lexer.Position {
+	return expression.Pos
 }
 
 type LiteralExpression struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Value util.Value `@@`
 }
 
 func (expression LiteralExpression) Constexpr() {}
-func (expression LiteralExpression) Pos() lexer.Position {
-	return expression.Position
+func (expression LiteralExpression) Position() lexer.Position {
+	return expression.Pos
+}
+
+type Char struct {
+	Pos lexer.Position
+
+	Val string `@SINGLE_QUOTED_VAL`
+}
+
+func (c Char) Position() lexer.Position {
+	return c.Pos
 }
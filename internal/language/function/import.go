@@ -3,38 +3,49 @@ package function
 import "github.com/alecthomas/participle/v2/lexer"
 
 type ImportModulePart struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Imports []Import `( USE_KEYWORD @@ EOL+ )+`
 }
 
 func (modPart ImportModulePart) ModulePart() {}
-func (modPart ImportModulePart) Pos() lexer.Position {
-	return modPart.Position
+func (modPart ImportModulePart) Position() lexer.Position {
+	return modPart.Pos
 }
 
 type Import interface {
 	ImportVal() []string
-	Pos() lexer.Position
+	Position() lexer.Position
+}
+
+type ImportPath struct {
+	Pos lexer.Position
+
+	Value string `@IDENTIFIER ( @"." @IDENTIFIER )*`
 }
 
 type ListImport struct {
-	Position lexer.Position
+	Pos lexer.Position
 
-	Value []string `"(" EOL+ (@IDENTIFIER EOL+)+ ")"`
+	Value []ImportPath `"(" EOL+ (@@ EOL+)+ ")"`
 }
 
 func (listImport ListImport) ImportVal() []string {
-	return listImport.Value
+	result := make([]string, 0, len(listImport.Value))
+	for _, path := range listImport.Value {
+		result = append(result, path.Value)
+	}
+
+	return result
 }
-func (listImport ListImport) Pos() lexer.Position {
-	return listImport.Position
+func (listImport ListImport) Position() lexer.Position {
+	return listImport.Pos
 }
 
 type SingleImport struct {
-	Position lexer.Position
+	Pos lexer.Position
 
-	Value string `@IDENTIFIER`
+	Value string `@IDENTIFIER ( @"." @IDENTIFIER )*`
 }
 
 func (singleImport SingleImport) ImportVal() []string {
@@ -42,6 +53,6 @@ func (singleImport SingleImport) ImportVal() []string {
 		singleImport.Value,
 	}
 }
-func (singleImport SingleImport) Pos() lexer.Position {
-	return singleImport.Position
+func (singleImport SingleImport) Position() lexer.Position {
+	return singleImport.Pos
 }
@@ -1,6 +1,15 @@
 package function
 
-import "github.com/alecthomas/participle/v2/lexer"
+import (
+	"regexp"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// IDENTIFIER_PATTERN matches names, every other function name is an operator
+const IDENTIFIER_PATTERN = `[a-zA-Z][a-zA-Z\d_]*`
+
+var identifierRegex = regexp.MustCompile("^" + IDENTIFIER_PATTERN + "$")
 
 type Type struct {
 	Name     string `@IDENTIFIER`
@@ -8,7 +17,7 @@ type Type struct {
 }
 
 type Input struct {
-	Position lexer.Position
+	Pos lexer.Position
 
 	Type Type   `@@`
 	Name string `@IDENTIFIER`
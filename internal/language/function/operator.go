@@ -0,0 +1,27 @@
+package function
+
+import "github.com/alecthomas/participle/v2/lexer"
+
+type OperatorModulePart struct {
+	Pos          lexer.Position
+	Declarations []FixityDeclaration `( @@ EOL* )+`
+}
+
+func (modPart OperatorModulePart) ModulePart() {}
+func (modPart OperatorModulePart) Position() lexer.Position {
+	return modPart.Pos
+}
+
+// FixityDeclaration gives operators their associativity and precedence, as
+// in infixl 6 +++ where higher precedences group tighter
+type FixityDeclaration struct {
+	Pos lexer.Position
+
+	Fixity     string   `@FIXITY_KEYWORD`
+	Precedence int      `@INTEGER`
+	Operators  []string `@FIXITY_OPERATOR+`
+}
+
+func (declaration FixityDeclaration) Position() lexer.Position {
+	return declaration.Pos
+}
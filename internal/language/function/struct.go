@@ -0,0 +1,24 @@
+package function
+
+import "github.com/alecthomas/participle/v2/lexer"
+
+type StructModulePart struct {
+	Pos     lexer.Position
+	Structs []Struct `( @@ EOL* )+`
+}
+
+func (modPart StructModulePart) ModulePart() {}
+func (modPart StructModulePart) Position() lexer.Position {
+	return modPart.Pos
+}
+
+type Struct struct {
+	Pos lexer.Position
+
+	Name   string  `STRUCT_KEYWORD @IDENTIFIER "(" EOL*`
+	Fields []Input `( "<" @@ ">" EOL* )* ")"`
+}
+
+func (structure Struct) Position() lexer.Position {
+	return structure.Pos
+}
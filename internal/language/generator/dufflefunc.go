@@ -2,12 +2,12 @@ package generator
 
 import (
 	"io"
-	"regexp"
 
 	"github.com/alecthomas/participle/v2"
 	"github.com/alecthomas/participle/v2/lexer"
 
 	"github.com/tflexsoom/duffle/internal/files"
+	"github.com/tflexsoom/duffle/internal/language/function"
 	"github.com/tflexsoom/duffle/internal/parsing/util"
 )
 
@@ -17,52 +17,62 @@ func GetDflParser() (files.SourceFileParser, error) {
 		return nil, err
 	}
 
-	parser, err := participle.Build[Module](
+	parser, err := participle.Build[function.Module](
 		participle.Lexer(lexer),
 		participle.Elide("WHITESPACE"),
 		participle.UseLookahead(1),
-		participle.Union[ModulePart](
-			ImportModulePart{},
-			StructModulePart{},
-			FunctionModulePart{},
+		participle.Union[function.ModulePart](
+			function.ImportModulePart{},
+			function.StructModulePart{},
+			function.OperatorModulePart{},
+			function.FunctionModulePart{},
 		),
-		participle.Union[Import](
-			ListImport{},
-			SingleImport{},
+		participle.Union[function.Import](
+			function.ListImport{},
+			function.SingleImport{},
 		),
-		participle.Union[FunctionDefinition](
-			ConstexprDefinition{},
-			BlockDefinition{},
-			PatternDefinition{},
+		participle.Union[function.FunctionDefinition](
+			function.ConstexprDefinition{},
+			function.BlockDefinition{},
+			function.PatternDefinition{},
 		),
-		participle.Union[ConstexprExpression](
-			ConstexprCaptureExpression{},
-			ConstexprParentheticalExpression{},
-			ConstexprReferenceExpression{},
-			ConstexprOperatorExpression{},
-			LiteralExpression{},
+		participle.Union[function.ConstexprExpression](
+			function.UseExpression{},
+			function.ConstexprCaptureExpression{},
+			function.ConstexprParentheticalExpression{},
+			function.ConstexprReferenceExpression{},
+			function.ConstexprOperatorExpression{},
+			function.LiteralExpression{},
 		),
-		participle.Union[BlockExpression](
-			BlockConditionalExpression{},
-			BlockCaptureExpression{},
-			InlineCaptureExpression{},
-			InlineConditionalExpression{},
-			ParentheticalExpression{},
-			LabelExpression{},
-			ReferenceExpression{},
+		participle.Union[function.BlockExpression](
+			function.BlockConditionalExpression{},
+			function.BlockCaptureExpression{},
+			function.InlineCaptureExpression{},
+			function.InlineConditionalExpression{},
+			function.ParentheticalExpression{},
+			function.LabelExpression{},
+			function.ReferenceExpression{},
 		),
-		participle.Union[InlineExpression](
-			InlineCaptureExpression{},
-			ParentheticalExpression{},
-			ReferenceExpression{},
-			OperatorExpression{},
+		participle.Union[function.InlineExpression](
+			function.BlockCaptureExpression{},
+			function.InlineCaptureExpression{},
+			function.ParentheticalExpression{},
+			function.ReferenceExpression{},
+			function.OperatorExpression{},
+		),
+		participle.Union[function.PatternParam](
+			function.WildcardPattern{},
+			function.LiteralPattern{},
+			function.StructPattern{},
+			function.ListPattern{},
+			function.BindingPattern{},
 		),
 		participle.Union[util.Value](
 			util.BoolGrammar{},
 			util.FloatGrammar{},
 			util.IntGrammar{},
 			util.StringGrammar{},
-			Char{},
+			function.Char{},
 		),
 	)
 
@@ -78,24 +88,20 @@ func GetDflParser() (files.SourceFileParser, error) {
 }
 
 // // Lexer
-const identifierRegexPattern = `[a-zA-Z][a-zA-Z\d_]*`
-
-var identifierRegex = regexp.MustCompile(identifierRegexPattern)
-
 func getDflLexer() (*lexer.StatefulDefinition, error) {
 	return lexer.New(lexer.Rules{
 		"Spacing": {
-			{Name: "EOL", Pattern: `(\r)?\n`, Action: nil},
+			{Name: "EOL", Pattern: `\r?\n`, Action: nil},
 			{Name: "WHITESPACE", Pattern: `[ \t]+`, Action: nil},
 		},
 		"Identity": {
-			{Name: "IDENTIFIER", Pattern: identifierRegexPattern, Action: nil},
+			{Name: "IDENTIFIER", Pattern: function.IDENTIFIER_PATTERN, Action: nil},
 		},
 		"Operator": {
-			{Name: "OPERATOR", Pattern: `[^\d\w][^\w]*`, Action: nil},
+			{Name: "OPERATOR", Pattern: `[^\d\w\s][^\w\s]*`, Action: nil},
 		},
 		"Literal": {
-			{Name: "BOOLEAN", Pattern: util.BooleanRegex, Action: nil},
+			{Name: util.BooleanTagName, Pattern: util.BooleanRegex, Action: nil},
 			{Name: util.DecimalTagName, Pattern: util.DecimalRegex, Action: nil},
 			{Name: util.IntTagName, Pattern: util.IntRegex, Action: nil},
 			{Name: "SINGLE_QUOTED_VAL", Pattern: `'[^']*'`, Action: nil},             // Escape quotes?
@@ -111,16 +117,39 @@ func getDflLexer() (*lexer.StatefulDefinition, error) {
 			lexer.Include("Spacing"),
 			lexer.Include("Expression"),
 			{Name: "USE_KEYWORD", Pattern: `use`, Action: nil},
+			{Name: "DOT_OPERATOR", Pattern: `\.`, Action: nil},
 			{Name: "STRUCT_KEYWORD", Pattern: `struct`, Action: nil},
 			{Name: "PARAM_PUNCTATION", Pattern: `[\[\],<>]`, Action: nil},
 			{Name: "BEGIN_KEYWORD", Pattern: `begin`, Action: lexer.Push("Instruction")},
 			{Name: "EVALS_KEYWORD", Pattern: `evals`, Action: lexer.Push("Pattern")},
+			{Name: "SUPPORTS_KEYWORD", Pattern: `supports`, Action: lexer.Push("Bound")},
+			{Name: "FIXITY_KEYWORD", Pattern: `infix[lr]?\b`, Action: lexer.Push("Fixity")},
 			lexer.Include("Literal"),
 			lexer.Include("Identity"),
+			lexer.Include("Operator"),
+		},
+		// A declaration ends with its line, and anything else on the line
+		// goes back to the state it came from. EOL has no group, as participle
+		// cannot apply an action to a rule whose group is unset.
+		"Fixity": {
+			{Name: "EOL", Pattern: `\r?\n`, Action: lexer.Pop()},
+			{Name: "WHITESPACE", Pattern: `[ \t]+`, Action: nil},
+			{Name: util.IntTagName, Pattern: util.IntRegex, Action: nil},
+			{Name: "FIXITY_OPERATOR", Pattern: `[^\w\s@][^\w\s]*`, Action: nil},
+			lexer.Return(),
+		},
+		"Bound": {
+			lexer.Include("Spacing"),
+			{Name: "BOUND_END", Pattern: `\]`, Action: lexer.Pop()},
+			{Name: "BOUND_PUNCTATION", Pattern: `,`, Action: nil},
+			{Name: "SUPPORTS_KEYWORD", Pattern: `supports`, Action: nil},
+			lexer.Include("Identity"),
+			{Name: "BOUND_OPERATOR", Pattern: `[^\w\s\],]+`, Action: nil},
 		},
 		"Instruction": {
 			lexer.Include("Spacing"),
 			lexer.Include("Expression"),
+			{Name: "BEGIN_KEYWORD", Pattern: `begin`, Action: lexer.Push("Instruction")},
 			{Name: "INLINE_IF_KEYWORD", Pattern: `ifthen`, Action: nil},
 			{Name: "IF_KEYWORD", Pattern: `if`, Action: nil},
 			{Name: "THEN_KEYWORD", Pattern: `then`, Action: lexer.Push("Condition")},
@@ -136,9 +165,12 @@ func getDflLexer() (*lexer.StatefulDefinition, error) {
 			lexer.Include("Instruction"),
 		},
 		"Pattern": {
-			{Name: "END_EVAL", Pattern: `((\r)?\n)[2]`, Action: lexer.Pop()},
+			{Name: "END_EVAL", Pattern: `(?:\r?\n[ \t]*){2,}|(?:\r?\n[ \t]*)+$`, Action: lexer.Pop()},
 			lexer.Include("Spacing"),
 			lexer.Include("Expression"),
+			{Name: "WILDCARD", Pattern: `_`, Action: nil},
+			{Name: "PATTERN_PUNCTATION", Pattern: `[\[\],:]`, Action: nil},
+			lexer.Include("Literal"),
 			lexer.Include("Identity"),
 			lexer.Include("Operator"),
 		},
@@ -146,7 +178,7 @@ func getDflLexer() (*lexer.StatefulDefinition, error) {
 }
 
 type ModuleParser struct {
-	Parser *participle.Parser[Module]
+	Parser *participle.Parser[function.Module]
 }
 
 func (modParser *ModuleParser) ParseSourceFile(
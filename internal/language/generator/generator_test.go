@@ -0,0 +1,131 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/language/config"
+	"github.com/tflexsoom/duffle/internal/language/function"
+)
+
+func TestParseExamples(t *testing.T) {
+	dflParser, err := GetDflParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ddatParser, err := GetDdatParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	examples, err := filepath.Glob(filepath.Join("..", "..", "..", "example", "*", "*.d*"))
+	if err != nil || len(examples) == 0 {
+		t.Fatalf("expected example files, got %v %v", examples, err)
+	}
+
+	for _, example := range examples {
+		reader, err := os.Open(example)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch filepath.Ext(example) {
+		case ".dfl":
+			ast, err := dflParser.ParseSourceFile(example, reader)
+			module, isOk := ast.(*function.Module)
+			if err != nil || !isOk || len(module.ModuleParts) == 0 {
+				t.Errorf("expected %v to parse, got %v", example, err)
+			} else if position := module.ModuleParts[0].Position(); position.Filename != example || position.Line != 1 {
+				t.Errorf("expected %v to record where its parts start, got %v", example, position)
+			}
+		case ".ddat":
+			ast, err := ddatParser.ParseSourceFile(example, reader)
+			if configuration, isOk := ast.(*config.Configuration); err != nil || !isOk || len(configuration.Assignments) == 0 {
+				t.Errorf("expected %v to parse, got %v", example, err)
+			}
+		}
+
+		reader.Close()
+	}
+}
+
+func TestParseDataValues(t *testing.T) {
+	parser, err := GetDdatParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := "module.roots = [\"src\", \"lib\"]\nLEVELS = 10\nSTUDENT = (Name = \"Abby\", 3.5, [1, 2])\n"
+	ast, err := parser.ParseSourceFile("test.ddat", strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assignments := ast.(*config.Configuration).Assignments
+	if len(assignments) != 3 {
+		t.Fatalf("expected 3 assignments, got %v", len(assignments))
+	}
+
+	roots := assignments[0].GetDataConfig()
+	if roots.FirstName != "module" || roots.SecondName != "roots" {
+		t.Errorf("unexpected names %v.%v", roots.FirstName, roots.SecondName)
+	}
+
+	literal, err := intermediate.LiteralOfData(roots.Values)
+	if err != nil || literal.String() != `["src", "lib"]` {
+		t.Errorf("expected a list of the roots, got %v %v", literal, err)
+	}
+
+	levels, err := intermediate.LiteralOfData(assignments[1].GetDataConfig().Values)
+	if err != nil || levels.Type != intermediate.TYPEID_INTEGER || levels.Integer != 10 {
+		t.Errorf("expected the integer 10, got %v %v", levels, err)
+	}
+
+	student := assignments[2].GetDataConfig().Values
+	if fields := student.GetChildren(); len(fields) != 3 || fields[0].GetValue().Name != "Name" || fields[1].GetValue().Name != "" {
+		t.Errorf("expected the struct fields to keep their names")
+	}
+
+	literal, err = intermediate.LiteralOfData(student)
+	if err != nil || literal.String() != `("Abby", 3.5, [1, 2])` {
+		t.Errorf("unexpected struct %v %v", literal, err)
+	}
+}
+
+func TestParseFixityDeclarations(t *testing.T) {
+	parser, err := GetDflParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last declaration is followed by a definition on its line, then
+	// ends the file without a newline
+	source := "infixl 6 +++\ninfix 4 === !==\ninfixr 5 ^^ @fact ONE := 1\ninfixr 3 <|"
+	ast, err := parser.ParseSourceFile("test.dfl", strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declarations := []function.FixityDeclaration{}
+	for _, part := range ast.(*function.Module).ModuleParts {
+		if operators, isOk := part.(function.OperatorModulePart); isOk {
+			declarations = append(declarations, operators.Declarations...)
+		}
+	}
+
+	expected := []string{"infixl 6 [+++]", "infix 4 [=== !==]", "infixr 5 [^^]", "infixr 3 [<|]"}
+	if len(declarations) != len(expected) {
+		t.Fatalf("expected %d declarations, got %+v", len(expected), declarations)
+	}
+
+	for i, declaration := range declarations {
+		if got := fmt.Sprintf("%v %v %v", declaration.Fixity, declaration.Precedence, declaration.Operators); got != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package optimize
+
+import (
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+// propagateConstants follows the values of @fact constants, which emit as
+// module values, through moves and operators. Operators of constants fold
+// to a new module value and branches on constants become jumps. Operators
+// that fail are left to fail when the program runs.
+func propagateConstants(module *intermediate.Module) {
+	heap := runtime.NewHeap()
+	for id := range module.Functions {
+		function := &module.Functions[id]
+		defined := definitions(*function)
+		constants := make(map[intermediate.Register]uint64)
+		changed := false
+		for index, instruction := range function.Definition {
+			single := intermediate.HasDest(instruction.Instruction) && defined[instruction.Dest] == 1
+			switch instruction.Instruction {
+			case intermediate.VALUE:
+				if single {
+					constants[instruction.Dest] = instruction.Operand
+				}
+			case intermediate.MOVE:
+				if value, isOk := constants[instruction.Args[0]]; isOk && single {
+					function.Definition[index] = valueOf(instruction, value)
+					constants[instruction.Dest] = value
+					changed = true
+				}
+			case intermediate.INTRINSIC:
+				name := intrinsicOf(module, instruction)
+				if !single || !runtime.IsOperator(name) || len(instruction.Args) != 2 {
+					continue
+				}
+
+				left, isLeft := constants[instruction.Args[0]]
+				right, isRight := constants[instruction.Args[1]]
+				if !isLeft || !isRight {
+					continue
+				}
+
+				result, err := runtime.ApplyOperator(
+					heap, name,
+					runtime.FromLiteral(module.Values[left]),
+					runtime.FromLiteral(module.Values[right]),
+				)
+				if err != nil {
+					continue
+				}
+
+				literal, isOk := runtime.ToLiteral(result)
+				if !isOk {
+					continue
+				}
+
+				value := uint64(module.ValueOf(literal))
+				function.Definition[index] = valueOf(instruction, value)
+				constants[instruction.Dest] = value
+				changed = true
+			case intermediate.BRANCH:
+				value, isOk := constants[instruction.Args[0]]
+				if !isOk || module.Values[value].Type != intermediate.TYPEID_BOOLEAN {
+					continue
+				}
+
+				if module.Values[value].Boolean {
+					function.Definition[index] = intermediate.Instruction{Instruction: intermediate.NOOP, Position: instruction.Position}
+				} else {
+					function.Definition[index] = intermediate.Instruction{
+						Instruction: intermediate.JUMP,
+						Operand:     instruction.Operand,
+						Position:    instruction.Position,
+					}
+				}
+
+				changed = true
+			}
+		}
+
+		if changed {
+			rebuild(function, dropNoops)
+		}
+	}
+}
+
+func valueOf(instruction intermediate.Instruction, value uint64) intermediate.Instruction {
+	return intermediate.Instruction{
+		Instruction: intermediate.VALUE,
+		Dest:        instruction.Dest,
+		Operand:     value,
+		Position:    instruction.Position,
+	}
+}
+
+func dropNoops(index int, instruction intermediate.Instruction) []intermediate.Instruction {
+	if instruction.Instruction == intermediate.NOOP {
+		return nil
+	}
+
+	return []intermediate.Instruction{instruction}
+}
@@ -0,0 +1,100 @@
+package optimize
+
+import (
+	"fmt"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+// eliminateCommon reuses the result of an expression computed earlier in
+// the same block instead of computing it again. Later reads of the repeated
+// result read the first one, which runs before them since jumps only go
+// forward.
+func eliminateCommon(module *intermediate.Module) {
+	for id := range module.Functions {
+		function := &module.Functions[id]
+		definition := function.Definition
+		defined := definitions(*function)
+		starts := make(map[int]bool)
+		for _, instruction := range definition {
+			if instruction.Instruction == intermediate.JUMP || instruction.Instruction == intermediate.BRANCH {
+				starts[int(instruction.Operand)] = true
+			}
+		}
+
+		available := make(map[string]intermediate.Register)
+		aliases := make(map[intermediate.Register]intermediate.Register)
+		repeated := make(map[int]bool)
+		for index, instruction := range definition {
+			if starts[index] {
+				available = make(map[string]intermediate.Register)
+			}
+
+			args := make([]intermediate.Register, 0, len(instruction.Args))
+			for _, arg := range instruction.Args {
+				if alias, isOk := aliases[arg]; isOk {
+					arg = alias
+				}
+
+				args = append(args, arg)
+			}
+
+			instruction.Args = args
+			definition[index] = instruction
+			if key, isOk := keyOf(module, instruction, defined); isOk {
+				if previous, exists := available[key]; exists {
+					aliases[instruction.Dest] = previous
+					repeated[index] = true
+				} else {
+					available[key] = instruction.Dest
+				}
+			}
+
+			switch instruction.Instruction {
+			case intermediate.JUMP, intermediate.BRANCH, intermediate.RETURN, intermediate.TAILCALL, intermediate.TAILAPPLY:
+				available = make(map[string]intermediate.Register)
+			}
+		}
+
+		if len(repeated) > 0 {
+			rebuild(function, func(index int, instruction intermediate.Instruction) []intermediate.Instruction {
+				if repeated[index] {
+					return nil
+				}
+
+				return []intermediate.Instruction{instruction}
+			})
+		}
+	}
+}
+
+// keyOf identifies the expression an instruction computes, for the pure
+// instructions whose registers are each written once
+func keyOf(
+	module *intermediate.Module,
+	instruction intermediate.Instruction,
+	defined map[intermediate.Register]int,
+) (string, bool) {
+	switch instruction.Instruction {
+	case intermediate.VALUE, intermediate.FIELD:
+	case intermediate.INTRINSIC:
+		if name := intrinsicOf(module, instruction); !isPure(module, instruction) || name == runtime.STRUCT_CONSTRUCTOR {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	if defined[instruction.Dest] != 1 {
+		return "", false
+	}
+
+	for _, arg := range instruction.Args {
+		if defined[arg] > 1 {
+			return "", false
+		}
+	}
+
+	return fmt.Sprintf("%d #%d %v", instruction.Instruction, instruction.Operand, instruction.Args), true
+}
@@ -0,0 +1,110 @@
+package optimize
+
+import "github.com/tflexsoom/duffle/internal/intermediate"
+
+// eliminateDead drops the instructions that can never run or whose result
+// is never read, then the functions the @exec entry point can never reach
+func eliminateDead(module *intermediate.Module) {
+	for id := range module.Functions {
+		for removeDeadInstructions(module, &module.Functions[id]) {
+		}
+	}
+
+	removeDeadFunctions(module)
+}
+
+// removeDeadInstructions makes one sweep over a function, telling whether
+// it removed anything another sweep could build on
+func removeDeadInstructions(module *intermediate.Module, function *intermediate.Function) bool {
+	definition := function.Definition
+	reachable := make([]bool, len(definition))
+	pending := []int{0}
+	for len(pending) > 0 {
+		index := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if index >= len(definition) || reachable[index] {
+			continue
+		}
+
+		reachable[index] = true
+		pending = append(pending, intermediate.SuccessorsOf(definition, index)...)
+	}
+
+	uses := make(map[intermediate.Register]int, function.Registers)
+	for index, instruction := range definition {
+		if reachable[index] {
+			for _, arg := range instruction.Args {
+				uses[arg]++
+			}
+		}
+	}
+
+	removed := false
+	rebuild(function, func(index int, instruction intermediate.Instruction) []intermediate.Instruction {
+		code := instruction.Instruction
+		isDead := !reachable[index] || code == intermediate.NOOP ||
+			((code == intermediate.JUMP || code == intermediate.BRANCH) && int(instruction.Operand) == index+1) ||
+			(intermediate.HasDest(code) && uses[instruction.Dest] == 0 && isRemovable(module, instruction))
+		if isDead {
+			removed = true
+			return nil
+		}
+
+		return []intermediate.Instruction{instruction}
+	})
+
+	return removed
+}
+
+// removeDeadFunctions keeps the functions reachable from the entry point
+// through calls and closures and renumbers them in their old order
+func removeDeadFunctions(module *intermediate.Module) {
+	if int(module.Entry) >= len(module.Functions) {
+		return
+	}
+
+	reachable := make([]bool, len(module.Functions))
+	pending := []intermediate.FunctionId{module.Entry}
+	for len(pending) > 0 {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if reachable[id] {
+			continue
+		}
+
+		reachable[id] = true
+		for _, instruction := range module.Functions[id].Definition {
+			if referencesFunction(instruction.Instruction) {
+				pending = append(pending, intermediate.FunctionId(instruction.Operand))
+			}
+		}
+	}
+
+	renumbered := make([]intermediate.FunctionId, len(module.Functions))
+	functions := make([]intermediate.Function, 0, len(module.Functions))
+	for id, function := range module.Functions {
+		if reachable[id] {
+			renumbered[id] = intermediate.FunctionId(len(functions))
+			functions = append(functions, function)
+		}
+	}
+
+	if len(functions) == len(module.Functions) {
+		return
+	}
+
+	for id := range functions {
+		for index, instruction := range functions[id].Definition {
+			if referencesFunction(instruction.Instruction) {
+				functions[id].Definition[index].Operand = uint64(renumbered[instruction.Operand])
+			}
+		}
+	}
+
+	module.Functions = functions
+	module.Entry = renumbered[module.Entry]
+}
+
+func referencesFunction(code intermediate.InstructionCode) bool {
+	return code == intermediate.CALL || code == intermediate.TAILCALL || code == intermediate.CLOSURE
+}
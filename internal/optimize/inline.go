@@ -0,0 +1,92 @@
+package optimize
+
+import "github.com/tflexsoom/duffle/internal/intermediate"
+
+// INLINE_LIMIT is the most instructions a function may have, its return
+// included, to be copied into its callers
+const INLINE_LIMIT = 8
+
+// inline copies small pure functions into the places that call them. Only
+// functions without branches, calls or captures qualify, so the copy needs
+// no jumps of its own.
+func inline(module *intermediate.Module) {
+	inlinable := make(map[intermediate.FunctionId]bool, len(module.Functions))
+	for id, function := range module.Functions {
+		inlinable[intermediate.FunctionId(id)] = isInlinable(module, function)
+	}
+
+	for id := range module.Functions {
+		caller := &module.Functions[id]
+		rebuild(caller, func(index int, instruction intermediate.Instruction) []intermediate.Instruction {
+			code := instruction.Instruction
+			callee := intermediate.FunctionId(instruction.Operand)
+			if (code != intermediate.CALL && code != intermediate.TAILCALL) ||
+				callee == intermediate.FunctionId(id) || !inlinable[callee] ||
+				len(instruction.Args) != int(module.Functions[callee].Params) {
+				return []intermediate.Instruction{instruction}
+			}
+
+			return expand(caller, module.Functions[callee], instruction)
+		})
+	}
+}
+
+func isInlinable(module *intermediate.Module, function intermediate.Function) bool {
+	definition := function.Definition
+	if function.Captures > 0 || len(definition) == 0 || len(definition) > INLINE_LIMIT ||
+		definition[len(definition)-1].Instruction != intermediate.RETURN {
+		return false
+	}
+
+	for _, instruction := range definition[:len(definition)-1] {
+		code := instruction.Instruction
+		if code == intermediate.CLOSURE || !isPure(module, instruction) || uint32(instruction.Dest) < function.Params {
+			return false
+		}
+	}
+
+	return true
+}
+
+// expand gives the body of a callee in place of a call to it. The callee's
+// registers move past the caller's, with its inputs read straight from the
+// arguments of the call.
+func expand(caller *intermediate.Function, callee intermediate.Function, call intermediate.Instruction) []intermediate.Instruction {
+	offset := caller.Registers
+	caller.Registers += callee.Registers
+	registerOf := func(register intermediate.Register) intermediate.Register {
+		if uint32(register) < callee.Params {
+			return call.Args[register]
+		}
+
+		return register + intermediate.Register(offset)
+	}
+
+	body := make([]intermediate.Instruction, 0, len(callee.Definition))
+	for _, instruction := range callee.Definition[:len(callee.Definition)-1] {
+		args := make([]intermediate.Register, 0, len(instruction.Args))
+		for _, arg := range instruction.Args {
+			args = append(args, registerOf(arg))
+		}
+
+		instruction.Dest = registerOf(instruction.Dest)
+		instruction.Args = args
+		body = append(body, instruction)
+	}
+
+	result := registerOf(callee.Definition[len(callee.Definition)-1].Args[0])
+	if call.Instruction == intermediate.TAILCALL {
+		return append(body, intermediate.Instruction{
+			Instruction: intermediate.RETURN,
+			Args:        []intermediate.Register{result},
+			Position:    call.Position,
+		})
+	}
+
+	return append(body, intermediate.Instruction{
+		Instruction: intermediate.MOVE,
+		Dest:        call.Dest,
+		Args:        []intermediate.Register{result},
+		Position:    call.Position,
+	})
+}
@@ -0,0 +1,122 @@
+package optimize
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/verify"
+)
+
+// Level selects how much work the optimizer does, like the -O flags of C
+// compilers
+type Level int
+
+const (
+	LEVEL_O0 Level = iota
+	LEVEL_O1
+	LEVEL_O2
+)
+
+const DEFAULT_LEVEL = LEVEL_O1
+
+const (
+	CONSTANT_PASS = "constprop"
+	CSE_PASS      = "cse"
+	DEAD_PASS     = "dce"
+	INLINE_PASS   = "inline"
+)
+
+// Pass rewrites a module in place. Every pass keeps the module runnable and
+// leaves the stack maps of the functions it changes up to date.
+type Pass struct {
+	Name string
+	Run  func(module *intermediate.Module)
+}
+
+var passes = map[string]Pass{
+	CONSTANT_PASS: {Name: CONSTANT_PASS, Run: propagateConstants},
+	CSE_PASS:      {Name: CSE_PASS, Run: eliminateCommon},
+	DEAD_PASS:     {Name: DEAD_PASS, Run: eliminateDead},
+	INLINE_PASS:   {Name: INLINE_PASS, Run: inline},
+}
+
+// levels lists the passes of each level in the order they run. Inlining
+// comes first so the others see through the calls it removes.
+var levels = map[Level][]string{
+	LEVEL_O0: {},
+	LEVEL_O1: {CONSTANT_PASS, CSE_PASS, DEAD_PASS},
+	LEVEL_O2: {INLINE_PASS, CONSTANT_PASS, CSE_PASS, DEAD_PASS},
+}
+
+// PassNames lists every pass --print-after accepts
+func PassNames() []string {
+	return levels[LEVEL_O2]
+}
+
+// PassesOf gives the passes a level runs, in order
+func PassesOf(level Level) ([]Pass, error) {
+	names, isOk := levels[level]
+	if !isOk {
+		return nil, fmt.Errorf("unknown optimization level %d, expected %d to %d", level, LEVEL_O0, LEVEL_O2)
+	}
+
+	selected := make([]Pass, 0, len(names))
+	for _, name := range names {
+		selected = append(selected, passes[name])
+	}
+
+	return selected, nil
+}
+
+type Options struct {
+	Level Level
+	// PrintAfter names the passes whose result is written to Output
+	PrintAfter []string
+	Output     io.Writer
+	// Verify checks the module after every pass, to find the pass that
+	// broke it
+	Verify bool
+}
+
+// Optimize runs the passes of a level over a module. Asking to print after
+// a pass the level does not run prints nothing for it.
+func Optimize(module *intermediate.Module, options Options) error {
+	selected, err := PassesOf(options.Level)
+	if err != nil {
+		return err
+	}
+
+	printed := make(map[string]bool, len(options.PrintAfter))
+	for _, name := range options.PrintAfter {
+		if _, isOk := passes[name]; !isOk {
+			return fmt.Errorf("unknown pass %v, expected one of %v", name, strings.Join(PassNames(), ", "))
+		}
+
+		printed[name] = true
+	}
+
+	// Passes rewrite definitions in place, which copies of the module share
+	functions := make([]intermediate.Function, 0, len(module.Functions))
+	for _, function := range module.Functions {
+		function.Definition = append([]intermediate.Instruction{}, function.Definition...)
+		functions = append(functions, function)
+	}
+
+	module.Functions = functions
+	for _, pass := range selected {
+		pass.Run(module)
+		if options.Verify {
+			if err := verify.Module(*module); err != nil {
+				return fmt.Errorf("module is invalid after pass %v:\n%w", pass.Name, err)
+			}
+		}
+
+		if printed[pass.Name] && options.Output != nil {
+			fmt.Fprintf(options.Output, "; after %v\n%v", pass.Name, module)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package optimize
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func instruction(code intermediate.InstructionCode, dest intermediate.Register, operand uint64, args ...intermediate.Register) intermediate.Instruction {
+	return intermediate.Instruction{Instruction: code, Dest: dest, Operand: operand, Args: args}
+}
+
+func function(name string, params uint32, registers uint32, definition ...intermediate.Instruction) intermediate.Function {
+	return intermediate.Function{Name: name, Params: params, Registers: registers, Definition: definition}
+}
+
+func namesOf(module intermediate.Module) []string {
+	names := make([]string, 0, len(module.Functions))
+	for _, function := range module.Functions {
+		names = append(names, function.Name)
+	}
+
+	return names
+}
+
+// branchingModule holds an entry point branching on a fact, an unused
+// function and a small function worth inlining
+func branchingModule() intermediate.Module {
+	module := intermediate.NewModule()
+	no := uint64(module.ValueOf(intermediate.BooleanLiteral(false)))
+	two := uint64(module.ValueOf(intermediate.IntegerLiteral(2)))
+	plus := module.IntrinsicOf("+")
+	module.Functions = append(module.Functions,
+		function("unused", 0, 1,
+			instruction(intermediate.VALUE, 0, two),
+			instruction(intermediate.RETURN, 0, 0, 0),
+		),
+		function("double", 1, 2,
+			instruction(intermediate.INTRINSIC, 1, plus, 0, 0),
+			instruction(intermediate.RETURN, 0, 0, 1),
+		),
+		function("main", 0, 7,
+			instruction(intermediate.VALUE, 0, no),
+			instruction(intermediate.BRANCH, 0, 4, 0),
+			instruction(intermediate.CALL, 1, 0),
+			instruction(intermediate.RETURN, 0, 0, 1),
+			instruction(intermediate.VALUE, 2, two),
+			instruction(intermediate.VALUE, 3, two),
+			instruction(intermediate.CALL, 4, 1, 2),
+			instruction(intermediate.CALL, 5, 1, 3),
+			instruction(intermediate.INTRINSIC, 6, plus, 4, 5),
+			instruction(intermediate.RETURN, 0, 0, 6),
+		),
+	)
+	module.Entry = 2
+	return module
+}
+
+func TestOptimizeLevels(t *testing.T) {
+	for _, test := range []struct {
+		level     Level
+		functions []string
+		main      string
+	}{
+		{LEVEL_O0, []string{"unused", "double", "main"}, ""},
+		{LEVEL_O1, []string{"double", "main"}, "r2 = value #1\n  r4 = call #0 r2\n  r5 = call #0 r2\n  r6 = intrinsic #0 r4 r5\n  return r6"},
+		{LEVEL_O2, []string{"main"}, "r6 = value #3\n  return r6"},
+	} {
+		module := branchingModule()
+		if err := Optimize(&module, Options{Level: test.level}); err != nil {
+			t.Fatal(err)
+		}
+
+		if names := strings.Join(namesOf(module), " "); names != strings.Join(test.functions, " ") {
+			t.Errorf("-O%d kept functions %v, expected %v\n%v", test.level, names, test.functions, module)
+		}
+
+		main := module.Functions[module.Entry]
+		if main.Name != "main" {
+			t.Errorf("-O%d lost the entry point\n%v", test.level, module)
+		}
+
+		if test.level == LEVEL_O0 {
+			if len(main.Definition) != 10 {
+				t.Errorf("-O0 changed main\n%v", module)
+			}
+
+			continue
+		}
+
+		lines := make([]string, 0, len(main.Definition))
+		for _, instruction := range main.Definition {
+			lines = append(lines, instruction.String())
+		}
+
+		if listed := strings.Join(lines, "\n  "); listed != test.main {
+			t.Errorf("-O%d left main as\n  %v\nexpected\n  %v", test.level, listed, test.main)
+		}
+	}
+}
+
+func TestCommonSubexpressions(t *testing.T) {
+	module := intermediate.NewModule()
+	plus := module.IntrinsicOf("+")
+	module.Functions = append(module.Functions, function("main", 2, 5,
+		instruction(intermediate.INTRINSIC, 2, plus, 0, 1),
+		instruction(intermediate.INTRINSIC, 3, plus, 0, 1),
+		instruction(intermediate.INTRINSIC, 4, plus, 2, 3),
+		instruction(intermediate.RETURN, 0, 0, 4),
+	))
+
+	eliminateCommon(&module)
+	definition := module.Functions[0].Definition
+	if len(definition) != 3 || definition[1].Args[0] != 2 || definition[1].Args[1] != 2 {
+		t.Errorf("expected the repeated sum to be reused\n%v", module)
+	}
+}
+
+func TestFailingOperatorsStay(t *testing.T) {
+	module := intermediate.NewModule()
+	zero := uint64(module.ValueOf(intermediate.IntegerLiteral(0)))
+	divide := module.IntrinsicOf("/")
+	module.Functions = append(module.Functions, function("main", 1, 3,
+		instruction(intermediate.VALUE, 1, zero),
+		instruction(intermediate.INTRINSIC, 2, divide, 0, 1),
+		instruction(intermediate.INTRINSIC, 2, divide, 1, 1),
+		instruction(intermediate.RETURN, 0, 0, 0),
+	))
+
+	if err := Optimize(&module, Options{Level: LEVEL_O2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if divisions := strings.Count(module.String(), "intrinsic #0"); divisions != 3 {
+		t.Errorf("expected both divisions to stay\n%v", module)
+	}
+}
+
+func TestPrintAfter(t *testing.T) {
+	module := branchingModule()
+	output := bytes.Buffer{}
+	err := Optimize(&module, Options{Level: LEVEL_O1, PrintAfter: []string{DEAD_PASS, INLINE_PASS}, Output: &output})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if printed := output.String(); strings.Count(printed, "; after ") != 1 || !strings.HasPrefix(printed, "; after dce\n") {
+		t.Errorf("unexpected output\n%v", printed)
+	}
+
+	err = Optimize(&module, Options{Level: LEVEL_O1, PrintAfter: []string{"fold"}})
+	if err == nil || !strings.Contains(err.Error(), "unknown pass fold") {
+		t.Errorf("expected an unknown pass error, got %v", err)
+	}
+
+	if err := Optimize(&module, Options{Level: 3}); err == nil {
+		t.Errorf("expected an unknown level error")
+	}
+}
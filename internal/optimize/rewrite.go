@@ -0,0 +1,88 @@
+package optimize
+
+import (
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+// failing lists the operators that may stop the program, by overflow or
+// division by zero, so they must run even when their result is unused
+var failing = map[string]bool{"+": true, "-": true, "*": true, "/": true, "%": true}
+
+// intrinsicOf names the intrinsic an instruction calls
+func intrinsicOf(module *intermediate.Module, instruction intermediate.Instruction) string {
+	if instruction.Instruction != intermediate.INTRINSIC || int(instruction.Operand) >= len(module.Intrinsics) {
+		return ""
+	}
+
+	return module.Intrinsics[instruction.Operand]
+}
+
+// isPure reports instructions whose result depends on nothing but their
+// arguments and that touch nothing else
+func isPure(module *intermediate.Module, instruction intermediate.Instruction) bool {
+	switch instruction.Instruction {
+	case intermediate.VALUE, intermediate.MOVE, intermediate.FIELD, intermediate.CLOSURE:
+		return true
+	case intermediate.INTRINSIC:
+		name := intrinsicOf(module, instruction)
+		return runtime.IsOperator(name) || name == runtime.STRUCT_CONSTRUCTOR
+	}
+
+	return false
+}
+
+// isRemovable reports pure instructions that cannot fail, which may be
+// dropped once their result is unused
+func isRemovable(module *intermediate.Module, instruction intermediate.Instruction) bool {
+	return isPure(module, instruction) && !failing[intrinsicOf(module, instruction)]
+}
+
+// definitions counts the writes of every register of a function. Captures
+// and inputs are written once by the call.
+func definitions(function intermediate.Function) map[intermediate.Register]int {
+	counts := make(map[intermediate.Register]int, function.Registers)
+	for register := uint32(0); register < function.Captures+function.Params; register++ {
+		counts[intermediate.Register(register)]++
+	}
+
+	for _, instruction := range function.Definition {
+		if intermediate.HasDest(instruction.Instruction) {
+			counts[instruction.Dest]++
+		}
+	}
+
+	return counts
+}
+
+// rebuild replaces every instruction of a function with the instructions
+// replace gives for it, which may be none, and moves jump targets along. A
+// jump to a removed instruction lands on whatever followed it.
+func rebuild(
+	function *intermediate.Function,
+	replace func(index int, instruction intermediate.Instruction) []intermediate.Instruction,
+) {
+	starts := make([]int, len(function.Definition)+1)
+	pieces := make([][]intermediate.Instruction, len(function.Definition))
+	total := 0
+	for index, instruction := range function.Definition {
+		starts[index] = total
+		pieces[index] = replace(index, instruction)
+		total += len(pieces[index])
+	}
+
+	starts[len(function.Definition)] = total
+	definition := make([]intermediate.Instruction, 0, total)
+	for _, piece := range pieces {
+		for _, instruction := range piece {
+			if instruction.Instruction == intermediate.JUMP || instruction.Instruction == intermediate.BRANCH {
+				instruction.Operand = uint64(starts[instruction.Operand])
+			}
+
+			definition = append(definition, instruction)
+		}
+	}
+
+	function.Definition = definition
+	function.StackMaps = intermediate.StackMapsOf(*function)
+}
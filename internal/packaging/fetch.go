@@ -0,0 +1,222 @@
+package packaging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	CACHE_DIRECTORY  = "duffle"
+	MIRROR_DIRECTORY = "git"
+	SOURCE_DIRECTORY = "src"
+)
+
+const DEFAULT_REVISION = "HEAD"
+
+// DefaultCacheDir is the shared cache of fetched dependencies for the current user
+func DefaultCacheDir() (string, error) {
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(userCache, CACHE_DIRECTORY), nil
+}
+
+type Fetched struct {
+	Location string
+	Revision string
+	Hash     string
+}
+
+type Fetcher struct {
+	CacheDir string
+}
+
+// Fetch copies a dependency into the cache. Git sources are checked out at
+// the requested revision, or at the given version when no revision is pinned.
+// Local directories are copied as they are.
+func (fetcher Fetcher) Fetch(dependency Dependency, revision string) (Fetched, error) {
+	if IsGitSource(dependency.Source) {
+		return fetcher.fetchGit(dependency, revision)
+	}
+
+	return fetcher.fetchLocal(dependency)
+}
+
+// IsGitSource distinguishes git remotes, including local bare repositories,
+// from plain directories.
+func IsGitSource(source string) bool {
+	if strings.Contains(source, "://") || strings.HasPrefix(source, "git@") || strings.HasSuffix(source, ".git") {
+		return true
+	}
+
+	if info, err := os.Stat(filepath.Join(source, ".git")); err == nil && info.IsDir() {
+		return true
+	}
+
+	_, headErr := os.Stat(filepath.Join(source, "HEAD"))
+	info, objectsErr := os.Stat(filepath.Join(source, "objects"))
+	return headErr == nil && objectsErr == nil && info.IsDir()
+}
+
+func keyOf(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (fetcher Fetcher) fetchGit(dependency Dependency, revision string) (Fetched, error) {
+	mirror := filepath.Join(fetcher.CacheDir, MIRROR_DIRECTORY, keyOf(dependency.Source))
+	if _, err := os.Stat(mirror); err != nil {
+		if err := os.MkdirAll(filepath.Dir(mirror), 0755); err != nil {
+			return Fetched{}, err
+		}
+
+		if _, err := git("", "clone", "--quiet", "--mirror", "--", dependency.Source, mirror); err != nil {
+			return Fetched{}, fmt.Errorf("fetching %v: %v", dependency.Name, err)
+		}
+	} else if revision == "" || !hasCommit(mirror, revision) {
+		if _, err := git(mirror, "remote", "update", "--prune"); err != nil {
+			return Fetched{}, fmt.Errorf("updating %v: %v", dependency.Name, err)
+		}
+	}
+
+	if revision == "" {
+		revision = dependency.Version
+		if revision == "" {
+			revision = DEFAULT_REVISION
+		}
+	}
+
+	commit, err := git(mirror, "rev-parse", "--verify", "--quiet", "--end-of-options", revision+"^{commit}")
+	if err != nil {
+		return Fetched{}, fmt.Errorf("%v has no revision %v", dependency.Name, revision)
+	}
+
+	location := filepath.Join(fetcher.CacheDir, SOURCE_DIRECTORY, keyOf(dependency.Source), commit)
+	if _, err := os.Stat(location); err != nil {
+		staging, err := os.MkdirTemp(filepath.Dir(mirror), "checkout-")
+		if err != nil {
+			return Fetched{}, err
+		}
+		defer os.RemoveAll(staging)
+
+		worktree := filepath.Join(staging, "tree")
+		if err := os.Mkdir(worktree, 0755); err != nil {
+			return Fetched{}, err
+		}
+
+		// A private index keeps concurrent checkouts from sharing the mirror's index
+		_, err = gitWithEnv(mirror, []string{"GIT_INDEX_FILE=" + filepath.Join(staging, "index")},
+			"--work-tree="+worktree, "checkout", "--force", commit, "--", ".")
+		if err != nil {
+			return Fetched{}, fmt.Errorf("checking out %v: %v", dependency.Name, err)
+		}
+
+		if err := install(worktree, location); err != nil {
+			return Fetched{}, err
+		}
+	}
+
+	hash, err := HashDirectory(location)
+	if err != nil {
+		return Fetched{}, err
+	}
+
+	return Fetched{Location: location, Revision: commit, Hash: hash}, nil
+}
+
+func (fetcher Fetcher) fetchLocal(dependency Dependency) (Fetched, error) {
+	info, err := os.Stat(dependency.Source)
+	if err != nil {
+		return Fetched{}, fmt.Errorf("fetching %v: %v", dependency.Name, err)
+	} else if !info.IsDir() {
+		return Fetched{}, fmt.Errorf("fetching %v: %v is not a directory", dependency.Name, dependency.Source)
+	}
+
+	hash, err := HashDirectory(dependency.Source)
+	if err != nil {
+		return Fetched{}, err
+	}
+
+	location := filepath.Join(fetcher.CacheDir, SOURCE_DIRECTORY, keyOf(dependency.Source), strings.TrimPrefix(hash, HASH_PREFIX))
+	if _, err := os.Stat(location); err != nil {
+		if err := os.MkdirAll(fetcher.CacheDir, 0755); err != nil {
+			return Fetched{}, err
+		}
+
+		staging, err := os.MkdirTemp(fetcher.CacheDir, "copy-")
+		if err != nil {
+			return Fetched{}, err
+		}
+		defer os.RemoveAll(staging)
+
+		if err := os.Chmod(staging, 0755); err != nil {
+			return Fetched{}, err
+		}
+
+		if err := copyDirectory(dependency.Source, staging); err != nil {
+			return Fetched{}, err
+		}
+
+		if err := install(staging, location); err != nil {
+			return Fetched{}, err
+		}
+	}
+
+	return Fetched{Location: location, Hash: hash}, nil
+}
+
+// install moves a finished tree into its cache location. Losing a race to
+// another fetch of the same content is fine since the contents are identical.
+func install(tree string, location string) error {
+	if err := os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tree, location); err != nil {
+		if _, statErr := os.Stat(location); statErr == nil {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func hasCommit(mirror string, revision string) bool {
+	_, err := git(mirror, "cat-file", "-e", "--end-of-options", revision+"^{commit}")
+	return err == nil
+}
+
+// git runs a git command. Sources and revisions come from manifests and
+// lockfiles, so they follow -- or --end-of-options, where git reads them as
+// values even when they start with a dash.
+func git(gitDir string, args ...string) (string, error) {
+	return gitWithEnv(gitDir, nil, args...)
+}
+
+func gitWithEnv(gitDir string, env []string, args ...string) (string, error) {
+	if gitDir != "" {
+		args = append([]string{"--git-dir=" + gitDir}, args...)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), env...)
+
+	stderr := bytes.Buffer{}
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %v: %v", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
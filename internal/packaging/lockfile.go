@@ -0,0 +1,101 @@
+package packaging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+// LOCKED_CONFIG is the single assignment of a duffle.lock file:
+//
+//	locked = [("mathlib", "https://github.com/tflexsoom/mathlib.git", "v1.0.0", "<commit>", "sha256-<hex>")]
+const LOCKED_CONFIG = "locked"
+
+type LockedDependency struct {
+	Dependency
+	Revision string
+	Hash     string
+}
+
+type Lockfile struct {
+	Dependencies []LockedDependency
+}
+
+// LockfileOf reads a lockfile out of the assignments of a parsed duffle.lock
+func LockfileOf(configs []intermediate.DataConfig) (Lockfile, error) {
+	lock := Lockfile{Dependencies: make([]LockedDependency, 0)}
+	for _, config := range configs {
+		if configName(config) != LOCKED_CONFIG {
+			continue
+		}
+
+		literal, err := intermediate.LiteralOfData(config.Values)
+		if err != nil {
+			return Lockfile{}, fmt.Errorf("%v: %v", LOCKED_CONFIG, err)
+		}
+
+		if literal.Type != intermediate.TYPEID_LIST {
+			return Lockfile{}, fmt.Errorf("%v: expected a list of locked dependencies but found %v", LOCKED_CONFIG, literal)
+		}
+
+		for _, item := range literal.Items {
+			texts, err := textsOf(item, 5, 5)
+			if err != nil {
+				return Lockfile{}, fmt.Errorf("%v: %v", LOCKED_CONFIG, err)
+			}
+
+			lock.Dependencies = append(lock.Dependencies, LockedDependency{
+				Dependency: Dependency{Name: texts[0], Source: texts[1], Version: texts[2]},
+				Revision:   texts[3],
+				Hash:       texts[4],
+			})
+		}
+	}
+
+	return lock, nil
+}
+
+func (lock Lockfile) Lookup(name string) (LockedDependency, bool) {
+	for _, locked := range lock.Dependencies {
+		if locked.Name == name {
+			return locked, true
+		}
+	}
+
+	return LockedDependency{}, false
+}
+
+// String writes the lockfile back out in the data file format, sorted by name
+// so that regenerating an unchanged lockfile gives the same bytes.
+func (lock Lockfile) String() string {
+	dependencies := append([]LockedDependency{}, lock.Dependencies...)
+	sort.Slice(dependencies, func(i, j int) bool {
+		return dependencies[i].Name < dependencies[j].Name
+	})
+
+	builder := strings.Builder{}
+	builder.WriteString(LOCKED_CONFIG + " = [")
+	for i, locked := range dependencies {
+		if i > 0 {
+			builder.WriteString(",")
+		}
+
+		item := intermediate.Literal{Type: intermediate.TYPEID_STRUCT, Items: []intermediate.Literal{
+			intermediate.TextLiteral(locked.Name),
+			intermediate.TextLiteral(locked.Source),
+			intermediate.TextLiteral(locked.Version),
+			intermediate.TextLiteral(locked.Revision),
+			intermediate.TextLiteral(locked.Hash),
+		}}
+		builder.WriteString("\n  " + item.String())
+	}
+
+	if len(dependencies) > 0 {
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("]\n")
+	return builder.String()
+}
@@ -0,0 +1,186 @@
+package packaging
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/resolve"
+)
+
+type Manager struct {
+	ProjectRoot string
+	Fetcher     Fetcher
+
+	// LoadManifest reads the manifest of a fetched dependency. Dependencies
+	// without a manifest report an error matching fs.ErrNotExist.
+	LoadManifest func(directory string) (Manifest, error)
+}
+
+func NewManager(projectRoot string, cacheDir string, loadManifest func(string) (Manifest, error)) Manager {
+	return Manager{
+		ProjectRoot:  projectRoot,
+		Fetcher:      Fetcher{CacheDir: cacheDir},
+		LoadManifest: loadManifest,
+	}
+}
+
+type pending struct {
+	dependency Dependency
+	base       string
+	requiredBy string
+}
+
+// Get fetches every dependency of the manifest, and the dependencies of those,
+// into the cache and locks each one to a revision and content hash. Entries of
+// the previous lockfile with the same source and version keep their revision.
+func (manager Manager) Get(manifest Manifest, previous Lockfile) (Lockfile, error) {
+	queue := make([]pending, 0, len(manifest.Dependencies))
+	for _, dependency := range manifest.Dependencies {
+		queue = append(queue, pending{dependency: dependency, base: manager.ProjectRoot, requiredBy: manifest.Name})
+	}
+
+	lock := Lockfile{Dependencies: make([]LockedDependency, 0, len(queue))}
+	requiredBy := make(map[string]string, len(queue))
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		dependency := next.dependency
+		if next.base != manager.ProjectRoot {
+			dependency.Source = sourceOf(next.base, dependency.Source)
+		}
+
+		if chosen, isOk := lock.Lookup(dependency.Name); isOk {
+			if chosen.Source != dependency.Source || chosen.Version != dependency.Version {
+				return Lockfile{}, fmt.Errorf(
+					"dependency %v is required as %v@%v by %v and as %v@%v by %v",
+					dependency.Name, chosen.Source, chosen.Version, requiredBy[dependency.Name],
+					dependency.Source, dependency.Version, next.requiredBy,
+				)
+			}
+
+			continue
+		}
+
+		// Local directories have no revision, so only their hash pins them
+		locked, isLocked := previous.Lookup(dependency.Name)
+		isLocked = isLocked && locked.Source == dependency.Source && locked.Version == dependency.Version
+		revision := ""
+		if isLocked {
+			revision = locked.Revision
+		}
+
+		fetched, err := manager.Fetcher.Fetch(manager.resolved(dependency), revision)
+		if err != nil {
+			return Lockfile{}, err
+		}
+
+		if isLocked && fetched.Hash != locked.Hash {
+			if revision == "" {
+				return Lockfile{}, fmt.Errorf(
+					"%v hashes to %v but is locked to %v, remove it from %v to lock its new contents",
+					dependency.Name, fetched.Hash, locked.Hash, LOCK_FILE_NAME,
+				)
+			}
+
+			return Lockfile{}, fmt.Errorf("%v at %v hashes to %v but is locked to %v", dependency.Name, revision, fetched.Hash, locked.Hash)
+		}
+
+		lock.Dependencies = append(lock.Dependencies, LockedDependency{
+			Dependency: dependency,
+			Revision:   fetched.Revision,
+			Hash:       fetched.Hash,
+		})
+		requiredBy[dependency.Name] = next.requiredBy
+
+		if manager.LoadManifest == nil {
+			continue
+		}
+
+		transitive, err := manager.LoadManifest(fetched.Location)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return Lockfile{}, fmt.Errorf("reading manifest of %v: %v", dependency.Name, err)
+		}
+
+		base := fetched.Location
+		if !IsGitSource(manager.resolved(dependency).Source) {
+			base = manager.resolved(dependency).Source
+		}
+
+		for _, child := range transitive.Dependencies {
+			queue = append(queue, pending{dependency: child, base: base, requiredBy: dependency.Name})
+		}
+	}
+
+	return lock, nil
+}
+
+// Vendor copies the locked dependencies into the vendor directory of the
+// project, replacing whatever was vendored before. Content that no longer
+// matches its locked hash is refused.
+func (manager Manager) Vendor(lock Lockfile) error {
+	staging, err := os.MkdirTemp(manager.ProjectRoot, "."+resolve.VENDOR_DIRECTORY+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	if err := os.Chmod(staging, 0755); err != nil {
+		return err
+	}
+
+	for _, locked := range lock.Dependencies {
+		fetched, err := manager.Fetcher.Fetch(manager.resolved(locked.Dependency), locked.Revision)
+		if err != nil {
+			return err
+		}
+
+		if fetched.Hash != locked.Hash {
+			return fmt.Errorf("%v hashes to %v but is locked to %v", locked.Name, fetched.Hash, locked.Hash)
+		}
+
+		destination := filepath.Join(append([]string{staging}, strings.Split(locked.Name, ".")...)...)
+		if err := os.MkdirAll(destination, 0755); err != nil {
+			return err
+		}
+
+		if err := copyDirectory(fetched.Location, destination); err != nil {
+			return err
+		}
+	}
+
+	// What was vendored before is moved aside, and only removed once the
+	// new dependencies took its place
+	vendor := filepath.Join(manager.ProjectRoot, resolve.VENDOR_DIRECTORY)
+	previous := staging + ".previous"
+	if err := os.Rename(vendor, previous); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Rename(staging, vendor); err != nil {
+		os.Rename(previous, vendor)
+		return err
+	}
+
+	return os.RemoveAll(previous)
+}
+
+// resolved makes a local source declared relative to the project absolute
+func (manager Manager) resolved(dependency Dependency) Dependency {
+	dependency.Source = sourceOf(manager.ProjectRoot, dependency.Source)
+	return dependency
+}
+
+func sourceOf(base string, source string) string {
+	if strings.Contains(source, "://") || strings.HasPrefix(source, "git@") || filepath.IsAbs(source) {
+		return source
+	}
+
+	return filepath.Join(base, source)
+}
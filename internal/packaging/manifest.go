@@ -0,0 +1,256 @@
+package packaging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+const (
+	MANIFEST_FILE_NAME = "duffle.ddat"
+	LOCK_FILE_NAME     = "duffle.lock"
+)
+
+// Manifest keys as written in duffle.ddat:
+//
+//	module.name = "pyramid"
+//	module.version = "0.1.0"
+//	module.roots = ["src", "lib"]
+//	module.entry = "main"
+//	build.backends = ["binary_x86_64_exe", "ir"]
+//	binary_x86_64_exe.output = "build/pyramid"
+//	ir.output = "build/pyramid.ir"
+//	ir.optimize = 0
+//	dependencies = [("mathlib", "https://github.com/tflexsoom/mathlib.git", "v1.0.0")]
+//
+// Any other key with a first name configures the backend of that name.
+const (
+	MODULE_CONFIG       = "module"
+	NAME_CONFIG         = "name"
+	VERSION_CONFIG      = "version"
+	ROOTS_CONFIG        = "roots"
+	ENTRY_CONFIG        = "entry"
+	BUILD_CONFIG        = "build"
+	BACKENDS_CONFIG     = "backends"
+	OUTPUT_CONFIG       = "output"
+	OPTIMIZE_CONFIG     = "optimize"
+	DEPENDENCIES_CONFIG = "dependencies"
+)
+
+// DEFAULT_ROOT is the source root of projects whose manifest names none
+const DEFAULT_ROOT = "."
+
+type Dependency struct {
+	Name    string
+	Source  string
+	Version string
+}
+
+// Target configures one backend of a project
+type Target struct {
+	// Output is relative to the directory of the manifest
+	Output string
+	// Optimize is the optimization level, or nil for the default
+	Optimize *int
+}
+
+type Manifest struct {
+	Name    string
+	Version string
+	// Roots hold the source files, relative to the directory of the
+	// manifest
+	Roots []string
+	// Entry names the @exec function the project runs from
+	Entry string
+	// Backends are built by default, the first when only one is needed
+	Backends     []string
+	Targets      map[string]Target
+	Dependencies []Dependency
+}
+
+// ManifestOf reads a manifest out of the assignments of a parsed duffle.ddat
+func ManifestOf(configs []intermediate.DataConfig) (Manifest, error) {
+	manifest := Manifest{Targets: make(map[string]Target), Dependencies: make([]Dependency, 0)}
+	for _, config := range configs {
+		literal, err := intermediate.LiteralOfData(config.Values)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("%v: %v", configName(config), err)
+		}
+
+		switch configName(config) {
+		case MODULE_CONFIG + "." + NAME_CONFIG:
+			manifest.Name, err = textOf(literal)
+		case MODULE_CONFIG + "." + VERSION_CONFIG:
+			manifest.Version, err = textOf(literal)
+		case MODULE_CONFIG + "." + ROOTS_CONFIG:
+			manifest.Roots, err = textListOf(literal)
+		case MODULE_CONFIG + "." + ENTRY_CONFIG:
+			manifest.Entry, err = textOf(literal)
+		case BUILD_CONFIG + "." + BACKENDS_CONFIG:
+			manifest.Backends, err = textListOf(literal)
+		case DEPENDENCIES_CONFIG:
+			manifest.Dependencies, err = dependenciesOf(literal)
+		default:
+			if config.FirstName != "" && config.FirstName != MODULE_CONFIG && config.FirstName != BUILD_CONFIG {
+				err = targetOf(&manifest, config.FirstName, config.SecondName, literal)
+			}
+		}
+
+		if err != nil {
+			return Manifest{}, fmt.Errorf("%v: %v", configName(config), err)
+		}
+	}
+
+	if manifest.Name == "" {
+		return Manifest{}, fmt.Errorf("manifest is missing %v.%v", MODULE_CONFIG, NAME_CONFIG)
+	}
+
+	if len(manifest.Roots) == 0 {
+		manifest.Roots = []string{DEFAULT_ROOT}
+	}
+
+	for _, root := range manifest.Roots {
+		if filepath.IsAbs(root) {
+			return Manifest{}, fmt.Errorf("%v.%v: root %v must be relative to the manifest", MODULE_CONFIG, ROOTS_CONFIG, root)
+		}
+	}
+
+	seen := make(map[string]bool, len(manifest.Dependencies))
+	for _, dependency := range manifest.Dependencies {
+		if seen[dependency.Name] {
+			return Manifest{}, fmt.Errorf("dependency %v is declared more than once", dependency.Name)
+		}
+
+		seen[dependency.Name] = true
+	}
+
+	return manifest, nil
+}
+
+func targetOf(manifest *Manifest, backendName string, option string, literal intermediate.Literal) error {
+	target := manifest.Targets[backendName]
+	switch option {
+	case OUTPUT_CONFIG:
+		output, err := textOf(literal)
+		if err != nil {
+			return err
+		}
+
+		target.Output = output
+	case OPTIMIZE_CONFIG:
+		if literal.Type != intermediate.TYPEID_INTEGER {
+			return fmt.Errorf("expected an optimization level but found %v", literal)
+		}
+
+		level := int(literal.Integer)
+		target.Optimize = &level
+	default:
+		return fmt.Errorf("unknown backend option %v, expected %v or %v", option, OUTPUT_CONFIG, OPTIMIZE_CONFIG)
+	}
+
+	manifest.Targets[backendName] = target
+	return nil
+}
+
+// ErrNoManifest is given by FindManifest when no directory holds a manifest
+var ErrNoManifest = errors.New("no " + MANIFEST_FILE_NAME + " was found")
+
+// FindManifest walks up from a directory to the nearest one holding a
+// manifest, giving the path of the manifest
+func FindManifest(directory string) (string, error) {
+	start, err := filepath.Abs(directory)
+	if err != nil {
+		return "", err
+	}
+
+	for current := start; ; current = filepath.Dir(current) {
+		file := filepath.Join(current, MANIFEST_FILE_NAME)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if filepath.Dir(current) == current {
+			return "", fmt.Errorf("%w in %v or any directory above it", ErrNoManifest, start)
+		}
+	}
+}
+
+func configName(config intermediate.DataConfig) string {
+	if config.FirstName == "" {
+		return config.SecondName
+	}
+
+	return config.FirstName + "." + config.SecondName
+}
+
+func textOf(literal intermediate.Literal) (string, error) {
+	if literal.Type != intermediate.TYPEID_TEXT {
+		return "", fmt.Errorf("expected text but found %v", literal)
+	}
+
+	return literal.Text, nil
+}
+
+// textsOf reads a struct of text values, allowing trailing values to be left off
+func textsOf(literal intermediate.Literal, minimum int, maximum int) ([]string, error) {
+	if literal.Type != intermediate.TYPEID_STRUCT || len(literal.Items) < minimum || len(literal.Items) > maximum {
+		return nil, fmt.Errorf("expected a struct of %v to %v text values but found %v", minimum, maximum, literal)
+	}
+
+	texts := make([]string, maximum)
+	for i, item := range literal.Items {
+		text, err := textOf(item)
+		if err != nil {
+			return nil, err
+		}
+
+		texts[i] = text
+	}
+
+	return texts, nil
+}
+
+func textListOf(literal intermediate.Literal) ([]string, error) {
+	if literal.Type != intermediate.TYPEID_LIST {
+		return nil, fmt.Errorf("expected a list of text but found %v", literal)
+	}
+
+	texts := make([]string, 0, len(literal.Items))
+	for _, item := range literal.Items {
+		text, err := textOf(item)
+		if err != nil {
+			return nil, err
+		}
+
+		texts = append(texts, text)
+	}
+
+	return texts, nil
+}
+
+func dependenciesOf(literal intermediate.Literal) ([]Dependency, error) {
+	if literal.Type != intermediate.TYPEID_LIST {
+		return nil, fmt.Errorf("expected a list of dependencies but found %v", literal)
+	}
+
+	dependencies := make([]Dependency, 0, len(literal.Items))
+	for _, item := range literal.Items {
+		texts, err := textsOf(item, 2, 3)
+		if err != nil {
+			return nil, err
+		}
+
+		dependencies = append(dependencies, Dependency{
+			Name:    texts[0],
+			Source:  texts[1],
+			Version: texts[2],
+		})
+	}
+
+	return dependencies, nil
+}
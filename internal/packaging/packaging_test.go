@@ -0,0 +1,344 @@
+package packaging
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func runGit(t *testing.T, directory string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = directory
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bareRepository stands in for a GitHub remote, committing each version of
+// the given files and tagging it.
+func bareRepository(t *testing.T, versions ...map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	bare := filepath.Join(root, "remote.git")
+	os.Mkdir(work, 0755)
+	runGit(t, work, "init", "--quiet")
+	for i, version := range versions {
+		for name, content := range version {
+			writeFile(t, filepath.Join(work, name), content)
+		}
+
+		runGit(t, work, "add", "-A")
+		runGit(t, work, "commit", "--quiet", "-m", "version")
+		runGit(t, work, "tag", "v"+string(rune('1'+i)))
+	}
+
+	runGit(t, root, "clone", "--quiet", "--bare", work, bare)
+	return bare
+}
+
+func TestGetAndVendorGit(t *testing.T) {
+	remote := bareRepository(t,
+		map[string]string{"math.dfl": "@@ one = 1\n"},
+		map[string]string{"math.dfl": "@@ one = 2\n"},
+	)
+
+	project := t.TempDir()
+	manager := NewManager(project, t.TempDir(), nil)
+	manifest := Manifest{Name: "app", Dependencies: []Dependency{{Name: "mathlib", Source: remote, Version: "v1"}}}
+
+	lock, err := manager.Get(manifest, Lockfile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked, isOk := lock.Lookup("mathlib")
+	if !isOk || len(locked.Revision) != 40 || !strings.HasPrefix(locked.Hash, HASH_PREFIX) {
+		t.Fatalf("unexpected lock %+v", lock)
+	}
+
+	if err := manager.Vendor(lock); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(project, "vendor", "mathlib", "math.dfl"))
+	if err != nil || string(content) != "@@ one = 1\n" {
+		t.Errorf("unexpected vendored content %q: %v", content, err)
+	}
+
+	// Getting again with the lockfile keeps the pinned revision
+	relocked, err := manager.Get(manifest, lock)
+	if err != nil || relocked.String() != lock.String() {
+		t.Errorf("lockfile changed:\n%v\n%v\n%v", lock, relocked, err)
+	}
+
+	tampered := Lockfile{Dependencies: []LockedDependency{locked}}
+	tampered.Dependencies[0].Hash = HASH_PREFIX + "00"
+	if err := manager.Vendor(tampered); err == nil {
+		t.Errorf("expected a hash mismatch")
+	}
+
+	// Vendoring again replaces the vendor directory and leaves nothing aside
+	if err := manager.Vendor(lock); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(project)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "vendor" {
+		t.Errorf("expected only the vendor directory in the project, got %v %v", entries, err)
+	}
+}
+
+func TestFetchSourceStartingWithADash(t *testing.T) {
+	remote := bareRepository(t, map[string]string{"math.dfl": "@@ one = 1\n"})
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// git would read the relative source as an option without --
+	if err := os.Chdir(filepath.Dir(remote)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(workingDirectory) })
+
+	if err := os.Rename(remote, "-remote.git"); err != nil {
+		t.Fatal(err)
+	}
+
+	fetcher := Fetcher{CacheDir: t.TempDir()}
+	fetched, err := fetcher.Fetch(Dependency{Name: "mathlib", Source: "-remote.git", Version: "v1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fetcher.Fetch(Dependency{Name: "mathlib", Source: "-remote.git"}, "--all"); err == nil {
+		t.Errorf("expected a revision starting with a dash to be looked up as a name")
+	}
+
+	if content, err := os.ReadFile(filepath.Join(fetched.Location, "math.dfl")); err != nil || string(content) != "@@ one = 1\n" {
+		t.Errorf("unexpected fetched content %q: %v", content, err)
+	}
+}
+
+func TestGetLocalAndTransitive(t *testing.T) {
+	remote := bareRepository(t, map[string]string{"strings.dfl": "@@ empty = \"\"\n"})
+
+	sources := t.TempDir()
+	writeFile(t, filepath.Join(sources, "shared", "shared.dfl"), "@@ two = 2\n")
+
+	project := filepath.Join(sources, "project")
+	writeFile(t, filepath.Join(project, "main.dfl"), "@@ main = 0\n")
+	manager := NewManager(project, t.TempDir(), func(directory string) (Manifest, error) {
+		if _, err := os.Stat(filepath.Join(directory, "shared.dfl")); err == nil {
+			return Manifest{Name: "shared", Dependencies: []Dependency{{Name: "text", Source: remote}}}, nil
+		}
+
+		return Manifest{}, fs.ErrNotExist
+	})
+
+	lock, err := manager.Get(Manifest{Name: "app", Dependencies: []Dependency{{Name: "shared", Source: "../shared"}}}, Lockfile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lock.Dependencies) != 2 {
+		t.Fatalf("unexpected lock\n%v", lock)
+	}
+
+	if err := manager.Vendor(lock); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"vendor/shared/shared.dfl", "vendor/text/strings.dfl"} {
+		if _, err := os.Stat(filepath.Join(project, file)); err != nil {
+			t.Errorf("missing vendored file: %v", err)
+		}
+	}
+
+	// A local dependency has no revision, so a change to it shows in its hash
+	writeFile(t, filepath.Join(sources, "shared", "shared.dfl"), "@@ two = 3\n")
+	_, err = manager.Get(Manifest{Name: "app", Dependencies: []Dependency{{Name: "shared", Source: "../shared"}}}, lock)
+	if err == nil || !strings.Contains(err.Error(), "shared hashes to") {
+		t.Errorf("expected the changed local dependency to mismatch its lock, got %v", err)
+	}
+}
+
+func TestConflictingDependencies(t *testing.T) {
+	remote := bareRepository(t, map[string]string{"math.dfl": "@@ one = 1\n"}, map[string]string{"math.dfl": "@@ one = 2\n"})
+
+	project := t.TempDir()
+	manager := NewManager(project, t.TempDir(), func(directory string) (Manifest, error) {
+		return Manifest{Name: "other", Dependencies: []Dependency{{Name: "mathlib", Source: remote, Version: "v2"}}}, nil
+	})
+
+	_, err := manager.Get(Manifest{Name: "app", Dependencies: []Dependency{
+		{Name: "mathlib", Source: remote, Version: "v1"},
+	}}, Lockfile{})
+	if err == nil || !strings.Contains(err.Error(), "required as") {
+		t.Errorf("expected a conflict but found %v", err)
+	}
+}
+
+func textData(text string) intermediate.DataValue {
+	return intermediate.DataValue{Type: intermediate.TYPEID_TEXT, TextValue: "\"" + text + "\""}
+}
+
+func TestManifestAndLockfileOfData(t *testing.T) {
+	dependencies := container.NewGraphTreeCap[intermediate.DataValue](2, 1).
+		SetValue(intermediate.DataValue{Type: intermediate.TYPEID_LIST})
+	dependency := container.NewGraphTreeCap[intermediate.DataValue](2, 3).
+		SetValue(intermediate.DataValue{Type: intermediate.TYPEID_STRUCT}).
+		AddChild(textData("mathlib")).
+		AddChild(textData("../mathlib.git"))
+	container.AddChildren(dependencies, dependency)
+
+	manifest, err := ManifestOf([]intermediate.DataConfig{
+		{FirstName: "module", SecondName: "name", Values: container.NewGraphTreeCap[intermediate.DataValue](1, 1).SetValue(textData("app"))},
+		{SecondName: "dependencies", Values: dependencies},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Name != "app" || len(manifest.Dependencies) != 1 || manifest.Dependencies[0].Source != "../mathlib.git" {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+
+	if _, err := ManifestOf(nil); err == nil {
+		t.Errorf("expected a missing name error")
+	}
+
+	lock := Lockfile{Dependencies: []LockedDependency{{
+		Dependency: manifest.Dependencies[0],
+		Revision:   "abc",
+		Hash:       HASH_PREFIX + "def",
+	}}}
+	expected := "locked = [\n  (\"mathlib\", \"../mathlib.git\", \"\", \"abc\", \"sha256-def\")\n]\n"
+	if lock.String() != expected {
+		t.Errorf("unexpected lockfile\n%v", lock)
+	}
+}
+
+func TestHashDirectory(t *testing.T) {
+	directory := t.TempDir()
+	writeFile(t, filepath.Join(directory, "a.dfl"), "a")
+	first, err := HashDirectory(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(directory, ".git", "HEAD"), "ignored")
+	if second, _ := HashDirectory(directory); second != first {
+		t.Errorf("version control metadata changed the hash")
+	}
+
+	writeFile(t, filepath.Join(directory, "a.dfl"), "b")
+	if third, _ := HashDirectory(directory); third == first {
+		t.Errorf("content change kept the hash")
+	}
+
+	if _, err := HashDirectory(filepath.Join(directory, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing directory error but found %v", err)
+	}
+}
+
+func dataOf(values ...intermediate.DataValue) container.Tree[intermediate.DataValue] {
+	if len(values) == 1 {
+		return container.NewGraphTreeCap[intermediate.DataValue](1, 1).SetValue(values[0])
+	}
+
+	list := container.NewGraphTreeCap[intermediate.DataValue](1, uint(len(values))).
+		SetValue(intermediate.DataValue{Type: intermediate.TYPEID_LIST})
+	for _, value := range values {
+		list = list.AddChild(value)
+	}
+
+	return list
+}
+
+func TestManifestBuildConfig(t *testing.T) {
+	level := intermediate.DataValue{Type: intermediate.TYPEID_INTEGER, TextValue: "0"}
+	manifest, err := ManifestOf([]intermediate.DataConfig{
+		{FirstName: "module", SecondName: "name", Values: dataOf(textData("app"))},
+		{FirstName: "module", SecondName: "roots", Values: dataOf(textData("src"), textData("lib"))},
+		{FirstName: "module", SecondName: "entry", Values: dataOf(textData("main"))},
+		{FirstName: "build", SecondName: "backends", Values: dataOf(textData("binary_x86_64_exe"), textData("ir"))},
+		{FirstName: "ir", SecondName: "output", Values: dataOf(textData("build/app.ir"))},
+		{FirstName: "ir", SecondName: "optimize", Values: dataOf(level)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(manifest.Roots, " ") != "src lib" || manifest.Entry != "main" || strings.Join(manifest.Backends, " ") != "binary_x86_64_exe ir" {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+
+	if target := manifest.Targets["ir"]; target.Output != "build/app.ir" || target.Optimize == nil || *target.Optimize != 0 {
+		t.Errorf("unexpected ir target %+v", target)
+	}
+
+	defaults, err := ManifestOf([]intermediate.DataConfig{{FirstName: "module", SecondName: "name", Values: dataOf(textData("app"))}})
+	if err != nil || len(defaults.Roots) != 1 || defaults.Roots[0] != DEFAULT_ROOT {
+		t.Errorf("expected the manifest directory as the default root, got %+v %v", defaults, err)
+	}
+
+	_, err = ManifestOf([]intermediate.DataConfig{
+		{FirstName: "module", SecondName: "name", Values: dataOf(textData("app"))},
+		{FirstName: "ir", SecondName: "format", Values: dataOf(textData("binary"))},
+	})
+	if err == nil || !strings.Contains(err.Error(), "unknown backend option format") {
+		t.Errorf("expected an unknown option error, got %v", err)
+	}
+}
+
+func TestFindManifest(t *testing.T) {
+	project := t.TempDir()
+	writeFile(t, filepath.Join(project, MANIFEST_FILE_NAME), "module.name = \"app\"\n")
+	nested := filepath.Join(project, "src", "deep")
+	os.MkdirAll(nested, 0755)
+
+	found, err := FindManifest(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found != filepath.Join(project, MANIFEST_FILE_NAME) {
+		t.Errorf("expected the manifest of the project, got %v", found)
+	}
+
+	if _, err := FindManifest(t.TempDir()); !errors.Is(err, ErrNoManifest) {
+		t.Errorf("expected no manifest to be found, got %v", err)
+	}
+}
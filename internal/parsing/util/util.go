@@ -0,0 +1,58 @@
+// Package util holds the literal tokens and grammars shared by the parsers
+// of function and data files
+package util
+
+import "github.com/alecthomas/participle/v2/lexer"
+
+// Token patterns of literals, shared by the lexers of both file types
+const (
+	BooleanRegex   = `(?:true|false)\b`
+	DecimalRegex   = `\d+\.\d*`
+	IntRegex       = `\d+`
+	QuotedValRegex = `"(?:\\.|[^"\\])*"`
+
+	BooleanTagName   = "BOOLEAN"
+	DecimalTagName   = "DECIMAL"
+	IntTagName       = "INTEGER"
+	QuotedValTagName = "QUOTED_VAL"
+)
+
+type Value interface {
+	Position() lexer.Position
+}
+
+type BoolGrammar struct {
+	Pos lexer.Position
+	Val string `parser:"@BOOLEAN"`
+}
+
+func (b BoolGrammar) Position() lexer.Position {
+	return b.Pos
+}
+
+type FloatGrammar struct {
+	Pos lexer.Position
+	Val string `parser:"@DECIMAL"`
+}
+
+func (f FloatGrammar) Position() lexer.Position {
+	return f.Pos
+}
+
+type IntGrammar struct {
+	Pos lexer.Position
+	Val string `parser:"@INTEGER"`
+}
+
+func (i IntGrammar) Position() lexer.Position {
+	return i.Pos
+}
+
+type StringGrammar struct {
+	Pos lexer.Position
+	Val string `parser:"@QUOTED_VAL"`
+}
+
+func (s StringGrammar) Position() lexer.Position {
+	return s.Pos
+}
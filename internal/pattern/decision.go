@@ -0,0 +1,226 @@
+package pattern
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type DecisionKind int
+
+const (
+	DECISION_FAIL DecisionKind = iota
+	DECISION_LEAF
+	DECISION_SWITCH
+)
+
+// Path locates a scrutinee: the first index selects the function input and
+// every following index selects a constructor argument of the previous value.
+type Path []int
+
+type Case struct {
+	Constructor Constructor
+	Decision    *Decision
+}
+
+type Decision struct {
+	Kind     DecisionKind
+	Clause   int
+	Bindings map[string]Path
+	Path     Path
+	Cases    []Case
+	Default  *Decision
+}
+
+type row struct {
+	patterns []Pattern
+	bindings map[string]Path
+	clause   int
+}
+
+func (r row) bind(pattern Pattern, path Path) row {
+	if pattern.Kind != KIND_BINDING {
+		return r
+	}
+
+	bindings := make(map[string]Path, len(r.bindings)+1)
+	for name, boundPath := range r.bindings {
+		bindings[name] = boundPath
+	}
+
+	bindings[pattern.Name] = path
+	return row{patterns: r.patterns, bindings: bindings, clause: r.clause}
+}
+
+func childPath(path Path, index int) Path {
+	result := make(Path, len(path), len(path)+1)
+	copy(result, path)
+	return append(result, index)
+}
+
+func (signatures Signatures) Compile(clauses []Clause) *Decision {
+	if len(clauses) == 0 {
+		return &Decision{Kind: DECISION_FAIL}
+	}
+
+	rows := make([]row, 0, len(clauses))
+	for index, clause := range clauses {
+		rows = append(rows, row{
+			patterns: clause.Params,
+			bindings: map[string]Path{},
+			clause:   index,
+		})
+	}
+
+	paths := make([]Path, len(clauses[0].Params))
+	for index := range paths {
+		paths[index] = Path{index}
+	}
+
+	return signatures.compile(rows, paths)
+}
+
+func (signatures Signatures) compile(rows []row, paths []Path) *Decision {
+	if len(rows) == 0 {
+		return &Decision{Kind: DECISION_FAIL}
+	}
+
+	first := rows[0]
+	column := -1
+	for index, pattern := range first.patterns {
+		if !pattern.IsWildcard() {
+			column = index
+			break
+		}
+	}
+
+	if column == -1 {
+		for index, pattern := range first.patterns {
+			first = first.bind(pattern, paths[index])
+		}
+
+		return &Decision{
+			Kind:     DECISION_LEAF,
+			Clause:   first.clause,
+			Bindings: first.bindings,
+		}
+	}
+
+	matrix := make([][]Pattern, 0, len(rows))
+	for _, r := range rows {
+		matrix = append(matrix, r.patterns)
+	}
+
+	used := headConstructors(matrix, column)
+	decision := &Decision{
+		Kind: DECISION_SWITCH,
+		Path: paths[column],
+	}
+
+	for _, constructor := range used {
+		argPaths := make([]Path, constructor.Arity)
+		for index := range argPaths {
+			argPaths[index] = childPath(paths[column], index)
+		}
+
+		decision.Cases = append(decision.Cases, Case{
+			Constructor: constructor,
+			Decision: signatures.compile(
+				specializeColumn(rows, column, constructor, paths[column]),
+				splicePaths(paths, column, argPaths),
+			),
+		})
+	}
+
+	if _, isComplete := signatures.siblings(used); !isComplete {
+		decision.Default = signatures.compile(
+			defaultColumn(rows, column, paths[column]),
+			splicePaths(paths, column, nil),
+		)
+	}
+
+	return decision
+}
+
+func splicePaths(paths []Path, column int, replacement []Path) []Path {
+	result := make([]Path, 0, len(paths)+len(replacement)-1)
+	result = append(result, paths[:column]...)
+	result = append(result, replacement...)
+	return append(result, paths[column+1:]...)
+}
+
+func specializeColumn(rows []row, column int, constructor Constructor, path Path) []row {
+	result := make([]row, 0, len(rows))
+	for _, r := range rows {
+		head := r.patterns[column]
+		if head.IsWildcard() {
+			bound := r.bind(head, path)
+			bound.patterns = splice(r.patterns, column, wildcards(constructor.Arity))
+			result = append(result, bound)
+		} else if head.Name == constructor.Name {
+			result = append(result, row{
+				patterns: splice(r.patterns, column, head.Args),
+				bindings: r.bindings,
+				clause:   r.clause,
+			})
+		}
+	}
+
+	return result
+}
+
+func defaultColumn(rows []row, column int, path Path) []row {
+	result := make([]row, 0, len(rows))
+	for _, r := range rows {
+		head := r.patterns[column]
+		if head.IsWildcard() {
+			bound := r.bind(head, path)
+			bound.patterns = splice(r.patterns, column, nil)
+			result = append(result, bound)
+		}
+	}
+
+	return result
+}
+
+func (path Path) String() string {
+	parts := make([]string, 0, len(path))
+	for _, index := range path {
+		parts = append(parts, fmt.Sprint(index))
+	}
+
+	return "$" + strings.Join(parts, ".")
+}
+
+func (decision *Decision) String() string {
+	builder := strings.Builder{}
+	decision.write(&builder, 0)
+	return builder.String()
+}
+
+func (decision *Decision) write(builder *strings.Builder, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch decision.Kind {
+	case DECISION_FAIL:
+		builder.WriteString(indent + "fail\n")
+	case DECISION_LEAF:
+		names := make([]string, 0, len(decision.Bindings))
+		for name, path := range decision.Bindings {
+			names = append(names, fmt.Sprintf("%s=%v", name, path))
+		}
+
+		sort.Strings(names)
+		builder.WriteString(fmt.Sprintf("%sclause %d {%s}\n", indent, decision.Clause, strings.Join(names, ", ")))
+	case DECISION_SWITCH:
+		builder.WriteString(fmt.Sprintf("%sswitch %v\n", indent, decision.Path))
+		for _, c := range decision.Cases {
+			builder.WriteString(fmt.Sprintf("%s  case %s/%d\n", indent, c.Constructor.Name, c.Constructor.Arity))
+			c.Decision.write(builder, depth+2)
+		}
+
+		if decision.Default != nil {
+			builder.WriteString(indent + "  default\n")
+			decision.Default.write(builder, depth+2)
+		}
+	}
+}
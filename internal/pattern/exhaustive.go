@@ -0,0 +1,124 @@
+package pattern
+
+type Report struct {
+	Unreachable []int
+	Exhaustive  bool
+	Missing     []Pattern
+}
+
+// Check runs the usefulness algorithm over the clause matrix. A clause that
+// is not useful with respect to the clauses above it can never be reached, and
+// the clauses are exhaustive when a row of wildcards is no longer useful.
+func (signatures Signatures) Check(clauses []Clause) Report {
+	report := Report{}
+	rows := make([][]Pattern, 0, len(clauses))
+	arity := 0
+
+	for index, clause := range clauses {
+		if _, isUseful := signatures.useful(rows, clause.Params); !isUseful {
+			report.Unreachable = append(report.Unreachable, index)
+		}
+
+		rows = append(rows, clause.Params)
+		arity = len(clause.Params)
+	}
+
+	missing, isUseful := signatures.useful(rows, wildcards(arity))
+	report.Exhaustive = !isUseful
+	if isUseful {
+		report.Missing = missing
+	}
+
+	return report
+}
+
+func specializeRows(rows [][]Pattern, constructor Constructor) [][]Pattern {
+	result := make([][]Pattern, 0, len(rows))
+	for _, row := range rows {
+		head := row[0]
+		if head.IsWildcard() {
+			result = append(result, splice(row, 0, wildcards(constructor.Arity)))
+		} else if head.Name == constructor.Name {
+			result = append(result, splice(row, 0, head.Args))
+		}
+	}
+
+	return result
+}
+
+func defaultRows(rows [][]Pattern) [][]Pattern {
+	result := make([][]Pattern, 0, len(rows))
+	for _, row := range rows {
+		if row[0].IsWildcard() {
+			result = append(result, row[1:])
+		}
+	}
+
+	return result
+}
+
+func construct(constructor Constructor, witness []Pattern) []Pattern {
+	head := Pattern{
+		Kind:   KIND_CONSTRUCTOR,
+		Name:   constructor.Name,
+		TypeId: constructor.TypeId,
+		Args:   witness[:constructor.Arity],
+	}
+
+	if constructor.IsLiteral {
+		head.Kind = KIND_LITERAL
+	}
+
+	return append([]Pattern{head}, witness[constructor.Arity:]...)
+}
+
+// useful reports whether vector matches a value no row of the matrix matches,
+// returning such a value as a witness when it does.
+func (signatures Signatures) useful(rows [][]Pattern, vector []Pattern) ([]Pattern, bool) {
+	if len(vector) == 0 {
+		return []Pattern{}, len(rows) == 0
+	}
+
+	head := vector[0]
+	if !head.IsWildcard() {
+		constructor := head.Constructor()
+		witness, isUseful := signatures.useful(
+			specializeRows(rows, constructor),
+			splice(vector, 0, head.Args),
+		)
+		if !isUseful {
+			return nil, false
+		}
+
+		return construct(constructor, witness), true
+	}
+
+	used := headConstructors(rows, 0)
+	siblings, isComplete := signatures.siblings(used)
+	if isComplete {
+		for _, constructor := range siblings {
+			witness, isUseful := signatures.useful(
+				specializeRows(rows, constructor),
+				splice(vector, 0, wildcards(constructor.Arity)),
+			)
+			if isUseful {
+				return construct(constructor, witness), true
+			}
+		}
+
+		return nil, false
+	}
+
+	witness, isUseful := signatures.useful(defaultRows(rows), vector[1:])
+	if !isUseful {
+		return nil, false
+	}
+
+	for _, sibling := range siblings {
+		if !containsConstructor(used, sibling) {
+			return construct(sibling, append(wildcards(sibling.Arity), witness...)), true
+		}
+	}
+
+	return append([]Pattern{Wildcard()}, witness...), true
+}
@@ -0,0 +1,151 @@
+package pattern
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+type Kind int
+
+const (
+	KIND_WILDCARD Kind = iota
+	KIND_BINDING
+	KIND_LITERAL
+	KIND_CONSTRUCTOR
+)
+
+type Pattern struct {
+	Position lexer.Position
+	Kind     Kind
+	Name     string
+	TypeId   intermediate.TypeId
+	Args     []Pattern
+}
+
+type Clause struct {
+	Position lexer.Position
+	Params   []Pattern
+}
+
+func Wildcard() Pattern {
+	return Pattern{Kind: KIND_WILDCARD, Name: "_"}
+}
+
+func (p Pattern) IsWildcard() bool {
+	return p.Kind == KIND_WILDCARD || p.Kind == KIND_BINDING
+}
+
+func (p Pattern) Constructor() Constructor {
+	return Constructor{
+		Name:      p.Name,
+		Arity:     len(p.Args),
+		TypeId:    p.TypeId,
+		IsLiteral: p.Kind == KIND_LITERAL,
+	}
+}
+
+func (p Pattern) String() string {
+	switch p.Kind {
+	case KIND_WILDCARD:
+		return "_"
+	case KIND_BINDING, KIND_LITERAL:
+		return p.Name
+	}
+
+	switch p.Name {
+	case intermediate.LIST_EMPTY_CONSTRUCTOR:
+		return "[]"
+	case intermediate.LIST_CONS_CONSTRUCTOR:
+		elements := make([]string, 0, 2)
+		tail := p
+		for tail.Kind == KIND_CONSTRUCTOR && tail.Name == intermediate.LIST_CONS_CONSTRUCTOR {
+			elements = append(elements, tail.Args[0].String())
+			tail = tail.Args[1]
+		}
+
+		if tail.Kind == KIND_CONSTRUCTOR && tail.Name == intermediate.LIST_EMPTY_CONSTRUCTOR {
+			return "[" + strings.Join(elements, ", ") + "]"
+		}
+
+		return fmt.Sprintf("[%s : %v]", strings.Join(elements, ", "), tail)
+	}
+
+	if len(p.Args) == 0 {
+		return p.Name
+	}
+
+	args := make([]string, 0, len(p.Args))
+	for _, arg := range p.Args {
+		args = append(args, arg.String())
+	}
+
+	return fmt.Sprintf("(%s %s)", p.Name, strings.Join(args, " "))
+}
+
+func FormatParams(params []Pattern) string {
+	formatted := make([]string, 0, len(params))
+	for _, param := range params {
+		formatted = append(formatted, param.String())
+	}
+
+	return strings.Join(formatted, " ")
+}
+
+func FromExpression(tree container.Tree[intermediate.SenimentExpression]) Pattern {
+	expression := tree.GetValue()
+	result := Pattern{
+		Position: expression.Position,
+		TypeId:   expression.TypeId,
+	}
+
+	switch expression.Op {
+	case intermediate.OPCODE_PATTERN_BIND:
+		result.Kind = KIND_BINDING
+		result.Name = expression.Value[0]
+	case intermediate.OPCODE_PATTERN_LITERAL:
+		result.Kind = KIND_LITERAL
+		result.Name = expression.Value[0]
+		if expression.TypeId == intermediate.TYPEID_BOOLEAN {
+			result.Kind = KIND_CONSTRUCTOR
+		}
+	case intermediate.OPCODE_PATTERN_CONSTRUCTOR:
+		result.Kind = KIND_CONSTRUCTOR
+		result.Name = expression.Value[0]
+		for _, child := range tree.GetChildren() {
+			result.Args = append(result.Args, FromExpression(child))
+		}
+	default:
+		result.Kind = KIND_WILDCARD
+		result.Name = "_"
+	}
+
+	return result
+}
+
+func ClausesOf(evals container.Tree[intermediate.SenimentExpression]) []Clause {
+	clauses := make([]Clause, 0, len(evals.GetChildren()))
+	for _, clauseTree := range evals.GetChildren() {
+		children := clauseTree.GetChildren()
+		if len(children) == 0 {
+			continue
+		}
+
+		clause := Clause{
+			Position: clauseTree.GetValue().Position,
+			Params:   make([]Pattern, 0, len(children)),
+		}
+
+		// The last child of a clause is its definition
+		for _, child := range children[:len(children)-1] {
+			clause.Params = append(clause.Params, FromExpression(child))
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	return clauses
+}
@@ -0,0 +1,140 @@
+package pattern
+
+import (
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func bind(name string) Pattern {
+	return Pattern{Kind: KIND_BINDING, Name: name}
+}
+
+func literal(value string) Pattern {
+	return Pattern{Kind: KIND_LITERAL, Name: value, TypeId: intermediate.TYPEID_INTEGER}
+}
+
+func constructor(name string, args ...Pattern) Pattern {
+	return Pattern{Kind: KIND_CONSTRUCTOR, Name: name, Args: args}
+}
+
+func empty() Pattern {
+	return constructor(intermediate.LIST_EMPTY_CONSTRUCTOR)
+}
+
+func cons(head Pattern, tail Pattern) Pattern {
+	return constructor(intermediate.LIST_CONS_CONSTRUCTOR, head, tail)
+}
+
+func clauses(rows ...[]Pattern) []Clause {
+	result := make([]Clause, 0, len(rows))
+	for _, params := range rows {
+		result = append(result, Clause{Params: params})
+	}
+
+	return result
+}
+
+func TestListExhaustive(t *testing.T) {
+	report := DefaultSignatures().Check(clauses(
+		[]Pattern{empty()},
+		[]Pattern{cons(Wildcard(), bind("tail"))},
+	))
+
+	if !report.Exhaustive || len(report.Unreachable) != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestListMissingEmpty(t *testing.T) {
+	report := DefaultSignatures().Check(clauses(
+		[]Pattern{cons(bind("head"), bind("tail"))},
+	))
+
+	if report.Exhaustive {
+		t.Fatalf("expected non exhaustive report")
+	}
+
+	if FormatParams(report.Missing) != "[]" {
+		t.Errorf("unexpected missing pattern: %v", FormatParams(report.Missing))
+	}
+}
+
+func TestLiteralNeedsWildcard(t *testing.T) {
+	report := DefaultSignatures().Check(clauses(
+		[]Pattern{literal("0")},
+		[]Pattern{literal("1")},
+	))
+
+	if report.Exhaustive || FormatParams(report.Missing) != "_" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestUnreachableClause(t *testing.T) {
+	report := DefaultSignatures().Check(clauses(
+		[]Pattern{bind("n"), Wildcard()},
+		[]Pattern{literal("0"), literal("1")},
+	))
+
+	if !report.Exhaustive {
+		t.Errorf("expected exhaustive report")
+	}
+
+	if len(report.Unreachable) != 1 || report.Unreachable[0] != 1 {
+		t.Errorf("unexpected unreachable clauses: %v", report.Unreachable)
+	}
+}
+
+func TestBooleanAndStructMissing(t *testing.T) {
+	truePattern := Pattern{Kind: KIND_CONSTRUCTOR, Name: "true"}
+	report := DefaultSignatures().Check(clauses(
+		[]Pattern{constructor("Pair", truePattern, Wildcard())},
+	))
+
+	if report.Exhaustive || FormatParams(report.Missing) != "(Pair false _)" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestCompileDecisionTree(t *testing.T) {
+	decision := DefaultSignatures().Compile(clauses(
+		[]Pattern{empty(), bind("other")},
+		[]Pattern{cons(bind("head"), Wildcard()), literal("0")},
+		[]Pattern{Wildcard(), Wildcard()},
+	))
+
+	expected := `switch $0
+  case []/0
+    clause 0 {other=$1}
+  case ::/2
+    switch $1
+      case 0/0
+        clause 1 {head=$0.0}
+      default
+        clause 2 {}
+`
+	if decision.String() != expected {
+		t.Errorf("unexpected decision tree:\n%v", decision)
+	}
+}
+
+func TestMismatchedArity(t *testing.T) {
+	mismatches := DefaultSignatures().Mismatches(clauses(
+		[]Pattern{constructor("P", bind("a"))},
+		[]Pattern{constructor("P", bind("a"), bind("b"))},
+		[]Pattern{constructor(intermediate.LIST_CONS_CONSTRUCTOR, bind("head"))},
+	))
+
+	if len(mismatches) != 2 {
+		t.Fatalf("expected 2 mismatches, got %+v", mismatches)
+	}
+
+	if first := mismatches[0]; first.Pattern.String() != "(P a b)" || first.Arity != 1 || first.First == nil || first.First.String() != "(P a)" {
+		t.Errorf("expected (P a b) to mismatch its first use, got %+v", first)
+	}
+
+	if cons := mismatches[1]; cons.Arity != 2 || cons.First != nil {
+		t.Errorf("expected a cons of one argument to mismatch its signature, got %+v", cons)
+	}
+}
@@ -0,0 +1,151 @@
+package pattern
+
+import "github.com/tflexsoom/duffle/internal/intermediate"
+
+type Constructor struct {
+	Name      string
+	Arity     int
+	TypeId    intermediate.TypeId
+	IsLiteral bool
+}
+
+// Signatures maps a constructor name to every constructor of its type.
+// Constructors missing from the map are treated as the only constructor of
+// their type, which is the case for every struct.
+type Signatures map[string][]Constructor
+
+func DefaultSignatures() Signatures {
+	list := []Constructor{
+		{Name: intermediate.LIST_EMPTY_CONSTRUCTOR, Arity: 0, TypeId: intermediate.TYPEID_LIST},
+		{Name: intermediate.LIST_CONS_CONSTRUCTOR, Arity: 2, TypeId: intermediate.TYPEID_LIST},
+	}
+
+	boolean := []Constructor{
+		{Name: "true", Arity: 0, TypeId: intermediate.TYPEID_BOOLEAN},
+		{Name: "false", Arity: 0, TypeId: intermediate.TYPEID_BOOLEAN},
+	}
+
+	return Signatures{
+		intermediate.LIST_EMPTY_CONSTRUCTOR: list,
+		intermediate.LIST_CONS_CONSTRUCTOR:  list,
+		"true":                              boolean,
+		"false":                             boolean,
+	}
+}
+
+func (signatures Signatures) siblings(used []Constructor) ([]Constructor, bool) {
+	if len(used) == 0 || used[0].IsLiteral {
+		return nil, false
+	}
+
+	siblings, isOk := signatures[used[0].Name]
+	if !isOk {
+		return []Constructor{used[0]}, true
+	}
+
+	for _, sibling := range siblings {
+		if !containsConstructor(used, sibling) {
+			return siblings, false
+		}
+	}
+
+	return siblings, true
+}
+
+func containsConstructor(constructors []Constructor, constructor Constructor) bool {
+	for _, other := range constructors {
+		if other.Name == constructor.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mismatch is a constructor pattern given a different number of arguments
+// than its constructor takes
+type Mismatch struct {
+	Pattern Pattern
+	Arity   int
+	// First is where the constructor was first used, and nil when its arity
+	// comes from the signatures
+	First *Pattern
+}
+
+// Mismatches finds the constructor patterns of the clauses whose arity
+// differs from their signature or, for constructors without one, from their
+// first use. Check and Compile assume there are none.
+func (signatures Signatures) Mismatches(clauses []Clause) []Mismatch {
+	mismatches := []Mismatch{}
+	firsts := make(map[string]Pattern)
+	var walk func(pattern Pattern)
+	walk = func(pattern Pattern) {
+		if pattern.Kind == KIND_CONSTRUCTOR {
+			if mismatch, isMismatch := signatures.mismatchOf(pattern, firsts); isMismatch {
+				mismatches = append(mismatches, mismatch)
+			}
+		}
+
+		for _, arg := range pattern.Args {
+			walk(arg)
+		}
+	}
+
+	for _, clause := range clauses {
+		for _, param := range clause.Params {
+			walk(param)
+		}
+	}
+
+	return mismatches
+}
+
+func (signatures Signatures) mismatchOf(pattern Pattern, firsts map[string]Pattern) (Mismatch, bool) {
+	if siblings, isOk := signatures[pattern.Name]; isOk {
+		for _, sibling := range siblings {
+			if sibling.Name == pattern.Name && sibling.Arity != len(pattern.Args) {
+				return Mismatch{Pattern: pattern, Arity: sibling.Arity}, true
+			}
+		}
+
+		return Mismatch{}, false
+	}
+
+	first, isOk := firsts[pattern.Name]
+	if !isOk {
+		firsts[pattern.Name] = pattern
+		return Mismatch{}, false
+	}
+
+	return Mismatch{Pattern: pattern, Arity: len(first.Args), First: &first}, len(first.Args) != len(pattern.Args)
+}
+
+func headConstructors(rows [][]Pattern, column int) []Constructor {
+	result := make([]Constructor, 0, len(rows))
+	for _, row := range rows {
+		head := row[column]
+		if head.IsWildcard() || containsConstructor(result, head.Constructor()) {
+			continue
+		}
+
+		result = append(result, head.Constructor())
+	}
+
+	return result
+}
+
+func wildcards(count int) []Pattern {
+	result := make([]Pattern, count)
+	for i := range result {
+		result[i] = Wildcard()
+	}
+
+	return result
+}
+
+func splice(row []Pattern, column int, replacement []Pattern) []Pattern {
+	result := make([]Pattern, 0, len(row)+len(replacement)-1)
+	result = append(result, row[:column]...)
+	result = append(result, replacement...)
+	return append(result, row[column+1:]...)
+}
@@ -0,0 +1,423 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/cache"
+	"github.com/tflexsoom/duffle/internal/emit"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/interpreter"
+	"github.com/tflexsoom/duffle/internal/types"
+	"github.com/tflexsoom/duffle/internal/typing"
+	"github.com/tflexsoom/duffle/internal/verify"
+)
+
+const (
+	PROMPT              = "duffle> "
+	CONTINUATION_PROMPT = "   ...> "
+	// SOURCE_NAME is the file the definitions of a session appear to be in
+	SOURCE_NAME = "<repl>"
+	// ENTRY is the entry point an expression is evaluated in
+	ENTRY = "__repl__"
+)
+
+var errQuit = errors.New("quit")
+
+// Lowerer parses and lowers the source of a function file
+type Lowerer func(file string, source string) (intermediate.Goal, error)
+
+// Loader lowers the function files of a project along with the data
+// configs next to them
+type Loader func(location string) ([]intermediate.Goal, [][]intermediate.DataConfig, error)
+
+type Options struct {
+	Lower  Lowerer
+	Load   Loader
+	Input  io.Reader
+	Output io.Writer
+	// HistoryFile keeps every entry of every session when set
+	HistoryFile string
+}
+
+type definition struct {
+	names  []string
+	source string
+}
+
+// Session holds what was defined and loaded so far. Every entry checks the
+// whole session again, so a definition may use anything defined before it.
+type Session struct {
+	options     Options
+	definitions []definition
+	// project holds loaded goals encoded, since checking a program changes
+	// its goals and every entry needs them as they were lowered
+	project [][]byte
+	configs [][]intermediate.DataConfig
+	history []string
+}
+
+func New(options Options) *Session {
+	if options.Output == nil {
+		options.Output = os.Stdout
+	}
+
+	return &Session{options: options}
+}
+
+// Run reads entries until the input ends or :quit. Definitions and
+// expressions may span lines until their begin and end are balanced.
+func (session *Session) Run() error {
+	scanner := bufio.NewScanner(session.options.Input)
+	output := session.options.Output
+	lines := []string{}
+	fmt.Fprint(output, PROMPT)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		input := strings.Join(lines, "\n")
+		if !isComplete(input) {
+			fmt.Fprint(output, CONTINUATION_PROMPT)
+			continue
+		}
+
+		lines = lines[:0]
+		if strings.TrimSpace(input) != "" {
+			err := session.Eval(input)
+			if errors.Is(err, errQuit) {
+				return nil
+			} else if err != nil {
+				fmt.Fprintln(output, err)
+			}
+		}
+
+		fmt.Fprint(output, PROMPT)
+	}
+
+	fmt.Fprintln(output)
+	return scanner.Err()
+}
+
+// isComplete tells whether every begin of an entry has its end. Pattern
+// definitions end with an empty line instead.
+func isComplete(input string) bool {
+	depth := 0
+	isPattern := false
+	for _, word := range strings.Fields(input) {
+		switch word {
+		case "begin":
+			depth++
+		case "end":
+			depth--
+		case "evals":
+			isPattern = true
+		}
+	}
+
+	if isPattern {
+		return strings.HasSuffix(input, "\n")
+	}
+
+	return depth <= 0
+}
+
+// Eval runs a single entry: a meta-command, a definition or an expression
+func (session *Session) Eval(input string) error {
+	session.remember(input)
+	trimmed := strings.TrimSpace(input)
+	switch {
+	case strings.HasPrefix(trimmed, ":"):
+		return session.command(trimmed)
+	case strings.HasPrefix(trimmed, "@"):
+		return session.define(input)
+	}
+
+	value, t, err := session.evaluate(trimmed)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(session.options.Output, "%v : %v\n", value, t)
+	return nil
+}
+
+func (session *Session) remember(input string) {
+	session.history = append(session.history, input)
+	if session.options.HistoryFile == "" {
+		return
+	}
+
+	historyFile, err := os.OpenFile(session.options.HistoryFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer historyFile.Close()
+
+	fmt.Fprintln(historyFile, input)
+}
+
+func (session *Session) command(input string) error {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+	output := session.options.Output
+	if arg == "" && (name == ":type" || name == ":ir" || name == ":load") {
+		return fmt.Errorf("%v needs an argument, see :help", name)
+	}
+
+	switch name {
+	case ":quit", ":q":
+		return errQuit
+	case ":help":
+		fmt.Fprint(output, HELP)
+	case ":reset":
+		session.definitions = nil
+		session.project = nil
+		session.configs = nil
+		fmt.Fprintln(output, "session reset")
+	case ":history":
+		for index, entry := range session.history[:len(session.history)-1] {
+			fmt.Fprintf(output, "%4d  %v\n", index+1, entry)
+		}
+	case ":type":
+		goals, err := session.check(arg)
+		if err != nil {
+			return err
+		}
+
+		t, err := entryType(goals[len(goals)-1])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(output, "%v : %v\n", arg, t)
+	case ":ir":
+		module, _, err := session.build("0")
+		if err != nil {
+			return err
+		}
+
+		for id, function := range module.Functions {
+			if function.Name == arg {
+				fmt.Fprint(output, module.FormatFunction(intermediate.FunctionId(id)))
+				return nil
+			}
+		}
+
+		return fmt.Errorf("no function %v was emitted", arg)
+	case ":load":
+		return session.Load(arg)
+	default:
+		return fmt.Errorf("unknown command %v, see :help", name)
+	}
+
+	return nil
+}
+
+const HELP = `enter @ definitions, @@ functions or expressions to evaluate
+  :type <expression>  show the type of an expression
+  :ir <function>      show the emitted instructions of a function
+  :load <project>     bring the functions and data of a project into scope
+  :history            list the entries of this session
+  :reset              forget every definition and loaded project
+  :quit               leave the repl
+`
+
+// Load brings a project into scope. Its entry points are left out, since
+// the repl has its own.
+func (session *Session) Load(location string) error {
+	if session.options.Load == nil {
+		return errors.New("loading projects is not supported")
+	}
+
+	goals, configs, err := session.options.Load(location)
+	if err != nil {
+		return err
+	}
+
+	for index, goal := range goals {
+		for name, sentiment := range goal.Sentments {
+			if annotation.HasAnnotation(sentiment, annotation.EXEC_ANNOTATION) {
+				delete(goal.Sentments, name)
+			}
+		}
+
+		data, err := cache.EncodeGoal(goal)
+		if err != nil {
+			return err
+		}
+
+		session.project = append(session.project, data)
+		if index < len(configs) {
+			session.configs = append(session.configs, configs[index])
+		} else {
+			session.configs = append(session.configs, nil)
+		}
+	}
+
+	fmt.Fprintf(session.options.Output, "loaded %d files of %v\n", len(goals), location)
+	return nil
+}
+
+// define checks a definition against the session before keeping it. A
+// definition replaces the earlier ones of the same names.
+func (session *Session) define(source string) error {
+	goal, err := session.options.Lower(SOURCE_NAME, source)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(goal.Sentments)+len(goal.Imports))
+	for name := range goal.Sentments {
+		names = append(names, name)
+	}
+
+	for _, imported := range goal.Imports {
+		names = append(names, imported.Alias)
+	}
+
+	if len(names) == 0 {
+		return errors.New("nothing was defined")
+	}
+
+	sort.Strings(names)
+
+	previous := session.definitions
+	session.definitions = append(withoutNames(previous, names), definition{names: names, source: source})
+	if _, err := session.check(""); err != nil {
+		session.definitions = previous
+		return err
+	}
+
+	fmt.Fprintf(session.options.Output, "defined %v\n", strings.Join(names, ", "))
+	return nil
+}
+
+func withoutNames(definitions []definition, names []string) []definition {
+	kept := make([]definition, 0, len(definitions))
+	for _, current := range definitions {
+		isReplaced := false
+		for _, name := range current.names {
+			for _, replaced := range names {
+				isReplaced = isReplaced || name == replaced
+			}
+		}
+
+		if !isReplaced {
+			kept = append(kept, current)
+		}
+	}
+
+	return kept
+}
+
+// program lowers the session, with an entry point returning the expression
+// when one is given. The goal of the session comes last.
+func (session *Session) program(expression string) ([]intermediate.Goal, [][]intermediate.DataConfig, error) {
+	builder := strings.Builder{}
+	for _, current := range session.definitions {
+		builder.WriteString(current.source)
+		builder.WriteString("\n")
+	}
+
+	if expression != "" {
+		builder.WriteString(fmt.Sprintf("@exec %v begin\n  return (%v)\nend\n", ENTRY, expression))
+	}
+
+	goals := make([]intermediate.Goal, 0, len(session.project)+1)
+	for _, data := range session.project {
+		goal, err := cache.DecodeGoal(data)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		goals = append(goals, goal)
+	}
+
+	goal, err := session.options.Lower(SOURCE_NAME, builder.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configs := append(append(make([][]intermediate.DataConfig, 0, len(goals)+1), session.configs...), nil)
+	return append(goals, goal), configs, nil
+}
+
+func (session *Session) check(expression string) ([]intermediate.Goal, error) {
+	goals, configs, err := session.program(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := typing.CheckProgram(goals, configs, "")
+	if diagnostics.HasErrors() {
+		return nil, diagnostics
+	}
+
+	return goals, nil
+}
+
+// build emits the session, giving its own goal along with the module
+func (session *Session) build(expression string) (intermediate.Module, intermediate.Goal, error) {
+	goals, err := session.check(expression)
+	if err != nil {
+		return intermediate.Module{}, intermediate.Goal{}, err
+	}
+
+	module, diagnostics := emit.Emit(goals, ENTRY)
+	if diagnostics.HasErrors() {
+		return intermediate.Module{}, intermediate.Goal{}, diagnostics
+	}
+
+	if err := verify.Module(module); err != nil {
+		return intermediate.Module{}, intermediate.Goal{}, fmt.Errorf("emitted module is invalid:\n%w", err)
+	}
+
+	return module, goals[len(goals)-1], nil
+}
+
+func (session *Session) evaluate(expression string) (string, types.Type, error) {
+	module, goal, err := session.build(expression)
+	if err != nil {
+		return "", types.Type{}, err
+	}
+
+	t, err := entryType(goal)
+	if err != nil {
+		return "", types.Type{}, err
+	}
+
+	program, err := interpreter.New(module, session.options.Output)
+	if err != nil {
+		return "", types.Type{}, err
+	}
+
+	value, err := program.Run(nil)
+	if err != nil {
+		return "", types.Type{}, err
+	}
+
+	return value.Format(), t, nil
+}
+
+// entryType gives the type inference found for the expression of the
+// entry point, the result of its function type
+func entryType(goal intermediate.Goal) (types.Type, error) {
+	sentiment, isOk := goal.Sentments[ENTRY]
+	if !isOk || sentiment.Definition == nil {
+		return types.Type{}, errors.New("the expression was not lowered to an entry point")
+	}
+
+	t, err := types.Parse(goal.Types[sentiment.Definition.GetValue().TypeId])
+	if err != nil {
+		return types.Type{}, err
+	} else if !t.IsFunction() {
+		return types.Type{}, fmt.Errorf("the entry point has type %v instead of a function type", t)
+	}
+
+	return t.Result(), nil
+}
@@ -0,0 +1,224 @@
+package repl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+type node = container.Tree[intermediate.SenimentExpression]
+
+func leaf(op intermediate.OpCode, typeId intermediate.TypeId, line int, value ...string) node {
+	return intermediate.NewExpressionTree(intermediate.SenimentExpression{
+		Position: lexer.Position{Filename: SOURCE_NAME, Line: line},
+		Op:       op,
+		TypeId:   typeId,
+		Value:    value,
+	})
+}
+
+func parent(op intermediate.OpCode, line int, children ...node) node {
+	tree := leaf(op, intermediate.TYPEID_NO_TYPE, line)
+	for _, child := range children {
+		container.AddChildren(tree, child)
+	}
+
+	return tree
+}
+
+// atomOf lowers a word of the scripted language: integers, quoted text,
+// operators and references
+func atomOf(word string, line int) node {
+	switch {
+	case strings.HasPrefix(word, `"`):
+		return leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_TEXT, line, word)
+	case strings.Trim(word, "0123456789") == "":
+		return leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_INTEGER, line, word)
+	case strings.Trim(word, "+-*/") == "":
+		return leaf(intermediate.OPCODE_OPERATOR, intermediate.TYPEID_NO_TYPE, line, word)
+	}
+
+	return leaf(intermediate.OPCODE_REF, intermediate.TYPEID_NO_TYPE, line, word)
+}
+
+func expressionOf(text string, line int) node {
+	words := strings.Fields(text)
+	if len(words) == 1 {
+		return atomOf(words[0], line)
+	}
+
+	chain := parent(intermediate.OPCODE_CHAIN, line)
+	for _, word := range words {
+		container.AddChildren(chain, atomOf(word, line))
+	}
+
+	return chain
+}
+
+// scriptedLower stands in for the parser with a tiny part of the language:
+// facts of a single expression and the entry points the session writes
+func scriptedLower(file string, source string) (intermediate.Goal, error) {
+	goal := intermediate.NewGoal()
+	lines := strings.Split(source, "\n")
+	for index := 0; index < len(lines); index++ {
+		line := strings.TrimSpace(lines[index])
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "@fact "):
+			name, expression, isOk := strings.Cut(strings.TrimPrefix(line, "@fact "), " := ")
+			if !isOk {
+				return goal, fmt.Errorf("%v:%d: expected :=", file, index+1)
+			}
+
+			goal.Sentments[name] = intermediate.Sentiment{
+				Annotations: []string{annotation.FACT_ANNOTATION},
+				Name:        name,
+				Definition:  parent(intermediate.OPCODE_CONSTEXPR, index+1, expressionOf(expression, index+1)),
+			}
+		case strings.HasPrefix(line, "@exec ") && strings.HasSuffix(line, " begin") && index+2 < len(lines):
+			name := strings.Fields(line)[1]
+			expression := strings.TrimSpace(lines[index+1])
+			expression = strings.TrimSuffix(strings.TrimPrefix(expression, "return ("), ")")
+			goal.Sentments[name] = intermediate.Sentiment{
+				Annotations: []string{annotation.EXEC_ANNOTATION},
+				Name:        name,
+				Definition: parent(intermediate.OPCODE_BLOCK, index+1,
+					parent(intermediate.OPCODE_RETURN, index+2, expressionOf(expression, index+2)),
+				),
+			}
+			index += 2
+		default:
+			return goal, fmt.Errorf("%v:%d: unexpected %q", file, index+1, line)
+		}
+	}
+
+	return goal, nil
+}
+
+func runScript(t *testing.T, options Options, script string) string {
+	t.Helper()
+	output := bytes.Buffer{}
+	options.Lower = scriptedLower
+	options.Input = strings.NewReader(script)
+	options.Output = &output
+	if err := New(options).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	return output.String()
+}
+
+func expectLines(t *testing.T, output string, expected ...string) {
+	t.Helper()
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("expected %q in output:\n%v", line, output)
+		}
+	}
+}
+
+func TestSessionScript(t *testing.T) {
+	output := runScript(t, Options{}, strings.Join([]string{
+		"@fact ONE := 1",
+		"ONE + 2",
+		`@fact GREETING := "hello"`,
+		"GREETING",
+		":type ONE * 4",
+		":ir __repl__",
+		"@fact ONE := 5",
+		"ONE + 2",
+		":reset",
+		"ONE",
+		":quit",
+		"ONE",
+	}, "\n"))
+
+	expectLines(t, output,
+		"defined ONE",
+		"3 : integer",
+		`"hello" : text`,
+		"ONE * 4 : integer",
+		"function #0 __repl__",
+		"7 : integer",
+		"session reset",
+		"unknown name ONE",
+	)
+
+	if strings.Count(output, "defined ONE") != 2 {
+		t.Errorf("expected the redefinition to be accepted:\n%v", output)
+	}
+
+	if strings.Count(output, PROMPT) != 11 {
+		t.Errorf("expected the script to stop at :quit:\n%v", output)
+	}
+}
+
+func TestRejectedEntries(t *testing.T) {
+	output := runScript(t, Options{}, strings.Join([]string{
+		"@fact ONE := 1",
+		`@fact BAD := ONE + "a"`,
+		"BAD",
+		":frobnicate",
+		":type",
+		"ONE",
+	}, "\n"))
+
+	expectLines(t, output,
+		"unknown command :frobnicate",
+		":type needs an argument",
+		"1 : integer",
+	)
+
+	if strings.Contains(output, "defined BAD") {
+		t.Errorf("expected an ill typed definition to be rejected:\n%v", output)
+	}
+}
+
+func TestLoadProject(t *testing.T) {
+	load := func(location string) ([]intermediate.Goal, [][]intermediate.DataConfig, error) {
+		if location != "project" {
+			return nil, nil, errors.New("no such project")
+		}
+
+		goal, err := scriptedLower("project/main.dfl", "@fact LIMIT := 21\n@exec main begin\n  return (LIMIT)\nend")
+		return []intermediate.Goal{goal}, nil, err
+	}
+
+	output := runScript(t, Options{Load: load}, ":load elsewhere\n:load project\nLIMIT * 2\nLIMIT + 1\n")
+	expectLines(t, output, "no such project", "loaded 1 files of project", "42 : integer", "22 : integer")
+}
+
+func TestHistory(t *testing.T) {
+	history := t.TempDir() + "/history"
+	output := runScript(t, Options{HistoryFile: history}, "@fact ONE := 1\nONE\n:history\n")
+	expectLines(t, output, "   1  @fact ONE := 1", "   2  ONE")
+	if strings.Contains(output, ":history\n") {
+		t.Errorf("expected :history to leave itself out:\n%v", output)
+	}
+
+	if data, err := os.ReadFile(history); err != nil || string(data) != "@fact ONE := 1\nONE\n:history\n" {
+		t.Errorf("expected every entry in the history file, got %q %v", data, err)
+	}
+}
+
+func TestIsComplete(t *testing.T) {
+	for input, expected := range map[string]bool{
+		"1 + 2":                      true,
+		"@@ double <number n> begin": false,
+		"@@ double <number n> begin\n  return (n * 2)\nend": true,
+		"@@ sign evals\n  sign 0 = 0":                       false,
+		"@@ sign evals\n  sign 0 = 0\n":                     true,
+	} {
+		if isComplete(input) != expected {
+			t.Errorf("expected %q to be complete: %v", input, expected)
+		}
+	}
+}
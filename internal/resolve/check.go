@@ -0,0 +1,185 @@
+package resolve
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/files"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+// CheckBindings reports aliases that are bound more than once in a file
+func CheckBindings(imports []intermediate.Import) diagnostic.Diagnostics {
+	diagnostics := diagnostic.Diagnostics{}
+	bound := make(map[string]intermediate.Import, len(imports))
+	for _, imported := range imports {
+		previous, exists := bound[imported.Alias]
+		if !exists {
+			bound[imported.Alias] = imported
+			continue
+		}
+
+		diagnostics.Errorf(
+			imported.Position,
+			"import alias %v is rebound to %v",
+			imported.Alias,
+			strings.Join(imported.Path, "."),
+		).WithRelated(previous.Position, "%v was first bound to %v here", previous.Alias, strings.Join(previous.Path, "."))
+	}
+
+	return diagnostics
+}
+
+type edge struct {
+	to       string
+	imported intermediate.Import
+}
+
+type Graph struct {
+	edges map[string][]edge
+}
+
+func NewGraph() *Graph {
+	return &Graph{edges: make(map[string][]edge)}
+}
+
+// Resolve resolves every import of a file, recording project and vendor
+// imports as edges of the module graph. Files are keyed by their absolute
+// path, so discovered and resolved paths of the same file meet.
+func (graph *Graph) Resolve(resolver Resolver, file string, imports []intermediate.Import) diagnostic.Diagnostics {
+	diagnostics := diagnostic.Diagnostics{}
+	file = absolute(file)
+	if _, exists := graph.edges[file]; !exists {
+		graph.edges[file] = []edge{}
+	}
+
+	for _, imported := range imports {
+		module, err := resolver.Resolve(imported.Path)
+		if err != nil {
+			diagnostics.Errorf(imported.Position, "%v", err)
+			continue
+		} else if module.Origin == ORIGIN_STANDARD {
+			continue
+		}
+
+		for _, target := range sourceFilesOf(module.Location) {
+			graph.edges[file] = append(graph.edges[file], edge{to: absolute(target), imported: imported})
+		}
+	}
+
+	return diagnostics
+}
+
+// ImportedFiles lists the source files the imports of a file resolve to,
+// leaving out the standard library and imports that do not resolve
+func (resolver Resolver) ImportedFiles(imports []intermediate.Import) []string {
+	seen := make(map[string]bool, len(imports))
+	result := make([]string, 0, len(imports))
+	for _, imported := range imports {
+		module, err := resolver.Resolve(imported.Path)
+		if err != nil || module.Origin == ORIGIN_STANDARD {
+			continue
+		}
+
+		for _, target := range sourceFilesOf(module.Location) {
+			if target = absolute(target); !seen[target] {
+				seen[target] = true
+				result = append(result, target)
+			}
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+func absolute(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+
+	return filepath.Clean(path)
+}
+
+func sourceFilesOf(location string) []string {
+	entries, err := os.ReadDir(location)
+	if err != nil {
+		return []string{location}
+	}
+
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == "."+files.FUNCTION_FILE_ENDING {
+			result = append(result, filepath.Join(location, entry.Name()))
+		}
+	}
+
+	return result
+}
+
+// Cycles reports every import cycle once, at the import that closes it
+func (graph *Graph) Cycles() diagnostic.Diagnostics {
+	diagnostics := diagnostic.Diagnostics{}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	states := make(map[string]int, len(graph.edges))
+	stack := make([]edge, 0, 8)
+
+	var visit func(file string)
+	visit = func(file string) {
+		states[file] = visiting
+		for _, next := range graph.edges[file] {
+			switch states[next.to] {
+			case unvisited:
+				stack = append(stack, next)
+				visit(next.to)
+				stack = stack[:len(stack)-1]
+			case visiting:
+				graph.reportCycle(&diagnostics, append(stack, next), next.to)
+			}
+		}
+
+		states[file] = visited
+	}
+
+	names := make([]string, 0, len(graph.edges))
+	for file := range graph.edges {
+		names = append(names, file)
+	}
+
+	sort.Strings(names)
+	for _, file := range names {
+		if states[file] == unvisited {
+			visit(file)
+		}
+	}
+
+	return diagnostics
+}
+
+func (graph *Graph) reportCycle(diagnostics *diagnostic.Diagnostics, path []edge, start string) {
+	first := len(path) - 1
+	for first > 0 && path[first-1].to != start {
+		first--
+	}
+
+	cycle := path[first:]
+	startName := strings.TrimSuffix(filepath.Base(start), filepath.Ext(start))
+	names := []string{startName}
+	for _, step := range cycle {
+		names = append(names, strings.Join(step.imported.Path, "."))
+	}
+
+	closing := cycle[len(cycle)-1].imported
+	report := diagnostics.Errorf(closing.Position, "import cycle: %v", strings.Join(names, " -> "))
+	for _, step := range cycle[:len(cycle)-1] {
+		report.WithRelated(step.imported.Position, "%v is imported here", strings.Join(step.imported.Path, "."))
+	}
+}
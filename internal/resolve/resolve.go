@@ -0,0 +1,132 @@
+package resolve
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/files"
+)
+
+type Origin int
+
+const (
+	ORIGIN_STANDARD Origin = iota
+	ORIGIN_PROJECT
+	ORIGIN_VENDOR
+)
+
+const VENDOR_DIRECTORY = "vendor"
+
+type Module struct {
+	Path     string
+	Origin   Origin
+	Location string
+	Member   string
+}
+
+// StandardModules lists the built-in modules and their exported members. A
+// nil member list accepts every member.
+var StandardModules = map[string][]string{
+	"dfl": nil,
+}
+
+type Resolver struct {
+	Roots    []string
+	Vendor   []string
+	Standard map[string][]string
+}
+
+// NewResolver searches the project itself, the projects next to it and then
+// the vendored packages of the project.
+func NewResolver(projectRoot string) Resolver {
+	absolute, err := filepath.Abs(projectRoot)
+	if err != nil {
+		absolute = projectRoot
+	}
+
+	return Resolver{
+		Roots:    []string{absolute, filepath.Dir(absolute)},
+		Vendor:   []string{filepath.Join(absolute, VENDOR_DIRECTORY)},
+		Standard: StandardModules,
+	}
+}
+
+func (resolver Resolver) Resolve(path []string) (Module, error) {
+	// The last part of a path may name a member of the module before it
+	for split := len(path); split >= 1 && len(path)-split <= 1; split-- {
+		modulePath := strings.Join(path[:split], ".")
+		member := strings.Join(path[split:], ".")
+
+		if members, isOk := resolver.Standard[modulePath]; isOk {
+			if member != "" && members != nil && !contains(members, member) {
+				return Module{}, fmt.Errorf("standard module %v has no member %v", modulePath, member)
+			}
+
+			return Module{Path: modulePath, Origin: ORIGIN_STANDARD, Member: member}, nil
+		}
+
+		if location, isOk := findLocation(resolver.Roots, path[:split]); isOk {
+			return Module{Path: modulePath, Origin: ORIGIN_PROJECT, Location: location, Member: member}, nil
+		}
+
+		if location, isOk := findLocation(resolver.Vendor, path[:split]); isOk {
+			return Module{Path: modulePath, Origin: ORIGIN_VENDOR, Location: location, Member: member}, nil
+		}
+	}
+
+	return Module{}, fmt.Errorf("cannot resolve module %v", strings.Join(path, "."))
+}
+
+func contains(values []string, value string) bool {
+	for _, other := range values {
+		if other == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findLocation(roots []string, parts []string) (string, bool) {
+	for _, root := range roots {
+		candidate := filepath.Join(append([]string{root}, parts...)...)
+		if hasSourceFiles(candidate) {
+			return candidate, true
+		}
+
+		file := candidate + "." + files.FUNCTION_FILE_ENDING
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file, true
+		}
+	}
+
+	return "", false
+}
+
+func hasSourceFiles(directory string) bool {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == "."+files.FUNCTION_FILE_ENDING {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ModulePathOf names the module a source file belongs to relative to its project root
+func ModulePathOf(projectRoot string, file string) string {
+	relative, err := filepath.Rel(projectRoot, file)
+	if err != nil {
+		relative = file
+	}
+
+	relative = strings.TrimSuffix(relative, filepath.Ext(relative))
+	return strings.Join(strings.Split(filepath.ToSlash(relative), "/"), ".")
+}
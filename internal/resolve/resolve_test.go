@@ -0,0 +1,141 @@
+package resolve
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func writeFiles(t *testing.T, root string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func importOf(alias string, path string, line int) intermediate.Import {
+	return intermediate.Import{
+		Position: lexer.Position{Filename: "test.dfl", Line: line},
+		Alias:    alias,
+		Path:     strings.Split(path, "."),
+	}
+}
+
+func TestResolveOrigins(t *testing.T) {
+	parent := t.TempDir()
+	project := filepath.Join(parent, "project")
+	writeFiles(t, parent,
+		"project/main.dfl",
+		"project/util/strings.dfl",
+		"project/vendor/mathlib/math.dfl",
+		"other/shared.dfl",
+	)
+
+	resolver := NewResolver(project)
+	cases := map[string]Origin{
+		"dfl.sysout":        ORIGIN_STANDARD,
+		"util.strings":      ORIGIN_PROJECT,
+		"util.strings.trim": ORIGIN_PROJECT,
+		"other.shared":      ORIGIN_PROJECT,
+		"mathlib.sqrt":      ORIGIN_VENDOR,
+	}
+
+	for path, origin := range cases {
+		module, err := resolver.Resolve(strings.Split(path, "."))
+		if err != nil {
+			t.Errorf("%v: %v", path, err)
+		} else if module.Origin != origin {
+			t.Errorf("%v: unexpected origin %v", path, module.Origin)
+		}
+	}
+
+	module, _ := resolver.Resolve([]string{"util", "strings", "trim"})
+	if module.Path != "util.strings" || module.Member != "trim" {
+		t.Errorf("unexpected module %+v", module)
+	}
+
+	if _, err := resolver.Resolve([]string{"missing", "module", "member"}); err == nil {
+		t.Errorf("expected unresolved module")
+	}
+}
+
+func TestRebinding(t *testing.T) {
+	diagnostics := CheckBindings([]intermediate.Import{
+		importOf("sysout", "dfl.sysout", 1),
+		importOf("sysout", "dfl.loop", 2),
+		importOf("loop", "dfl.loop", 3),
+	})
+
+	if len(diagnostics) != 1 || diagnostics[0].Position.Line != 2 || diagnostics[0].Related[0].Position.Line != 1 {
+		t.Errorf("unexpected diagnostics:\n%v", diagnostics)
+	}
+}
+
+func TestImportCycle(t *testing.T) {
+	project := t.TempDir()
+	writeFiles(t, project, "a.dfl", "b.dfl", "c.dfl")
+
+	resolver := NewResolver(project)
+	graph := NewGraph()
+	graph.Resolve(resolver, filepath.Join(project, "a.dfl"), []intermediate.Import{importOf("b", "b", 1)})
+	graph.Resolve(resolver, filepath.Join(project, "b.dfl"), []intermediate.Import{importOf("c", "c", 2)})
+	graph.Resolve(resolver, filepath.Join(project, "c.dfl"), []intermediate.Import{importOf("a", "a", 3)})
+
+	diagnostics := graph.Cycles()
+	if len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "a -> b -> c -> a") {
+		t.Errorf("unexpected diagnostics:\n%v", diagnostics)
+	}
+}
+
+func TestImportCycleOfRelativePaths(t *testing.T) {
+	project := t.TempDir()
+	writeFiles(t, project, "a.dfl", "b.dfl")
+
+	working, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	} else if err := os.Chdir(filepath.Dir(project)); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(working)
+
+	// Discovery gives paths relative to the working directory, while the
+	// resolver locates modules from wherever the project was given
+	relative := filepath.Base(project)
+	graph := NewGraph()
+	graph.Resolve(NewResolver(project), filepath.Join(relative, "a.dfl"), []intermediate.Import{importOf("b", "b", 1)})
+	graph.Resolve(NewResolver(relative), filepath.Join(relative, "b.dfl"), []intermediate.Import{importOf("a", "a", 2)})
+
+	diagnostics := graph.Cycles()
+	if len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "a -> b -> a") {
+		t.Errorf("unexpected diagnostics:\n%v", diagnostics)
+	}
+}
+
+func TestImportedFiles(t *testing.T) {
+	project := t.TempDir()
+	writeFiles(t, project, "a.dfl", "shapes/circle.dfl", "shapes/square.dfl")
+
+	imported := NewResolver(project).ImportedFiles([]intermediate.Import{
+		importOf("square", "shapes.square", 1),
+		importOf("shapes", "shapes", 2),
+		importOf("sysout", "dfl.sysout", 3),
+		importOf("missing", "missing", 4),
+	})
+
+	expected := []string{filepath.Join(project, "shapes", "circle.dfl"), filepath.Join(project, "shapes", "square.dfl")}
+	if strings.Join(imported, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, imported)
+	}
+}
@@ -0,0 +1,229 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unsafe"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+const DEFAULT_GC_THRESHOLD = 1 << 20
+
+const (
+	objectHeaderSize = int(unsafe.Sizeof(Object{}))
+	valueSize        = int(unsafe.Sizeof(Value{}))
+)
+
+var ErrCollected = errors.New("value was used after it was collected")
+
+// Object holds the contents of a heap value. Objects made outside of a heap
+// are never collected and may only refer to other such objects.
+type Object struct {
+	Text      string
+	Items     []Value
+	heap      *Heap
+	size      int
+	marked    bool
+	collected bool
+}
+
+type Statistics struct {
+	Collections int
+	Allocations int
+	Freed       int
+	LiveObjects int
+	LiveBytes   int
+	PeakBytes   int
+	Pause       time.Duration
+}
+
+func (stats Statistics) String() string {
+	return fmt.Sprintf(
+		"%d collections, %d allocations, %d freed, %d objects live in %d bytes, %d bytes at peak, %v paused",
+		stats.Collections, stats.Allocations, stats.Freed, stats.LiveObjects, stats.LiveBytes, stats.PeakBytes, stats.Pause,
+	)
+}
+
+// Heap allocates the values of a running program and frees them with a
+// mark-sweep collector. Roots reports every value the program still uses,
+// which the interpreter finds through the stack maps of its functions.
+type Heap struct {
+	// Stress collects before every allocation and never reuses freed
+	// objects, so every use of a collected value can be detected.
+	Stress    bool
+	Trace     io.Writer
+	Threshold int
+	Roots     func(mark func(Value))
+	objects   []*Object
+	free      []*Object
+	pins      []Value
+	bytes     int
+	next      int
+	stats     Statistics
+}
+
+func NewHeap() *Heap {
+	return &Heap{
+		Threshold: DEFAULT_GC_THRESHOLD,
+		next:      DEFAULT_GC_THRESHOLD,
+		objects:   make([]*Object, 0, 64),
+	}
+}
+
+func (heap *Heap) Text(value string) Value {
+	return Value{Kind: KIND_TEXT, Object: heap.allocate(value, nil)}
+}
+
+func (heap *Heap) List(items []Value) Value {
+	return Value{Kind: KIND_LIST, Object: heap.allocate("", items)}
+}
+
+func (heap *Heap) Struct(name string, fields []Value) Value {
+	return Value{Kind: KIND_STRUCT, Object: heap.allocate(name, fields)}
+}
+
+// Function only allocates when there is something captured
+func (heap *Heap) Function(id intermediate.FunctionId, captures []Value) Value {
+	if len(captures) == 0 {
+		return Value{Kind: KIND_FUNCTION, Integer: int64(id)}
+	}
+
+	return Value{Kind: KIND_FUNCTION, Integer: int64(id), Object: heap.allocate("", captures)}
+}
+
+// Items are not reachable from the roots yet, so they are kept alive by hand
+// should allocating collect
+func (heap *Heap) allocate(text string, items []Value) *Object {
+	if heap.Stress || heap.bytes >= heap.next {
+		heap.collect(items)
+	}
+
+	var object *Object
+	if len(heap.free) > 0 {
+		object = heap.free[len(heap.free)-1]
+		heap.free = heap.free[:len(heap.free)-1]
+	} else {
+		object = &Object{}
+	}
+
+	*object = Object{
+		Text:  text,
+		Items: items,
+		heap:  heap,
+		size:  objectHeaderSize + len(text) + len(items)*valueSize,
+	}
+
+	heap.objects = append(heap.objects, object)
+	heap.bytes += object.size
+	heap.stats.Allocations++
+	if heap.bytes > heap.stats.PeakBytes {
+		heap.stats.PeakBytes = heap.bytes
+	}
+
+	return object
+}
+
+// Pin keeps values alive that only Go code refers to, until Release is
+// called with the returned mark
+func (heap *Heap) Pin(values ...Value) int {
+	mark := len(heap.pins)
+	heap.pins = append(heap.pins, values...)
+	return mark
+}
+
+func (heap *Heap) Release(mark int) {
+	heap.pins = heap.pins[:mark]
+}
+
+// Check fails for values whose object was already collected
+func (heap *Heap) Check(value Value) error {
+	if value.Object != nil && value.Object.collected {
+		return fmt.Errorf("%w: %v", ErrCollected, value.Kind)
+	}
+
+	return nil
+}
+
+func (heap *Heap) Collect() {
+	heap.collect(nil)
+}
+
+func (heap *Heap) collect(pending []Value) {
+	start := time.Now()
+	stack := make([]*Object, 0, 64)
+	mark := func(value Value) {
+		if object := value.Object; object != nil && object.heap == heap && !object.marked {
+			object.marked = true
+			stack = append(stack, object)
+		}
+	}
+
+	if heap.Roots != nil {
+		heap.Roots(mark)
+	}
+
+	for _, value := range heap.pins {
+		mark(value)
+	}
+
+	for _, value := range pending {
+		mark(value)
+	}
+
+	for len(stack) > 0 {
+		object := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, item := range object.Items {
+			mark(item)
+		}
+	}
+
+	live, freed := heap.objects[:0], 0
+	for _, object := range heap.objects {
+		if object.marked {
+			object.marked = false
+			live = append(live, object)
+			continue
+		}
+
+		freed++
+		heap.bytes -= object.size
+		object.collected = true
+		object.Items = nil
+		if !heap.Stress {
+			heap.free = append(heap.free, object)
+		}
+	}
+
+	for index := len(live); index < len(heap.objects); index++ {
+		heap.objects[index] = nil
+	}
+
+	heap.objects = live
+	heap.next = heap.Threshold
+	if 2*heap.bytes > heap.next {
+		heap.next = 2 * heap.bytes
+	}
+
+	pause := time.Since(start)
+	heap.stats.Collections++
+	heap.stats.Freed += freed
+	heap.stats.Pause += pause
+	if heap.Trace != nil {
+		fmt.Fprintf(
+			heap.Trace,
+			"gc %d: freed %d objects, %d live in %d bytes, next at %d bytes, %v\n",
+			heap.stats.Collections, freed, len(heap.objects), heap.bytes, heap.next, pause,
+		)
+	}
+}
+
+func (heap *Heap) Statistics() Statistics {
+	stats := heap.stats
+	stats.LiveObjects = len(heap.objects)
+	stats.LiveBytes = heap.bytes
+	return stats
+}
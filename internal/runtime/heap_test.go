@@ -0,0 +1,77 @@
+package runtime
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCollectFreesUnreachable(t *testing.T) {
+	heap := NewHeap()
+	var root Value
+	heap.Roots = func(mark func(Value)) {
+		mark(root)
+	}
+
+	kept := heap.Text("kept")
+	root = heap.List([]Value{kept, Integer(1)})
+	garbage := heap.List([]Value{heap.Text("garbage")})
+	pinned := heap.Text("pinned")
+	mark := heap.Pin(pinned)
+
+	heap.Collect()
+	stats := heap.Statistics()
+	if stats.Freed != 2 || stats.LiveObjects != 3 {
+		t.Fatalf("unexpected statistics %v", stats)
+	}
+
+	if heap.Check(kept) != nil || heap.Check(pinned) != nil {
+		t.Errorf("reachable values were collected")
+	}
+
+	if err := heap.Check(garbage); !errors.Is(err, ErrCollected) {
+		t.Errorf("expected the unreachable list to be collected, got %v", err)
+	}
+
+	heap.Release(mark)
+	heap.Collect()
+	if err := heap.Check(pinned); !errors.Is(err, ErrCollected) {
+		t.Errorf("expected the released value to be collected, got %v", err)
+	}
+}
+
+func TestCollectKeepsPendingItems(t *testing.T) {
+	heap := NewHeap()
+	heap.Stress = true
+	item := heap.Text("item")
+
+	// Allocating the list collects first while only the list holds the item
+	list := heap.List([]Value{item})
+	if heap.Check(item) != nil || list.Items()[0].Text() != "item" {
+		t.Fatalf("the item of a new list was collected")
+	}
+
+	heap.Collect()
+	if heap.Check(item) == nil {
+		t.Errorf("expected the item to be collected once unreachable")
+	}
+}
+
+func TestThresholdGrowsWithLiveBytes(t *testing.T) {
+	heap := NewHeap()
+	heap.Threshold = 1
+	heap.next = 1
+	live := make([]Value, 0, 8)
+	heap.Roots = func(mark func(Value)) {
+		for _, value := range live {
+			mark(value)
+		}
+	}
+
+	for index := 0; index < 8; index++ {
+		live = append(live, heap.Text("x"))
+	}
+
+	if stats := heap.Statistics(); stats.Collections == 0 || stats.Collections >= 8 || stats.Freed != 0 {
+		t.Errorf("expected a few collections freeing nothing, got %v", stats)
+	}
+}
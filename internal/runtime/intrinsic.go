@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Context is what an intrinsic sees of the running program. Intrinsics
+// allocate every text, list, struct and function they return on its heap.
+type Context interface {
+	Apply(function Value, args ...Value) (Value, error)
+	Stdout() io.Writer
+	Heap() *Heap
+}
+
+type Intrinsic func(context Context, args []Value) (Value, error)
+
+var intrinsics = make(map[string]Intrinsic)
+
+// Register makes an intrinsic callable by name from every module
+func Register(name string, intrinsic Intrinsic) error {
+	if _, exists := intrinsics[name]; exists {
+		return fmt.Errorf("intrinsic %v is already registered", name)
+	}
+
+	intrinsics[name] = intrinsic
+	return nil
+}
+
+func Lookup(name string) (Intrinsic, bool) {
+	intrinsic, isOk := intrinsics[name]
+	return intrinsic, isOk
+}
+
+func Names() []string {
+	names := make([]string, 0, len(intrinsics))
+	for name := range intrinsics {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// MATCH_FAILURE is called with the name of an evals definition when none of
+// its clauses match. Names starting with ! cannot be written in source.
+const MATCH_FAILURE = "!match"
+
+// STRUCT_CONSTRUCTOR builds a struct named by its first argument from the
+// rest of its arguments
+const STRUCT_CONSTRUCTOR = "!struct"
+
+func init() {
+	err := Register(MATCH_FAILURE, func(context Context, args []Value) (Value, error) {
+		return Nothing, fmt.Errorf("no clause of %v matches its inputs", args[0].Text())
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = Register(STRUCT_CONSTRUCTOR, func(context Context, args []Value) (Value, error) {
+		fields := append([]Value{}, args[1:]...)
+		return context.Heap().Struct(args[0].Text(), fields), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
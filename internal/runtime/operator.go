@@ -0,0 +1,193 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+)
+
+// Operators are the intrinsics behind the built-in binary operators
+var Operators = []string{"+", "-", "*", "/", "%", "=", "!=", "<", ">", "<=", ">=", "&", "|"}
+
+// IsOperator reports the intrinsics behind built-in operators
+func IsOperator(name string) bool {
+	for _, operator := range Operators {
+		if operator == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func init() {
+	for _, operator := range Operators {
+		operator := operator
+		err := Register(operator, func(context Context, args []Value) (Value, error) {
+			return ApplyOperator(context.Heap(), operator, args[0], args[1])
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func isNumber(value Value) bool {
+	return value.Kind == KIND_INTEGER || value.Kind == KIND_DECIMAL
+}
+
+func asDecimal(value Value) float64 {
+	if value.Kind == KIND_INTEGER {
+		return float64(value.Integer)
+	}
+
+	return value.Decimal
+}
+
+// ApplyOperator allocates joined texts on the heap
+func ApplyOperator(heap *Heap, operator string, left Value, right Value) (Value, error) {
+	switch operator {
+	case "+", "-", "*", "/", "%":
+		if left.Kind == KIND_TEXT && right.Kind == KIND_TEXT && operator == "+" {
+			return heap.Text(left.Text() + right.Text()), nil
+		} else if !isNumber(left) || !isNumber(right) {
+			break
+		} else if left.Kind == KIND_INTEGER && right.Kind == KIND_INTEGER {
+			return applyInteger(operator, left.Integer, right.Integer)
+		}
+
+		return applyDecimal(operator, asDecimal(left), asDecimal(right))
+	case "=", "!=", "<", ">", "<=", ">=":
+		return compare(operator, left, right)
+	case "&", "|":
+		if left.Kind != KIND_BOOLEAN || right.Kind != KIND_BOOLEAN {
+			break
+		} else if operator == "&" {
+			return Boolean(left.Bool() && right.Bool()), nil
+		}
+
+		return Boolean(left.Bool() || right.Bool()), nil
+	default:
+		return Nothing, fmt.Errorf("unknown operator %v", operator)
+	}
+
+	return Nothing, fmt.Errorf("operator %v cannot be applied to %v and %v", operator, left.Format(), right.Format())
+}
+
+func applyInteger(operator string, left int64, right int64) (Value, error) {
+	var result int64
+	overflow := false
+	switch operator {
+	case "+":
+		result = left + right
+		overflow = (right > 0 && left > math.MaxInt64-right) || (right < 0 && left < math.MinInt64-right)
+	case "-":
+		result = left - right
+		overflow = (right < 0 && left > math.MaxInt64+right) || (right > 0 && left < math.MinInt64+right)
+	case "*":
+		result = left * right
+		overflow = left != 0 && (result/left != right || (left == -1 && right == math.MinInt64))
+	case "/", "%":
+		if right == 0 {
+			return Nothing, fmt.Errorf("division by zero")
+		}
+
+		overflow = left == math.MinInt64 && right == -1
+		if operator == "/" {
+			result = left / right
+		} else if !overflow {
+			result = left % right
+		}
+	}
+
+	if overflow {
+		return Nothing, fmt.Errorf("%d %v %d overflows a number", left, operator, right)
+	}
+
+	return Integer(result), nil
+}
+
+func applyDecimal(operator string, left float64, right float64) (Value, error) {
+	var result float64
+	switch operator {
+	case "+":
+		result = left + right
+	case "-":
+		result = left - right
+	case "*":
+		result = left * right
+	case "/", "%":
+		if right == 0 {
+			return Nothing, fmt.Errorf("division by zero")
+		}
+
+		if operator == "/" {
+			result = left / right
+		} else {
+			result = math.Mod(left, right)
+		}
+	}
+
+	return Decimal(result), nil
+}
+
+func compare(operator string, left Value, right Value) (Value, error) {
+	var ordering int
+	switch {
+	case isNumber(left) && isNumber(right):
+		ordering = compareNumbers(left, right)
+	case left.Kind == right.Kind && left.Kind == KIND_TEXT:
+		if left.Text() < right.Text() {
+			ordering = -1
+		} else if left.Text() > right.Text() {
+			ordering = 1
+		}
+	case left.Kind == right.Kind && left.Kind == KIND_CHAR:
+		if left.Integer < right.Integer {
+			ordering = -1
+		} else if left.Integer > right.Integer {
+			ordering = 1
+		}
+	case left.Kind == right.Kind && (operator == "=" || operator == "!="):
+		if !left.Equal(right) {
+			ordering = 1
+		}
+	default:
+		return Nothing, fmt.Errorf("operator %v cannot compare %v and %v", operator, left.Format(), right.Format())
+	}
+
+	switch operator {
+	case "=":
+		return Boolean(ordering == 0), nil
+	case "!=":
+		return Boolean(ordering != 0), nil
+	case "<":
+		return Boolean(ordering < 0), nil
+	case ">":
+		return Boolean(ordering > 0), nil
+	case "<=":
+		return Boolean(ordering <= 0), nil
+	}
+
+	return Boolean(ordering >= 0), nil
+}
+
+func compareNumbers(left Value, right Value) int {
+	if left.Kind == KIND_INTEGER && right.Kind == KIND_INTEGER {
+		if left.Integer < right.Integer {
+			return -1
+		} else if left.Integer > right.Integer {
+			return 1
+		}
+
+		return 0
+	}
+
+	leftValue, rightValue := asDecimal(left), asDecimal(right)
+	if leftValue < rightValue {
+		return -1
+	} else if leftValue > rightValue {
+		return 1
+	}
+
+	return 0
+}
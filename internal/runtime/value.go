@@ -0,0 +1,208 @@
+package runtime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+type Kind uint8
+
+const (
+	KIND_NOTHING Kind = iota
+	KIND_BOOLEAN
+	KIND_INTEGER
+	KIND_DECIMAL
+	KIND_CHAR
+	KIND_TEXT
+	KIND_LIST
+	KIND_STRUCT
+	KIND_FUNCTION
+)
+
+// Value is a runtime value. Text, lists, structs and functions keep their
+// contents in an Object, function values keep the function id in Integer
+// and their captures as the items of the object.
+type Value struct {
+	Kind    Kind
+	Integer int64
+	Decimal float64
+	Object  *Object
+}
+
+var Nothing = Value{Kind: KIND_NOTHING}
+
+func Boolean(value bool) Value {
+	if value {
+		return Value{Kind: KIND_BOOLEAN, Integer: 1}
+	}
+
+	return Value{Kind: KIND_BOOLEAN}
+}
+
+func Integer(value int64) Value {
+	return Value{Kind: KIND_INTEGER, Integer: value}
+}
+
+func Decimal(value float64) Value {
+	return Value{Kind: KIND_DECIMAL, Decimal: value}
+}
+
+func Char(value rune) Value {
+	return Value{Kind: KIND_CHAR, Integer: int64(value)}
+}
+
+// Text, List, Struct and Function build values outside of any heap, which
+// is how module values live for the whole run. Running code allocates
+// through its Heap instead.
+func Text(value string) Value {
+	return Value{Kind: KIND_TEXT, Object: &Object{Text: value}}
+}
+
+func List(items []Value) Value {
+	return Value{Kind: KIND_LIST, Object: &Object{Items: items}}
+}
+
+func Struct(name string, fields []Value) Value {
+	return Value{Kind: KIND_STRUCT, Object: &Object{Text: name, Items: fields}}
+}
+
+func Function(id intermediate.FunctionId, captures []Value) Value {
+	return Value{Kind: KIND_FUNCTION, Integer: int64(id), Object: &Object{Items: captures}}
+}
+
+func (value Value) Bool() bool {
+	return value.Integer != 0
+}
+
+// Text is the contents of a text or the name of a struct
+func (value Value) Text() string {
+	if value.Object == nil {
+		return ""
+	}
+
+	return value.Object.Text
+}
+
+// Items are the items of a list, the fields of a struct or the captures of a function
+func (value Value) Items() []Value {
+	if value.Object == nil {
+		return nil
+	}
+
+	return value.Object.Items
+}
+
+// FromLiteral converts a module value into a runtime value
+func FromLiteral(literal intermediate.Literal) Value {
+	switch literal.Type {
+	case intermediate.TYPEID_BOOLEAN:
+		return Boolean(literal.Boolean)
+	case intermediate.TYPEID_INTEGER, intermediate.TYPEID_BYTE:
+		return Integer(literal.Integer)
+	case intermediate.TYPEID_DECIMAL:
+		return Decimal(literal.Decimal)
+	case intermediate.TYPEID_CHAR:
+		runes := []rune(literal.Text)
+		if len(runes) == 0 {
+			return Char(0)
+		}
+
+		return Char(runes[0])
+	case intermediate.TYPEID_TEXT:
+		return Text(literal.Text)
+	case intermediate.TYPEID_LIST, intermediate.TYPEID_STRUCT:
+		items := make([]Value, 0, len(literal.Items))
+		for _, item := range literal.Items {
+			items = append(items, FromLiteral(item))
+		}
+
+		if literal.Type == intermediate.TYPEID_LIST {
+			return List(items)
+		}
+
+		return Struct(literal.TypeName, items)
+	}
+
+	return Nothing
+}
+
+// ToLiteral converts a scalar runtime value back into a module value. Lists,
+// structs and functions have no literal form.
+func ToLiteral(value Value) (intermediate.Literal, bool) {
+	switch value.Kind {
+	case KIND_BOOLEAN:
+		return intermediate.BooleanLiteral(value.Bool()), true
+	case KIND_INTEGER:
+		return intermediate.IntegerLiteral(value.Integer), true
+	case KIND_DECIMAL:
+		return intermediate.DecimalLiteral(value.Decimal), true
+	case KIND_CHAR:
+		return intermediate.CharLiteral(string(rune(value.Integer))), true
+	case KIND_TEXT:
+		return intermediate.TextLiteral(value.Text()), true
+	}
+
+	return intermediate.Literal{}, false
+}
+
+func (value Value) Equal(other Value) bool {
+	items, otherItems := value.Items(), other.Items()
+	if value.Kind != other.Kind || value.Integer != other.Integer ||
+		value.Decimal != other.Decimal || value.Text() != other.Text() || len(items) != len(otherItems) {
+		return false
+	}
+
+	for i := range items {
+		if !items[i].Equal(otherItems[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String shows text and characters as they are, which is how sysout prints
+// them, and every other value the way it would be written in source.
+func (value Value) String() string {
+	switch value.Kind {
+	case KIND_TEXT:
+		return value.Text()
+	case KIND_CHAR:
+		return string(rune(value.Integer))
+	}
+
+	return value.Format()
+}
+
+func (value Value) Format() string {
+	switch value.Kind {
+	case KIND_BOOLEAN:
+		return strconv.FormatBool(value.Bool())
+	case KIND_INTEGER:
+		return strconv.FormatInt(value.Integer, 10)
+	case KIND_DECIMAL:
+		return strconv.FormatFloat(value.Decimal, 'f', -1, 64)
+	case KIND_CHAR:
+		return intermediate.CharLiteral(string(rune(value.Integer))).String()
+	case KIND_TEXT:
+		return strconv.Quote(value.Text())
+	case KIND_LIST, KIND_STRUCT:
+		items := make([]string, 0, len(value.Items()))
+		for _, item := range value.Items() {
+			items = append(items, item.Format())
+		}
+
+		if value.Kind == KIND_LIST {
+			return "[" + strings.Join(items, ", ") + "]"
+		}
+
+		return value.Text() + "(" + strings.Join(items, ", ") + ")"
+	case KIND_FUNCTION:
+		return fmt.Sprintf("<function #%d>", value.Integer)
+	}
+
+	return "nothing"
+}
@@ -0,0 +1,152 @@
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Templates live in templates/<name>, along with templates/common which
+// every project gets. File names drop TEMPLATE_ENDING and have NAME_MARKER
+// replaced with the name of the project.
+const (
+	DEFAULT_TEMPLATE = "exec"
+	COMMON_TEMPLATE  = "common"
+	TEMPLATE_ENDING  = ".tmpl"
+	NAME_MARKER      = "_name_"
+)
+
+//go:embed all:templates
+var templates embed.FS
+
+// names become data file prefixes and module names, so they must be
+// identifiers
+var validName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+type Options struct {
+	Directory string
+	// Name defaults to the name of the directory
+	Name     string
+	Template string
+	// Force overwrites files that already exist
+	Force bool
+}
+
+// Templates lists the project templates init can create
+func Templates() []string {
+	entries, _ := fs.ReadDir(templates, "templates")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != COMMON_TEMPLATE {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+type file struct {
+	path     string
+	contents []byte
+}
+
+// Create writes a new project from a template, giving the paths it wrote.
+// Nothing is written when any file already exists, unless forced.
+func Create(options Options) ([]string, error) {
+	templateName := options.Template
+	if templateName == "" {
+		templateName = DEFAULT_TEMPLATE
+	}
+
+	if _, err := fs.Stat(templates, path.Join("templates", templateName)); err != nil || templateName == COMMON_TEMPLATE {
+		return nil, fmt.Errorf("unknown template %v, expected one of %v", templateName, strings.Join(Templates(), ", "))
+	}
+
+	name := options.Name
+	if name == "" {
+		name = filepath.Base(filepath.Clean(options.Directory))
+	}
+
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("project name %q must start with a letter and hold only letters, digits and _", name)
+	}
+
+	files := []file{}
+	for _, templateDirectory := range []string{COMMON_TEMPLATE, templateName} {
+		rendered, err := render(templateDirectory, options.Directory, name)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, rendered...)
+	}
+
+	if !options.Force {
+		existing := []string{}
+		for _, current := range files {
+			if _, err := os.Lstat(current.path); err == nil {
+				existing = append(existing, current.path)
+			}
+		}
+
+		if len(existing) > 0 {
+			return nil, fmt.Errorf("refusing to overwrite %v, use --force to replace them", strings.Join(existing, ", "))
+		}
+	}
+
+	written := make([]string, 0, len(files))
+	for _, current := range files {
+		if err := os.MkdirAll(filepath.Dir(current.path), 0755); err != nil {
+			return written, err
+		}
+
+		if err := os.WriteFile(current.path, current.contents, 0644); err != nil {
+			return written, err
+		}
+
+		written = append(written, current.path)
+	}
+
+	return written, nil
+}
+
+func render(templateDirectory string, directory string, name string) ([]file, error) {
+	root := path.Join("templates", templateDirectory)
+	files := []file{}
+	err := fs.WalkDir(templates, root, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		source, err := templates.ReadFile(current)
+		if err != nil {
+			return err
+		}
+
+		parsed, err := template.New(current).Parse(string(source))
+		if err != nil {
+			return err
+		}
+
+		contents := bytes.Buffer{}
+		if err := parsed.Execute(&contents, struct{ Name string }{name}); err != nil {
+			return err
+		}
+
+		relative := strings.TrimSuffix(strings.TrimPrefix(current, root+"/"), TEMPLATE_ENDING)
+		relative = strings.ReplaceAll(relative, NAME_MARKER, name)
+		files = append(files, file{path: filepath.Join(directory, filepath.FromSlash(relative)), contents: contents.Bytes()})
+		return nil
+	})
+
+	return files, err
+}
@@ -0,0 +1,92 @@
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	if templates := Templates(); !reflect.DeepEqual(templates, []string{"config", "exec", "library", "web"}) {
+		t.Errorf("unexpected templates %v", templates)
+	}
+}
+
+func TestCreateProjects(t *testing.T) {
+	for _, template := range Templates() {
+		directory := filepath.Join(t.TempDir(), "greeter")
+		written, err := Create(Options{Directory: directory, Template: template})
+		if err != nil {
+			t.Fatalf("%v: %v", template, err)
+		}
+
+		kinds := map[string]int{}
+		for _, path := range written {
+			kinds[filepath.Ext(path)]++
+			if strings.HasSuffix(path, TEMPLATE_ENDING) || strings.Contains(path, NAME_MARKER) {
+				t.Errorf("%v: unexpected file name %v", template, path)
+			}
+		}
+
+		if kinds[".dfl"] != 2 || kinds[".ddat"] != 2 || kinds[".duffleignore"] != 1 {
+			t.Errorf("%v: expected a module, a test, a manifest, data and an ignore file, got %v", template, written)
+		}
+
+		manifest, err := os.ReadFile(filepath.Join(directory, "duffle.ddat"))
+		if err != nil || !strings.Contains(string(manifest), `module.name = "greeter"`) {
+			t.Errorf("%v: unexpected manifest %q %v", template, manifest, err)
+		}
+	}
+}
+
+func TestLibraryNames(t *testing.T) {
+	directory := t.TempDir()
+	if _, err := Create(Options{Directory: directory, Name: "mathlib", Template: "library"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(directory, "mathlib.ddat"))
+	if err != nil || strings.TrimSpace(string(data)) != "mathlib.LIMIT = 100" {
+		t.Errorf("unexpected data file %q %v", data, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(directory, "mathlib_test.dfl")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRefusesToOverwrite(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "app")
+	os.MkdirAll(directory, 0755)
+	os.WriteFile(filepath.Join(directory, "main.dfl"), []byte("mine"), 0644)
+
+	if _, err := Create(Options{Directory: directory}); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("expected a refusal, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(directory, "duffle.ddat")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written after a refusal")
+	}
+
+	if _, err := Create(Options{Directory: directory, Force: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(directory, "main.dfl")); string(data) == "mine" {
+		t.Errorf("expected --force to overwrite main.dfl")
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, options := range []Options{
+		{Directory: t.TempDir(), Template: "common"},
+		{Directory: t.TempDir(), Template: "mobile"},
+		{Directory: filepath.Join(t.TempDir(), "my-app")},
+	} {
+		if _, err := Create(options); err == nil {
+			t.Errorf("expected %+v to fail", options)
+		}
+	}
+}
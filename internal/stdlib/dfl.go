@@ -0,0 +1,234 @@
+package stdlib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/runtime"
+	"github.com/tflexsoom/duffle/internal/types"
+)
+
+func init() {
+	for _, member := range []Member{
+		{
+			Name:        "sysout",
+			Signatures:  signatures("@@ sysout <a value>"),
+			Description: "writes a value to standard output. Text and characters are written as they are, without a trailing newline.",
+			Intrinsic:   sysout,
+		},
+		{
+			Name:        "loop",
+			Signatures:  signatures("@@ loop <integer times> <Function[a] body>"),
+			Description: "calls body the given number of times, doing nothing when times is not positive.",
+			Intrinsic:   loop,
+		},
+		{
+			Name:        "text2Number",
+			Signatures:  signatures("@@ integer text2Number <text digits>"),
+			Description: "reads a base 10 number, failing when the text is not one.",
+			Intrinsic:   text2Number,
+		},
+		{
+			Name:        "identity",
+			Signatures:  signatures("@@ a identity <a value>"),
+			Description: "returns its input.",
+			Intrinsic:   identity,
+		},
+		{
+			Name:        "assert",
+			Signatures:  signatures("@@ boolean assert <boolean condition> <text message>"),
+			Description: "returns true when the condition holds and otherwise fails with the message, at the position of the call.",
+			Intrinsic:   assert,
+		},
+		{
+			Name:        types.FUNCTION_TYPE,
+			Description: "Function[inputs..., result] is the type of function values and captures.",
+			IsType:      true,
+		},
+		{
+			Name:        "head",
+			Signatures:  signatures("@@ a head <List[a] items>"),
+			Description: "the first item of a list, failing on an empty list.",
+			Intrinsic:   head,
+		},
+		{
+			Name:        "tail",
+			Signatures:  signatures("@@ List[a] tail <List[a] items>"),
+			Description: "every item of a list but the first, failing on an empty list.",
+			Intrinsic:   tail,
+		},
+		{
+			Name: "length",
+			Signatures: signatures(
+				"@@ integer length <List[a] items>",
+				"@@ integer length <text characters>",
+			),
+			Description: "the number of items in a list or characters in a text.",
+			Intrinsic:   length,
+		},
+		{
+			Name: "slice",
+			Signatures: signatures(
+				"@@ List[a] slice <List[a] items> <integer start> <integer end>",
+				"@@ text slice <text characters> <integer start> <integer end>",
+			),
+			Description: "the items from start up to but not including end, failing when either is out of bounds.",
+			Intrinsic:   slice,
+		},
+		{
+			Name: "index",
+			Signatures: signatures(
+				"@@ a index <integer position> <List[a] items>",
+				"@@ char index <integer position> <text characters>",
+			),
+			Description: "the item at a zero based position, failing when it is out of bounds.",
+			Intrinsic:   index,
+		},
+		{
+			Name: "concat",
+			Signatures: signatures(
+				"@@ List[a] concat <List[a] first> <List[a] second>",
+				"@@ text concat <text first> <text second>",
+			),
+			Description: "joins two lists or two texts.",
+			Intrinsic:   concat,
+		},
+		{
+			Name:        "list",
+			Signatures:  signatures("@@ List[a] list <a item>"),
+			Description: "a list holding a single item.",
+			Intrinsic:   list,
+		},
+		{
+			Name:          "listOf",
+			Signatures:    signatures("@@ List[a] listOf <a element>"),
+			Description:   "names the element type of a @fact or @theory list that data files fill in. Only usable in constant definitions.",
+			ConstexprOnly: true,
+		},
+	} {
+		register(member)
+	}
+}
+
+func sysout(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	_, err := io.WriteString(context.Stdout(), args[0].String())
+	return runtime.Nothing, err
+}
+
+func loop(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	for i := int64(0); i < args[0].Integer; i++ {
+		if _, err := context.Apply(args[1]); err != nil {
+			return runtime.Nothing, err
+		}
+	}
+
+	return runtime.Nothing, nil
+}
+
+func text2Number(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	number, err := strconv.ParseInt(strings.TrimSpace(args[0].Text()), 10, 64)
+	if err != nil {
+		return runtime.Nothing, fmt.Errorf("cannot read %q as a number", args[0].Text())
+	}
+
+	return runtime.Integer(number), nil
+}
+
+func identity(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	return args[0], nil
+}
+
+func assert(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	if !args[0].Bool() {
+		return runtime.Nothing, fmt.Errorf("assertion failed: %v", args[1].Text())
+	}
+
+	return runtime.Boolean(true), nil
+}
+
+var errEmptyList = errors.New("list is empty")
+
+func head(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	if len(args[0].Items()) == 0 {
+		return runtime.Nothing, fmt.Errorf("head: %w", errEmptyList)
+	}
+
+	return args[0].Items()[0], nil
+}
+
+func tail(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	if len(args[0].Items()) == 0 {
+		return runtime.Nothing, fmt.Errorf("tail: %w", errEmptyList)
+	}
+
+	return context.Heap().List(args[0].Items()[1:]), nil
+}
+
+func length(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	if args[0].Kind == runtime.KIND_TEXT {
+		return runtime.Integer(int64(len([]rune(args[0].Text())))), nil
+	}
+
+	return runtime.Integer(int64(len(args[0].Items()))), nil
+}
+
+func bounds(start int64, end int64, size int) error {
+	if start < 0 || end < start || end > int64(size) {
+		return fmt.Errorf("slice [%d, %d) is out of bounds for length %d", start, end, size)
+	}
+
+	return nil
+}
+
+func slice(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	start, end := args[1].Integer, args[2].Integer
+	if args[0].Kind == runtime.KIND_TEXT {
+		characters := []rune(args[0].Text())
+		if err := bounds(start, end, len(characters)); err != nil {
+			return runtime.Nothing, err
+		}
+
+		return context.Heap().Text(string(characters[start:end])), nil
+	}
+
+	if err := bounds(start, end, len(args[0].Items())); err != nil {
+		return runtime.Nothing, err
+	}
+
+	return context.Heap().List(args[0].Items()[start:end]), nil
+}
+
+func index(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	position := args[0].Integer
+	if args[1].Kind == runtime.KIND_TEXT {
+		characters := []rune(args[1].Text())
+		if position < 0 || position >= int64(len(characters)) {
+			return runtime.Nothing, fmt.Errorf("index %d is out of bounds for length %d", position, len(characters))
+		}
+
+		return runtime.Char(characters[position]), nil
+	}
+
+	if position < 0 || position >= int64(len(args[1].Items())) {
+		return runtime.Nothing, fmt.Errorf("index %d is out of bounds for length %d", position, len(args[1].Items()))
+	}
+
+	return args[1].Items()[position], nil
+}
+
+func concat(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	if args[0].Kind == runtime.KIND_TEXT {
+		return context.Heap().Text(args[0].Text() + args[1].Text()), nil
+	}
+
+	items := make([]runtime.Value, 0, len(args[0].Items())+len(args[1].Items()))
+	items = append(items, args[0].Items()...)
+	return context.Heap().List(append(items, args[1].Items()...)), nil
+}
+
+func list(context runtime.Context, args []runtime.Value) (runtime.Value, error) {
+	return context.Heap().List([]runtime.Value{args[0]}), nil
+}
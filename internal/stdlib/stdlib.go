@@ -0,0 +1,104 @@
+package stdlib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/resolve"
+	"github.com/tflexsoom/duffle/internal/runtime"
+	"github.com/tflexsoom/duffle/internal/types"
+)
+
+const MODULE_NAME = "dfl"
+
+// Member is a function or type exported by the standard library. Functions
+// are described by their Duffle signatures, one per overload, and run as Go
+// intrinsics registered under the member's full path.
+type Member struct {
+	Name          string
+	Signatures    []types.Signature
+	Description   string
+	Intrinsic     runtime.Intrinsic
+	IsType        bool
+	ConstexprOnly bool
+}
+
+func (member Member) Path() string {
+	return MODULE_NAME + "." + member.Name
+}
+
+var members = make(map[string]Member)
+
+func signatures(texts ...string) []types.Signature {
+	parsed := make([]types.Signature, 0, len(texts))
+	for _, text := range texts {
+		parsed = append(parsed, types.MustParseSignature(text))
+	}
+
+	return parsed
+}
+
+func register(member Member) {
+	if _, exists := members[member.Name]; exists {
+		panic(fmt.Sprintf("standard member %v is already registered", member.Path()))
+	}
+
+	members[member.Name] = member
+	if member.Intrinsic != nil {
+		if err := runtime.Register(member.Path(), member.Intrinsic); err != nil {
+			panic(err)
+		}
+	}
+
+	names := append(resolve.StandardModules[MODULE_NAME], member.Name)
+	sort.Strings(names)
+	resolve.StandardModules[MODULE_NAME] = names
+}
+
+// Lookup finds a member by its path within the standard library, e.g. dfl.head
+func Lookup(path []string) (Member, bool) {
+	if len(path) != 2 || path[0] != MODULE_NAME {
+		return Member{}, false
+	}
+
+	member, isOk := members[path[1]]
+	return member, isOk
+}
+
+// Prelude finds a member by its bare name. Programs use the standard library
+// without importing it, behind any name they define or import themselves.
+func Prelude(name string) (Member, bool) {
+	return Lookup([]string{MODULE_NAME, name})
+}
+
+func Members() []Member {
+	sorted := make([]Member, 0, len(members))
+	for _, member := range members {
+		sorted = append(sorted, member)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
+
+// Document lists every member with its signatures and description
+func Document() string {
+	builder := strings.Builder{}
+	for _, member := range Members() {
+		if member.IsType {
+			builder.WriteString(fmt.Sprintf("type %v\n", member.Path()))
+		}
+
+		for _, signature := range member.Signatures {
+			builder.WriteString(signature.String() + "\n")
+		}
+
+		builder.WriteString("  " + member.Description + "\n\n")
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,140 @@
+package stdlib
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/resolve"
+	"github.com/tflexsoom/duffle/internal/runtime"
+)
+
+type testContext struct {
+	stdout  bytes.Buffer
+	applied int
+	heap    *runtime.Heap
+}
+
+func newTestContext() *testContext {
+	return &testContext{heap: runtime.NewHeap()}
+}
+
+func (context *testContext) Apply(function runtime.Value, args ...runtime.Value) (runtime.Value, error) {
+	context.applied++
+	return runtime.Nothing, nil
+}
+
+func (context *testContext) Stdout() io.Writer {
+	return &context.stdout
+}
+
+func (context *testContext) Heap() *runtime.Heap {
+	return context.heap
+}
+
+func call(t *testing.T, context *testContext, name string, args ...runtime.Value) (runtime.Value, error) {
+	t.Helper()
+	intrinsic, isOk := runtime.Lookup(MODULE_NAME + "." + name)
+	if !isOk {
+		t.Fatalf("%v is not registered", name)
+	}
+
+	return intrinsic(context, args)
+}
+
+func numbers(values ...int64) runtime.Value {
+	items := make([]runtime.Value, 0, len(values))
+	for _, value := range values {
+		items = append(items, runtime.Integer(value))
+	}
+
+	return runtime.List(items)
+}
+
+func TestIntrinsics(t *testing.T) {
+	context := newTestContext()
+	cases := []struct {
+		name     string
+		args     []runtime.Value
+		expected runtime.Value
+	}{
+		{"identity", []runtime.Value{runtime.Text("same")}, runtime.Text("same")},
+		{"text2Number", []runtime.Value{runtime.Text(" 42\n")}, runtime.Integer(42)},
+		{"head", []runtime.Value{numbers(1, 2, 3)}, runtime.Integer(1)},
+		{"tail", []runtime.Value{numbers(1, 2, 3)}, numbers(2, 3)},
+		{"length", []runtime.Value{numbers(1, 2, 3)}, runtime.Integer(3)},
+		{"length", []runtime.Value{runtime.Text("héllo")}, runtime.Integer(5)},
+		{"slice", []runtime.Value{numbers(1, 2, 3, 4), runtime.Integer(1), runtime.Integer(3)}, numbers(2, 3)},
+		{"slice", []runtime.Value{runtime.Text("duffle"), runtime.Integer(0), runtime.Integer(4)}, runtime.Text("duff")},
+		{"index", []runtime.Value{runtime.Integer(2), numbers(1, 2, 3)}, runtime.Integer(3)},
+		{"index", []runtime.Value{runtime.Integer(1), runtime.Text("ab")}, runtime.Char('b')},
+		{"concat", []runtime.Value{numbers(1), numbers(2, 3)}, numbers(1, 2, 3)},
+		{"concat", []runtime.Value{runtime.Text("~"), runtime.Text("Hello")}, runtime.Text("~Hello")},
+		{"list", []runtime.Value{runtime.Integer(7)}, numbers(7)},
+	}
+
+	for _, testCase := range cases {
+		result, err := call(t, context, testCase.name, testCase.args...)
+		if err != nil || !result.Equal(testCase.expected) {
+			t.Errorf("%v: expected %v but found %v (%v)", testCase.name, testCase.expected.Format(), result.Format(), err)
+		}
+	}
+}
+
+func TestIntrinsicFailures(t *testing.T) {
+	context := newTestContext()
+	cases := map[string][]runtime.Value{
+		"text2Number": {runtime.Text("five")},
+		"head":        {numbers()},
+		"tail":        {numbers()},
+		"slice":       {numbers(1, 2), runtime.Integer(1), runtime.Integer(3)},
+		"index":       {runtime.Integer(-1), numbers(1)},
+	}
+
+	for name, args := range cases {
+		if _, err := call(t, context, name, args...); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestSysoutAndLoop(t *testing.T) {
+	context := newTestContext()
+	call(t, context, "sysout", runtime.Text("Hello"))
+	call(t, context, "sysout", runtime.Char('\n'))
+	call(t, context, "sysout", numbers(1, 2))
+	if context.stdout.String() != "Hello\n[1, 2]" {
+		t.Errorf("unexpected output %q", context.stdout.String())
+	}
+
+	call(t, context, "loop", runtime.Integer(3), runtime.Function(0, nil))
+	call(t, context, "loop", runtime.Integer(-1), runtime.Function(0, nil))
+	if context.applied != 3 {
+		t.Errorf("expected 3 iterations but found %v", context.applied)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	for _, name := range []string{"sysout", "loop", "text2Number", "identity", "Function", "head", "tail",
+		"length", "slice", "index", "concat", "list", "listOf"} {
+		member, isOk := Lookup([]string{MODULE_NAME, name})
+		if !isOk {
+			t.Errorf("missing member %v", name)
+		} else if !member.IsType && len(member.Signatures) == 0 {
+			t.Errorf("%v has no signature", name)
+		}
+
+		if _, err := resolve.NewResolver(t.TempDir()).Resolve([]string{MODULE_NAME, name}); err != nil {
+			t.Errorf("%v does not resolve: %v", name, err)
+		}
+	}
+
+	if _, err := resolve.NewResolver(t.TempDir()).Resolve([]string{MODULE_NAME, "missing"}); err == nil {
+		t.Errorf("expected unknown members to be rejected")
+	}
+
+	if document := Document(); !strings.Contains(document, "@@ List[a] slice <List[a] items> <integer start> <integer end>") {
+		t.Errorf("unexpected documentation\n%v", document)
+	}
+}
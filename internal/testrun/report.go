@@ -0,0 +1,152 @@
+package testrun
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	FORMAT_TEXT  = "text"
+	FORMAT_JSON  = "json"
+	FORMAT_JUNIT = "junit"
+)
+
+var reporters = map[string]func(io.Writer, string, []Result) error{
+	FORMAT_TEXT:  writeText,
+	FORMAT_JSON:  writeJSON,
+	FORMAT_JUNIT: writeJUnit,
+}
+
+func Formats() []string {
+	formats := make([]string, 0, len(reporters))
+	for format := range reporters {
+		formats = append(formats, format)
+	}
+
+	sort.Strings(formats)
+	return formats
+}
+
+// Report writes the results of the suite of a project in a format
+func Report(writer io.Writer, format string, suiteName string, results []Result) error {
+	reporter, isOk := reporters[format]
+	if !isOk {
+		return fmt.Errorf("unknown report format %v, expected one of %v", format, strings.Join(Formats(), ", "))
+	}
+
+	return reporter(writer, suiteName, results)
+}
+
+func writeText(writer io.Writer, suiteName string, results []Result) error {
+	total := time.Duration(0)
+	for _, result := range results {
+		total += result.Duration
+		if result.Passed {
+			fmt.Fprintf(writer, "--- PASS: %v (%.2fs)\n", result.Name, result.Duration.Seconds())
+			continue
+		}
+
+		fmt.Fprintf(writer, "--- FAIL: %v (%.2fs)\n", result.Name, result.Duration.Seconds())
+		for _, line := range strings.Split(result.Failure, "\n") {
+			fmt.Fprintf(writer, "    %v\n", line)
+		}
+	}
+
+	status := "ok"
+	if Failed(results) > 0 {
+		status = "FAIL"
+	}
+
+	_, err := fmt.Fprintf(writer, "%v\t%v\t%d passed, %d failed\t%.3fs\n", status, suiteName, len(results)-Failed(results), Failed(results), total.Seconds())
+	return err
+}
+
+type jsonReport struct {
+	Suite   string   `json:"suite"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	Results []Result `json:"results"`
+}
+
+func writeJSON(writer io.Writer, suiteName string, results []Result) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(jsonReport{
+		Suite:   suiteName,
+		Passed:  len(results) - Failed(results),
+		Failed:  Failed(results),
+		Results: results,
+	})
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Contents string `xml:",chardata"`
+}
+
+func seconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
+
+func writeJUnit(writer io.Writer, suiteName string, results []Result) error {
+	suite := junitSuite{Name: suiteName, Tests: len(results), Failures: Failed(results)}
+	total := time.Duration(0)
+	for _, result := range results {
+		total += result.Duration
+		testCase := junitCase{
+			Name:      result.Name,
+			ClassName: suiteName,
+			File:      result.Position,
+			Time:      seconds(result.Duration),
+			SystemOut: result.Output,
+		}
+
+		if !result.Passed {
+			message, _, _ := strings.Cut(result.Failure, "\n")
+			testCase.Failure = &junitFailure{Message: message, Contents: result.Failure}
+		}
+
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	suite.Time = seconds(total)
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
@@ -0,0 +1,255 @@
+package testrun
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	goruntime "runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/interpreter"
+	"github.com/tflexsoom/duffle/internal/runtime"
+	"github.com/tflexsoom/duffle/internal/types"
+)
+
+// Golden files hold the standard output the entry point of a project is
+// expected to write. The arguments it runs with are read from the args
+// file of the same name, one per line.
+const (
+	GOLDEN_ENDING = ".golden"
+	ARGS_ENDING   = ".args"
+)
+
+type Test struct {
+	Name     string
+	Position lexer.Position
+}
+
+// Golden runs the entry point of a project and compares what it writes
+// with the contents of a golden file
+type Golden struct {
+	Name     string
+	Path     string
+	Args     []string
+	Expected string
+}
+
+type Suite struct {
+	Module intermediate.Module
+	// Entry names the @exec function golden tests run, if there is one
+	Entry   string
+	Tests   []Test
+	Goldens []Golden
+}
+
+type Options struct {
+	// Filter keeps the tests whose names it matches, every test when nil
+	Filter *regexp.Regexp
+	Jobs   int
+	// Update rewrites golden files with what the entry point writes instead
+	// of comparing them
+	Update bool
+}
+
+type Result struct {
+	Name     string        `json:"name"`
+	Position string        `json:"position,omitempty"`
+	Passed   bool          `json:"passed"`
+	Failure  string        `json:"failure,omitempty"`
+	Output   string        `json:"output,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Discover finds the @test functions of a program in name order, along
+// with its entry point when it has a single one
+func Discover(goals []intermediate.Goal) ([]Test, string) {
+	tests := []Test{}
+	entries := []string{}
+	for _, sentiment := range annotation.SentimentsOf(goals...) {
+		if annotation.HasAnnotation(sentiment, annotation.TEST_ANNOTATION) {
+			tests = append(tests, Test{Name: sentiment.Name, Position: sentiment.Position})
+		} else if annotation.HasAnnotation(sentiment, annotation.EXEC_ANNOTATION) {
+			entries = append(entries, sentiment.Name)
+		}
+	}
+
+	entry := ""
+	if len(entries) == 1 {
+		entry = entries[0]
+	}
+
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].Name < tests[j].Name
+	})
+
+	return tests, entry
+}
+
+// FindGoldens reads the golden files at the root of a project
+func FindGoldens(projectLocation string) ([]Golden, error) {
+	paths, err := filepath.Glob(filepath.Join(projectLocation, "*"+GOLDEN_ENDING))
+	if err != nil {
+		return nil, err
+	}
+
+	goldens := make([]Golden, 0, len(paths))
+	for _, path := range paths {
+		expected, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		golden := Golden{
+			Name:     "golden/" + strings.TrimSuffix(filepath.Base(path), GOLDEN_ENDING),
+			Path:     path,
+			Expected: string(expected),
+		}
+
+		args, err := os.ReadFile(strings.TrimSuffix(path, GOLDEN_ENDING) + ARGS_ENDING)
+		if err == nil {
+			golden.Args = strings.Fields(string(args))
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		goldens = append(goldens, golden)
+	}
+
+	return goldens, nil
+}
+
+// Run runs the tests of a suite on a pool of workers, each in an
+// interpreter of its own. Results keep the order of the suite.
+func Run(suite Suite, options Options) []Result {
+	jobs := []func() Result{}
+	for _, test := range suite.Tests {
+		if options.Filter == nil || options.Filter.MatchString(test.Name) {
+			test := test
+			jobs = append(jobs, func() Result { return runTest(suite.Module, test) })
+		}
+	}
+
+	for _, golden := range suite.Goldens {
+		if options.Filter == nil || options.Filter.MatchString(golden.Name) {
+			golden := golden
+			jobs = append(jobs, func() Result { return runGolden(suite, golden, options.Update) })
+		}
+	}
+
+	workers := options.Jobs
+	if workers <= 0 {
+		workers = goruntime.NumCPU()
+	}
+
+	results := make([]Result, len(jobs))
+	slots := make(chan struct{}, workers)
+	group := sync.WaitGroup{}
+	for index, job := range jobs {
+		group.Add(1)
+		slots <- struct{}{}
+		go func(index int, job func() Result) {
+			defer group.Done()
+			start := time.Now()
+			results[index] = job()
+			results[index].Duration = time.Since(start)
+			<-slots
+		}(index, job)
+	}
+
+	group.Wait()
+	return results
+}
+
+func functionNamed(module intermediate.Module, name string) (intermediate.FunctionId, bool) {
+	for id, function := range module.Functions {
+		if function.Name == name {
+			return intermediate.FunctionId(id), true
+		}
+	}
+
+	return 0, false
+}
+
+// runFrom runs a module from one of its functions, giving what it wrote
+func runFrom(module intermediate.Module, id intermediate.FunctionId, args []string) (runtime.Value, string, error) {
+	module.Entry = id
+	output := bytes.Buffer{}
+	program, err := interpreter.New(module, &output)
+	if err != nil {
+		return runtime.Nothing, "", err
+	}
+
+	value, err := program.Run(args)
+	return value, output.String(), err
+}
+
+var testType = types.Function(nil, types.Boolean).String()
+
+func runTest(module intermediate.Module, test Test) Result {
+	result := Result{Name: test.Name, Position: test.Position.String()}
+	id, isOk := functionNamed(module, test.Name)
+	if !isOk {
+		result.Failure = "the test was not emitted"
+		return result
+	}
+
+	if function := module.Functions[id]; function.Params != 0 || function.Type != testType {
+		result.Failure = fmt.Sprintf("@%v functions must have type %v, not %v", annotation.TEST_ANNOTATION, testType, function.Type)
+		return result
+	}
+
+	value, output, err := runFrom(module, id, nil)
+	result.Output = output
+	if err != nil {
+		result.Failure = err.Error()
+	} else if !value.Bool() {
+		result.Failure = "returned false"
+	}
+
+	result.Passed = result.Failure == ""
+	return result
+}
+
+func runGolden(suite Suite, golden Golden, update bool) Result {
+	result := Result{Name: golden.Name, Position: golden.Path}
+	id, isOk := functionNamed(suite.Module, suite.Entry)
+	if suite.Entry == "" || !isOk {
+		result.Failure = fmt.Sprintf("golden files need a single @%v entry point to run", annotation.EXEC_ANNOTATION)
+		return result
+	}
+
+	_, output, err := runFrom(suite.Module, id, golden.Args)
+	result.Output = output
+	if err != nil {
+		result.Failure = err.Error()
+	} else if update {
+		if err := os.WriteFile(golden.Path, []byte(output), 0644); err != nil {
+			result.Failure = err.Error()
+		}
+	} else if output != golden.Expected {
+		result.Failure = fmt.Sprintf("standard output differs from %v:\nexpected %q\n     got %q", golden.Path, golden.Expected, output)
+	}
+
+	result.Passed = result.Failure == ""
+	return result
+}
+
+// Failed counts the results that did not pass
+func Failed(results []Result) int {
+	failed := 0
+	for _, result := range results {
+		if !result.Passed {
+			failed++
+		}
+	}
+
+	return failed
+}
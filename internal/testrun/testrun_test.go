@@ -0,0 +1,203 @@
+package testrun
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/emit"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/typing"
+	"github.com/tflexsoom/duffle/internal/verify"
+)
+
+type node = container.Tree[intermediate.SenimentExpression]
+
+func leaf(line int, op intermediate.OpCode, typeId intermediate.TypeId, value ...string) node {
+	return intermediate.NewExpressionTree(intermediate.SenimentExpression{
+		Position: lexer.Position{Filename: "test.dfl", Line: line, Column: 3},
+		Op:       op,
+		TypeId:   typeId,
+		Value:    value,
+	})
+}
+
+func parent(line int, op intermediate.OpCode, children ...node) node {
+	tree := leaf(line, op, intermediate.TYPEID_NO_TYPE)
+	for _, child := range children {
+		container.AddChildren(tree, child)
+	}
+
+	return tree
+}
+
+func integer(line int, text string) node {
+	return leaf(line, intermediate.OPCODE_CONST, intermediate.TYPEID_INTEGER, text)
+}
+
+func ref(line int, name string) node {
+	return leaf(line, intermediate.OPCODE_REF, intermediate.TYPEID_NO_TYPE, name)
+}
+
+func equals(line int, left string, right string) node {
+	return parent(line, intermediate.OPCODE_CHAIN,
+		integer(line, left), leaf(line, intermediate.OPCODE_OPERATOR, intermediate.TYPEID_NO_TYPE, "="), integer(line, right),
+	)
+}
+
+func returns(name string, annotationName string, line int, result node) intermediate.Sentiment {
+	return intermediate.Sentiment{
+		Position:    lexer.Position{Filename: "test.dfl", Line: line},
+		Annotations: []string{annotationName},
+		Name:        name,
+		Definition:  parent(line, intermediate.OPCODE_BLOCK, parent(line+1, intermediate.OPCODE_RETURN, result)),
+	}
+}
+
+// suiteGoal holds a passing test, a failing assertion, a test of the wrong
+// type and an entry point for golden files
+func suiteGoal() intermediate.Goal {
+	goal := intermediate.NewGoal()
+	goal.Imports = append(goal.Imports,
+		intermediate.Import{Alias: "sysout", Path: []string{"dfl", "sysout"}},
+		intermediate.Import{Alias: "assert", Path: []string{"dfl", "assert"}},
+	)
+
+	goal.Sentments["addsUp"] = returns("addsUp", annotation.TEST_ANNOTATION, 1, equals(2, "2", "2"))
+	goal.Sentments["assertsWrong"] = returns("assertsWrong", annotation.TEST_ANNOTATION, 4, parent(5, intermediate.OPCODE_CALL,
+		ref(5, "assert"), equals(5, "1", "2"), leaf(5, intermediate.OPCODE_CONST, intermediate.TYPEID_TEXT, `"one is two"`),
+	))
+	goal.Sentments["counts"] = returns("counts", annotation.TEST_ANNOTATION, 7, integer(8, "3"))
+	goal.Sentments["main"] = intermediate.Sentiment{
+		Position:    lexer.Position{Filename: "test.dfl", Line: 10},
+		Annotations: []string{annotation.EXEC_ANNOTATION},
+		Name:        "main",
+		Definition: parent(10, intermediate.OPCODE_BLOCK,
+			parent(11, intermediate.OPCODE_CALL, ref(11, "sysout"), leaf(11, intermediate.OPCODE_CONST, intermediate.TYPEID_TEXT, `"hello"`)),
+		),
+	}
+
+	return goal
+}
+
+func suiteOf(t *testing.T, goldens ...Golden) Suite {
+	t.Helper()
+	goals := []intermediate.Goal{suiteGoal()}
+	if diagnostics := typing.CheckProgram(goals, nil, ""); diagnostics.HasErrors() {
+		t.Fatalf("unexpected type errors:\n%v", diagnostics)
+	}
+
+	module, diagnostics := emit.EmitLibrary(goals)
+	if diagnostics.HasErrors() {
+		t.Fatalf("unexpected emit errors:\n%v", diagnostics)
+	}
+
+	if err := verify.Module(module); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, entry := Discover(goals)
+	return Suite{Module: module, Entry: entry, Tests: tests, Goldens: goldens}
+}
+
+func TestRunSuite(t *testing.T) {
+	results := Run(suiteOf(t), Options{Jobs: 2})
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %v", results)
+	}
+
+	if results[0].Name != "addsUp" || !results[0].Passed {
+		t.Errorf("expected addsUp to pass, got %+v", results[0])
+	}
+
+	if failure := results[1].Failure; results[1].Passed || !strings.Contains(failure, "test.dfl:5:3") ||
+		!strings.Contains(failure, "assertion failed: one is two") {
+		t.Errorf("expected the assertion to fail at its call, got %+v", results[1])
+	}
+
+	if results[2].Passed || !strings.Contains(results[2].Failure, "Function[boolean], not Function[integer]") {
+		t.Errorf("expected counts to fail for its type, got %+v", results[2])
+	}
+
+	if Failed(results) != 2 {
+		t.Errorf("expected 2 failures, got %v", Failed(results))
+	}
+
+	filtered := Run(suiteOf(t), Options{Filter: regexp.MustCompile("^add")})
+	if len(filtered) != 1 || filtered[0].Name != "addsUp" {
+		t.Errorf("expected only addsUp to run, got %v", filtered)
+	}
+}
+
+func TestGoldenFiles(t *testing.T) {
+	project := t.TempDir()
+	os.WriteFile(filepath.Join(project, "greeting.golden"), []byte("hello"), 0644)
+	os.WriteFile(filepath.Join(project, "stale.golden"), []byte("goodbye"), 0644)
+	os.WriteFile(filepath.Join(project, "stale.args"), []byte("first\nsecond\n"), 0644)
+
+	goldens, err := FindGoldens(project)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(goldens) != 2 || goldens[1].Name != "golden/stale" || len(goldens[1].Args) != 2 {
+		t.Fatalf("unexpected goldens %+v", goldens)
+	}
+
+	options := Options{Filter: regexp.MustCompile("^golden/")}
+	results := Run(suiteOf(t, goldens...), options)
+	if len(results) != 2 || !results[0].Passed || results[1].Passed || !strings.Contains(results[1].Failure, `expected "goodbye"`) {
+		t.Errorf("expected only the stale golden file to fail, got %+v", results)
+	}
+
+	options.Update = true
+	if results := Run(suiteOf(t, goldens...), options); Failed(results) != 0 {
+		t.Errorf("expected updating to pass, got %+v", results)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(project, "stale.golden")); string(data) != "hello" {
+		t.Errorf("expected the golden file to be updated, got %q", data)
+	}
+}
+
+func TestReports(t *testing.T) {
+	results := Run(suiteOf(t), Options{})
+
+	text := bytes.Buffer{}
+	if err := Report(&text, FORMAT_TEXT, "example", results); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(text.String(), "--- FAIL: assertsWrong") || !strings.Contains(text.String(), "FAIL\texample\t1 passed, 2 failed") {
+		t.Errorf("unexpected text report:\n%v", text.String())
+	}
+
+	data := bytes.Buffer{}
+	if err := Report(&data, FORMAT_JSON, "example", results); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := jsonReport{}
+	if err := json.Unmarshal(data.Bytes(), &decoded); err != nil || decoded.Passed != 1 || decoded.Failed != 2 || len(decoded.Results) != 3 {
+		t.Errorf("unexpected json report %+v %v", decoded, err)
+	}
+
+	junit := bytes.Buffer{}
+	if err := Report(&junit, FORMAT_JUNIT, "example", results); err != nil {
+		t.Fatal(err)
+	}
+
+	suites := junitSuites{}
+	if err := xml.Unmarshal(junit.Bytes(), &suites); err != nil || len(suites.Suites) != 1 ||
+		suites.Suites[0].Failures != 2 || suites.Suites[0].Cases[1].Failure == nil {
+		t.Errorf("unexpected junit report %+v %v\n%v", suites, err, junit.String())
+	}
+
+	if err := Report(&text, "yaml", "example", results); err == nil {
+		t.Errorf("expected an unknown format to fail")
+	}
+}
@@ -0,0 +1,31 @@
+package types
+
+// Supports tells whether values of a type can be operands of an operator.
+// Rigid type variables support nothing on their own, their bounds are known
+// only to the function declaring them.
+func Supports(t Type, operator string) bool {
+	switch operator {
+	case "+":
+		return t.Equal(Integer) || t.Equal(Decimal) || t.Equal(Text)
+	case "-", "*", "/", "%":
+		return t.Equal(Integer) || t.Equal(Decimal)
+	case "<", ">", "<=", ">=":
+		return t.Equal(Integer) || t.Equal(Decimal) || t.Equal(Text) || t.Equal(Char)
+	case "=", "!=":
+		return !t.Variable && !t.IsRigid() && !t.IsFunction()
+	case "&", "|":
+		return t.Equal(Boolean)
+	}
+
+	return false
+}
+
+// IsOperator reports the operators types can be bound by
+func IsOperator(operator string) bool {
+	switch operator {
+	case "+", "-", "*", "/", "%", "<", ">", "<=", ">=", "=", "!=", "&", "|":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Param struct {
+	Name string
+	Type Type
+}
+
+// Signature is the type of a named function as written in Duffle:
+//
+//	@@ List[a] slice <List[a] items> <integer start> <integer end>
+type Signature struct {
+	Name   string
+	Params []Param
+	Result Type
+}
+
+func (signature Signature) Type() Type {
+	params := make([]Type, 0, len(signature.Params))
+	for _, param := range signature.Params {
+		params = append(params, param.Type)
+	}
+
+	return Function(params, signature.Result)
+}
+
+func (signature Signature) String() string {
+	builder := strings.Builder{}
+	builder.WriteString("@@ ")
+	if !signature.Result.Equal(Nothing) {
+		builder.WriteString(signature.Result.String() + " ")
+	}
+
+	builder.WriteString(signature.Name)
+	for _, param := range signature.Params {
+		builder.WriteString(" <" + param.Type.String() + " " + param.Name + ">")
+	}
+
+	return builder.String()
+}
+
+// ParseSignature reads the header of a function definition. A missing result
+// type means the function returns nothing.
+func ParseSignature(text string) (Signature, error) {
+	header := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "@@"))
+	inputs := ""
+	if index := strings.Index(header, "<"); index >= 0 {
+		header, inputs = strings.TrimSpace(header[:index]), header[index:]
+	}
+
+	signature := Signature{Result: Nothing, Params: make([]Param, 0, 2)}
+	if split := strings.LastIndex(header, " "); split >= 0 {
+		result, err := Parse(header[:split])
+		if err != nil {
+			return Signature{}, err
+		}
+
+		signature.Result = result
+		header = header[split+1:]
+	}
+
+	signature.Name = header
+	if signature.Name == "" {
+		return Signature{}, fmt.Errorf("missing function name in %q", text)
+	}
+
+	for strings.TrimSpace(inputs) != "" {
+		inputs = strings.TrimSpace(inputs)
+		close := strings.Index(inputs, ">")
+		if !strings.HasPrefix(inputs, "<") || close < 0 {
+			return Signature{}, fmt.Errorf("malformed input in %q", text)
+		}
+
+		end := strings.LastIndex(inputs[:close], " ")
+		if end < 0 {
+			return Signature{}, fmt.Errorf("input %q is missing its type or name", inputs[:close+1])
+		}
+
+		paramType, err := Parse(inputs[1:end])
+		if err != nil {
+			return Signature{}, err
+		}
+
+		signature.Params = append(signature.Params, Param{Name: inputs[end+1 : close], Type: paramType})
+		inputs = inputs[close+1:]
+	}
+
+	return signature, nil
+}
+
+func MustParseSignature(text string) Signature {
+	signature, err := ParseSignature(text)
+	if err != nil {
+		panic(err)
+	}
+
+	return signature
+}
@@ -0,0 +1,229 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+const (
+	LIST_TYPE     = "List"
+	FUNCTION_TYPE = "Function"
+
+	// NOTHING_TYPE is the result of functions that never return a value
+	NOTHING_TYPE = "nothing"
+)
+
+// Type is a possibly generic type such as integer, List[a] or
+// Function[a, decimal]. The last argument of a Function is its result.
+type Type struct {
+	Name     string
+	Args     []Type
+	Variable bool
+}
+
+func Named(name string, args ...Type) Type {
+	return Type{Name: name, Args: args}
+}
+
+func Variable(name string) Type {
+	return Type{Name: name, Variable: true}
+}
+
+func List(element Type) Type {
+	return Named(LIST_TYPE, element)
+}
+
+func Function(params []Type, result Type) Type {
+	return Named(FUNCTION_TYPE, append(append([]Type{}, params...), result)...)
+}
+
+var (
+	Boolean = Named("boolean")
+	Byte    = Named("byte")
+	Char    = Named("char")
+	Integer = Named("integer")
+	Decimal = Named("decimal")
+	Text    = Named("text")
+	Nothing = Named(NOTHING_TYPE)
+)
+
+// aliases name the same type in more than one way
+var aliases = map[string]string{
+	"number": "integer",
+}
+
+func (t Type) IsFunction() bool {
+	return !t.Variable && t.Name == FUNCTION_TYPE && len(t.Args) > 0
+}
+
+// IsRigid reports the fixed type variables a generic function sees its own
+// type parameters as
+func (t Type) IsRigid() bool {
+	return !t.Variable && len(t.Args) == 0 && isVariableName(t.Name)
+}
+
+func (t Type) IsList() bool {
+	return !t.Variable && t.Name == LIST_TYPE && len(t.Args) == 1
+}
+
+// Params and Result split a function type into its inputs and output
+func (t Type) Params() []Type {
+	return t.Args[:len(t.Args)-1]
+}
+
+func (t Type) Result() Type {
+	return t.Args[len(t.Args)-1]
+}
+
+func (t Type) Equal(other Type) bool {
+	if t.Name != other.Name || t.Variable != other.Variable || len(t.Args) != len(other.Args) {
+		return false
+	}
+
+	for i := range t.Args {
+		if !t.Args[i].Equal(other.Args[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t Type) String() string {
+	if len(t.Args) == 0 {
+		return t.Name
+	}
+
+	args := make([]string, 0, len(t.Args))
+	for _, arg := range t.Args {
+		args = append(args, arg.String())
+	}
+
+	return t.Name + "[" + strings.Join(args, ", ") + "]"
+}
+
+// Of gives the type of a literal value
+func Of(literal intermediate.Literal) Type {
+	switch literal.Type {
+	case intermediate.TYPEID_BOOLEAN:
+		return Boolean
+	case intermediate.TYPEID_BYTE:
+		return Byte
+	case intermediate.TYPEID_CHAR:
+		return Char
+	case intermediate.TYPEID_INTEGER:
+		return Integer
+	case intermediate.TYPEID_DECIMAL:
+		return Decimal
+	case intermediate.TYPEID_TEXT:
+		return Text
+	case intermediate.TYPEID_LIST:
+		if len(literal.Items) > 0 {
+			return List(Of(literal.Items[0]))
+		} else if parsed, err := Parse(literal.TypeName); err == nil && parsed.IsList() {
+			return parsed
+		}
+
+		return List(Variable("a"))
+	case intermediate.TYPEID_NO_TYPE:
+		return Nothing
+	}
+
+	return Named(literal.TypeName)
+}
+
+// isVariableName treats single lower case letters as type variables
+func isVariableName(name string) bool {
+	return len(name) == 1 && unicode.IsLower(rune(name[0]))
+}
+
+// Parse reads a type written the way type names are lowered, e.g. List[a]
+func Parse(text string) (Type, error) {
+	parser := typeParser{text: text}
+	parsed, err := parser.parse()
+	if err != nil {
+		return Type{}, err
+	}
+
+	if parser.skipSpace(); parser.position != len(parser.text) {
+		return Type{}, fmt.Errorf("unexpected %q in type %q", parser.text[parser.position:], text)
+	}
+
+	return parsed, nil
+}
+
+func MustParse(text string) Type {
+	parsed, err := Parse(text)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+type typeParser struct {
+	text     string
+	position int
+}
+
+func (parser *typeParser) skipSpace() {
+	for parser.position < len(parser.text) && parser.text[parser.position] == ' ' {
+		parser.position++
+	}
+}
+
+func (parser *typeParser) parse() (Type, error) {
+	parser.skipSpace()
+	start := parser.position
+	for parser.position < len(parser.text) {
+		c := rune(parser.text[parser.position])
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			break
+		}
+
+		parser.position++
+	}
+
+	name := parser.text[start:parser.position]
+	if name == "" {
+		return Type{}, fmt.Errorf("missing type name in %q", parser.text)
+	}
+
+	if alias, isOk := aliases[name]; isOk {
+		name = alias
+	}
+
+	parser.skipSpace()
+	if parser.position >= len(parser.text) || parser.text[parser.position] != '[' {
+		if isVariableName(name) {
+			return Variable(name), nil
+		}
+
+		return Named(name), nil
+	}
+
+	parser.position++
+	args := make([]Type, 0, 2)
+	for {
+		arg, err := parser.parse()
+		if err != nil {
+			return Type{}, err
+		}
+
+		args = append(args, arg)
+		parser.skipSpace()
+		if parser.position >= len(parser.text) {
+			return Type{}, fmt.Errorf("unclosed type arguments in %q", parser.text)
+		}
+
+		parser.position++
+		if parser.text[parser.position-1] == ']' {
+			return Named(name, args...), nil
+		} else if parser.text[parser.position-1] != ',' {
+			return Type{}, fmt.Errorf("unexpected %q in type %q", parser.text[parser.position-1], parser.text)
+		}
+	}
+}
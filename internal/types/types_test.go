@@ -0,0 +1,81 @@
+package types
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := map[string]string{
+		"number":               "integer",
+		"List[a]":              "List[a]",
+		"Function[a, decimal]": "Function[a, decimal]",
+		"List[ List[text] ]":   "List[List[text]]",
+		"Function[List[a],a]":  "Function[List[a], a]",
+	}
+
+	for text, expected := range cases {
+		parsed, err := Parse(text)
+		if err != nil || parsed.String() != expected {
+			t.Errorf("%v: expected %v but found %v (%v)", text, expected, parsed, err)
+		}
+	}
+
+	if !MustParse("List[a]").Args[0].Variable || MustParse("text").Variable {
+		t.Errorf("single letters should be the only type variables")
+	}
+
+	for _, text := range []string{"", "List[a", "List[a]]", "List[,]"} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+}
+
+func TestUnify(t *testing.T) {
+	substitution := Substitution{}
+	if err := substitution.Unify(MustParse("Function[a, List[a]]"), MustParse("Function[text, b]")); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := substitution.Apply(Variable("b")); !found.Equal(List(Text)) {
+		t.Errorf("expected b to be List[text] but found %v", found)
+	}
+
+	if err := substitution.Unify(Variable("a"), Integer); err == nil {
+		t.Errorf("expected a conflict between text and integer")
+	}
+
+	if err := (Substitution{}).Unify(Variable("a"), List(Variable("a"))); err == nil {
+		t.Errorf("expected an infinite type error")
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	signature, err := ParseSignature("@@ List[a] sortLambda <List[a] items> <Function[a, decimal] key>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signature.Name != "sortLambda" || len(signature.Params) != 2 || signature.Params[1].Name != "key" ||
+		signature.Type().String() != "Function[List[a], Function[a, decimal], List[a]]" {
+		t.Errorf("unexpected signature %+v", signature)
+	}
+
+	printer := MustParseSignature("@@ sysout <a value>")
+	if !printer.Result.Equal(Nothing) || printer.String() != "@@ sysout <a value>" {
+		t.Errorf("unexpected signature %v", printer)
+	}
+
+	if _, err := ParseSignature("@@ broken <text>"); err == nil {
+		t.Errorf("expected a malformed input error")
+	}
+}
+
+func TestGeneralize(t *testing.T) {
+	inferred := Function([]Type{Variable("'4"), Named("b")}, List(Variable("'4")))
+	if found := Generalize(inferred); found.String() != "Function[a, b, List[a]]" {
+		t.Errorf("unexpected generalized type %v", found)
+	}
+
+	if found := Generalize(Function([]Type{Text}, Integer)); !found.Equal(Function([]Type{Text}, Integer)) {
+		t.Errorf("expected a concrete type to be kept but found %v", found)
+	}
+}
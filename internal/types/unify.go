@@ -0,0 +1,185 @@
+package types
+
+import "fmt"
+
+// Substitution binds type variables to the types inferred for them
+type Substitution map[string]Type
+
+func (substitution Substitution) Apply(t Type) Type {
+	if t.Variable {
+		if bound, isOk := substitution[t.Name]; isOk {
+			return substitution.Apply(bound)
+		}
+
+		return t
+	}
+
+	if len(t.Args) == 0 {
+		return t
+	}
+
+	args := make([]Type, 0, len(t.Args))
+	for _, arg := range t.Args {
+		args = append(args, substitution.Apply(arg))
+	}
+
+	return Named(t.Name, args...)
+}
+
+func (substitution Substitution) occurs(name string, t Type) bool {
+	t = substitution.Apply(t)
+	if t.Variable {
+		return t.Name == name
+	}
+
+	for _, arg := range t.Args {
+		if substitution.occurs(name, arg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Unify binds variables of either type so that both become the same type
+func (substitution Substitution) Unify(expected Type, actual Type) error {
+	expected = substitution.Apply(expected)
+	actual = substitution.Apply(actual)
+
+	switch {
+	case expected.Variable && actual.Variable && expected.Name == actual.Name:
+		return nil
+	case expected.Variable:
+		return substitution.bind(expected.Name, actual)
+	case actual.Variable:
+		return substitution.bind(actual.Name, expected)
+	case expected.Name != actual.Name || len(expected.Args) != len(actual.Args):
+		return fmt.Errorf("expected %v but found %v", expected, actual)
+	}
+
+	for i := range expected.Args {
+		if err := substitution.Unify(expected.Args[i], actual.Args[i]); err != nil {
+			return fmt.Errorf("expected %v but found %v", expected, substitution.Apply(actual))
+		}
+	}
+
+	return nil
+}
+
+func (substitution Substitution) bind(name string, t Type) error {
+	if substitution.occurs(name, t) {
+		return fmt.Errorf("%v cannot be the infinite type %v", name, t)
+	}
+
+	substitution[name] = t
+	return nil
+}
+
+// Fresh renames the declared variables of a type apart from every other use
+// of the same names, so each use of a generic signature is inferred
+// separately. Variables introduced by inference are shared and kept.
+func Fresh(t Type, suffix string) Type {
+	if t.Variable && isVariableName(t.Name) {
+		return Variable(t.Name + suffix)
+	} else if t.Variable {
+		return t
+	}
+
+	if len(t.Args) == 0 {
+		return t
+	}
+
+	args := make([]Type, 0, len(t.Args))
+	for _, arg := range t.Args {
+		args = append(args, Fresh(arg, suffix))
+	}
+
+	return Named(t.Name, args...)
+}
+
+// Declared lists the declared variables of a type in order of appearance
+func Declared(t Type) []string {
+	names := make([]string, 0, 2)
+	return declared(t, names)
+}
+
+func declared(t Type, names []string) []string {
+	if t.Variable && isVariableName(t.Name) {
+		for _, name := range names {
+			if name == t.Name {
+				return names
+			}
+		}
+
+		return append(names, t.Name)
+	}
+
+	for _, arg := range t.Args {
+		names = declared(arg, names)
+	}
+
+	return names
+}
+
+// Rigid fixes the declared variables of a type, which is how a generic
+// function sees its own type parameters while its body is checked.
+func Rigid(t Type) Type {
+	if t.Variable && isVariableName(t.Name) {
+		return Named(t.Name)
+	} else if len(t.Args) == 0 {
+		return t
+	}
+
+	args := make([]Type, 0, len(t.Args))
+	for _, arg := range t.Args {
+		args = append(args, Rigid(arg))
+	}
+
+	return Named(t.Name, args...)
+}
+
+// Generalize renames the variables left in a type to a, b, c in order of
+// appearance so the type can be written down and parsed again. The rigid
+// variables of a generic function keep their names and are skipped.
+func Generalize(t Type) Type {
+	reserved := make(map[string]bool)
+	rigidNames(t, reserved)
+	return generalize(t, make(map[string]Type), reserved)
+}
+
+func rigidNames(t Type, reserved map[string]bool) {
+	if t.IsRigid() {
+		reserved[t.Name] = true
+	}
+
+	for _, arg := range t.Args {
+		rigidNames(arg, reserved)
+	}
+}
+
+func generalize(t Type, names map[string]Type, reserved map[string]bool) Type {
+	if t.Variable {
+		if renamed, isOk := names[t.Name]; isOk {
+			return renamed
+		}
+
+		next := 'a'
+		for reserved[string(next)] {
+			next++
+		}
+
+		renamed := Variable(string(next))
+		reserved[renamed.Name] = true
+		names[t.Name] = renamed
+		return renamed
+	} else if len(t.Args) == 0 {
+		return t
+	}
+
+	args := make([]Type, 0, len(t.Args))
+	for _, arg := range t.Args {
+		args = append(args, generalize(arg, names, reserved))
+	}
+
+	return Named(t.Name, args...)
+}
@@ -0,0 +1,205 @@
+package typing
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/pattern"
+	"github.com/tflexsoom/duffle/internal/types"
+)
+
+// constraint is an operator a type parameter of a callee must support,
+// checked once the argument the caller gave for it is known
+type constraint struct {
+	position lexer.Position
+	t        types.Type
+	operator string
+	callee   string
+	param    intermediate.TypeParam
+	bounds   map[string][]string
+}
+
+// TypeParamsOf gives the type parameters of a sentiment. Sentiments that
+// declare none are generic over the variables of their declared types.
+func TypeParamsOf(goal intermediate.Goal, sentiment intermediate.Sentiment) []intermediate.TypeParam {
+	if len(sentiment.TypeParams) > 0 {
+		return sentiment.TypeParams
+	}
+
+	declared := types.Declared(DeclaredType(goal, sentiment))
+	params := make([]intermediate.TypeParam, 0, len(declared))
+	for _, name := range declared {
+		params = append(params, intermediate.TypeParam{Position: sentiment.Position, Name: name})
+	}
+
+	return params
+}
+
+// DeclaredType is the type a sentiment is written with, the way uses of it
+// see it. Inputs and results without a type are left open.
+func DeclaredType(goal intermediate.Goal, sentiment intermediate.Sentiment) types.Type {
+	open := 0
+	declaredOf := func(typeId intermediate.TypeId) types.Type {
+		if parsed, err := types.Parse(goal.TypeNameOf(typeId)); err == nil && typeId != intermediate.TYPEID_NO_TYPE {
+			return parsed
+		}
+
+		open++
+		return types.Variable(fmt.Sprintf("$%d", open))
+	}
+
+	params := make([]types.Type, 0, len(sentiment.Inputs))
+	for _, input := range sentiment.Inputs {
+		params = append(params, declaredOf(input.TypeId))
+	}
+
+	if len(params) == 0 && sentiment.Definition.GetValue().Op == intermediate.OPCODE_EVALS {
+		if clauses := pattern.ClausesOf(sentiment.Definition); len(clauses) > 0 {
+			for range clauses[0].Params {
+				params = append(params, declaredOf(intermediate.TYPEID_NO_TYPE))
+			}
+		}
+	}
+
+	result := declaredOf(sentiment.Result)
+	if len(params) == 0 {
+		return result
+	}
+
+	return types.Function(params, result)
+}
+
+func isTypeParamName(name string) bool {
+	return len(name) == 1 && unicode.IsLower(rune(name[0]))
+}
+
+// checkTypeParams checks the type parameters a sentiment declares against
+// the types it is written with
+func checkTypeParams(goal intermediate.Goal, sentiment intermediate.Sentiment, diagnostics *diagnostic.Diagnostics) {
+	if len(sentiment.TypeParams) == 0 {
+		return
+	}
+
+	declared := make(map[string]bool, len(sentiment.TypeParams))
+	for _, param := range sentiment.TypeParams {
+		if !isTypeParamName(param.Name) {
+			diagnostics.Errorf(param.Position, "type parameter %v of %v must be a single lower case letter", param.Name, sentiment.Name)
+		} else if declared[param.Name] {
+			diagnostics.Errorf(param.Position, "type parameter %v of %v is declared more than once", param.Name, sentiment.Name)
+		}
+
+		declared[param.Name] = true
+		for _, operator := range param.Bounds {
+			if !types.IsOperator(operator) {
+				diagnostics.Errorf(param.Position, "type parameter %v of %v cannot be bound by unknown operator %v", param.Name, sentiment.Name, operator)
+			}
+		}
+	}
+
+	used := types.Declared(DeclaredType(goal, sentiment))
+	for _, name := range used {
+		if !declared[name] {
+			diagnostics.Errorf(sentiment.Position, "%v uses type variable %v without declaring it in [%v]", sentiment.Name, name, typeParamNames(sentiment.TypeParams))
+		}
+	}
+
+	for _, param := range sentiment.TypeParams {
+		if !slices.Contains(used, param.Name) {
+			diagnostics.Warnf(param.Position, "type parameter %v of %v is never used", param.Name, sentiment.Name)
+		}
+	}
+}
+
+func typeParamNames(params []intermediate.TypeParam) string {
+	names := make([]string, 0, len(params))
+	for _, param := range params {
+		names = append(names, param.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func boundsOf(params []intermediate.TypeParam) map[string][]string {
+	bounds := make(map[string][]string, len(params))
+	for _, param := range params {
+		bounds[param.Name] = param.Bounds
+	}
+
+	return bounds
+}
+
+// supports tells whether a type can be an operand of an operator. The type
+// parameters of the function being checked support only their bounds.
+func (state *inference) supports(t types.Type, operator string) bool {
+	if t.IsRigid() {
+		return slices.Contains(state.bounds[t.Name], operator)
+	}
+
+	return types.Supports(t, operator)
+}
+
+func (state *inference) unsupported(position lexer.Position, t types.Type, operator string) {
+	if t.IsRigid() {
+		state.diagnostics.Errorf(position, "%v does not support %v, declare [%v supports %v] to require it", t, operator, t, operator)
+		return
+	}
+
+	state.diagnostics.Errorf(position, "%v does not support %v", t, operator)
+}
+
+// instantiated records what a use of a generic sentiment needs from the
+// types it is used at, and the instance type the emitter specializes it by
+func (state *inference) instantiated(tree expressionTree, target string, suffix string, t types.Type) {
+	params := state.program.typeParams[target]
+	if len(params) == 0 {
+		return
+	}
+
+	state.program.instances = append(state.program.instances, typedTree{state.goal, tree, t})
+	for _, param := range params {
+		for _, operator := range param.Bounds {
+			state.program.constraints = append(state.program.constraints, constraint{
+				position: tree.GetValue().Position,
+				t:        types.Variable(param.Name + suffix),
+				operator: operator,
+				callee:   target,
+				param:    param,
+				bounds:   state.bounds,
+			})
+		}
+	}
+}
+
+// checkConstraints checks the bounds of generic sentiments at the types
+// their uses settled on. Uses that stay generic are checked where their own
+// type is fixed.
+func (state *program) checkConstraints(substitution types.Substitution, diagnostics *diagnostic.Diagnostics) {
+	for _, required := range state.constraints {
+		t := substitution.Apply(required.t)
+		if t.Variable {
+			continue
+		}
+
+		supported := types.Supports(t, required.operator)
+		if t.IsRigid() {
+			supported = slices.Contains(required.bounds[t.Name], required.operator)
+		}
+
+		if !supported {
+			diagnostics.Errorf(
+				required.position,
+				"%v does not support %v required by %v of %v",
+				t, required.operator, required.param.Name, required.callee,
+			).WithRelated(
+				required.param.Position,
+				"%v declares %v supports %v here",
+				required.callee, required.param.Name, required.operator,
+			)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package typing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+// comparing is a sentiment of two inputs of type a, evaluating x <= y
+func comparing(goal intermediate.Goal, name string, params []intermediate.TypeParam, body node) {
+	goal.Sentments[name] = intermediate.Sentiment{
+		Name:       name,
+		TypeParams: params,
+		Inputs: []intermediate.SentimentInput{
+			{Name: "x", TypeId: goal.TypeIdOf("a")},
+			{Name: "y", TypeId: goal.TypeIdOf("a")},
+		},
+		Result:     intermediate.TYPEID_BOOLEAN,
+		Definition: parent(intermediate.OPCODE_CONSTEXPR, body),
+	}
+}
+
+func lessOrEqual() node {
+	return parent(intermediate.OPCODE_CHAIN, ref("x"), leaf(intermediate.OPCODE_OPERATOR, intermediate.TYPEID_NO_TYPE, "<="), ref("y"))
+}
+
+func TestInferGenerics(t *testing.T) {
+	goal := goalWithMain(
+		parent(intermediate.OPCODE_CALL, ref("sysout"), parent(intermediate.OPCODE_CALL, ref("smaller"),
+			leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_INTEGER, "1"),
+			leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_INTEGER, "2"),
+		)),
+		parent(intermediate.OPCODE_CALL, ref("sysout"), parent(intermediate.OPCODE_CALL, ref("smaller"),
+			leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_TEXT, `"a"`),
+			leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_TEXT, `"b"`),
+		)),
+	)
+	comparing(goal, "smaller", []intermediate.TypeParam{{Name: "a", Bounds: []string{"<="}}}, lessOrEqual())
+
+	if diagnostics := Infer([]intermediate.Goal{goal}); len(diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics:\n%v", diagnostics)
+	}
+
+	// Uses of a generic sentiment record the type they are used at
+	for index, expected := range []string{"Function[integer, integer, boolean]", "Function[text, text, boolean]"} {
+		head := goal.Sentments["main"].Definition.GetChild(index).GetChild(1).GetChild(0).GetValue()
+		if name := goal.TypeNameOf(head.TypeId); name != expected {
+			t.Errorf("use %d of smaller has type %v, expected %v", index, name, expected)
+		}
+	}
+}
+
+func TestInferGenericErrors(t *testing.T) {
+	goal := goalWithMain(
+		parent(intermediate.OPCODE_CALL, ref("sysout"), parent(intermediate.OPCODE_CALL, ref("smaller"), ref("args"), ref("args"))),
+	)
+	comparing(goal, "smaller", []intermediate.TypeParam{{Name: "a", Bounds: []string{"<="}}}, lessOrEqual())
+	comparing(goal, "loose", []intermediate.TypeParam{{Name: "a"}}, lessOrEqual())
+	comparing(goal, "outer", []intermediate.TypeParam{{Name: "a"}}, parent(intermediate.OPCODE_CALL, ref("smaller"), ref("x"), ref("y")))
+	comparing(goal, "unbound", []intermediate.TypeParam{{Name: "b", Bounds: []string{"<=>"}}}, lessOrEqual())
+
+	diagnostics := Infer([]intermediate.Goal{goal})
+	messages := make([]string, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		messages = append(messages, diagnostic.Message)
+	}
+
+	for _, fragment := range []string{
+		"type parameter b of unbound cannot be bound by unknown operator <=>",
+		"unbound uses type variable a without declaring it in [b]",
+		"type parameter b of unbound is never used",
+		"a does not support <=, declare [a supports <=] to require it",
+		"List[text] does not support <= required by a of smaller",
+		"a does not support <= required by a of smaller",
+	} {
+		found := false
+		for _, message := range messages {
+			found = found || strings.Contains(message, fragment)
+		}
+
+		if !found {
+			t.Errorf("expected %q in:\n%v", fragment, diagnostics)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package typing
+
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/constexpr"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/pattern"
+)
+
+type immutability struct {
+	constants   map[string]intermediate.Sentiment
+	scopes      []map[string]lexer.Position
+	captures    []lexer.Position
+	diagnostics diagnostic.Diagnostics
+}
+
+func constantsOf(goals ...intermediate.Goal) map[string]intermediate.Sentiment {
+	constants := make(map[string]intermediate.Sentiment)
+	for _, goal := range goals {
+		for name, sentiment := range goal.Sentments {
+			if constexpr.IsConstant(sentiment) {
+				constants[name] = sentiment
+			}
+		}
+	}
+
+	return constants
+}
+
+// checkImmutability enforces that names are bound once, never hide a
+// constant and that literals are only written in constant definitions
+// rather than inside captures
+func checkImmutability(sentiment intermediate.Sentiment, constants map[string]intermediate.Sentiment) diagnostic.Diagnostics {
+	state := &immutability{
+		constants:   constants,
+		diagnostics: diagnostic.Diagnostics{},
+	}
+
+	if sentiment.Definition == nil {
+		return state.diagnostics
+	}
+
+	state.pushScope()
+	if !constexpr.IsConstant(sentiment) {
+		for _, input := range sentiment.Inputs {
+			state.bind(input.Name, sentiment.Position)
+		}
+	}
+
+	if sentiment.Definition.GetValue().Op == intermediate.OPCODE_EVALS {
+		for _, clause := range sentiment.Definition.GetChildren() {
+			children := clause.GetChildren()
+			if len(children) == 0 {
+				continue
+			}
+
+			state.pushScope()
+			for _, param := range children[:len(children)-1] {
+				state.bindPattern(pattern.FromExpression(param))
+			}
+
+			state.check(children[len(children)-1])
+			state.popScope()
+		}
+	} else {
+		state.check(sentiment.Definition)
+	}
+
+	return state.diagnostics
+}
+
+func (state *immutability) pushScope() {
+	state.scopes = append(state.scopes, make(map[string]lexer.Position))
+}
+
+func (state *immutability) popScope() {
+	state.scopes = state.scopes[:len(state.scopes)-1]
+}
+
+func (state *immutability) bind(name string, position lexer.Position) {
+	if constant, isOk := state.constants[name]; isOk {
+		state.diagnostics.Errorf(position, "%v shadows the constant %v", name, name).
+			WithRelated(constant.Position, "%v is defined here", name)
+	}
+
+	for index := len(state.scopes) - 1; index >= 0; index-- {
+		if bound, isOk := state.scopes[index][name]; isOk {
+			state.diagnostics.Errorf(position, "%v is already bound and values cannot be rebound", name).
+				WithRelated(bound, "%v is first bound here", name)
+			return
+		}
+	}
+
+	state.scopes[len(state.scopes)-1][name] = position
+}
+
+func (state *immutability) bindPattern(param pattern.Pattern) {
+	if param.Kind == pattern.KIND_BINDING {
+		state.bind(param.Name, param.Position)
+	}
+
+	for _, arg := range param.Args {
+		state.bindPattern(arg)
+	}
+}
+
+func (state *immutability) check(tree expressionTree) {
+	expression := tree.GetValue()
+	children := tree.GetChildren()
+	switch expression.Op {
+	case intermediate.OPCODE_CONST:
+		if len(state.captures) > 0 {
+			state.diagnostics.Errorf(expression.Position, "literal %v is scoped inside a capture", expression.Value[0]).
+				WithRelated(state.captures[len(state.captures)-1], "the capture starts here, define the literal with @fact instead")
+		}
+	case intermediate.OPCODE_LABEL:
+		for _, child := range children {
+			state.check(child)
+		}
+
+		state.bind(expression.Value[0], expression.Position)
+		return
+	case intermediate.OPCODE_BLOCK:
+		state.pushScope()
+		defer state.popScope()
+	case intermediate.OPCODE_CAPTURE:
+		state.captures = append(state.captures, expression.Position)
+		state.pushScope()
+		for _, name := range expression.Value {
+			state.bind(name, expression.Position)
+		}
+
+		defer func() {
+			state.popScope()
+			state.captures = state.captures[:len(state.captures)-1]
+		}()
+	}
+
+	for _, child := range children {
+		state.check(child)
+	}
+}
@@ -0,0 +1,97 @@
+package typing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func at(tree node, line int) node {
+	expression := tree.GetValue()
+	expression.Position = lexer.Position{Filename: "test.dfl", Line: line}
+	tree.SetValue(expression)
+	return tree
+}
+
+func label(name string, line int, value node) node {
+	tree := at(leaf(intermediate.OPCODE_LABEL, intermediate.TYPEID_NO_TYPE, name), line)
+	container.AddChildren(tree, value)
+	return tree
+}
+
+func immutabilityOf(statements ...node) []string {
+	goal := goalWithMain(statements...)
+	goal.Sentments["LIMIT"] = intermediate.Sentiment{
+		Position:    lexer.Position{Filename: "test.dfl", Line: 1},
+		Annotations: []string{annotation.FACT_ANNOTATION},
+		Name:        "LIMIT",
+		Definition:  parent(intermediate.OPCODE_CONSTEXPR, leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_INTEGER, "3")),
+	}
+
+	messages := make([]string, 0, 2)
+	constants := constantsOf(goal)
+	for _, sentiment := range annotation.SentimentsOf(goal) {
+		for _, diagnostic := range checkImmutability(sentiment, constants) {
+			messages = append(messages, diagnostic.String())
+		}
+	}
+
+	return messages
+}
+
+func expectImmutability(t *testing.T, messages []string, fragments ...string) {
+	t.Helper()
+	if len(messages) != len(fragments) {
+		t.Fatalf("expected %d diagnostics got:\n%v", len(fragments), strings.Join(messages, "\n"))
+	}
+
+	for index, fragment := range fragments {
+		if !strings.Contains(messages[index], fragment) {
+			t.Errorf("expected %q in %q", fragment, messages[index])
+		}
+	}
+}
+
+func TestRebindingLabels(t *testing.T) {
+	expectImmutability(t, immutabilityOf(
+		label("total", 2, ref("args")),
+		parent(intermediate.OPCODE_BLOCK, label("total", 4, ref("args"))),
+	), "test.dfl:4:0: error: total is already bound and values cannot be rebound\n\ttest.dfl:2:0: note: total is first bound here")
+
+	expectImmutability(t, immutabilityOf(
+		label("args", 3, ref("args")),
+	), "args is already bound")
+
+	// Sibling blocks never see each other's names
+	expectImmutability(t, immutabilityOf(
+		parent(intermediate.OPCODE_BLOCK, label("total", 2, ref("args"))),
+		parent(intermediate.OPCODE_BLOCK, label("total", 3, ref("args"))),
+	))
+}
+
+func TestShadowingConstants(t *testing.T) {
+	capture := at(leaf(intermediate.OPCODE_CAPTURE, intermediate.TYPEID_NO_TYPE, "LIMIT"), 5)
+	container.AddChildren(capture, parent(intermediate.OPCODE_BLOCK))
+
+	expectImmutability(t, immutabilityOf(
+		label("LIMIT", 2, ref("args")),
+		capture,
+	),
+		"test.dfl:2:0: error: LIMIT shadows the constant LIMIT\n\ttest.dfl:1:0: note: LIMIT is defined here",
+		"test.dfl:5:0: error: LIMIT shadows the constant LIMIT",
+		"LIMIT is already bound",
+	)
+}
+
+func TestLiteralsInCaptures(t *testing.T) {
+	capture := at(leaf(intermediate.OPCODE_CAPTURE, intermediate.TYPEID_NO_TYPE), 2)
+	container.AddChildren(capture, at(leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_INTEGER, "7"), 3))
+
+	expectImmutability(t, immutabilityOf(capture),
+		"test.dfl:3:0: error: literal 7 is scoped inside a capture\n\ttest.dfl:2:0: note: the capture starts here",
+	)
+}
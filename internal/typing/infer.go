@@ -0,0 +1,687 @@
+package typing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/constexpr"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/pattern"
+	"github.com/tflexsoom/duffle/internal/stdlib"
+	"github.com/tflexsoom/duffle/internal/types"
+)
+
+type expressionTree = container.Tree[intermediate.SenimentExpression]
+
+type candidate struct {
+	kind      string
+	target    string
+	signature types.Signature
+	constant  *intermediate.Literal
+}
+
+// binding is the type of a local name. Thunks are block captures of no
+// inputs bound to a name, which like functions of no inputs are called where
+// the name is referenced.
+type binding struct {
+	t       types.Type
+	isThunk bool
+}
+
+type typedTree struct {
+	goal intermediate.Goal
+	tree expressionTree
+	t    types.Type
+}
+
+type program struct {
+	goals       []intermediate.Goal
+	sentiments  map[string]int
+	signatures  map[string]types.Signature
+	typeParams  map[string][]intermediate.TypeParam
+	captures    []typedTree
+	instances   []typedTree
+	constraints []constraint
+	fresh       int
+}
+
+type inference struct {
+	program      *program
+	goal         intermediate.Goal
+	substitution types.Substitution
+	diagnostics  *diagnostic.Diagnostics
+	scopes       []map[string]binding
+	results      []types.Type
+	returned     []bool
+	bounds       map[string][]string
+}
+
+// IsFunction reports sentiments that become functions of the program rather
+// than constants or import bindings
+func IsFunction(sentiment intermediate.Sentiment) bool {
+	return sentiment.Definition != nil && !constexpr.IsConstant(sentiment) && !annotation.IsUse(sentiment)
+}
+
+// Infer checks the types of every function of a program. References are
+// resolved in place so later passes know what each name stands for.
+func Infer(goals []intermediate.Goal) diagnostic.Diagnostics {
+	diagnostics := diagnostic.Diagnostics{}
+	state := &program{
+		goals:      goals,
+		sentiments: make(map[string]int),
+		signatures: make(map[string]types.Signature),
+		typeParams: make(map[string][]intermediate.TypeParam),
+	}
+
+	names := make([]string, 0, 16)
+	for index, goal := range goals {
+		for name, sentiment := range goal.Sentments {
+			if !IsFunction(sentiment) {
+				continue
+			}
+
+			if _, exists := state.sentiments[name]; !exists {
+				names = append(names, name)
+			}
+
+			state.sentiments[name] = index
+			state.signatures[name] = state.signatureOf(goal, sentiment, &diagnostics)
+			state.typeParams[name] = TypeParamsOf(goal, sentiment)
+			checkTypeParams(goal, sentiment, &diagnostics)
+		}
+	}
+
+	sort.Strings(names)
+	substitution := types.Substitution{}
+	for _, name := range names {
+		goal := goals[state.sentiments[name]]
+		inference := &inference{
+			program:      state,
+			goal:         goal,
+			substitution: substitution,
+			diagnostics:  &diagnostics,
+		}
+
+		inference.inferSentiment(goal.Sentments[name])
+	}
+
+	state.checkConstraints(substitution, &diagnostics)
+	state.annotate(names, substitution)
+	return diagnostics
+}
+
+// annotate records the inferred function types of sentiments and captures on
+// their trees, so function values keep a Function[...] type past inference.
+// Uses of generic sentiments record the type they are used at.
+func (state *program) annotate(names []string, substitution types.Substitution) {
+	for _, name := range names {
+		goal := state.goals[state.sentiments[name]]
+		definition := goal.Sentments[name].Definition
+		expression := definition.GetValue()
+		expression.TypeId = goal.TypeIdOf(types.Generalize(substitution.Apply(state.signatures[name].Type())).String())
+		definition.SetValue(expression)
+	}
+
+	for _, typed := range append(state.captures, state.instances...) {
+		expression := typed.tree.GetValue()
+		expression.TypeId = typed.goal.TypeIdOf(types.Generalize(substitution.Apply(typed.t)).String())
+		typed.tree.SetValue(expression)
+	}
+}
+
+func (state *program) freshVariable() types.Type {
+	state.fresh++
+	return types.Variable(fmt.Sprintf("'%d", state.fresh))
+}
+
+func (state *program) instantiate(signature types.Signature) (types.Signature, string) {
+	state.fresh++
+	suffix := fmt.Sprintf("#%d", state.fresh)
+	instance := types.Signature{Name: signature.Name, Result: types.Fresh(signature.Result, suffix)}
+	for _, param := range signature.Params {
+		instance.Params = append(instance.Params, types.Param{Name: param.Name, Type: types.Fresh(param.Type, suffix)})
+	}
+
+	return instance, suffix
+}
+
+func (state *program) typeOf(goal intermediate.Goal, typeId intermediate.TypeId, position lexer.Position, diagnostics *diagnostic.Diagnostics) types.Type {
+	name := goal.TypeNameOf(typeId)
+	if typeId == intermediate.TYPEID_NO_TYPE || name == "" {
+		return state.freshVariable()
+	} else if typeId == intermediate.TYPEID_LIST {
+		return types.List(state.freshVariable())
+	}
+
+	parsed, err := types.Parse(name)
+	if err != nil {
+		diagnostics.Errorf(position, "%v", err)
+		return state.freshVariable()
+	}
+
+	return parsed
+}
+
+// Undeclared inputs and results are inferred from the body and the uses of
+// the sentiment, so they are shared rather than generic
+func (state *program) signatureOf(goal intermediate.Goal, sentiment intermediate.Sentiment, diagnostics *diagnostic.Diagnostics) types.Signature {
+	signature := types.Signature{
+		Name:   sentiment.Name,
+		Params: make([]types.Param, 0, len(sentiment.Inputs)),
+		Result: state.typeOf(goal, sentiment.Result, sentiment.Position, diagnostics),
+	}
+
+	for _, input := range sentiment.Inputs {
+		signature.Params = append(signature.Params, types.Param{
+			Name: input.Name,
+			Type: state.typeOf(goal, input.TypeId, sentiment.Position, diagnostics),
+		})
+	}
+
+	if len(signature.Params) == 0 && sentiment.Definition.GetValue().Op == intermediate.OPCODE_EVALS {
+		clauses := pattern.ClausesOf(sentiment.Definition)
+		if len(clauses) > 0 {
+			for index := range clauses[0].Params {
+				signature.Params = append(signature.Params, types.Param{
+					Name: fmt.Sprintf("$%d", index),
+					Type: state.freshVariable(),
+				})
+			}
+		}
+	}
+
+	return signature
+}
+
+func typeOfConst(typeId intermediate.TypeId) types.Type {
+	return types.Of(intermediate.Literal{Type: typeId})
+}
+
+func (state *inference) unify(position lexer.Position, expected types.Type, actual types.Type, context string) bool {
+	if err := state.substitution.Unify(expected, actual); err != nil {
+		state.diagnostics.Errorf(position, "%v: %v", context, err)
+		return false
+	}
+
+	return true
+}
+
+func (state *inference) pushScope() {
+	state.scopes = append(state.scopes, make(map[string]binding))
+}
+
+func (state *inference) popScope() {
+	state.scopes = state.scopes[:len(state.scopes)-1]
+}
+
+func (state *inference) bind(name string, t types.Type) {
+	state.scopes[len(state.scopes)-1][name] = binding{t: t}
+}
+
+func (state *inference) bindThunk(name string, t types.Type) {
+	state.scopes[len(state.scopes)-1][name] = binding{t: t, isThunk: true}
+}
+
+func (state *inference) lookup(name string) (binding, bool) {
+	for i := len(state.scopes) - 1; i >= 0; i-- {
+		if bound, isOk := state.scopes[i][name]; isOk {
+			return bound, true
+		}
+	}
+
+	return binding{}, false
+}
+
+func (state *inference) local(name string) (types.Type, bool) {
+	bound, isOk := state.lookup(name)
+	return bound.t, isOk
+}
+
+func isThunk(tree expressionTree) bool {
+	expression := tree.GetValue()
+	return expression.Op == intermediate.OPCODE_CAPTURE && len(expression.Value) == 0 &&
+		len(tree.GetChildren()) == 1 && tree.GetChild(0).GetValue().Op == intermediate.OPCODE_BLOCK
+}
+
+func resolveReference(tree expressionTree, kind string, target string) {
+	expression := tree.GetValue()
+	expression.Value = []string{expression.Value[0], kind, target}
+	tree.SetValue(expression)
+}
+
+func (state *inference) inferSentiment(sentiment intermediate.Sentiment) {
+	signature := state.program.signatures[sentiment.Name]
+	result := types.Rigid(signature.Result)
+	state.results = append(state.results, result)
+	state.returned = append(state.returned, false)
+	state.bounds = boundsOf(state.program.typeParams[sentiment.Name])
+	state.pushScope()
+	for _, param := range signature.Params {
+		state.bind(param.Name, types.Rigid(param.Type))
+	}
+
+	definition := sentiment.Definition
+	switch definition.GetValue().Op {
+	case intermediate.OPCODE_CONSTEXPR:
+		if children := definition.GetChildren(); len(children) > 0 {
+			state.unify(sentiment.Position, result, state.infer(children[0]), "result of "+sentiment.Name)
+		}
+	case intermediate.OPCODE_EVALS:
+		state.inferEvals(sentiment, signature, definition)
+	default:
+		state.infer(definition)
+		if !state.returned[len(state.returned)-1] {
+			state.unify(sentiment.Position, result, types.Nothing, sentiment.Name+" never returns a value")
+		}
+	}
+
+	state.popScope()
+	state.results = state.results[:len(state.results)-1]
+	state.returned = state.returned[:len(state.returned)-1]
+}
+
+func (state *inference) inferEvals(sentiment intermediate.Sentiment, signature types.Signature, evals expressionTree) {
+	clauses := pattern.ClausesOf(evals)
+	clauseTrees := evals.GetChildren()
+	for index, clause := range clauses {
+		state.pushScope()
+		for column, param := range clause.Params {
+			if column < len(signature.Params) {
+				state.bindPattern(param, types.Rigid(signature.Params[column].Type))
+			}
+		}
+
+		children := clauseTrees[index].GetChildren()
+		body := state.infer(children[len(children)-1])
+		state.unify(clause.Position, state.results[len(state.results)-1], body, "result of "+sentiment.Name)
+		state.popScope()
+	}
+}
+
+func (state *inference) bindPattern(param pattern.Pattern, expected types.Type) {
+	switch param.Kind {
+	case pattern.KIND_BINDING:
+		state.bind(param.Name, expected)
+	case pattern.KIND_LITERAL:
+		state.unify(param.Position, expected, typeOfConst(param.TypeId), "pattern "+param.Name)
+	case pattern.KIND_CONSTRUCTOR:
+		switch param.Name {
+		case "true", "false":
+			state.unify(param.Position, expected, types.Boolean, "pattern "+param.Name)
+		case intermediate.LIST_EMPTY_CONSTRUCTOR:
+			state.unify(param.Position, expected, types.List(state.program.freshVariable()), "pattern []")
+		case intermediate.LIST_CONS_CONSTRUCTOR:
+			element := state.program.freshVariable()
+			state.unify(param.Position, expected, types.List(element), "list pattern")
+			state.bindPattern(param.Args[0], element)
+			state.bindPattern(param.Args[1], types.List(element))
+		default:
+			if !state.bindStructPattern(param, expected) {
+				state.diagnostics.Errorf(param.Position, "unknown constructor %v", param.Name)
+			}
+		}
+	}
+}
+
+func (state *inference) infer(tree expressionTree) types.Type {
+	expression := tree.GetValue()
+	children := tree.GetChildren()
+	switch expression.Op {
+	case intermediate.OPCODE_CONST:
+		return typeOfConst(expression.TypeId)
+	case intermediate.OPCODE_REF:
+		return state.inferReference(tree)
+	case intermediate.OPCODE_CALL:
+		return state.inferCall(tree)
+	case intermediate.OPCODE_CHAIN:
+		return state.inferChain(tree)
+	case intermediate.OPCODE_CAPTURE:
+		return state.inferCapture(tree)
+	case intermediate.OPCODE_LABEL:
+		if isThunk(children[0]) {
+			state.bindThunk(expression.Value[0], state.infer(children[0]))
+		} else {
+			state.bind(expression.Value[0], state.infer(children[0]))
+		}
+	case intermediate.OPCODE_IF:
+		condition := state.infer(children[0])
+		state.unify(children[0].GetValue().Position, types.Boolean, condition, "condition")
+		for _, branch := range children[1:] {
+			state.infer(branch)
+		}
+	case intermediate.OPCODE_BLOCK:
+		state.pushScope()
+		for _, child := range children {
+			state.infer(child)
+		}
+		state.popScope()
+	case intermediate.OPCODE_RETURN:
+		value := types.Nothing
+		if len(children) > 0 {
+			value = state.infer(children[0])
+		}
+
+		state.returned[len(state.returned)-1] = true
+		state.unify(expression.Position, state.results[len(state.results)-1], value, "returned value")
+	case intermediate.OPCODE_NOOP:
+	default:
+		state.diagnostics.Errorf(expression.Position, "expression cannot be type checked")
+	}
+
+	return types.Nothing
+}
+
+// candidates lists what a name may stand for in order of preference:
+// constants, functions of the program, structs, imported members and then
+// the prelude
+func (state *inference) candidates(name string) []candidate {
+	result := make([]candidate, 0, 2)
+	if literal, isOk := state.goal.Constants[name]; isOk {
+		return append(result, candidate{kind: intermediate.REFERENCE_CONSTANT, target: name, constant: &literal})
+	}
+
+	for _, goal := range state.program.goals {
+		if literal, isOk := goal.Constants[name]; isOk {
+			return append(result, candidate{kind: intermediate.REFERENCE_CONSTANT, target: name, constant: &literal})
+		}
+	}
+
+	if signature, isOk := state.program.signatures[name]; isOk {
+		result = append(result, candidate{kind: intermediate.REFERENCE_SENTIMENT, target: name, signature: signature})
+	}
+
+	if goal, structure, isOk := state.program.structOf(name); isOk {
+		result = append(result, candidate{kind: intermediate.REFERENCE_STRUCT, target: name, signature: state.constructorOf(goal, structure)})
+	}
+
+	for _, imported := range state.goal.Imports {
+		if imported.Alias != name {
+			continue
+		}
+
+		if member, isOk := stdlib.Lookup(imported.Path); isOk {
+			for _, signature := range member.Signatures {
+				result = append(result, candidate{kind: intermediate.REFERENCE_STANDARD, target: member.Path(), signature: signature})
+			}
+		} else if signature, isOk := state.program.signatures[imported.Path[len(imported.Path)-1]]; isOk && len(result) == 0 {
+			result = append(result, candidate{kind: intermediate.REFERENCE_SENTIMENT, target: signature.Name, signature: signature})
+		}
+	}
+
+	if member, isOk := stdlib.Prelude(name); isOk && len(result) == 0 {
+		for _, signature := range member.Signatures {
+			result = append(result, candidate{kind: intermediate.REFERENCE_STANDARD, target: member.Path(), signature: signature})
+		}
+	}
+
+	return result
+}
+
+func (state *inference) inferReference(tree expressionTree) types.Type {
+	expression := tree.GetValue()
+	name := expression.Value[0]
+	if bound, isOk := state.lookup(name); isOk && bound.isThunk {
+		resolveReference(tree, intermediate.REFERENCE_THUNK, name)
+		return bound.t.Result()
+	} else if isOk {
+		resolveReference(tree, intermediate.REFERENCE_LOCAL, name)
+		return bound.t
+	}
+
+	candidates := state.candidates(name)
+	if len(candidates) == 0 {
+		state.diagnostics.Errorf(expression.Position, "unknown name %v", name)
+		return state.program.freshVariable()
+	}
+
+	chosen := candidates[0]
+	resolveReference(tree, chosen.kind, chosen.target)
+	if chosen.constant != nil {
+		return types.Of(*chosen.constant)
+	}
+
+	// Functions without inputs are called where they are referenced
+	instance, suffix := state.program.instantiate(chosen.signature)
+	t := instance.Type()
+	if len(instance.Params) == 0 {
+		t = instance.Result
+	}
+
+	if chosen.kind == intermediate.REFERENCE_SENTIMENT {
+		state.instantiated(tree, chosen.target, suffix, t)
+	}
+
+	return t
+}
+
+func (state *inference) inferCall(tree expressionTree) types.Type {
+	children := tree.GetChildren()
+	head := children[0]
+	args := make([]types.Type, 0, len(children)-1)
+	for _, child := range children[1:] {
+		args = append(args, state.infer(child))
+	}
+
+	expression := head.GetValue()
+	if expression.Op == intermediate.OPCODE_REF {
+		if _, isLocal := state.local(expression.Value[0]); !isLocal {
+			return state.inferNamedCall(head, args)
+		}
+	}
+
+	return state.apply(expression.Position, state.infer(head), args)
+}
+
+func (state *inference) apply(position lexer.Position, function types.Type, args []types.Type) types.Type {
+	result := state.program.freshVariable()
+	state.unify(position, types.Function(args, result), function, "call")
+	return result
+}
+
+func formatTypes(substitution types.Substitution, list []types.Type) string {
+	formatted := make([]string, 0, len(list))
+	for _, t := range list {
+		formatted = append(formatted, substitution.Apply(t).String())
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+// inferNamedCall picks the first definition of a name whose inputs accept
+// the arguments, which is how imported members and local functions overload
+func (state *inference) inferNamedCall(head expressionTree, args []types.Type) types.Type {
+	expression := head.GetValue()
+	name := expression.Value[0]
+	candidates := state.candidates(name)
+	if len(candidates) == 0 {
+		state.diagnostics.Errorf(expression.Position, "unknown name %v", name)
+		return state.program.freshVariable()
+	}
+
+	for _, option := range candidates {
+		if option.constant != nil {
+			resolveReference(head, option.kind, option.target)
+			return state.apply(expression.Position, types.Of(*option.constant), args)
+		}
+
+		instance, suffix := state.program.instantiate(option.signature)
+		if len(instance.Params) != len(args) {
+			continue
+		}
+
+		trial := make(types.Substitution, len(state.substitution))
+		for variable, bound := range state.substitution {
+			trial[variable] = bound
+		}
+
+		accepted := true
+		for index, param := range instance.Params {
+			if err := trial.Unify(param.Type, args[index]); err != nil {
+				accepted = false
+				break
+			}
+		}
+
+		if accepted {
+			for variable, bound := range trial {
+				state.substitution[variable] = bound
+			}
+
+			resolveReference(head, option.kind, option.target)
+			if option.kind == intermediate.REFERENCE_SENTIMENT {
+				state.instantiated(head, option.target, suffix, instance.Type())
+			}
+
+			return instance.Result
+		}
+	}
+
+	diagnostic := state.diagnostics.Errorf(
+		expression.Position,
+		"no definition of %v accepts (%v)",
+		name,
+		formatTypes(state.substitution, args),
+	)
+	for _, option := range candidates {
+		if option.constant == nil {
+			diagnostic.WithRelated(expression.Position, "candidate %v", option.signature)
+		}
+	}
+
+	return state.program.freshVariable()
+}
+
+func isArithmetic(operator string) bool {
+	return operator == "+" || operator == "-" || operator == "*" || operator == "/" || operator == "%"
+}
+
+func isNumeric(t types.Type) bool {
+	return t.Equal(types.Integer) || t.Equal(types.Decimal)
+}
+
+// Chains apply their operators from left to right. The right operand of
+// the field operator names a field rather than a value.
+func (state *inference) inferChain(tree expressionTree) types.Type {
+	children := tree.GetChildren()
+	start := 1
+	var result types.Type
+	if len(children) > 2 && isFieldOperator(children[1]) && state.projected(children[0]) {
+		result = state.projection(children[0], children[2])
+		start = 3
+	} else {
+		result = state.infer(children[0])
+	}
+
+	for index := start; index+1 < len(children); index += 2 {
+		if isFieldOperator(children[index]) {
+			result = state.field(result, children[index+1])
+			continue
+		}
+
+		operator := children[index].GetValue()
+		right := state.infer(children[index+1])
+		result = state.inferOperator(operator.Value[0], operator.Position, result, right)
+	}
+
+	return result
+}
+
+func (state *inference) inferOperator(operator string, position lexer.Position, left types.Type, right types.Type) types.Type {
+	left, right = state.substitution.Apply(left), state.substitution.Apply(right)
+	switch {
+	case isArithmetic(operator):
+		if left.IsRigid() || right.IsRigid() {
+			state.unify(position, left, right, "operator "+operator)
+			if !state.supports(left, operator) {
+				state.unsupported(position, left, operator)
+			}
+
+			return left
+		} else if left.Variable || right.Variable {
+			state.unify(position, left, right, "operator "+operator)
+			return state.substitution.Apply(left)
+		} else if left.Equal(types.Integer) && right.Equal(types.Integer) {
+			return types.Integer
+		} else if isNumeric(left) && isNumeric(right) {
+			return types.Decimal
+		} else if operator == "+" && left.Equal(types.Text) && right.Equal(types.Text) {
+			return types.Text
+		}
+	case operator == "=" || operator == "!=" || operator == "<" || operator == ">" || operator == "<=" || operator == ">=":
+		if !isNumeric(left) || !isNumeric(right) {
+			state.unify(position, left, right, "operator "+operator)
+		}
+
+		if operand := state.substitution.Apply(left); !operand.Variable && !state.supports(operand, operator) {
+			state.unsupported(position, operand, operator)
+		}
+
+		return types.Boolean
+	case operator == "&" || operator == "|":
+		state.unify(position, types.Boolean, left, "operator "+operator)
+		state.unify(position, types.Boolean, right, "operator "+operator)
+		return types.Boolean
+	default:
+		state.diagnostics.Errorf(position, "unknown operator %v", operator)
+		return state.program.freshVariable()
+	}
+
+	state.diagnostics.Errorf(position, "operator %v cannot be applied to %v and %v", operator, left, right)
+	return state.program.freshVariable()
+}
+
+// Inline captures are functions of no inputs returning their expression,
+// block captures name their inputs and return like any function body
+func (state *inference) inferCapture(tree expressionTree) types.Type {
+	expression := tree.GetValue()
+	body := tree.GetChild(0)
+	if body.GetValue().Op != intermediate.OPCODE_BLOCK {
+		captured := types.Function(nil, state.infer(body))
+		state.program.captures = append(state.program.captures, typedTree{state.goal, tree, captured})
+		return captured
+	}
+
+	params := make([]types.Type, 0, len(expression.Value))
+	result := state.program.freshVariable()
+	declared := types.Type{}
+	if expression.TypeId != intermediate.TYPEID_NO_TYPE {
+		declared = types.Rigid(state.program.typeOf(state.goal, expression.TypeId, expression.Position, state.diagnostics))
+		if declared.IsFunction() && len(declared.Params()) == len(expression.Value) {
+			result = declared.Result()
+		}
+	}
+
+	state.results = append(state.results, result)
+	state.returned = append(state.returned, false)
+	state.pushScope()
+	for index, name := range expression.Value {
+		param := state.program.freshVariable()
+		if declared.IsFunction() && index < len(declared.Params()) {
+			param = declared.Params()[index]
+		}
+
+		params = append(params, param)
+		state.bind(name, param)
+	}
+
+	state.infer(body)
+	if !state.returned[len(state.returned)-1] {
+		state.unify(expression.Position, result, types.Nothing, "capture")
+	}
+
+	state.popScope()
+	state.results = state.results[:len(state.results)-1]
+	state.returned = state.returned[:len(state.returned)-1]
+	captured := types.Function(params, result)
+	if expression.TypeId != intermediate.TYPEID_NO_TYPE {
+		state.unify(expression.Position, declared, captured, "capture")
+	}
+
+	state.program.captures = append(state.program.captures, typedTree{state.goal, tree, captured})
+	return captured
+}
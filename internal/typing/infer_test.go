@@ -0,0 +1,109 @@
+package typing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+type node = container.Tree[intermediate.SenimentExpression]
+
+func leaf(op intermediate.OpCode, typeId intermediate.TypeId, value ...string) node {
+	return intermediate.NewExpressionTree(intermediate.SenimentExpression{Op: op, TypeId: typeId, Value: value})
+}
+
+func parent(op intermediate.OpCode, children ...node) node {
+	tree := leaf(op, intermediate.TYPEID_NO_TYPE)
+	for _, child := range children {
+		container.AddChildren(tree, child)
+	}
+
+	return tree
+}
+
+func ref(name string) node {
+	return leaf(intermediate.OPCODE_REF, intermediate.TYPEID_NO_TYPE, name)
+}
+
+func goalWithMain(statements ...node) intermediate.Goal {
+	goal := intermediate.NewGoal()
+	for _, name := range []string{"sysout", "length", "text2Number"} {
+		goal.Imports = append(goal.Imports, intermediate.Import{Alias: name, Path: []string{"dfl", name}})
+	}
+
+	goal.Sentments["main"] = intermediate.Sentiment{
+		Annotations: []string{annotation.EXEC_ANNOTATION},
+		Name:        "main",
+		Inputs:      []intermediate.SentimentInput{{Name: "args", TypeId: goal.TypeIdOf("List[text]")}},
+		Definition:  parent(intermediate.OPCODE_BLOCK, statements...),
+	}
+
+	return goal
+}
+
+func TestInferResolvesReferences(t *testing.T) {
+	goal := goalWithMain(parent(intermediate.OPCODE_CALL, ref("sysout"),
+		parent(intermediate.OPCODE_CALL, ref("length"), ref("args")),
+	))
+	if diagnostics := Infer([]intermediate.Goal{goal}); len(diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics:\n%v", diagnostics)
+	}
+
+	// Children are copied into their parents, so look the call up again
+	length := goal.Sentments["main"].Definition.GetChild(0).GetChild(1)
+
+	head := length.GetChild(0).GetValue()
+	if len(head.Value) != 3 || head.Value[1] != intermediate.REFERENCE_STANDARD || head.Value[2] != "dfl.length" {
+		t.Errorf("length resolved to %v", head.Value)
+	}
+
+	if arg := length.GetChild(1).GetValue(); len(arg.Value) != 3 || arg.Value[1] != intermediate.REFERENCE_LOCAL {
+		t.Errorf("args resolved to %v", arg.Value)
+	}
+}
+
+func TestInferErrors(t *testing.T) {
+	goal := goalWithMain(
+		parent(intermediate.OPCODE_CALL, ref("text2Number"), ref("args")),
+		parent(intermediate.OPCODE_CALL, ref("missing")),
+	)
+
+	diagnostics := Infer([]intermediate.Goal{goal})
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics got:\n%v", diagnostics)
+	}
+
+	for index, fragment := range []string{"no definition of text2Number accepts (List[text])", "unknown name missing"} {
+		if !strings.Contains(diagnostics[index].Message, fragment) {
+			t.Errorf("expected %q in %q", fragment, diagnostics[index].Message)
+		}
+	}
+}
+
+func TestInferPrelude(t *testing.T) {
+	goal := goalWithMain(
+		parent(intermediate.OPCODE_CALL, ref("tail"), ref("args")),
+		parent(intermediate.OPCODE_CALL, ref("head"), ref("args")),
+	)
+	goal.Sentments["head"] = intermediate.Sentiment{
+		Name:       "head",
+		Inputs:     []intermediate.SentimentInput{{Name: "items", TypeId: goal.TypeIdOf("List[text]")}},
+		Definition: parent(intermediate.OPCODE_BLOCK),
+	}
+
+	if diagnostics := Infer([]intermediate.Goal{goal}); len(diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics:\n%v", diagnostics)
+	}
+
+	definition := goal.Sentments["main"].Definition
+	if tail := definition.GetChild(0).GetChild(0).GetValue(); len(tail.Value) != 3 || tail.Value[2] != "dfl.tail" {
+		t.Errorf("expected tail to come from the prelude without an import, got %v", tail.Value)
+	}
+
+	if head := definition.GetChild(1).GetChild(0).GetValue(); len(head.Value) != 3 || head.Value[1] != intermediate.REFERENCE_SENTIMENT {
+		t.Errorf("expected the program's head to hide the prelude, got %v", head.Value)
+	}
+}
@@ -0,0 +1,87 @@
+package typing
+
+import (
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/pattern"
+)
+
+func checkPatterns(sentiment intermediate.Sentiment) diagnostic.Diagnostics {
+	diagnostics := diagnostic.Diagnostics{}
+	if sentiment.Definition == nil || sentiment.Definition.GetValue().Op != intermediate.OPCODE_EVALS {
+		return diagnostics
+	}
+
+	clauses := pattern.ClausesOf(sentiment.Definition)
+	signatures := pattern.DefaultSignatures()
+	diagnostics.Extend(checkArity(sentiment, clauses, signatures))
+	if diagnostics.HasErrors() {
+		return diagnostics
+	}
+
+	report := signatures.Check(clauses)
+
+	for _, index := range report.Unreachable {
+		warning := diagnostics.Warnf(
+			clauses[index].Position,
+			"unreachable clause %v %v",
+			sentiment.Name,
+			pattern.FormatParams(clauses[index].Params),
+		)
+
+		if index > 0 {
+			warning.WithRelated(clauses[0].Position, "earlier clauses already match every value it matches")
+		}
+	}
+
+	if !report.Exhaustive {
+		diagnostics.Warnf(
+			sentiment.Position,
+			"non-exhaustive patterns in %v: %v %v is not matched",
+			sentiment.Name,
+			sentiment.Name,
+			pattern.FormatParams(report.Missing),
+		)
+	}
+
+	return diagnostics
+}
+
+// checkArity reports the clauses and constructor patterns that disagree on
+// their number of inputs, which the usefulness check cannot compare
+func checkArity(sentiment intermediate.Sentiment, clauses []pattern.Clause, signatures pattern.Signatures) diagnostic.Diagnostics {
+	diagnostics := diagnostic.Diagnostics{}
+	if len(clauses) == 0 {
+		return diagnostics
+	}
+
+	for _, clause := range clauses[1:] {
+		if len(clause.Params) != len(clauses[0].Params) {
+			diagnostics.Errorf(
+				clause.Position,
+				"clause %v %v takes %d inputs, not the %d of the first clause",
+				sentiment.Name,
+				pattern.FormatParams(clause.Params),
+				len(clause.Params),
+				len(clauses[0].Params),
+			).WithRelated(clauses[0].Position, "the first clause is here")
+		}
+	}
+
+	for _, mismatch := range signatures.Mismatches(clauses) {
+		err := diagnostics.Errorf(
+			mismatch.Pattern.Position,
+			"constructor %v takes %d arguments, but %v gives it %d",
+			mismatch.Pattern.Name,
+			mismatch.Arity,
+			mismatch.Pattern,
+			len(mismatch.Pattern.Args),
+		)
+
+		if mismatch.First != nil {
+			err.WithRelated(mismatch.First.Position, "%v is first used with %d arguments here", mismatch.First.Name, mismatch.Arity)
+		}
+	}
+
+	return diagnostics
+}
@@ -0,0 +1,50 @@
+package typing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func clause(line int, params ...node) node {
+	return at(parent(intermediate.OPCODE_CLAUSE, append(params, parent(intermediate.OPCODE_BLOCK))...), line)
+}
+
+func constructorPattern(line int, name string, args ...node) node {
+	tree := at(leaf(intermediate.OPCODE_PATTERN_CONSTRUCTOR, intermediate.TYPEID_NO_TYPE, name), line)
+	for _, arg := range args {
+		container.AddChildren(tree, arg)
+	}
+
+	return tree
+}
+
+func TestPatternsOfMixedArity(t *testing.T) {
+	bind := func(name string) node {
+		return leaf(intermediate.OPCODE_PATTERN_BIND, intermediate.TYPEID_NO_TYPE, name)
+	}
+
+	diagnostics := checkPatterns(intermediate.Sentiment{
+		Name: "first",
+		Definition: parent(intermediate.OPCODE_EVALS,
+			clause(1, constructorPattern(1, "P", bind("a"))),
+			clause(2, constructorPattern(2, "P", bind("a"), bind("b"))),
+			clause(3, bind("a"), bind("b")),
+		),
+	})
+
+	if !diagnostics.HasErrors() || len(diagnostics) != 2 {
+		t.Fatalf("expected 2 errors, got:\n%v", diagnostics)
+	}
+
+	for index, fragment := range []string{
+		"test.dfl:3:0: error: clause first a b takes 2 inputs, not the 1 of the first clause",
+		"test.dfl:2:0: error: constructor P takes 1 arguments, but (P a b) gives it 2",
+	} {
+		if !strings.Contains(diagnostics[index].String(), fragment) {
+			t.Errorf("expected %q in %q", fragment, diagnostics[index])
+		}
+	}
+}
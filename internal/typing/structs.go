@@ -0,0 +1,112 @@
+package typing
+
+import (
+	"strconv"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/pattern"
+	"github.com/tflexsoom/duffle/internal/types"
+)
+
+func (state *program) structOf(name string) (intermediate.Goal, intermediate.Struct, bool) {
+	for _, goal := range state.goals {
+		if structure, isOk := goal.Structs[name]; isOk {
+			return goal, structure, true
+		}
+	}
+
+	return intermediate.Goal{}, intermediate.Struct{}, false
+}
+
+func (state *inference) fieldTypeOf(goal intermediate.Goal, structure intermediate.Struct, index int) types.Type {
+	field := structure.Fields[index]
+	return state.program.typeOf(goal, field.TypeId, structure.Position, state.diagnostics)
+}
+
+// Structs are built by calling them with every field in order
+func (state *inference) constructorOf(goal intermediate.Goal, structure intermediate.Struct) types.Signature {
+	signature := types.Signature{Name: structure.Name, Result: types.Named(structure.Name)}
+	for index, field := range structure.Fields {
+		signature.Params = append(signature.Params, types.Param{Name: field.Name, Type: state.fieldTypeOf(goal, structure, index)})
+	}
+
+	return signature
+}
+
+func isFieldOperator(tree expressionTree) bool {
+	expression := tree.GetValue()
+	return expression.Op == intermediate.OPCODE_OPERATOR && expression.Value[0] == intermediate.FIELD_OPERATOR
+}
+
+// projected tells whether the left operand of a field operator names a
+// struct rather than holding a struct value
+func (state *inference) projected(tree expressionTree) bool {
+	expression := tree.GetValue()
+	if expression.Op != intermediate.OPCODE_REF {
+		return false
+	} else if _, isLocal := state.local(expression.Value[0]); isLocal {
+		return false
+	}
+
+	_, _, isStruct := state.program.structOf(expression.Value[0])
+	return isStruct
+}
+
+// field resolves the right operand of a field operator to the index of the
+// field in the struct and gives its type
+func (state *inference) field(structType types.Type, tree expressionTree) types.Type {
+	expression := tree.GetValue()
+	structType = state.substitution.Apply(structType)
+	if expression.Op != intermediate.OPCODE_REF {
+		state.diagnostics.Errorf(expression.Position, "the field operator expects a field name on its right")
+		return state.program.freshVariable()
+	} else if structType.Variable {
+		state.diagnostics.Errorf(expression.Position, "field %v cannot be read before the type of the struct is known", expression.Value[0])
+		return state.program.freshVariable()
+	}
+
+	goal, structure, isStruct := state.program.structOf(structType.Name)
+	if !isStruct || len(structType.Args) > 0 {
+		state.diagnostics.Errorf(expression.Position, "field %v cannot be read from %v, which is not a struct", expression.Value[0], structType)
+		return state.program.freshVariable()
+	}
+
+	index := structure.FieldIndex(expression.Value[0])
+	if index < 0 {
+		state.diagnostics.Errorf(expression.Position, "%v has no field %v", structure.Name, expression.Value[0]).
+			WithRelated(structure.Position, "%v is defined here", structure.Name)
+		return state.program.freshVariable()
+	}
+
+	resolveReference(tree, intermediate.REFERENCE_FIELD, strconv.Itoa(index))
+	return state.fieldTypeOf(goal, structure, index)
+}
+
+// projection types (Student . Name) as the function reading Name from a
+// Student
+func (state *inference) projection(structure expressionTree, field expressionTree) types.Type {
+	name := structure.GetValue().Value[0]
+	resolveReference(structure, intermediate.REFERENCE_STRUCT, name)
+	structType := types.Named(name)
+	return types.Function([]types.Type{structType}, state.field(structType, field))
+}
+
+func (state *inference) bindStructPattern(param pattern.Pattern, expected types.Type) bool {
+	goal, structure, isStruct := state.program.structOf(param.Name)
+	if !isStruct {
+		return false
+	}
+
+	state.unify(param.Position, expected, types.Named(structure.Name), "pattern "+param.Name)
+	if len(param.Args) != len(structure.Fields) {
+		state.diagnostics.Errorf(param.Position, "%v has %d fields but the pattern gives %d", structure.Name, len(structure.Fields), len(param.Args)).
+			WithRelated(structure.Position, "%v is defined here", structure.Name)
+		return true
+	}
+
+	for index, arg := range param.Args {
+		state.bindPattern(arg, state.fieldTypeOf(goal, structure, index))
+	}
+
+	return true
+}
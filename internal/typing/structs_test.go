@@ -0,0 +1,78 @@
+package typing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/container"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func withStudent(goal intermediate.Goal) intermediate.Goal {
+	goal.Structs["Student"] = intermediate.Struct{
+		Name: "Student",
+		Fields: []intermediate.StructField{
+			{Name: "Name", TypeId: intermediate.TYPEID_TEXT},
+			{Name: "Gpa", TypeId: intermediate.TYPEID_DECIMAL},
+		},
+	}
+
+	return goal
+}
+
+func field(value node, name string) node {
+	return parent(intermediate.OPCODE_CHAIN, value, leaf(intermediate.OPCODE_OPERATOR, intermediate.TYPEID_NO_TYPE, "."), ref(name))
+}
+
+func labelled(name string, value node) node {
+	tree := leaf(intermediate.OPCODE_LABEL, intermediate.TYPEID_NO_TYPE, name)
+	container.AddChildren(tree, value)
+	return tree
+}
+
+func TestInferStructs(t *testing.T) {
+	goal := withStudent(goalWithMain(
+		labelled("student", parent(intermediate.OPCODE_CALL, ref("Student"),
+			parent(intermediate.OPCODE_CALL, ref("identity"), leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_TEXT, `"Abby"`)),
+			leaf(intermediate.OPCODE_CONST, intermediate.TYPEID_DECIMAL, "3.5"),
+		)),
+		parent(intermediate.OPCODE_CALL, ref("sysout"), field(ref("Student"), "Gpa")),
+	))
+	goal.Imports = append(goal.Imports, intermediate.Import{Alias: "identity", Path: []string{"dfl", "identity"}})
+
+	if diagnostics := Infer([]intermediate.Goal{goal}); len(diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics:\n%v", diagnostics)
+	}
+
+	projection := goal.Sentments["main"].Definition.GetChild(1).GetChild(1)
+	if head := projection.GetChild(0).GetValue(); head.Value[1] != intermediate.REFERENCE_STRUCT {
+		t.Errorf("Student resolved to %v", head.Value)
+	}
+
+	if gpa := projection.GetChild(2).GetValue(); gpa.Value[1] != intermediate.REFERENCE_FIELD || gpa.Value[2] != "1" {
+		t.Errorf("Gpa resolved to %v", gpa.Value)
+	}
+}
+
+func TestInferStructErrors(t *testing.T) {
+	goal := withStudent(goalWithMain(
+		parent(intermediate.OPCODE_CALL, ref("sysout"), field(ref("Student"), "Age")),
+		parent(intermediate.OPCODE_CALL, ref("sysout"), field(ref("args"), "Name")),
+		parent(intermediate.OPCODE_CALL, ref("Student"), ref("args")),
+	))
+
+	diagnostics := Infer([]intermediate.Goal{goal})
+	if len(diagnostics) != 3 {
+		t.Fatalf("expected 3 diagnostics got:\n%v", diagnostics)
+	}
+
+	for index, fragment := range []string{
+		"Student has no field Age",
+		"field Name cannot be read from List[text], which is not a struct",
+		"no definition of Student accepts (List[text])",
+	} {
+		if !strings.Contains(diagnostics[index].Message, fragment) {
+			t.Errorf("expected %q in %q", fragment, diagnostics[index].Message)
+		}
+	}
+}
@@ -1,11 +1,44 @@
 package typing
 
 import (
-	"github.com/tflexsoom/duffle/internal/parsing/dflgrammar"
+	"github.com/tflexsoom/duffle/internal/annotation"
+	"github.com/tflexsoom/duffle/internal/constexpr"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/fixity"
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/resolve"
 )
 
 const PASS_STRING = "PASS"
 
-func TypeCheck(fileName string, ast dflgrammar.Module) (string, error) {
-	return PASS_STRING, nil
+// CheckProgram checks the goals of a program together, folding each goal's
+// constants with the data configs given for it. Operator chains are grouped
+// first, by the fixities every goal declares. The entry names the @exec
+// function the program runs from, and may be left empty when it has one.
+func CheckProgram(goals []intermediate.Goal, configs [][]intermediate.DataConfig, entry string) diagnostic.Diagnostics {
+	diagnostics := fixity.Reassociate(goals...)
+	constants := constantsOf(goals...)
+	for index, goal := range goals {
+		var goalConfigs []intermediate.DataConfig
+		if index < len(configs) {
+			goalConfigs = configs[index]
+		}
+
+		sentiments := annotation.SentimentsOf(goal)
+		diagnostics.Extend(annotation.Validate(sentiments))
+		diagnostics.Extend(resolve.CheckBindings(goal.Imports))
+		diagnostics.Extend(constexpr.Fold(goal, goalConfigs))
+		for _, sentiment := range sentiments {
+			diagnostics.Extend(checkPatterns(sentiment))
+			diagnostics.Extend(checkImmutability(sentiment, constants))
+		}
+	}
+
+	diagnostics.Extend(annotation.ValidateProgram(annotation.SentimentsOf(goals...), entry))
+	if diagnostics.HasErrors() {
+		return diagnostics
+	}
+
+	diagnostics.Extend(Infer(goals))
+	return diagnostics
 }
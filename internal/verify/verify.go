@@ -0,0 +1,288 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+	"github.com/tflexsoom/duffle/internal/runtime"
+	"github.com/tflexsoom/duffle/internal/types"
+)
+
+// Error locates an instruction breaking an invariant of a module.
+// Instruction is -1 for problems of a function as a whole.
+type Error struct {
+	Function    string
+	Instruction int
+	Message     string
+}
+
+func (err Error) Error() string {
+	if err.Instruction < 0 {
+		return fmt.Sprintf("%v: %v", err.Function, err.Message)
+	}
+
+	return fmt.Sprintf("%v at %d: %v", err.Function, err.Instruction, err.Message)
+}
+
+type Errors []Error
+
+func (errs Errors) Error() string {
+	lines := make([]string, 0, len(errs))
+	for _, err := range errs {
+		lines = append(lines, err.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Module checks the invariants the interpreter and the backends rely on:
+// every id names an entry of its table, every register is inside its
+// frame, every instruction has the arguments its code needs and jumps only
+// go forward. Registers holding literals or typed inputs must agree with
+// the types of the functions they are passed to and returned from.
+func Module(module intermediate.Module) error {
+	verifier := verifier{module: module}
+	if len(module.Functions) > 0 && int(module.Entry) >= len(module.Functions) {
+		verifier.errs = append(verifier.errs, Error{
+			Function:    "module",
+			Instruction: -1,
+			Message:     fmt.Sprintf("entry function #%d does not exist", module.Entry),
+		})
+	}
+
+	for _, function := range module.Functions {
+		verifier.function(function)
+	}
+
+	if len(verifier.errs) > 0 {
+		return verifier.errs
+	}
+
+	return nil
+}
+
+type verifier struct {
+	module intermediate.Module
+	errs   Errors
+}
+
+// arities gives the number of arguments of the codes that take a fixed
+// number of them
+var arities = map[intermediate.InstructionCode]int{
+	intermediate.NOOP:   0,
+	intermediate.VALUE:  0,
+	intermediate.MOVE:   1,
+	intermediate.JUMP:   0,
+	intermediate.BRANCH: 1,
+	intermediate.RETURN: 1,
+	intermediate.FIELD:  1,
+}
+
+type checker struct {
+	*verifier
+	function intermediate.Function
+	// known holds the types of registers that are sure to hold a scalar.
+	// Only registers written once, or inputs never written, are known.
+	known  map[intermediate.Register]types.Type
+	writes map[intermediate.Register]int
+}
+
+func (verifier *verifier) function(function intermediate.Function) {
+	current := checker{
+		verifier: verifier,
+		function: function,
+		known:    make(map[intermediate.Register]types.Type),
+		writes:   make(map[intermediate.Register]int),
+	}
+
+	for _, instruction := range function.Definition {
+		if intermediate.HasDest(instruction.Instruction) {
+			current.writes[instruction.Dest]++
+		}
+	}
+
+	if function.Captures+function.Params > function.Registers {
+		current.errorf(-1, "has %d captures and %d params in %d registers", function.Captures, function.Params, function.Registers)
+	}
+
+	result := types.Type{}
+	if function.Type != "" {
+		signature, err := types.Parse(function.Type)
+		if err != nil || !signature.IsFunction() {
+			current.errorf(-1, "has type %v, which is not a function type", function.Type)
+		} else if len(signature.Params()) != int(function.Params) {
+			current.errorf(-1, "has type %v but takes %d params", function.Type, function.Params)
+		} else {
+			for index, param := range signature.Params() {
+				if register := intermediate.Register(function.Captures + uint32(index)); current.writes[register] == 0 && isScalar(param) {
+					current.known[register] = param
+				}
+			}
+
+			result = signature.Result()
+		}
+	}
+
+	for index, instruction := range function.Definition {
+		current.instruction(index, instruction, result)
+	}
+
+	for _, stackMap := range function.StackMaps {
+		if int(stackMap.Instruction) >= len(function.Definition) {
+			current.errorf(-1, "has a stack map for missing instruction %d", stackMap.Instruction)
+		}
+
+		current.registers(int(stackMap.Instruction), stackMap.Live)
+	}
+}
+
+func (current *checker) errorf(index int, format string, args ...any) {
+	current.errs = append(current.errs, Error{
+		Function:    current.function.Name,
+		Instruction: index,
+		Message:     fmt.Sprintf(format, args...),
+	})
+}
+
+func (current *checker) registers(index int, registers []intermediate.Register) bool {
+	for _, register := range registers {
+		if uint32(register) >= current.function.Registers {
+			current.errorf(index, "uses r%d of %d registers", register, current.function.Registers)
+			return false
+		}
+	}
+
+	return true
+}
+
+func (current *checker) instruction(index int, instruction intermediate.Instruction, result types.Type) {
+	code := instruction.Instruction
+	if _, isOk := intermediate.InstructionNames[code]; !isOk {
+		current.errorf(index, "has unknown instruction code %d", code)
+		return
+	}
+
+	if arity, isFixed := arities[code]; isFixed && len(instruction.Args) != arity {
+		current.errorf(index, "%v takes %d args, not %d", intermediate.InstructionNames[code], arity, len(instruction.Args))
+		return
+	}
+
+	isValid := current.registers(index, instruction.Args)
+	if intermediate.HasDest(code) {
+		isValid = current.registers(index, []intermediate.Register{instruction.Dest}) && isValid
+	}
+
+	if !isValid {
+		return
+	}
+
+	module := current.module
+	switch code {
+	case intermediate.VALUE:
+		if int(instruction.Operand) >= len(module.Values) {
+			current.errorf(index, "reads missing value #%d", instruction.Operand)
+			return
+		}
+
+		current.learn(instruction.Dest, types.Of(module.Values[instruction.Operand]))
+	case intermediate.CALL, intermediate.TAILCALL, intermediate.CLOSURE:
+		if int(instruction.Operand) >= len(module.Functions) {
+			current.errorf(index, "references missing function #%d", instruction.Operand)
+			return
+		}
+
+		callee := module.Functions[instruction.Operand]
+		expected := int(callee.Captures)
+		if code != intermediate.CLOSURE {
+			expected += int(callee.Params)
+		}
+
+		if len(instruction.Args) != expected {
+			current.errorf(index, "passes %d args to %v, which takes %d", len(instruction.Args), callee.Name, expected)
+			return
+		}
+
+		current.call(index, instruction, callee)
+	case intermediate.INTRINSIC:
+		if int(instruction.Operand) >= len(module.Intrinsics) {
+			current.errorf(index, "calls missing intrinsic #%d", instruction.Operand)
+			return
+		}
+
+		name := module.Intrinsics[instruction.Operand]
+		if runtime.IsOperator(name) && len(instruction.Args) != 2 {
+			current.errorf(index, "applies operator %v to %d args", name, len(instruction.Args))
+		}
+	case intermediate.APPLY, intermediate.TAILAPPLY:
+		if len(instruction.Args) == 0 {
+			current.errorf(index, "applies no function value")
+		}
+	case intermediate.JUMP, intermediate.BRANCH:
+		if target := int(instruction.Operand); target <= index || target > len(current.function.Definition) {
+			current.errorf(index, "jumps to %d, which is not after it in a function of %d instructions", target, len(current.function.Definition))
+		}
+
+		if code == intermediate.JUMP {
+			return
+		} else if known, isOk := current.known[instruction.Args[0]]; isOk && !known.Equal(types.Boolean) {
+			current.errorf(index, "branches on r%d holding %v", instruction.Args[0], known)
+		}
+	case intermediate.RETURN:
+		if known, isOk := current.known[instruction.Args[0]]; isOk && !compatible(result, known) {
+			current.errorf(index, "returns r%d holding %v from a function giving %v", instruction.Args[0], known, result)
+		}
+	}
+}
+
+// call checks the arguments of a call that are known to hold a scalar
+// against the inputs of the callee
+func (current *checker) call(index int, instruction intermediate.Instruction, callee intermediate.Function) {
+	if callee.Type == "" || instruction.Instruction == intermediate.CLOSURE {
+		return
+	}
+
+	signature, err := types.Parse(callee.Type)
+	if err != nil || !signature.IsFunction() || len(signature.Params()) != int(callee.Params) {
+		return
+	}
+
+	for position, param := range signature.Params() {
+		arg := instruction.Args[int(callee.Captures)+position]
+		if known, isOk := current.known[arg]; isOk && !compatible(param, known) {
+			current.errorf(index, "passes r%d holding %v to %v as %v", arg, known, callee.Name, param)
+		}
+	}
+
+	if instruction.Instruction == intermediate.CALL {
+		current.learn(instruction.Dest, signature.Result())
+	}
+}
+
+var scalars = []types.Type{types.Boolean, types.Byte, types.Char, types.Integer, types.Decimal, types.Text}
+
+func isScalar(t types.Type) bool {
+	for _, scalar := range scalars {
+		if scalar.Equal(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isNumber(t types.Type) bool {
+	return t.Equal(types.Byte) || t.Equal(types.Integer) || t.Equal(types.Decimal)
+}
+
+func (current *checker) learn(register intermediate.Register, t types.Type) {
+	if isScalar(t) && current.writes[register] == 1 {
+		current.known[register] = t
+	}
+}
+
+// compatible allows a known scalar where a type is expected. Types that are
+// not scalars are not checked, and numbers convert into each other.
+func compatible(expected types.Type, actual types.Type) bool {
+	return !isScalar(expected) || expected.Equal(actual) || (isNumber(expected) && isNumber(actual))
+}
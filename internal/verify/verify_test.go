@@ -0,0 +1,98 @@
+package verify
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tflexsoom/duffle/internal/intermediate"
+)
+
+func instruction(code intermediate.InstructionCode, dest intermediate.Register, operand uint64, args ...intermediate.Register) intermediate.Instruction {
+	return intermediate.Instruction{Instruction: code, Dest: dest, Operand: operand, Args: args}
+}
+
+func validModule() intermediate.Module {
+	module := intermediate.NewModule()
+	one := uint64(module.ValueOf(intermediate.IntegerLiteral(1)))
+	plus := module.IntrinsicOf("+")
+	module.Functions = append(module.Functions,
+		intermediate.Function{
+			Name: "increment", Type: "Function[integer, integer]", Params: 1, Registers: 3,
+			Definition: []intermediate.Instruction{
+				instruction(intermediate.VALUE, 1, one),
+				instruction(intermediate.INTRINSIC, 2, plus, 0, 1),
+				instruction(intermediate.RETURN, 0, 0, 2),
+			},
+		},
+		intermediate.Function{
+			Name: "main", Type: "Function[integer]", Registers: 2,
+			Definition: []intermediate.Instruction{
+				instruction(intermediate.VALUE, 0, one),
+				instruction(intermediate.TAILCALL, 0, 0, 0),
+			},
+		},
+	)
+	module.Entry = 1
+	return module
+}
+
+func TestValidModule(t *testing.T) {
+	if err := Module(validModule()); err != nil {
+		t.Errorf("unexpected errors:\n%v", err)
+	}
+}
+
+func TestInvalidModules(t *testing.T) {
+	for _, test := range []struct {
+		expected string
+		breaks   func(module *intermediate.Module)
+	}{
+		{"module: entry function #7 does not exist", func(module *intermediate.Module) {
+			module.Entry = 7
+		}},
+		{"increment at 0: reads missing value #9", func(module *intermediate.Module) {
+			module.Functions[0].Definition[0].Operand = 9
+		}},
+		{"increment at 1: uses r5 of 3 registers", func(module *intermediate.Module) {
+			module.Functions[0].Definition[1].Args[1] = 5
+		}},
+		{"increment at 2: return takes 1 args, not 0", func(module *intermediate.Module) {
+			module.Functions[0].Definition[2].Args = nil
+		}},
+		{"main at 1: passes 2 args to increment, which takes 1", func(module *intermediate.Module) {
+			module.Functions[1].Definition[1].Args = []intermediate.Register{0, 0}
+		}},
+		{"main at 1: references missing function #4", func(module *intermediate.Module) {
+			module.Functions[1].Definition[1].Operand = 4
+		}},
+		{"increment: has type Function[integer, integer, integer] but takes 1 params", func(module *intermediate.Module) {
+			module.Functions[0].Type = "Function[integer, integer, integer]"
+		}},
+		{"main at 1: passes r0 holding text to increment as integer", func(module *intermediate.Module) {
+			module.Functions[1].Definition[0].Operand = uint64(module.ValueOf(intermediate.TextLiteral("one")))
+		}},
+		{"main at 0: jumps to 0, which is not after it", func(module *intermediate.Module) {
+			module.Functions[1].Definition[0] = instruction(intermediate.JUMP, 0, 0)
+		}},
+		{"main at 1: branches on r0 holding integer", func(module *intermediate.Module) {
+			module.Functions[1].Definition = []intermediate.Instruction{
+				instruction(intermediate.VALUE, 0, 0),
+				instruction(intermediate.BRANCH, 0, 2, 0),
+				instruction(intermediate.RETURN, 0, 0, 0),
+			}
+		}},
+	} {
+		module := validModule()
+		test.breaks(&module)
+		err := Module(module)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected %q, got %v", test.expected, err)
+		}
+
+		var errs Errors
+		if err != nil && (!errors.As(err, &errs) || len(errs) != 1) {
+			t.Errorf("expected exactly one error, got %v", err)
+		}
+	}
+}
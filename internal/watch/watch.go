@@ -0,0 +1,193 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+	"github.com/tflexsoom/duffle/internal/discovery"
+)
+
+const (
+	DEFAULT_INTERVAL = 500 * time.Millisecond
+	DEFAULT_DEBOUNCE = 200 * time.Millisecond
+)
+
+type Stamp struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Snapshot holds the stamps of every source file of some projects at one
+// moment. It is taken by polling, so it works the same on every platform.
+type Snapshot map[string]Stamp
+
+// Take discovers the source files of the projects again and stamps them.
+// Files removed while being stamped are left out, as they would be on the
+// next poll.
+func Take(locations []string) (Snapshot, error) {
+	snapshot := Snapshot{}
+	for _, location := range locations {
+		fileMap, err := discovery.DiscoverFiles(location, false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fileList := range fileMap {
+			for _, file := range fileList {
+				info, err := os.Stat(file)
+				if os.IsNotExist(err) {
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+
+				snapshot[file] = Stamp{ModTime: info.ModTime(), Size: info.Size()}
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// Changed gives the files added, removed or modified since another
+// snapshot, in order
+func (snapshot Snapshot) Changed(since Snapshot) []string {
+	changed := []string{}
+	for file, stamp := range snapshot {
+		if previous, isOk := since[file]; !isOk || previous != stamp {
+			changed = append(changed, file)
+		}
+	}
+
+	for file := range since {
+		if _, isOk := snapshot[file]; !isOk {
+			changed = append(changed, file)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+type Watcher struct {
+	Locations []string
+	// Interval is how often the files are polled
+	Interval time.Duration
+	// Debounce is how long the files must stay the same before a build, so
+	// an editor saving several files starts a single one
+	Debounce time.Duration
+}
+
+// Watch builds once, then again after every settled change, until the
+// context is done. Build is given the files changed since the last build.
+func (watcher Watcher) Watch(ctx context.Context, build func(changed []string)) error {
+	interval, debounce := watcher.Interval, watcher.Debounce
+	if interval <= 0 {
+		interval = DEFAULT_INTERVAL
+	}
+
+	if debounce <= 0 {
+		debounce = DEFAULT_DEBOUNCE
+	}
+
+	built, err := Take(watcher.Locations)
+	if err != nil {
+		return err
+	}
+
+	build(nil)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	latest := built
+	var settled time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			current, err := Take(watcher.Locations)
+			if err != nil {
+				return err
+			}
+
+			if len(current.Changed(latest)) > 0 {
+				latest = current
+				settled = now.Add(debounce)
+			}
+
+			changed := latest.Changed(built)
+			if len(changed) == 0 || now.Before(settled) {
+				continue
+			}
+
+			built = latest
+			build(changed)
+		}
+	}
+}
+
+// Report remembers the diagnostics of the last build, so a rebuild shows
+// only what it changed
+type Report struct {
+	previous map[string]bool
+}
+
+// Update records the result of a build, giving the messages it newly
+// reports in order and the ones it no longer reports sorted
+func (report *Report) Update(err error) (added []string, resolved []string) {
+	current := make(map[string]bool)
+	for _, message := range MessagesOf(err) {
+		if current[message] {
+			continue
+		}
+
+		current[message] = true
+		if !report.previous[message] {
+			added = append(added, message)
+		}
+	}
+
+	for message := range report.previous {
+		if !current[message] {
+			resolved = append(resolved, message)
+		}
+	}
+
+	sort.Strings(resolved)
+	report.previous = current
+	return added, resolved
+}
+
+// MessagesOf splits the error of a build into its diagnostics and the
+// errors joined into it
+func MessagesOf(err error) []string {
+	if err == nil {
+		return nil
+	}
+
+	if joined, isOk := err.(interface{ Unwrap() []error }); isOk {
+		messages := []string{}
+		for _, inner := range joined.Unwrap() {
+			messages = append(messages, MessagesOf(inner)...)
+		}
+
+		return messages
+	}
+
+	var diagnostics diagnostic.Diagnostics
+	if errors.As(err, &diagnostics) {
+		messages := make([]string, 0, len(diagnostics))
+		for _, diagnostic := range diagnostics {
+			messages = append(messages, diagnostic.String())
+		}
+
+		return messages
+	}
+
+	return []string{err.Error()}
+}
@@ -0,0 +1,100 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/tflexsoom/duffle/internal/diagnostic"
+)
+
+func TestSnapshotChanges(t *testing.T) {
+	project := t.TempDir()
+	main := filepath.Join(project, "main.dfl")
+	settings := filepath.Join(project, "settings.ddat")
+	os.WriteFile(main, []byte("a"), 0644)
+	os.WriteFile(settings, []byte("a"), 0644)
+	os.WriteFile(filepath.Join(project, "notes.txt"), []byte("a"), 0644)
+
+	before, err := Take([]string{project})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(before) != 2 {
+		t.Fatalf("expected only source files, got %v", before)
+	}
+
+	added := filepath.Join(project, "lib", "math.dfl")
+	os.MkdirAll(filepath.Dir(added), 0755)
+	os.WriteFile(added, []byte("a"), 0644)
+	os.WriteFile(main, []byte("ab"), 0644)
+	os.Remove(settings)
+
+	after, err := Take([]string{project})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{added, main, settings}
+	if changed := after.Changed(before); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected %v, got %v", expected, changed)
+	}
+}
+
+func TestWatchDebounces(t *testing.T) {
+	project := t.TempDir()
+	main := filepath.Join(project, "main.dfl")
+	os.WriteFile(main, []byte("a"), 0644)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	builds := [][]string{}
+	watcher := Watcher{Locations: []string{project}, Interval: 10 * time.Millisecond, Debounce: 100 * time.Millisecond}
+	err := watcher.Watch(ctx, func(changed []string) {
+		builds = append(builds, changed)
+		if len(builds) == 1 {
+			go func() {
+				for size := 2; size < 6; size++ {
+					os.WriteFile(main, make([]byte, size), 0644)
+					time.Sleep(20 * time.Millisecond)
+				}
+			}()
+		} else {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(builds) != 2 || len(builds[0]) != 0 || !reflect.DeepEqual(builds[1], []string{main}) {
+		t.Errorf("expected an initial build and one rebuild, got %v", builds)
+	}
+}
+
+func TestReportChanges(t *testing.T) {
+	diagnostics := diagnostic.Diagnostics{}
+	diagnostics.Errorf(lexer.Position{Filename: "main.dfl", Line: 1, Column: 1}, "undefined x")
+	diagnostics.Errorf(lexer.Position{Filename: "main.dfl", Line: 2, Column: 1}, "undefined y")
+
+	report := Report{}
+	added, resolved := report.Update(errors.Join(errors.New("lib.dfl: unexpected token"), diagnostics))
+	if len(added) != 3 || len(resolved) != 0 {
+		t.Errorf("expected every message to be new, got %v and %v", added, resolved)
+	}
+
+	added, resolved = report.Update(diagnostics[1:])
+	if len(added) != 0 || len(resolved) != 2 || resolved[0] != "lib.dfl: unexpected token" {
+		t.Errorf("expected two resolved messages, got %v and %v", added, resolved)
+	}
+
+	added, resolved = report.Update(nil)
+	if len(added) != 0 || !reflect.DeepEqual(resolved, []string{diagnostics[1].String()}) {
+		t.Errorf("expected the last message to be resolved, got %v and %v", added, resolved)
+	}
+}